@@ -0,0 +1,50 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/migrations"
+)
+
+func main() {
+	dbDNS := flag.String("MYSQL_DNS", os.Getenv("MYSQL_DNS"), "dns string for workshop db")
+	steps := flag.Int("STEPS", 1, "number of migrations to revert with down")
+	flag.Parse()
+	if *dbDNS == "" {
+		log.Fatal("dns string not found")
+	}
+	if flag.NArg() != 1 {
+		log.Fatal("usage: migrate [-STEPS n] up|down|status|verify")
+	}
+
+	db, err := sql.Open("mysql", *dbDNS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	switch flag.Arg(0) {
+	case "up":
+		err = migrations.Up(db)
+	case "down":
+		err = migrations.Down(db, *steps)
+	case "verify":
+		err = migrations.Verify(db)
+	case "status":
+		var applied []migrations.Applied
+		applied, err = migrations.Status(db)
+		for _, a := range applied {
+			fmt.Printf("%4d  %-40s  %s  %s\n", a.Version, a.Name, a.AppliedAt.Format("2006-01-02 15:04:05"), a.Checksum[:12])
+		}
+	default:
+		log.Fatalf("unknown command %q", flag.Arg(0))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
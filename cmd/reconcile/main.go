@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/workshop/lib/repository"
+)
+
+// reconcile recomputes the signup counters stored on workshops from the
+// signups table and reports any drift. Run it with -FIX to correct them.
+func main() {
+	dbDNS := flag.String("MYSQL_DNS", os.Getenv("MYSQL_DNS"), "dns string for workshop db")
+	fix := flag.Bool("FIX", false, "write the recomputed counters back")
+	flag.Parse()
+	if *dbDNS == "" {
+		log.Fatal("dns string not found")
+	}
+
+	workshopDB, err := repository.NewWorkshopDB(*dbDNS, false)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	drifts, err := workshopDB.ReconcileSignUpCounts(*fix)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	for _, d := range drifts {
+		fmt.Printf("%s: seats %d stored, %d actual; waitlist %d stored, %d actual\n", d.WorkshopID, d.StoredSeats, d.Seats, d.StoredWaitlist, d.Waitlist)
+	}
+	switch {
+	case len(drifts) == 0:
+		fmt.Println("no drift")
+	case *fix:
+		fmt.Printf("fixed %d workshops\n", len(drifts))
+	default:
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// AttendanceHandler records who came to each session of a workshop.
+type AttendanceHandler struct {
+	workshopRepo repository.WorkshopDB
+}
+
+type AttendanceRequest struct {
+	SignupID int  `json:"SignupID"`
+	Attended bool `json:"Attended"`
+}
+
+type Attendance struct {
+	SignupID   int       `json:"SignupID"`
+	Attended   bool      `json:"Attended"`
+	RecordedAt time.Time `json:"RecordedAt"`
+}
+
+type AttendanceListResponse struct {
+	SessionID  int          `json:"session_id"`
+	Attendance []Attendance `json:"attendance"`
+}
+
+func sessionID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["session_id"])
+	if err != nil {
+		return 0, errBadRequest
+	}
+	return id, nil
+}
+
+func (h AttendanceHandler) GetAttendance(w http.ResponseWriter, r *http.Request) error {
+	id, err := sessionID(r)
+	if err != nil {
+		return err
+	}
+	attendance, err := h.workshopRepo.GetAttendanceBySessionID(id)
+	if err != nil {
+		return err
+	}
+	resp := AttendanceListResponse{SessionID: id}
+	for _, a := range attendance {
+		resp.Attendance = append(resp.Attendance, Attendance{SignupID: a.SignUpID, Attended: a.Attended, RecordedAt: a.RecordedAt})
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func (h AttendanceHandler) RecordAttendance(w http.ResponseWriter, r *http.Request) error {
+	id, err := sessionID(r)
+	if err != nil {
+		return err
+	}
+	var req AttendanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err := h.workshopRepo.RecordAttendance(workshop.Attendance{SessionID: id, SignUpID: req.SignupID, Attended: req.Attended}); err != nil {
+		return err
+	}
+	return h.GetAttendance(w, r)
+}
+
+func (h AttendanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "GET":
+		err = h.GetAttendance(w, r)
+	case "PUT":
+		err = h.RecordAttendance(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == errBadRequest:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "session not found", http.StatusNotFound)
+	case err == repository.ErrNotEnrolled:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
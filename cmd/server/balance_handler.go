@@ -0,0 +1,222 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/workshop/lib/payment"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// manualProvider is the provider payments recorded by hand are stored
+// under.
+const manualProvider = "manual"
+
+// BalanceHandler tracks what signups have paid against what they owe, on
+// workshops that take a deposit at booking in particular.
+type BalanceHandler struct {
+	workshopRepo repository.WorkshopDB
+	payments     payment.Provider
+	// checkoutReturnURL is where participants land after the checkout.
+	checkoutReturnURL string
+	// invoicer is nil when invoicing is not set up.
+	invoicer *Invoicer
+}
+
+// Balance is what a signup owes after its voucher, what it has paid and
+// what is left, due by DueAt on workshops taking deposits.
+type Balance struct {
+	SignupID    int        `json:"signupId"`
+	Owed        Price      `json:"owed"`
+	Paid        Price      `json:"paid"`
+	Balance     Price      `json:"balance"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	Overdue     bool       `json:"overdue"`
+	CheckoutURL string     `json:"checkoutUrl,omitempty"`
+}
+
+// Payment is a checkout at the payment provider or money recorded by hand.
+type Payment struct {
+	ID        int                    `json:"id"`
+	SignupID  int                    `json:"signupId"`
+	Provider  string                 `json:"provider"`
+	SessionID string                 `json:"sessionId"`
+	Amount    Price                  `json:"amount"`
+	Status    workshop.PaymentStatus `json:"status"`
+	CreatedAt time.Time              `json:"createdAt"`
+	UpdatedAt time.Time              `json:"updatedAt"`
+}
+
+// ManualPaymentRequest records money received outside the payment provider,
+// e.g. a bank transfer.
+type ManualPaymentRequest struct {
+	Amount Price `json:"amount"`
+}
+
+var (
+	errNothingDue        = errors.New("the signup has nothing left to pay")
+	errNoPaymentProvider = errors.New("payments are taken offline, there is no checkout")
+	errInvalidPayment    = errors.New("payments need a positive amount in the currency of the price")
+)
+
+func balanceResponse(su workshop.SignUp, ws workshop.Workshop) Balance {
+	return Balance{
+		SignupID: su.ID,
+		Owed:     priceResponse(su.Due()),
+		Paid:     priceResponse(su.AmountPaid),
+		Balance:  priceResponse(su.Balance()),
+		DueAt:    timeOrNil(ws.BalanceDueAt),
+		Overdue:  !su.BalanceOverdueAt.IsZero(),
+	}
+}
+
+// load returns the signup the request is for and its workshop.
+func (h BalanceHandler) load(r *http.Request) (workshop.SignUp, workshop.Workshop, error) {
+	id, err := signupID(r)
+	if err != nil {
+		return workshop.SignUp{}, workshop.Workshop{}, err
+	}
+	su, err := h.workshopRepo.SignUpByID(id)
+	if err != nil {
+		return su, workshop.Workshop{}, err
+	}
+	ws, err := h.workshopRepo.WorkshopByID(su.WorkshopID)
+	return su, ws, err
+}
+
+func (h BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) error {
+	su, ws, err := h.load(r)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(balanceResponse(su, ws))
+}
+
+// PayBalance opens a checkout for what a confirmed signup has left to pay.
+func (h BalanceHandler) PayBalance(w http.ResponseWriter, r *http.Request) error {
+	if h.payments == nil {
+		return errNoPaymentProvider
+	}
+	su, ws, err := h.load(r)
+	if err != nil {
+		return err
+	}
+	if su.Status != workshop.StatusConfirmed || su.Balance().IsZero() {
+		return errNothingDue
+	}
+	session, err := createCheckout(h.workshopRepo, h.payments, h.checkoutReturnURL, ws, su, su.Balance())
+	if err != nil {
+		log.Printf("could not open balance checkout for signup %d: %v", su.ID, err)
+		return errCheckoutFailed
+	}
+	resp := balanceResponse(su, ws)
+	resp.CheckoutURL = session.URL
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// RecordPayment records money a signup paid outside the payment provider.
+// It settles like a provider payment: a pending signup is confirmed, and a
+// signup that has paid in full is invoiced.
+func (h BalanceHandler) RecordPayment(w http.ResponseWriter, r *http.Request) {
+	err := h.recordPayment(w, r)
+	switch {
+	case err == errBadRequest, err == errInvalidPayment:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "signup not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h BalanceHandler) recordPayment(w http.ResponseWriter, r *http.Request) error {
+	var req ManualPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return errBadRequest
+	}
+	defer r.Body.Close()
+	su, ws, err := h.load(r)
+	if err != nil {
+		return err
+	}
+	amount := req.Amount.money()
+	if amount.Amount <= 0 || amount.Currency != su.Price.Currency {
+		return errInvalidPayment
+	}
+	sessionID := fmt.Sprintf("manual-%d-%d", su.ID, time.Now().UnixNano())
+	if _, err := h.workshopRepo.InsertPayment(workshop.Payment{SignUpID: su.ID, Provider: manualProvider, SessionID: sessionID, Amount: amount}); err != nil {
+		return err
+	}
+	if _, _, err := h.workshopRepo.SettlePayment(manualProvider, sessionID, workshop.PaymentPaid); err != nil {
+		return err
+	}
+	if su, err = h.workshopRepo.SignUpByID(su.ID); err != nil {
+		return err
+	}
+	if h.invoicer != nil && su.Status == workshop.StatusConfirmed && su.Balance().IsZero() {
+		h.invoicer.invoicePaid(su)
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(balanceResponse(su, ws))
+}
+
+// GetPayments lists the payments in the status asked for, e.g.
+// ?status=mismatched for those paid with another amount than was due, which
+// an admin resolves by recording the payment or cancelling the signup.
+func (h BalanceHandler) GetPayments(w http.ResponseWriter, r *http.Request) {
+	status := workshop.PaymentStatus(r.URL.Query().Get("status"))
+	if status != workshop.PaymentOpen && !status.Settled() {
+		http.Error(w, "status must be open, paid, failed, expired or mismatched", http.StatusBadRequest)
+		return
+	}
+	payments, err := h.workshopRepo.GetPaymentsByStatus(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := []Payment{}
+	for _, p := range payments {
+		resp = append(resp, Payment{
+			ID:        p.ID,
+			SignupID:  p.SignUpID,
+			Provider:  p.Provider,
+			SessionID: p.SessionID,
+			Amount:    priceResponse(p.Amount),
+			Status:    p.Status,
+			CreatedAt: p.CreatedAt,
+			UpdatedAt: p.UpdatedAt,
+		})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h BalanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "GET":
+		err = h.GetBalance(w, r)
+	case "POST":
+		err = h.PayBalance(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == errBadRequest:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "signup not found", http.StatusNotFound)
+	case err == errNothingDue, err == errNoPaymentProvider:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err == errCheckoutFailed:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// CalendarHandler manages the weekly opening hours of the studio and the
+// days it is closed. Workshops and events are only scheduled while it is
+// open.
+type CalendarHandler struct {
+	workshopRepo repository.WorkshopDB
+}
+
+// OpeningHours is a span of a weekday the studio is open, e.g.
+// {"weekday": "monday", "opens": "09:00", "closes": "21:00"}. A closes of
+// "24:00" is open until midnight.
+type OpeningHours struct {
+	Weekday string `json:"weekday"`
+	Opens   string `json:"opens"`
+	Closes  string `json:"closes"`
+}
+
+type OpeningHoursResponse struct {
+	Hours []OpeningHours `json:"hours"`
+}
+
+// Closure is a run of days the studio is closed; From and Until are
+// inclusive dates such as "2024-12-23".
+type Closure struct {
+	ID        int        `json:"id"`
+	From      string     `json:"from"`
+	Until     string     `json:"until"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+type ClosureListResponse struct {
+	Closures []Closure `json:"closures"`
+}
+
+// clock writes minutes after midnight as "15:04".
+func clock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// parseClock reads a "15:04" time of day, allowing "24:00", as minutes
+// after midnight.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || len(s) != 5 || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, workshop.ErrInvalidOpeningHours
+	}
+	return h*60 + m, nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s, d.String()) {
+			return d, nil
+		}
+	}
+	return 0, workshop.ErrInvalidOpeningHours
+}
+
+func closureResponse(c workshop.Closure) Closure {
+	return Closure{
+		ID:        c.ID,
+		From:      c.From.Format(workshop.DateLayout),
+		Until:     c.Until.Format(workshop.DateLayout),
+		Reason:    c.Reason,
+		CreatedAt: timeOrNil(c.CreatedAt),
+	}
+}
+
+func (h CalendarHandler) GetOpeningHours(w http.ResponseWriter, r *http.Request) {
+	cal, err := h.workshopRepo.GetCalendar()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := OpeningHoursResponse{Hours: []OpeningHours{}}
+	for _, oh := range cal.Hours {
+		resp.Hours = append(resp.Hours, OpeningHours{Weekday: strings.ToLower(oh.Weekday.String()), Opens: clock(oh.Opens), Closes: clock(oh.Closes)})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SetOpeningHours replaces the weekly opening hours. Weekdays left out are
+// closed; an empty list leaves the studio open around the clock.
+// Workshops and events already scheduled are kept.
+func (h CalendarHandler) SetOpeningHours(w http.ResponseWriter, r *http.Request) {
+	var req OpeningHoursResponse
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, errBadRequest.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	hours := []workshop.OpeningHours{}
+	for _, oh := range req.Hours {
+		day, err := parseWeekday(oh.Weekday)
+		var opens, closes int
+		if err == nil {
+			opens, err = parseClock(oh.Opens)
+		}
+		if err == nil {
+			closes, err = parseClock(oh.Closes)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hours = append(hours, workshop.OpeningHours{Weekday: day, Opens: opens, Closes: closes})
+	}
+	if err := workshop.ValidateOpeningHours(hours); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.workshopRepo.SetOpeningHours(hours); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.GetOpeningHours(w, r)
+}
+
+// GetClosures lists the closures that have not ended yet.
+func (h CalendarHandler) GetClosures(w http.ResponseWriter, r *http.Request) error {
+	closures, err := h.workshopRepo.GetClosures(time.Now())
+	if err != nil {
+		return err
+	}
+	resp := ClosureListResponse{Closures: []Closure{}}
+	for _, c := range closures {
+		resp.Closures = append(resp.Closures, closureResponse(c))
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// CreateClosure closes the studio on the days of the closure in the
+// request body. Workshops and events already scheduled then are kept.
+func (h CalendarHandler) CreateClosure(w http.ResponseWriter, r *http.Request) error {
+	var req Closure
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return errBadRequest
+	}
+	defer r.Body.Close()
+	from, err := time.Parse(workshop.DateLayout, req.From)
+	if err != nil {
+		return workshop.ErrInvalidClosure
+	}
+	until, err := time.Parse(workshop.DateLayout, req.Until)
+	if err != nil {
+		return workshop.ErrInvalidClosure
+	}
+	c := workshop.Closure{From: from, Until: until, Reason: req.Reason}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	c, err = h.workshopRepo.InsertClosure(c)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(closureResponse(c))
+}
+
+// DeleteClosure reopens the studio on the days of the closure
+// {closure_id}.
+func (h CalendarHandler) DeleteClosure(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "closure_id")
+	if err != nil {
+		return err
+	}
+	if err := h.workshopRepo.DeleteClosure(id); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h CalendarHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, one := mux.Vars(r)["closure_id"]
+	var err error
+	switch {
+	case r.Method == "GET" && !one:
+		err = h.GetClosures(w, r)
+	case r.Method == "POST" && !one:
+		err = h.CreateClosure(w, r)
+	case r.Method == "DELETE" && one:
+		err = h.DeleteClosure(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == errBadRequest, err == workshop.ErrInvalidClosure:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "closure not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
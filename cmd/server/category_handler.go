@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// CategoryHandler manages the categories workshops and events are filed
+// under.
+type CategoryHandler struct {
+	workshopRepo repository.WorkshopDB
+}
+
+// Category is a category of the tree. WorkshopCount and EventCount are the
+// upcoming workshops and events in it or one of its subcategories.
+type Category struct {
+	ID            int        `json:"id"`
+	ParentID      int        `json:"parentId,omitempty"`
+	Name          string     `json:"name"`
+	Slug          string     `json:"slug"`
+	Description   string     `json:"description,omitempty"`
+	WorkshopCount int        `json:"workshopCount"`
+	EventCount    int        `json:"eventCount"`
+	CreatedAt     *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt     *time.Time `json:"updatedAt,omitempty"`
+}
+
+// CategorySummary is what workshop and event listings embed of their
+// categories.
+type CategorySummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type CategoryListResponse struct {
+	Categories []Category `json:"categories"`
+}
+
+func categoryResponse(c workshop.Category) Category {
+	return Category{
+		ID:            c.ID,
+		ParentID:      c.ParentID,
+		Name:          c.Name,
+		Slug:          c.Slug,
+		Description:   c.Description,
+		WorkshopCount: c.WorkshopCount,
+		EventCount:    c.EventCount,
+		CreatedAt:     timeOrNil(c.CreatedAt),
+		UpdatedAt:     timeOrNil(c.UpdatedAt),
+	}
+}
+
+func categorySummaries(categories []workshop.Category) []CategorySummary {
+	var resp []CategorySummary
+	for _, c := range categories {
+		resp = append(resp, CategorySummary{ID: c.ID, Name: c.Name, Slug: c.Slug})
+	}
+	return resp
+}
+
+// decodeCategory reads and validates the category in the request body.
+func decodeCategory(r *http.Request) (workshop.Category, error) {
+	var req Category
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return workshop.Category{}, errBadRequest
+	}
+	defer r.Body.Close()
+	c := workshop.Category{ParentID: req.ParentID, Name: req.Name, Slug: req.Slug, Description: req.Description}
+	return c, c.Validate()
+}
+
+// GetCategories lists every category by name with the number of upcoming
+// workshops and events in it.
+func (h CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) error {
+	categories, err := h.workshopRepo.GetCategories(time.Now())
+	if err != nil {
+		return err
+	}
+	resp := CategoryListResponse{Categories: []Category{}}
+	for _, c := range categories {
+		resp.Categories = append(resp.Categories, categoryResponse(c))
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func (h CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) error {
+	c, err := decodeCategory(r)
+	if err != nil {
+		return err
+	}
+	c, err = h.workshopRepo.InsertCategory(c)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(categoryResponse(c))
+}
+
+func (h CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "category_id")
+	if err != nil {
+		return err
+	}
+	c, err := h.workshopRepo.CategoryByID(id)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(categoryResponse(c))
+}
+
+// UpdateCategory replaces a category, which may move it under another
+// parent.
+func (h CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "category_id")
+	if err != nil {
+		return err
+	}
+	c, err := decodeCategory(r)
+	if err != nil {
+		return err
+	}
+	c.ID = id
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	c, err = h.workshopRepo.UpdateCategory(c)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(categoryResponse(c))
+}
+
+// DeleteCategory removes a category without subcategories and takes the
+// workshops and events filed under it out of it.
+func (h CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "category_id")
+	if err != nil {
+		return err
+	}
+	if err := h.workshopRepo.DeleteCategory(id); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h CategoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, one := mux.Vars(r)["category_id"]
+	var err error
+	switch {
+	case r.Method == "GET" && one:
+		err = h.GetCategory(w, r)
+	case r.Method == "GET":
+		err = h.GetCategories(w, r)
+	case r.Method == "POST" && !one:
+		err = h.CreateCategory(w, r)
+	case r.Method == "PUT" && one:
+		err = h.UpdateCategory(w, r)
+	case r.Method == "DELETE" && one:
+		err = h.DeleteCategory(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == errBadRequest, err == workshop.ErrInvalidCategory:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == repository.ErrUnknownCategory:
+		http.Error(w, "parent category not found", http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "category not found", http.StatusNotFound)
+	case err == repository.ErrDuplicateCategory, err == repository.ErrCategoryHasChildren, err == repository.ErrCategoryInUse:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
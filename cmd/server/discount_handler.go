@@ -0,0 +1,154 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// DiscountCodeHandler manages promotion codes participants can give when
+// signing up.
+type DiscountCodeHandler struct {
+	workshopRepo repository.WorkshopDB
+}
+
+// DiscountCode takes either Percent percent or a fixed Amount off the price.
+// MaxRedemptions, ExpiresAt, WorkshopIDs and CategoryIDs are optional
+// limits.
+type DiscountCode struct {
+	Code           string                `json:"code"`
+	Kind           workshop.DiscountKind `json:"kind"`
+	Percent        int                   `json:"percent,omitempty"`
+	Amount         *Price                `json:"amount,omitempty"`
+	MaxRedemptions int                   `json:"maxRedemptions,omitempty"`
+	Redemptions    int                   `json:"redemptions"`
+	ExpiresAt      *time.Time            `json:"expiresAt,omitempty"`
+	WorkshopIDs    []string              `json:"workshopIds,omitempty"`
+	CategoryIDs    []int                 `json:"categoryIds,omitempty"`
+	CreatedAt      time.Time             `json:"createdAt"`
+}
+
+type DiscountCodeListResponse struct {
+	DiscountCodes []DiscountCode `json:"discount_codes"`
+}
+
+type Redemption struct {
+	SignupID   int       `json:"signupId"`
+	WorkshopID string    `json:"workshopId"`
+	Discount   Price     `json:"discount"`
+	RedeemedAt time.Time `json:"redeemedAt"`
+}
+
+type RedemptionListResponse struct {
+	Code        string       `json:"code"`
+	Redemptions []Redemption `json:"redemptions"`
+}
+
+func discountCodeResponse(d workshop.DiscountCode) DiscountCode {
+	resp := DiscountCode{
+		Code:           d.Code,
+		Kind:           d.Kind,
+		Percent:        d.Percent,
+		MaxRedemptions: d.MaxRedemptions,
+		Redemptions:    d.Redemptions,
+		ExpiresAt:      timeOrNil(d.ExpiresAt),
+		WorkshopIDs:    d.WorkshopIDs,
+		CategoryIDs:    d.CategoryIDs,
+		CreatedAt:      d.CreatedAt,
+	}
+	if d.Kind == workshop.DiscountFixed {
+		amount := priceResponse(d.Amount)
+		resp.Amount = &amount
+	}
+	return resp
+}
+
+func (h DiscountCodeHandler) GetDiscountCodes(w http.ResponseWriter, r *http.Request) error {
+	codes, err := h.workshopRepo.GetDiscountCodes()
+	if err != nil {
+		return err
+	}
+	var resp DiscountCodeListResponse
+	for _, d := range codes {
+		resp.DiscountCodes = append(resp.DiscountCodes, discountCodeResponse(d))
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func (h DiscountCodeHandler) CreateDiscountCode(w http.ResponseWriter, r *http.Request) error {
+	var req DiscountCode
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	d := workshop.DiscountCode{
+		Code:           workshop.NormalizeCode(req.Code),
+		Kind:           req.Kind,
+		Percent:        req.Percent,
+		MaxRedemptions: req.MaxRedemptions,
+		ExpiresAt:      timeOrZero(req.ExpiresAt),
+		WorkshopIDs:    req.WorkshopIDs,
+		CategoryIDs:    req.CategoryIDs,
+	}
+	if req.Amount != nil {
+		d.Amount = req.Amount.money()
+	}
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	if err := h.workshopRepo.InsertDiscountCode(d); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(discountCodeResponse(d))
+}
+
+// GetRedemptions lists the signups a code has been used on.
+func (h DiscountCodeHandler) GetRedemptions(w http.ResponseWriter, r *http.Request) {
+	code := workshop.NormalizeCode(mux.Vars(r)["code"])
+	redemptions, err := h.workshopRepo.GetRedemptions(code)
+	if err == sql.ErrNoRows {
+		http.Error(w, "discount code not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := RedemptionListResponse{Code: code}
+	for _, rd := range redemptions {
+		resp.Redemptions = append(resp.Redemptions, Redemption{
+			SignupID:   rd.SignUpID,
+			WorkshopID: rd.WorkshopID,
+			Discount:   priceResponse(rd.Discount),
+			RedeemedAt: rd.RedeemedAt,
+		})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h DiscountCodeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "GET":
+		err = h.GetDiscountCodes(w, r)
+	case "POST":
+		err = h.CreateDiscountCode(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == workshop.ErrInvalidDiscountCode, err == repository.ErrUnknownCategory:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == repository.ErrDuplicateCode:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/workshop/lib/money"
 	"github.com/workshop/lib/repository"
 	"github.com/workshop/lib/workshop"
 )
@@ -17,37 +18,66 @@ type EventHandler struct {
 
 type EventListResponse struct {
 	Events []Event `json:"events"`
+	// NextCursor fetches the next page; it is left out on the last one.
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 type Event struct {
 	ID          string    `json:"id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
-	Time        string    `json:"time"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+	Timezone    string    `json:"timezone"`
+	Duration    string    `json:"duration"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
-	Cost        string    `json:"cost"`
+	Price       Price     `json:"price"`
 	Caption     string    `json:"caption"`
 	Location    string    `json:"location"`
+	RoomID      int       `json:"roomId,omitempty"`
+	// CategoryIDs files the event under categories on requests; responses
+	// embed Categories instead.
+	CategoryIDs []int             `json:"categoryIds,omitempty"`
+	Categories  []CategorySummary `json:"categories,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
 }
 
 func createEvent(e Event) (workshop.Event, error) {
-	//TODO validate workshop entry later
+	if err := workshop.ValidateTimes(e.StartTime, e.EndTime, e.Timezone); err != nil {
+		return workshop.Event{}, err
+	}
+	if err := validateTaxonomy(&e.CategoryIDs, &e.Tags); err != nil {
+		return workshop.Event{}, err
+	}
+	if err := workshop.ValidatePrice(e.Price.money()); err != nil {
+		return workshop.Event{}, err
+	}
 	return workshop.Event{
 		ID:          e.ID,
 		Name:        e.Name,
 		Description: e.Description,
-		Time:        e.Time, //Deal e.th this later
-		Cost:        e.Cost,
+		StartTime:   e.StartTime.UTC(),
+		EndTime:     e.EndTime.UTC(),
+		Timezone:    e.Timezone,
+		Price:       e.Price.money(),
 		Location:    e.Location,
+		RoomID:      e.RoomID,
 		Caption:     e.Caption,
+		CategoryIDs: e.CategoryIDs,
+		Tags:        e.Tags,
 	}, nil
 }
 
+// GetEvents lists a page of the upcoming events, narrowed and sorted by the
+// query parameters.
 func (h EventHandler) GetEvents(w http.ResponseWriter, r *http.Request) error {
-
-	events, err := h.workshopRepo.GetEvents()
+	filter, page, err := listQuery(r, time.Now())
+	if err != nil {
+		return err
+	}
+	events, next, err := h.workshopRepo.ListEvents(filter, page)
 	if err != nil {
-		return nil
+		return err
 	}
 	var eResp []Event
 	for _, e := range events {
@@ -57,13 +87,19 @@ func (h EventHandler) GetEvents(w http.ResponseWriter, r *http.Request) error {
 			Description: e.Description,
 			CreatedAt:   e.CreatedAt,
 			UpdatedAt:   e.UpdatedAt,
-			Time:        e.Time,
+			StartTime:   e.LocalStart(),
+			EndTime:     e.LocalEnd(),
+			Timezone:    workshopTimezone(e.Timezone),
+			Duration:    formatDuration(e.Duration()),
 			Caption:     e.Caption,
-			Cost:        e.Cost,
+			Price:       priceResponse(e.Price),
 			Location:    e.Location,
+			RoomID:      e.RoomID,
+			Categories:  categorySummaries(e.Categories),
+			Tags:        e.Tags,
 		})
 	}
-	resp := EventListResponse{Events: eResp}
+	resp := EventListResponse{Events: eResp, NextCursor: next}
 	json.NewEncoder(w).Encode(resp)
 
 	return nil
@@ -76,7 +112,10 @@ func (h EventHandler) CreateEvent(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 	defer r.Body.Close()
-	e, _ := createEvent(event)
+	e, err := createEvent(event)
+	if err != nil {
+		return err
+	}
 	if err := h.workshopRepo.InsertEvent(e); err != nil {
 		return err
 	}
@@ -93,6 +132,16 @@ func (h EventHandler) UpdateEvent(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 	defer r.Body.Close()
+	if err := workshop.ValidateTimes(event.StartTime, event.EndTime, event.Timezone); err != nil {
+		return err
+	}
+	if err := validateTaxonomy(&event.CategoryIDs, &event.Tags); err != nil {
+		return err
+	}
+	event.Price = money.New(event.Price.Amount, event.Price.Currency)
+	if err := workshop.ValidatePrice(event.Price); err != nil {
+		return err
+	}
 	if err = h.workshopRepo.UpdateEvent(event); err != nil {
 		return err
 	}
@@ -116,18 +165,26 @@ func (h EventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 		err := h.GetEvents(w, r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeListError(w, err)
 		}
 		return
 	case "POST":
 		err := h.CreateEvent(w, r)
-		if err != nil {
+		if isValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if c, ok := err.(*workshop.RoomConflictError); ok {
+			writeRoomConflict(w, c)
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	case "PUT":
 		err := h.UpdateEvent(w, r)
-		if err != nil {
+		if isValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if c, ok := err.(*workshop.RoomConflictError); ok {
+			writeRoomConflict(w, c)
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	case "DELETE":
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// InstructorHandler manages the profiles of the instructors workshops are
+// assigned to.
+type InstructorHandler struct {
+	workshopRepo repository.WorkshopDB
+}
+
+// Instructor is the profile of an instructor. PhotoKey is the key a photo
+// was uploaded to through /upload/instructors/{key}.
+type Instructor struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Bio       string     `json:"bio"`
+	PhotoKey  string     `json:"photoKey,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	Phone     string     `json:"phone,omitempty"`
+	Website   string     `json:"website,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// InstructorSummary is what workshop listings embed of their instructors.
+type InstructorSummary struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	PhotoKey string `json:"photoKey,omitempty"`
+}
+
+type InstructorListResponse struct {
+	Instructors []Instructor `json:"instructors"`
+}
+
+func instructorResponse(i workshop.Instructor) Instructor {
+	return Instructor{
+		ID:        i.ID,
+		Name:      i.Name,
+		Bio:       i.Bio,
+		PhotoKey:  i.PhotoKey,
+		Email:     i.Email,
+		Phone:     i.Phone,
+		Website:   i.Website,
+		CreatedAt: timeOrNil(i.CreatedAt),
+		UpdatedAt: timeOrNil(i.UpdatedAt),
+	}
+}
+
+func instructorSummaries(instructors []workshop.Instructor) []InstructorSummary {
+	var resp []InstructorSummary
+	for _, i := range instructors {
+		resp = append(resp, InstructorSummary{ID: i.ID, Name: i.Name, PhotoKey: i.PhotoKey})
+	}
+	return resp
+}
+
+// decodeInstructor reads and validates the instructor in the request body.
+func decodeInstructor(r *http.Request) (workshop.Instructor, error) {
+	var req Instructor
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return workshop.Instructor{}, errBadRequest
+	}
+	defer r.Body.Close()
+	i := workshop.Instructor{
+		Name:     req.Name,
+		Bio:      req.Bio,
+		PhotoKey: req.PhotoKey,
+		Email:    req.Email,
+		Phone:    req.Phone,
+		Website:  req.Website,
+	}
+	return i, i.Validate()
+}
+
+func (h InstructorHandler) GetInstructors(w http.ResponseWriter, r *http.Request) error {
+	instructors, err := h.workshopRepo.GetInstructors()
+	if err != nil {
+		return err
+	}
+	resp := InstructorListResponse{Instructors: []Instructor{}}
+	for _, i := range instructors {
+		resp.Instructors = append(resp.Instructors, instructorResponse(i))
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func (h InstructorHandler) CreateInstructor(w http.ResponseWriter, r *http.Request) error {
+	i, err := decodeInstructor(r)
+	if err != nil {
+		return err
+	}
+	i, err = h.workshopRepo.InsertInstructor(i)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(instructorResponse(i))
+}
+
+func (h InstructorHandler) GetInstructor(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "instructor_id")
+	if err != nil {
+		return err
+	}
+	i, err := h.workshopRepo.InstructorByID(id)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(instructorResponse(i))
+}
+
+// UpdateInstructor replaces the profile of an instructor; fields left out
+// are cleared.
+func (h InstructorHandler) UpdateInstructor(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "instructor_id")
+	if err != nil {
+		return err
+	}
+	i, err := decodeInstructor(r)
+	if err != nil {
+		return err
+	}
+	i.ID = id
+	i, err = h.workshopRepo.UpdateInstructor(i)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(instructorResponse(i))
+}
+
+// DeleteInstructor removes an instructor and takes them off the workshops
+// they were assigned to.
+func (h InstructorHandler) DeleteInstructor(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "instructor_id")
+	if err != nil {
+		return err
+	}
+	if err := h.workshopRepo.DeleteInstructor(id); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// GetWorkshops lists the workshops an instructor teaches, earliest first.
+func (h InstructorHandler) GetWorkshops(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "instructor_id")
+	var workshops []workshop.Workshop
+	if err == nil {
+		workshops, err = h.workshopRepo.GetWorkshopsByInstructorID(id)
+	}
+	switch {
+	case err == errBadRequest:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "instructor not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		json.NewEncoder(w).Encode(WorkshopListResponse{Workshops: workshopResponses(workshops)})
+	}
+}
+
+func (h InstructorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, one := mux.Vars(r)["instructor_id"]
+	var err error
+	switch {
+	case r.Method == "GET" && one:
+		err = h.GetInstructor(w, r)
+	case r.Method == "GET":
+		err = h.GetInstructors(w, r)
+	case r.Method == "POST" && !one:
+		err = h.CreateInstructor(w, r)
+	case r.Method == "PUT" && one:
+		err = h.UpdateInstructor(w, r)
+	case r.Method == "DELETE" && one:
+		err = h.DeleteInstructor(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == errBadRequest, err == workshop.ErrInvalidInstructor:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "instructor not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
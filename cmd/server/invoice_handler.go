@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// Invoicer issues invoices for paid signups and emails them to the buyer.
+type Invoicer struct {
+	workshopRepo repository.WorkshopDB
+	mailer       Mailer
+	seller       workshop.Seller
+	// vatRate is in basis points, 1900 for 19 %.
+	vatRate int
+}
+
+// InvoiceHandler lets admins list and download invoices, and invoice
+// signups that were paid offline.
+type InvoiceHandler struct {
+	workshopRepo repository.WorkshopDB
+	invoicer     Invoicer
+}
+
+// Invoice amounts are the gross price paid and the net amount and VAT it
+// is made of. VATRate is in basis points.
+type Invoice struct {
+	Number       string    `json:"number"`
+	SignupID     int       `json:"signupId"`
+	WorkshopID   string    `json:"workshopId"`
+	IssuedAt     time.Time `json:"issuedAt"`
+	ServiceDate  time.Time `json:"serviceDate"`
+	BuyerName    string    `json:"buyerName"`
+	BuyerEmail   string    `json:"buyerEmail"`
+	BuyerCompany string    `json:"buyerCompany,omitempty"`
+	BuyerAddress string    `json:"buyerAddress,omitempty"`
+	Description  string    `json:"description"`
+	VATRate      int       `json:"vatRate"`
+	Net          Price     `json:"net"`
+	VAT          Price     `json:"vat"`
+	Gross        Price     `json:"gross"`
+}
+
+type InvoiceListResponse struct {
+	Invoices []Invoice `json:"invoices"`
+}
+
+// InvoiceRequest invoices a confirmed signup, e.g. one paid by bank
+// transfer. Companies booking for their staff give their name and address.
+type InvoiceRequest struct {
+	SignupID     int    `json:"signupId"`
+	BuyerCompany string `json:"buyerCompany"`
+	BuyerAddress string `json:"buyerAddress"`
+}
+
+func invoiceResponse(inv workshop.Invoice) Invoice {
+	return Invoice{
+		Number:       inv.Number,
+		SignupID:     inv.SignUpID,
+		WorkshopID:   inv.WorkshopID,
+		IssuedAt:     inv.IssuedAt,
+		ServiceDate:  inv.ServiceDate,
+		BuyerName:    inv.Buyer.Name,
+		BuyerEmail:   inv.Buyer.Email,
+		BuyerCompany: inv.Buyer.Company,
+		BuyerAddress: inv.Buyer.Address,
+		Description:  inv.Description,
+		VATRate:      inv.VATRate,
+		Net:          priceResponse(inv.Net),
+		VAT:          priceResponse(inv.VAT),
+		Gross:        priceResponse(inv.Gross),
+	}
+}
+
+func invoiceFilename(inv workshop.Invoice) string {
+	return inv.Number + ".pdf"
+}
+
+// Issue invoices a signup and emails the invoice to the buyer. The invoice
+// is stored before it is sent, so a failed email is logged rather than
+// returned; it can still be downloaded.
+func (i Invoicer) Issue(signupID int, buyer workshop.Buyer) (workshop.Invoice, error) {
+	su, err := i.workshopRepo.SignUpByID(signupID)
+	if err != nil {
+		return workshop.Invoice{}, err
+	}
+	ws, err := i.workshopRepo.WorkshopByID(su.WorkshopID)
+	if err != nil {
+		return workshop.Invoice{}, err
+	}
+	inv, err := workshop.NewInvoice(i.seller, i.vatRate, su, ws, buyer, time.Now())
+	if err != nil {
+		return inv, err
+	}
+	inv, err = i.workshopRepo.InsertInvoice(inv)
+	if err != nil {
+		return inv, err
+	}
+	body := fmt.Sprintf("Hi %s,\n\nThank you for your payment for %s. Your invoice %s is attached.\n\n%s", su.FirstName, ws.Name, inv.Number, i.seller.Name)
+	attachment := Attachment{Name: invoiceFilename(inv), ContentType: "application/pdf", Data: inv.PDF()}
+	if err := i.mailer.SendWithAttachment(inv.Buyer.Email, "Invoice "+inv.Number+": "+ws.Name, body, attachment); err != nil {
+		log.Printf("could not email invoice %s to %s: %v", inv.Number, inv.Buyer.Email, err)
+	}
+	return inv, nil
+}
+
+// invoicePaid invoices a signup whose payment just went through. It runs
+// after the payment is settled, so failures are logged for an admin to
+// invoice by hand rather than returned.
+func (i Invoicer) invoicePaid(su workshop.SignUp) {
+	inv, err := i.Issue(su.ID, workshop.Buyer{})
+	switch {
+	case err == workshop.ErrInvoiceExists:
+	case err != nil:
+		log.Printf("could not invoice paid signup %d: %v", su.ID, err)
+	default:
+		log.Printf("issued invoice %s for signup %d", inv.Number, su.ID)
+	}
+}
+
+func (h InvoiceHandler) GetInvoices(w http.ResponseWriter, r *http.Request) error {
+	invoices, err := h.workshopRepo.GetInvoices(r.URL.Query().Get("workshop_id"))
+	if err != nil {
+		return err
+	}
+	var resp InvoiceListResponse
+	for _, inv := range invoices {
+		resp.Invoices = append(resp.Invoices, invoiceResponse(inv))
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func (h InvoiceHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) error {
+	var req InvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	inv, err := h.invoicer.Issue(req.SignupID, workshop.Buyer{Company: req.BuyerCompany, Address: req.BuyerAddress})
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(invoiceResponse(inv))
+}
+
+// PDF serves an invoice as the PDF document that was emailed.
+func (h InvoiceHandler) PDF(w http.ResponseWriter, r *http.Request) {
+	inv, err := h.workshopRepo.InvoiceByNumber(mux.Vars(r)["number"])
+	if err == sql.ErrNoRows {
+		http.Error(w, "invoice not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoiceFilename(inv)))
+	w.Write(inv.PDF())
+}
+
+func (h InvoiceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "GET":
+		err = h.GetInvoices(w, r)
+	case "POST":
+		err = h.CreateInvoice(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "signup not found", http.StatusNotFound)
+	case err == workshop.ErrInvoiceExists, err == workshop.ErrNothingToInvoice:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// listQuery reads the filter and page of a listing of workshops or events
+// from the query string. Without from, only those starting from now on are
+// listed.
+func listQuery(r *http.Request, now time.Time) (workshop.Filter, workshop.Page, error) {
+	q := r.URL.Query()
+	tags, err := workshop.NormalizeTags(q["tag"])
+	if err != nil {
+		return workshop.Filter{}, workshop.Page{}, err
+	}
+	f := workshop.Filter{
+		Category: q.Get("category"),
+		Tags:     tags,
+		From:     now,
+		Level:    q.Get("level"),
+		Location: q.Get("location"),
+	}
+	bad := func() (workshop.Filter, workshop.Page, error) {
+		return workshop.Filter{}, workshop.Page{}, workshop.ErrInvalidFilter
+	}
+	if v := q.Get("from"); v != "" {
+		if f.From, err = parseListTime(v); err != nil {
+			return bad()
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = parseListTime(v); err != nil {
+			return bad()
+		}
+	}
+	if v := q.Get("venue"); v != "" {
+		if f.VenueID, err = strconv.Atoi(v); err != nil {
+			return bad()
+		}
+	}
+	for name, bound := range map[string]**int64{"minPrice": &f.MinPrice, "maxPrice": &f.MaxPrice} {
+		if v := q.Get(name); v != "" {
+			amount, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return bad()
+			}
+			*bound = &amount
+		}
+	}
+	if v := q.Get("available"); v != "" {
+		if f.Available, err = strconv.ParseBool(v); err != nil {
+			return bad()
+		}
+	}
+	p := workshop.Page{Sort: workshop.Sort(q.Get("sort")), Cursor: q.Get("cursor")}
+	if v := q.Get("limit"); v != "" {
+		if p.Limit, err = strconv.Atoi(v); err != nil || p.Limit == 0 {
+			return workshop.Filter{}, workshop.Page{}, workshop.ErrInvalidPage
+		}
+	}
+	if err := f.Validate(); err != nil {
+		return f, p, err
+	}
+	return f, p, p.Validate()
+}
+
+// parseListTime reads an RFC 3339 time, or a date as its midnight in the
+// studio's timezone.
+func parseListTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	loc, err := workshop.Zone("")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(workshop.DateLayout, v, loc)
+}
+
+// writeListError answers a failed listing of workshops or events.
+func writeListError(w http.ResponseWriter, err error) {
+	switch err {
+	case workshop.ErrInvalidTags, workshop.ErrInvalidFilter, workshop.ErrInvalidPage, workshop.ErrInvalidCursor:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case repository.ErrUnknownCategory:
+		http.Error(w, "category not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
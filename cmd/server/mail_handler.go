@@ -4,13 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ses"
 )
 
 type MailHandler struct {
-	ses *ses.SES
+	mailer Mailer
 }
 
 type MailRequest struct {
@@ -23,7 +20,6 @@ type MailRequest struct {
 
 func (h MailHandler) SendMail(w http.ResponseWriter, r *http.Request) error {
 	recipient := "info@workshop-on-forster.de"
-	from := "sacre.kool@gmail.com"
 	var mr MailRequest
 	err := json.NewDecoder(r.Body).Decode(&mr)
 	if err != nil {
@@ -36,26 +32,7 @@ func (h MailHandler) SendMail(w http.ResponseWriter, r *http.Request) error {
 	if mr.Message != "" {
 		emailBody = fmt.Sprintf("%s\n\n Message: %s", emailBody, mr.Message)
 	}
-	sesEmailInput := &ses.SendEmailInput{
-		Destination: &ses.Destination{
-			ToAddresses: []*string{aws.String(recipient)},
-		},
-		Message: &ses.Message{
-			Body: &ses.Body{
-				Text: &ses.Content{
-					Data: aws.String(emailBody)},
-			},
-			Subject: &ses.Content{
-				Data: aws.String(mr.Subject),
-			},
-		},
-		Source: aws.String(from),
-		ReplyToAddresses: []*string{
-			aws.String(from),
-		},
-	}
-	_, err = h.ses.SendEmail(sesEmailInput)
-	if err != nil {
+	if err := h.mailer.Send(recipient, mr.Subject, emailBody); err != nil {
 		fmt.Println(err.Error())
 		return err
 	}
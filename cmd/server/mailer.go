@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/workshop/lib/payment"
+	"github.com/workshop/lib/workshop"
+)
+
+const mailFrom = "sacre.kool@gmail.com"
+
+// Mailer sends plain text email through SES.
+type Mailer struct {
+	ses *ses.SES
+}
+
+func (m Mailer) Send(to, subject, body string) error {
+	sesEmailInput := &ses.SendEmailInput{
+		Destination: &ses.Destination{
+			ToAddresses: []*string{aws.String(to)},
+		},
+		Message: &ses.Message{
+			Body: &ses.Body{
+				Text: &ses.Content{
+					Data: aws.String(body)},
+			},
+			Subject: &ses.Content{
+				Data: aws.String(subject),
+			},
+		},
+		Source: aws.String(mailFrom),
+		ReplyToAddresses: []*string{
+			aws.String(mailFrom),
+		},
+	}
+	_, err := m.ses.SendEmail(sesEmailInput)
+	return err
+}
+
+// Attachment is a file sent along with an email.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// SendWithAttachment sends a plain text email with a file attached. SES
+// only takes attachments as a raw MIME message, which is put together here.
+func (m Mailer) SendWithAttachment(to, subject, body string, a Attachment) error {
+	const boundary = "workshop-attachment-boundary"
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nReply-To: %s\r\n", mailFrom, to, mailFrom)
+	fmt.Fprintf(&b, "Subject: %s\r\nMIME-Version: 1.0\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\n", boundary)
+	writeBase64Lines(&b, []byte(body))
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: %s; name=%q\r\n", boundary, a.ContentType, a.Name)
+	fmt.Fprintf(&b, "Content-Disposition: attachment; filename=%q\r\nContent-Transfer-Encoding: base64\r\n\r\n", a.Name)
+	writeBase64Lines(&b, a.Data)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	_, err := m.ses.SendRawEmail(&ses.SendRawEmailInput{
+		Destinations: []*string{aws.String(to)},
+		Source:       aws.String(mailFrom),
+		RawMessage:   &ses.RawMessage{Data: b.Bytes()},
+	})
+	return err
+}
+
+// writeBase64Lines writes data base64 encoded in lines of 76 characters, as
+// MIME requires.
+func writeBase64Lines(b *bytes.Buffer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		b.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	b.WriteString(enc + "\r\n")
+}
+
+// notifyPromoted emails everyone who was moved off the waitlist of ws.
+// Signups held pending get the link to their checkout in checkouts. The
+// promotion is already stored, so failures are logged rather than returned.
+func notifyPromoted(m Mailer, ws workshop.Workshop, promoted []workshop.SignUp, checkouts map[int]payment.Session) {
+	for _, s := range promoted {
+		body := fmt.Sprintf("Hi %s,\n\nA seat has opened up on %s and you have been moved off the waitlist. Your place is confirmed, see you there!\n\n%s", s.FirstName, ws.Name, sessionLines(ws))
+		if c, ok := checkouts[s.ID]; ok {
+			body = fmt.Sprintf("Hi %s,\n\nA seat has opened up on %s and you have been moved off the waitlist. It is held for you until %s: pay at %s to confirm your place.\n\n%s",
+				s.FirstName, ws.Name, ws.Local(c.ExpiresAt).Format("15:04 MST on Mon 2 Jan"), c.URL, sessionLines(ws))
+		}
+		if err := m.Send(s.Email, "You're in: "+ws.Name, body); err != nil {
+			log.Printf("could not notify %s of promotion on workshop %s: %v", s.Email, ws.WorkshopID, err)
+		}
+	}
+}
+
+// notifyCancelled emails the signups of ws that was called off, with the
+// refund each gets, if any. Failures are logged rather than returned.
+func notifyCancelled(m Mailer, ws workshop.Workshop, cancelled []workshop.SignUp, refunds map[int]workshop.Refund) {
+	for _, s := range cancelled {
+		body := fmt.Sprintf("Hi %s,\n\nWe are sorry, %s has been cancelled and your signup with it.", s.FirstName, ws.Name)
+		if r, ok := refunds[s.ID]; ok {
+			body += fmt.Sprintf(" You will be refunded %s, see credit note %s.", r.Amount, r.Number)
+		}
+		if err := m.Send(s.Email, "Cancelled: "+ws.Name, body); err != nil {
+			log.Printf("could not notify %s of cancellation of workshop %s: %v", s.Email, ws.WorkshopID, err)
+		}
+	}
+}
+
+// notifyBalanceDue reminds signups of ws of the balance they have left to
+// pay. payURL is where they can pay it online, if anywhere. Failures are
+// logged rather than returned.
+func notifyBalanceDue(m Mailer, ws workshop.Workshop, signups []workshop.SignUp, payURL string) {
+	due := ws.LocalBalanceDue().Format("Mon 2 Jan 2006")
+	for _, s := range signups {
+		body := fmt.Sprintf("Hi %s,\n\nThe balance of %s for %s is due by %s.", s.FirstName, s.Balance(), ws.Name, due)
+		if payURL != "" {
+			body += " You can pay it at " + checkoutURL(payURL, s.ID, "balance")
+		}
+		if err := m.Send(s.Email, "Balance due: "+ws.Name, body); err != nil {
+			log.Printf("could not remind %s of the balance for workshop %s: %v", s.Email, ws.WorkshopID, err)
+		}
+	}
+}
+
+// notifyBalanceOverdue lets signups of ws know they missed the balance due
+// date, and whether they lost their seat for it. Failures are logged rather
+// than returned.
+func notifyBalanceOverdue(m Mailer, ws workshop.Workshop, signups []workshop.SignUp) {
+	for _, s := range signups {
+		body := fmt.Sprintf("Hi %s,\n\nThe balance of %s for %s was due and has not been paid.", s.FirstName, s.Balance(), ws.Name)
+		if s.Status == workshop.StatusCancelled {
+			body += " Your seat has been released; the deposit is not refunded."
+		} else {
+			body += " Please pay it as soon as possible to keep your seat."
+		}
+		if err := m.Send(s.Email, "Balance overdue: "+ws.Name, body); err != nil {
+			log.Printf("could not notify %s of the overdue balance for workshop %s: %v", s.Email, ws.WorkshopID, err)
+		}
+	}
+}
+
+// sessionLines lists the sessions of ws, one per line, in its timezone.
+func sessionLines(ws workshop.Workshop) string {
+	var b strings.Builder
+	for _, s := range ws.SessionList() {
+		fmt.Fprintf(&b, "%s - %s", s.LocalStart(ws.Timezone).Format("Mon 2 Jan 2006, 15:04"), s.LocalEnd(ws.Timezone).Format("15:04 MST"))
+		if loc := ws.SessionLocation(s); loc != "" {
+			fmt.Fprintf(&b, ", %s", loc)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
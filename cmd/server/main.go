@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -15,7 +17,10 @@ import (
 	"github.com/aws/aws-sdk-go/service/ses"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/workshop/lib/payment"
 	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/token"
+	"github.com/workshop/lib/workshop"
 )
 
 func main() {
@@ -25,15 +30,34 @@ func main() {
 	awsSecretKey := flag.String("AWS_SECRET_ACCESS_KEY", os.Getenv("AWS_SECRET_ACCESS_KEY"), "aws access key for ses session")
 	awsRegion := flag.String("AWS_REGION", os.Getenv("AWS_REGION"), "region for aws")
 	uploadBucket := flag.String("S3_UPLOAD_BUCKET", os.Getenv("S3_UPLOAD_BUCKET"), "bucket for photos")
+	store := flag.String("STORE", "mysql", "backing store for workshop data, mysql or memory")
+	fixtures := flag.String("FIXTURES", "", "directory of sql fixtures to seed the memory store with")
+	migrate := flag.Bool("MIGRATE", false, "apply pending schema migrations on startup")
+	signupSecret := flag.String("SIGNUP_SECRET", os.Getenv("SIGNUP_SECRET"), "key for signing signup cancellation tokens")
+	signupTokenTTL := flag.Duration("SIGNUP_TOKEN_TTL", 90*24*time.Hour, "how long signup cancellation tokens stay valid")
+	paymentProvider := flag.String("PAYMENT_PROVIDER", os.Getenv("PAYMENT_PROVIDER"), "payment provider for paid workshops, stripe or fake; empty takes payment offline")
+	paymentWebhookSecret := flag.String("PAYMENT_WEBHOOK_SECRET", os.Getenv("PAYMENT_WEBHOOK_SECRET"), "key the payment provider signs webhook callbacks with")
+	stripeKey := flag.String("STRIPE_API_KEY", os.Getenv("STRIPE_API_KEY"), "secret api key for stripe")
+	stripeURL := flag.String("STRIPE_URL", payment.StripeURL, "base url of the stripe api")
+	checkoutTTL := flag.Duration("CHECKOUT_TTL", 35*time.Minute, "how long a checkout stays open; its seat is held a few minutes longer")
+	promotionHold := flag.Duration("PROMOTION_HOLD", 24*time.Hour, "how long a seat is held for a waitlisted signup promoted on a paid workshop to pay")
+	holdSweepInterval := flag.Duration("HOLD_SWEEP_INTERVAL", time.Minute, "how often seats held by expired checkouts are released")
+	balanceSweepInterval := flag.Duration("BALANCE_SWEEP_INTERVAL", time.Hour, "how often balance reminders are sent and overdue balances flagged")
+	balanceReminderLead := flag.Duration("BALANCE_REMINDER_LEAD", 7*24*time.Hour, "how long before the balance due date signups are reminded")
+	balanceGrace := flag.Duration("BALANCE_GRACE", 48*time.Hour, "how long after their reminder unpaid signups are flagged at the earliest")
+	checkoutReturnURL := flag.String("CHECKOUT_RETURN_URL", os.Getenv("CHECKOUT_RETURN_URL"), "page participants return to after the checkout")
+	sellerName := flag.String("SELLER_NAME", os.Getenv("SELLER_NAME"), "name invoices are issued in; empty turns invoicing off")
+	sellerAddress := flag.String("SELLER_ADDRESS", os.Getenv("SELLER_ADDRESS"), "postal address printed on invoices, lines separated by |")
+	sellerEmail := flag.String("SELLER_EMAIL", mailFrom, "contact email printed on invoices")
+	sellerVATID := flag.String("SELLER_VAT_ID", os.Getenv("SELLER_VAT_ID"), "VAT ID (USt-IdNr.) printed on invoices")
+	sellerTaxNumber := flag.String("SELLER_TAX_NUMBER", os.Getenv("SELLER_TAX_NUMBER"), "tax number (Steuernummer) printed on invoices")
+	vatRate := flag.Int("VAT_RATE", workshop.StandardVATRate, "VAT rate included in prices, in basis points")
 
 	flag.Parse()
 	if *port == "" {
 		log.Fatal("couldnt parse port")
 	}
 
-	if *dbDNS == "" {
-		log.Fatal("dns string not found")
-	}
 	if *awsKeyID == "" {
 		log.Fatal("awsKeyID string not found")
 	}
@@ -46,38 +70,169 @@ func main() {
 	if *uploadBucket == "" {
 		log.Fatal("uploadBucket string not found")
 	}
+	if *signupSecret == "" {
+		log.Fatal("signupSecret string not found")
+	}
+
+	var payments payment.Provider
+	switch *paymentProvider {
+	case "":
+	case "stripe":
+		if *stripeKey == "" {
+			log.Fatal("stripeKey string not found")
+		}
+		payments = payment.NewStripe(*stripeURL, *stripeKey, *paymentWebhookSecret, *checkoutTTL)
+	case "fake":
+		payments = payment.NewFake(*paymentWebhookSecret, *checkoutTTL)
+	default:
+		log.Fatalf("unknown payment provider %q", *paymentProvider)
+	}
+	if payments != nil && *paymentWebhookSecret == "" {
+		log.Fatal("paymentWebhookSecret string not found")
+	}
+	// Promoted signups that owe money get a seat hold to pay in, but only
+	// when there is a provider to pay with.
+	hold := time.Duration(0)
+	if payments != nil {
+		hold = *promotionHold
+	}
 
 	var workshopDB repository.WorkshopDB
-	workshopDB, err := repository.NewWorkshopDB(*dbDNS)
+	switch *store {
+	case "mysql":
+		if *dbDNS == "" {
+			log.Fatal("dns string not found")
+		}
+		db, err := repository.NewWorkshopDB(*dbDNS, *migrate)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		db.SetPromotionHold(hold)
+		workshopDB = db
+	case "memory":
+		db := repository.NewMemoryDB()
+		if *fixtures != "" {
+			if err := db.LoadFixtures(*fixtures); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		db.SetPromotionHold(hold)
+		workshopDB = db
+	default:
+		log.Fatalf("unknown store %q", *store)
+	}
+	// Every write goes through the search index to keep it in sync.
+	searchDB, err := repository.NewSearchDB(workshopDB)
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
+	workshopDB = searchDB
+	seller := workshop.Seller{
+		Name:      *sellerName,
+		Address:   strings.Replace(*sellerAddress, "|", "\n", -1),
+		Email:     *sellerEmail,
+		VATID:     *sellerVATID,
+		TaxNumber: *sellerTaxNumber,
+	}
+	if seller.Name != "" {
+		if err := seller.Validate(); err != nil {
+			log.Fatal(err.Error())
+		}
+		if *vatRate < 0 || *vatRate > 10000 {
+			log.Fatal(workshop.ErrInvalidVATRate.Error())
+		}
+	}
 	awsSession, err := session.NewSession(&aws.Config{
-		Region:      aws.String(*awsRegion),
+		Region: aws.String(*awsRegion),
 	})
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 
-	sesSession := ses.New(awsSession)
+	mailer := Mailer{ses: ses.New(awsSession)}
 
 	s3s, err := session.NewSession(&aws.Config{
-		Region:      aws.String(*awsRegion),
+		Region: aws.String(*awsRegion),
 	})
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 	eventHandler := EventHandler{workshopRepo: workshopDB}
-	workshopHandler := WorkshopHandler{workshopRepo: workshopDB}
-	signupHandler := SignupHandler{workshopRepo: workshopDB}
-	mailHandler := MailHandler{ses: sesSession}
+	workshopHandler := WorkshopHandler{workshopRepo: workshopDB, mailer: mailer, payments: payments, checkoutReturnURL: *checkoutReturnURL}
+	signupHandler := SignupHandler{
+		workshopRepo:      workshopDB,
+		mailer:            mailer,
+		tokens:            token.NewSigner(*signupSecret, *signupTokenTTL),
+		payments:          payments,
+		checkoutReturnURL: *checkoutReturnURL,
+		holdTTL:           *checkoutTTL,
+	}
+	attendanceHandler := AttendanceHandler{workshopRepo: workshopDB}
+	seriesHandler := SeriesHandler{workshopRepo: workshopDB, mailer: mailer, payments: payments, checkoutReturnURL: *checkoutReturnURL}
+	discountCodeHandler := DiscountCodeHandler{workshopRepo: workshopDB}
+	signupStatusHandler := SignupStatusHandler{workshopRepo: workshopDB, mailer: mailer, payments: payments, checkoutReturnURL: *checkoutReturnURL}
+	refundHandler := RefundHandler{workshopRepo: workshopDB, payments: payments}
+	voucherHandler := VoucherHandler{workshopRepo: workshopDB}
+	instructorHandler := InstructorHandler{workshopRepo: workshopDB}
+	venueHandler := VenueHandler{workshopRepo: workshopDB}
+	calendarHandler := CalendarHandler{workshopRepo: workshopDB}
+	categoryHandler := CategoryHandler{workshopRepo: workshopDB}
+	searchHandler := SearchHandler{searchRepo: searchDB}
+	var invoicer *Invoicer
+	if seller.Name != "" {
+		invoicer = &Invoicer{workshopRepo: workshopDB, mailer: mailer, seller: seller, vatRate: *vatRate}
+	}
+	balanceHandler := BalanceHandler{workshopRepo: workshopDB, payments: payments, checkoutReturnURL: *checkoutReturnURL, invoicer: invoicer}
+	mailHandler := MailHandler{mailer: mailer}
 	uploadHandler := UploadHandler{s3Cli: s3.New(s3s), bucket: *uploadBucket}
 
 	router := mux.NewRouter()
 	router.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "OK") })
 	router.Handle("/events", eventHandler)
 	router.Handle("/workshops", workshopHandler)
-	router.Handle("/signup/{workshop_id}", signupHandler)
+	router.HandleFunc("/workshops/{workshop_id}/calendar.ics", workshopHandler.Calendar).Methods("GET")
+	router.HandleFunc("/workshops/{workshop_id}/cancel", workshopHandler.CancelWorkshop).Methods("POST")
+	router.Handle("/sessions/{session_id}/attendance", attendanceHandler)
+	router.Handle("/instructors", instructorHandler)
+	router.Handle("/instructors/{instructor_id}", instructorHandler)
+	router.HandleFunc("/instructors/{instructor_id}/workshops", instructorHandler.GetWorkshops).Methods("GET")
+	router.Handle("/venues", venueHandler)
+	router.Handle("/venues/{venue_id}", venueHandler)
+	router.HandleFunc("/venues/{venue_id}/rooms", venueHandler.CreateRoom).Methods("POST")
+	router.HandleFunc("/rooms/{room_id}", venueHandler.UpdateRoom).Methods("PUT")
+	router.HandleFunc("/rooms/{room_id}", venueHandler.DeleteRoom).Methods("DELETE")
+	router.HandleFunc("/opening-hours", calendarHandler.GetOpeningHours).Methods("GET")
+	router.HandleFunc("/opening-hours", calendarHandler.SetOpeningHours).Methods("PUT")
+	router.Handle("/closures", calendarHandler)
+	router.Handle("/closures/{closure_id}", calendarHandler).Methods("DELETE")
+	router.Handle("/categories", categoryHandler)
+	router.Handle("/categories/{category_id}", categoryHandler)
+	router.HandleFunc("/search", searchHandler.Search).Methods("GET")
+	router.HandleFunc("/search/suggest", searchHandler.Suggest).Methods("GET")
+	router.Handle("/series", seriesHandler).Methods("POST")
+	router.Handle("/series/{series_id}", seriesHandler).Methods("GET")
+	router.Handle("/series/{series_id}/{workshop_id}", seriesHandler).Methods("PUT")
+	router.Handle("/signup/{workshop_id}", signupHandler).Methods("GET", "POST")
+	router.Handle("/signup/{workshop_id}/{token}", signupHandler).Methods("DELETE")
+	router.Handle("/signups/{signup_id}/status", signupStatusHandler)
+	router.Handle("/signups/{signup_id}/balance", balanceHandler)
+	router.HandleFunc("/signups/{signup_id}/payments", balanceHandler.RecordPayment).Methods("POST")
+	router.HandleFunc("/payments", balanceHandler.GetPayments).Methods("GET")
+	router.Handle("/discount-codes", discountCodeHandler)
+	router.Handle("/refunds", refundHandler)
+	router.Handle("/vouchers", voucherHandler).Methods("POST")
+	router.Handle("/vouchers/{code}", voucherHandler).Methods("GET")
+	router.HandleFunc("/vouchers/{code}/void", voucherHandler.VoidVoucher).Methods("POST")
+	router.HandleFunc("/refunds/{refund_id}/credit-note", refundHandler.CreditNote).Methods("GET")
+	if payments != nil {
+		router.Handle("/payments/webhook", PaymentHandler{workshopRepo: workshopDB, mailer: mailer, payments: payments, checkoutReturnURL: *checkoutReturnURL, invoicer: invoicer})
+	}
+	if invoicer != nil {
+		invoiceHandler := InvoiceHandler{workshopRepo: workshopDB, invoicer: *invoicer}
+		router.Handle("/invoices", invoiceHandler)
+		router.HandleFunc("/invoices/{number}/pdf", invoiceHandler.PDF).Methods("GET")
+	}
+	router.HandleFunc("/discount-codes/{code}/redemptions", discountCodeHandler.GetRedemptions).Methods("GET")
 	router.Handle("/mail", mailHandler)
 	router.HandleFunc("/upload/{folder}/{key}", uploadHandler.SignURL)
 	log.Printf("listening on port %s", *port)
@@ -87,8 +242,18 @@ func main() {
 		}
 	}()
 
-	signals := make(chan os.Signal)
+	stopSweeper := make(chan struct{})
+	go sweepHolds(workshopDB, mailer, payments, *checkoutReturnURL, *holdSweepInterval, stopSweeper)
+	// Reminders only link to a checkout when there is a provider to pay.
+	balancePayURL := ""
+	if payments != nil {
+		balancePayURL = *checkoutReturnURL
+	}
+	go sweepBalances(workshopDB, mailer, payments, *balanceSweepInterval, *balanceReminderLead, *balanceGrace, balancePayURL, stopSweeper)
+
+	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGTERM)
 	<-signals
+	close(stopSweeper)
 
 }
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/payment"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// PaymentHandler receives the payment provider's webhook callbacks and
+// settles the seats of the signups they are for.
+type PaymentHandler struct {
+	workshopRepo repository.WorkshopDB
+	mailer       Mailer
+	payments     payment.Provider
+	// checkoutReturnURL is where participants land after the checkout.
+	checkoutReturnURL string
+	// invoicer is nil when invoicing is not set up.
+	invoicer *Invoicer
+}
+
+var errCheckoutFailed = errors.New("could not open a checkout for the signup, please try again")
+
+// checkoutURL adds the signup and outcome to the page the provider sends
+// participants back to.
+func checkoutURL(base string, signupID int, outcome string) string {
+	return fmt.Sprintf("%s?signup_id=%d&checkout=%s", base, signupID, outcome)
+}
+
+// createCheckout opens a checkout for amount of a signup's price and
+// stores the payment it will settle. A pending signup's checkout stays open
+// for as long as its seat is held.
+func createCheckout(repo repository.WorkshopDB, p payment.Provider, returnURL string, ws workshop.Workshop, su workshop.SignUp, amount money.Money) (payment.Session, error) {
+	c := payment.Checkout{
+		SignUpID:    su.ID,
+		WorkshopID:  ws.WorkshopID,
+		Description: ws.Name,
+		Email:       su.Email,
+		Amount:      amount,
+		SuccessURL:  checkoutURL(returnURL, su.ID, "success"),
+		CancelURL:   checkoutURL(returnURL, su.ID, "cancel"),
+	}
+	if su.Status == workshop.StatusPending && !su.HoldExpiresAt.IsZero() {
+		c.TTL = time.Until(su.HoldExpiresAt)
+	}
+	session, err := p.CreateCheckout(c)
+	if err == nil {
+		_, err = repo.InsertPayment(workshop.Payment{SignUpID: su.ID, Provider: p.Name(), SessionID: session.ID, Amount: amount})
+	}
+	return session, err
+}
+
+// holdMargin keeps a seat held a little longer than its checkout stays open,
+// so that payments made at the last moment, and their callbacks, still find
+// it.
+const holdMargin = 5 * time.Minute
+
+// holdUntil is when the seat of a signup paying through session is released.
+func holdUntil(session payment.Session) time.Time {
+	return session.ExpiresAt.Add(holdMargin).UTC()
+}
+
+// openCheckout takes out a payment of amount for a pending signup and holds
+// its seat until the checkout has expired. If that fails the seat is
+// released again, as nobody could pay for it.
+func openCheckout(repo repository.WorkshopDB, m Mailer, p payment.Provider, returnURL string, ws workshop.Workshop, su workshop.SignUp, amount money.Money) (payment.Session, error) {
+	session, err := createCheckout(repo, p, returnURL, ws, su, amount)
+	if err == nil {
+		err = repo.HoldSeatUntil(su.ID, holdUntil(session))
+		if err == nil {
+			return session, nil
+		}
+		expireCheckout(p, session.ID)
+	}
+	log.Printf("could not open checkout for signup %d: %v", su.ID, err)
+	_, promoted, _, err := repo.UpdateSignUpStatus(su.ID, workshop.StatusCancelled)
+	if err != nil {
+		log.Printf("could not release seat of signup %d: %v", su.ID, err)
+	}
+	settlePromoted(repo, m, p, returnURL, ws, promoted)
+	return session, errCheckoutFailed
+}
+
+// settlePromoted opens checkouts for the signups promoted off the waitlist
+// of ws that are held pending until they have paid, and emails everyone
+// promoted. Signups whose checkout cannot be opened give their seat up to
+// the next in line.
+func settlePromoted(repo repository.WorkshopDB, m Mailer, p payment.Provider, returnURL string, ws workshop.Workshop, promoted []workshop.SignUp) {
+	checkouts := make(map[int]payment.Session)
+	var settled []workshop.SignUp
+	for _, s := range promoted {
+		if s.Status == workshop.StatusPending && p != nil {
+			session, err := openCheckout(repo, m, p, returnURL, ws, s, ws.DepositFor(s.Due(), time.Now()))
+			if err != nil {
+				continue
+			}
+			checkouts[s.ID] = session
+			s.HoldExpiresAt = holdUntil(session)
+		}
+		settled = append(settled, s)
+	}
+	notifyPromoted(m, ws, settled, checkouts)
+}
+
+// expireCheckout closes a checkout at the provider whose seat is no longer
+// held. Payments that still get through are refunded when they arrive.
+func expireCheckout(p payment.Provider, sessionID string) {
+	if err := p.Expire(sessionID); err != nil {
+		log.Printf("could not expire checkout %s: %v", sessionID, err)
+	}
+}
+
+// expireReleasedCheckouts closes the checkouts of signups whose hold was
+// released.
+func expireReleasedCheckouts(repo repository.WorkshopDB, p payment.Provider, released []workshop.SignUp) {
+	for _, s := range released {
+		payments, err := repo.GetPaymentsBySignUpID(s.ID)
+		if err != nil {
+			log.Printf("could not expire checkouts of signup %d: %v", s.ID, err)
+			continue
+		}
+		for _, pm := range payments {
+			if pm.Provider == p.Name() && pm.Status == workshop.PaymentExpired {
+				expireCheckout(p, pm.SessionID)
+			}
+		}
+	}
+}
+
+func (h PaymentHandler) Webhook(w http.ResponseWriter, r *http.Request) error {
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	event, err := h.payments.VerifyWebhook(payload, r.Header, time.Now())
+	if err != nil {
+		return err
+	}
+	if event.Status == "" {
+		io.WriteString(w, "OK")
+		return nil
+	}
+	// A payment for another amount than was asked for confirms nothing
+	// and is not invoiced; it is listed for an admin to resolve.
+	status := event.Status
+	if status == workshop.PaymentPaid {
+		expected, err := h.workshopRepo.PaymentBySessionID(h.payments.Name(), event.SessionID)
+		if err != nil {
+			return err
+		}
+		if event.Amount != expected.Amount {
+			log.Printf("payment %s for signup %d: paid %v, expected %v", expected.SessionID, expected.SignUpID, event.Amount, expected.Amount)
+			status = workshop.PaymentMismatched
+		}
+	}
+	p, promoted, err := h.workshopRepo.SettlePayment(h.payments.Name(), event.SessionID, status)
+	if err != nil {
+		return err
+	}
+	log.Printf("payment %s for signup %d: %s", p.SessionID, p.SignUpID, p.Status)
+	if p.Status == workshop.PaymentPaid {
+		su, err := h.workshopRepo.SignUpByID(p.SignUpID)
+		switch {
+		case err != nil:
+			log.Printf("could not load paid signup %d: %v", p.SignUpID, err)
+		case su.Status == workshop.StatusCancelled:
+			refundLatePayment(h.workshopRepo, h.payments, p)
+		case h.invoicer != nil && su.Balance().IsZero():
+			h.invoicer.invoicePaid(su)
+		}
+	}
+	if len(promoted) > 0 {
+		ws, err := h.workshopRepo.WorkshopByID(promoted[0].WorkshopID)
+		if err != nil {
+			return err
+		}
+		settlePromoted(h.workshopRepo, h.mailer, h.payments, h.checkoutReturnURL, ws, promoted)
+	}
+	io.WriteString(w, "OK")
+	return nil
+}
+
+func (h PaymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	err := h.Webhook(w, r)
+	switch {
+	case err == payment.ErrInvalidSignature, err == payment.ErrInvalidEvent:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "payment not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/payment"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/token"
+	"github.com/workshop/lib/workshop"
+)
+
+// paidWorkshop sets up a priced workshop on db and the routes a participant
+// goes through to pay for a seat on it with fake.
+func paidWorkshop(t *testing.T, db repository.WorkshopDB, fake *payment.Fake) *mux.Router {
+	start := time.Now().Add(30 * 24 * time.Hour).UTC()
+	if err := db.InsertWorkshop(workshop.Workshop{
+		WorkshopID: "paid",
+		Name:       "Paid",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Cap:        5,
+		Price:      money.New(4500, "EUR"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	router.Handle("/signup/{workshop_id}", SignupHandler{
+		workshopRepo:      db,
+		tokens:            token.NewSigner("secret", time.Hour),
+		payments:          fake,
+		checkoutReturnURL: "https://example.com/paid",
+		holdTTL:           time.Hour,
+	})
+	router.Handle("/payments/webhook", PaymentHandler{workshopRepo: db, payments: fake})
+	return router
+}
+
+func checkoutSignup(t *testing.T, router *mux.Router) SignUpResponse {
+	rec := httptest.NewRecorder()
+	body := `{"FirstName": "Ada", "LastName": "Lovelace", "Email": "ada@example.com"}`
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/signup/paid", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("signup: got %d %s", rec.Code, rec.Body)
+	}
+	var resp SignUpResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != workshop.StatusPending || resp.CheckoutURL == "" || resp.HoldExpiresAt == nil {
+		t.Fatalf("signup: got %+v, want a pending signup with a checkout", resp)
+	}
+	return resp
+}
+
+func postWebhook(router *mux.Router, payload []byte, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/payments/webhook", bytes.NewReader(payload))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCheckoutSettlesWithFakeProvider(t *testing.T) {
+	tests := []struct {
+		status workshop.PaymentStatus
+		want   workshop.Status
+	}{
+		{workshop.PaymentPaid, workshop.StatusConfirmed},
+		{workshop.PaymentFailed, workshop.StatusCancelled},
+		{workshop.PaymentExpired, workshop.StatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			db := repository.NewMemoryDB()
+			fake := payment.NewFake("whsec", time.Hour)
+			router := paidWorkshop(t, db, fake)
+			su := checkoutSignup(t, router)
+
+			payments, err := db.GetPaymentsBySignUpID(su.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(payments) != 1 || payments[0].Status != workshop.PaymentOpen {
+				t.Fatalf("got payments %+v, want one open payment", payments)
+			}
+			c, ok := fake.Checkout(payments[0].SessionID)
+			if !ok || c.SignUpID != su.ID || c.Amount != money.New(4500, "EUR") {
+				t.Fatalf("got checkout %+v, want 45.00 EUR for signup %d", c, su.ID)
+			}
+
+			payload, header, err := fake.Webhook(payments[0].SessionID, tt.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec := postWebhook(router, payload, header); rec.Code != http.StatusOK {
+				t.Fatalf("webhook: got %d %s", rec.Code, rec.Body)
+			}
+			settled, err := db.SignUpByID(su.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if settled.Status != tt.want {
+				t.Errorf("got signup %s, want %s", settled.Status, tt.want)
+			}
+			payments, err = db.GetPaymentsBySignUpID(su.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payments[0].Status != tt.status {
+				t.Errorf("got payment %s, want %s", payments[0].Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestWebhookRejectsForgedCallbacks(t *testing.T) {
+	db := repository.NewMemoryDB()
+	fake := payment.NewFake("whsec", time.Hour)
+	router := paidWorkshop(t, db, fake)
+	su := checkoutSignup(t, router)
+	payments, err := db.GetPaymentsBySignUpID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, header, err := fake.Webhook(payments[0].SessionID, workshop.PaymentPaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Replace(payload, []byte(`"paid"`), []byte(`"paid" `), 1)
+	if rec := postWebhook(router, tampered, header); rec.Code != http.StatusBadRequest {
+		t.Errorf("tampered webhook: got %d, want 400", rec.Code)
+	}
+	if rec := postWebhook(router, payload, http.Header{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("unsigned webhook: got %d, want 400", rec.Code)
+	}
+	pending, err := db.SignUpByID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Status != workshop.StatusPending {
+		t.Errorf("got signup %s after forged callbacks, want pending", pending.Status)
+	}
+}
+
+func TestLatePaymentIsRefunded(t *testing.T) {
+	db := repository.NewMemoryDB()
+	fake := payment.NewFake("whsec", time.Hour)
+	router := paidWorkshop(t, db, fake)
+	su := checkoutSignup(t, router)
+	payments, err := db.GetPaymentsBySignUpID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.ReleaseExpiredHolds(su.HoldExpiresAt.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, header, err := fake.Webhook(payments[0].SessionID, workshop.PaymentPaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Providers deliver callbacks at least once.
+	for i := 0; i < 2; i++ {
+		if rec := postWebhook(router, payload, header); rec.Code != http.StatusOK {
+			t.Fatalf("webhook %d: got %d %s", i+1, rec.Code, rec.Body)
+		}
+	}
+	released, err := db.SignUpByID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.Status != workshop.StatusCancelled {
+		t.Errorf("got signup %s, want it to stay cancelled", released.Status)
+	}
+	refunds, err := db.GetRefundsBySignUpID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 {
+		t.Fatalf("got refunds %+v, want one", refunds)
+	}
+	r := refunds[0]
+	if r.Reason != workshop.RefundLatePayment || r.Method != workshop.RefundProvider || r.PaymentID != payments[0].ID || r.Amount != money.New(4500, "EUR") {
+		t.Errorf("got refund %+v, want the late payment refunded in full", r)
+	}
+	if provider := fake.Refunds(); len(provider) != 1 || provider[0].ID != r.ProviderRefundID || provider[0].SessionID != payments[0].SessionID {
+		t.Errorf("got refunds %+v at the provider, want %s", provider, r.ProviderRefundID)
+	}
+}
+
+func TestMismatchedPaymentIsLeftForAnAdmin(t *testing.T) {
+	db := repository.NewMemoryDB()
+	fake := payment.NewFake("whsec", time.Hour)
+	router := paidWorkshop(t, db, fake)
+	su := checkoutSignup(t, router)
+	payments, err := db.GetPaymentsBySignUpID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	short, header, err := fake.WebhookAmount(payments[0].SessionID, workshop.PaymentPaid, money.New(4000, "EUR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := postWebhook(router, short, header); rec.Code != http.StatusOK {
+		t.Fatalf("webhook: got %d %s", rec.Code, rec.Body)
+	}
+	// A callback with the right amount after the fact settles nothing either.
+	paid, header, err := fake.Webhook(payments[0].SessionID, workshop.PaymentPaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := postWebhook(router, paid, header); rec.Code != http.StatusOK {
+		t.Fatalf("webhook: got %d %s", rec.Code, rec.Body)
+	}
+	pending, err := db.SignUpByID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Status != workshop.StatusPending || !pending.HoldExpiresAt.IsZero() || !pending.AmountPaid.IsZero() {
+		t.Errorf("got signup %s holding until %v with %v paid, want it pending without a hold and nothing paid", pending.Status, pending.HoldExpiresAt, pending.AmountPaid)
+	}
+	// The sweeper leaves the seat to the admin.
+	releaseHolds(db, Mailer{}, fake, "", su.HoldExpiresAt.Add(time.Hour))
+	if fake.Expired(payments[0].SessionID) {
+		t.Error("sweeper expired the checkout of a mismatched payment")
+	}
+
+	rec := httptest.NewRecorder()
+	BalanceHandler{workshopRepo: db}.GetPayments(rec, httptest.NewRequest("GET", "/payments?status=mismatched", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("payments: got %d %s", rec.Code, rec.Body)
+	}
+	var listed []Payment
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].SignupID != su.ID || listed[0].Status != workshop.PaymentMismatched || listed[0].Amount.Amount != 4500 {
+		t.Errorf("got payments %+v, want the mismatched checkout of signup %d", listed, su.ID)
+	}
+	rec = httptest.NewRecorder()
+	BalanceHandler{workshopRepo: db}.GetPayments(rec, httptest.NewRequest("GET", "/payments?status=lost", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown status: got %d, want 400", rec.Code)
+	}
+}
+
+func TestHoldOutlivesCheckout(t *testing.T) {
+	db := repository.NewMemoryDB()
+	fake := payment.NewFake("whsec", time.Hour)
+	router := paidWorkshop(t, db, fake)
+	su := checkoutSignup(t, router)
+	payments, err := db.GetPaymentsBySignUpID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, err := db.SignUpByID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake's checkouts expire an hour after they are opened.
+	if d := held.HoldExpiresAt.Sub(time.Now().Add(time.Hour)); d < holdMargin-time.Second || d > holdMargin {
+		t.Errorf("seat held until %v, want %v after the checkout expires", held.HoldExpiresAt, holdMargin)
+	}
+	if !su.HoldExpiresAt.Equal(held.HoldExpiresAt) {
+		t.Errorf("signup response holds until %v, stored %v", su.HoldExpiresAt, held.HoldExpiresAt)
+	}
+
+	releaseHolds(db, Mailer{}, fake, "", held.HoldExpiresAt.Add(-time.Second))
+	if fake.Expired(payments[0].SessionID) {
+		t.Fatal("checkout expired while the seat is still held")
+	}
+	releaseHolds(db, Mailer{}, fake, "", held.HoldExpiresAt)
+	if !fake.Expired(payments[0].SessionID) {
+		t.Error("checkout still open after its seat was released")
+	}
+	released, err := db.SignUpByID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.Status != workshop.StatusCancelled {
+		t.Errorf("got signup %s, want it released", released.Status)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// Price is an amount in minor units, e.g. cents, of an ISO 4217 currency.
+// Display is filled in on responses only.
+type Price struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Display  string `json:"display,omitempty"`
+}
+
+// PriceTier is a named alternative to a workshop's standard price. The
+// validity window and eligibility are optional.
+type PriceTier struct {
+	Name        string               `json:"name"`
+	Price       Price                `json:"price"`
+	ValidFrom   *time.Time           `json:"validFrom,omitempty"`
+	ValidUntil  *time.Time           `json:"validUntil,omitempty"`
+	Eligibility workshop.Eligibility `json:"eligibility,omitempty"`
+}
+
+func (p Price) money() money.Money {
+	return money.New(p.Amount, p.Currency)
+}
+
+func priceResponse(m money.Money) Price {
+	return Price{Amount: m.Amount, Currency: m.Currency, Display: m.String()}
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func priceTiers(tiers []PriceTier) []workshop.PriceTier {
+	var out []workshop.PriceTier
+	for _, t := range tiers {
+		out = append(out, workshop.PriceTier{
+			Name:        t.Name,
+			Price:       t.Price.money(),
+			ValidFrom:   timeOrZero(t.ValidFrom),
+			ValidUntil:  timeOrZero(t.ValidUntil),
+			Eligibility: t.Eligibility,
+		})
+	}
+	return out
+}
+
+func priceTierResponses(tiers []workshop.PriceTier) []PriceTier {
+	var out []PriceTier
+	for _, t := range tiers {
+		out = append(out, priceTierResponse(t))
+	}
+	return out
+}
+
+func priceTierResponse(t workshop.PriceTier) PriceTier {
+	return PriceTier{
+		Name:        t.Name,
+		Price:       priceResponse(t.Price),
+		ValidFrom:   timeOrNil(t.ValidFrom),
+		ValidUntil:  timeOrNil(t.ValidUntil),
+		Eligibility: t.Eligibility,
+	}
+}
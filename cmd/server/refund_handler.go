@@ -0,0 +1,320 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/payment"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// RefundHandler is the admin ledger of refunds. It also issues refunds by
+// hand, e.g. as a goodwill gesture.
+type RefundHandler struct {
+	workshopRepo repository.WorkshopDB
+	payments     payment.Provider
+}
+
+// RefundPolicy refunds in full until FullDays days before the workshop,
+// PartialPercent percent until PartialDays days before, and nothing after.
+type RefundPolicy struct {
+	FullDays       int `json:"fullDays"`
+	PartialDays    int `json:"partialDays"`
+	PartialPercent int `json:"partialPercent"`
+}
+
+type Refund struct {
+	ID               int                   `json:"id"`
+	SignupID         int                   `json:"signupId"`
+	WorkshopID       string                `json:"workshopId"`
+	Amount           Price                 `json:"amount"`
+	Reason           workshop.RefundReason `json:"reason"`
+	Method           workshop.RefundMethod `json:"method"`
+	ProviderRefundID string                `json:"providerRefundId,omitempty"`
+	Note             string                `json:"note,omitempty"`
+	CreditNote       string                `json:"creditNote"`
+	CreatedAt        time.Time             `json:"createdAt"`
+}
+
+type RefundListResponse struct {
+	Refunds []Refund `json:"refunds"`
+}
+
+// RefundRequest refunds Amount of a signup's price. Method "provider" pays
+// it back through the payment the signup was paid with, "manual" records a
+// refund paid outside the system.
+type RefundRequest struct {
+	SignupID int                   `json:"signupId"`
+	Amount   Price                 `json:"amount"`
+	Method   workshop.RefundMethod `json:"method"`
+	Note     string                `json:"note"`
+}
+
+func refundPolicy(p *RefundPolicy) workshop.RefundPolicy {
+	if p == nil {
+		return workshop.RefundPolicy{}
+	}
+	return workshop.RefundPolicy{FullDays: p.FullDays, PartialDays: p.PartialDays, PartialPercent: p.PartialPercent}
+}
+
+func refundPolicyResponse(p workshop.RefundPolicy) *RefundPolicy {
+	return &RefundPolicy{FullDays: p.FullDays, PartialDays: p.PartialDays, PartialPercent: p.PartialPercent}
+}
+
+func refundResponse(r workshop.Refund) Refund {
+	return Refund{
+		ID:               r.ID,
+		SignupID:         r.SignUpID,
+		WorkshopID:       r.WorkshopID,
+		Amount:           priceResponse(r.Amount),
+		Reason:           r.Reason,
+		Method:           r.Method,
+		ProviderRefundID: r.ProviderRefundID,
+		Note:             r.Note,
+		CreditNote:       r.Number,
+		CreatedAt:        r.CreatedAt,
+	}
+}
+
+// issueRefund pays r back through the provider when its method asks for it
+// and the signup paid through the provider, and records it.
+func issueRefund(repo repository.WorkshopDB, p payment.Provider, r workshop.Refund) (workshop.Refund, error) {
+	if r.Method == workshop.RefundProvider {
+		paid, err := paidPayment(repo, p, r.SignUpID)
+		if err != nil {
+			return r, err
+		}
+		if paid.ID == 0 {
+			return r, errNoProviderPayment
+		}
+		// The key makes retrying a refund that failed to be recorded safe.
+		return refundPayment(repo, p, r, paid, fmt.Sprintf("refund-%d-%s-%d", r.SignUpID, r.Reason, r.Amount.Amount))
+	}
+	return repo.InsertRefund(r)
+}
+
+// refundPayment pays r back through the provider from payment pm and
+// records it. Calls with the same key refund once at the provider.
+func refundPayment(repo repository.WorkshopDB, p payment.Provider, r workshop.Refund, pm workshop.Payment, key string) (workshop.Refund, error) {
+	id, err := p.Refund(pm.SessionID, r.Amount, key)
+	if err != nil {
+		return r, err
+	}
+	r.Method = workshop.RefundProvider
+	r.PaymentID = pm.ID
+	r.ProviderRefundID = id
+	return repo.InsertRefund(r)
+}
+
+// refundLatePayment pays back in full a payment that arrived after its
+// signup was cancelled, e.g. once its hold was released. The key is derived
+// from the checkout session and payments refunded before are skipped, so
+// callbacks the provider delivers again refund once. Failures are logged
+// for an admin to refund by hand, as the payment is already recorded.
+func refundLatePayment(repo repository.WorkshopDB, p payment.Provider, pm workshop.Payment) {
+	refunds, err := repo.GetRefundsBySignUpID(pm.SignUpID)
+	if err != nil {
+		log.Printf("could not refund late payment %s for signup %d: %v", pm.SessionID, pm.SignUpID, err)
+		return
+	}
+	for _, r := range refunds {
+		if r.PaymentID == pm.ID {
+			return
+		}
+	}
+	r := workshop.Refund{SignUpID: pm.SignUpID, Amount: pm.Amount, Reason: workshop.RefundLatePayment}
+	r, err = refundPayment(repo, p, r, pm, "late-"+pm.SessionID)
+	if err != nil {
+		log.Printf("could not refund late payment %s for signup %d: %v", pm.SessionID, pm.SignUpID, err)
+		return
+	}
+	log.Printf("refunded late payment %s of %v to signup %d (%s)", pm.SessionID, r.Amount, pm.SignUpID, r.Number)
+}
+
+var errNoProviderPayment = errors.New("signup was not paid through the payment provider")
+
+// paidPayment is the payment a signup paid through p with, if any.
+func paidPayment(repo repository.WorkshopDB, p payment.Provider, signupID int) (workshop.Payment, error) {
+	if p == nil {
+		return workshop.Payment{}, nil
+	}
+	payments, err := repo.GetPaymentsBySignUpID(signupID)
+	if err != nil {
+		return workshop.Payment{}, err
+	}
+	for _, pm := range payments {
+		if pm.Provider == p.Name() && pm.Status == workshop.PaymentPaid {
+			return pm, nil
+		}
+	}
+	return workshop.Payment{}, nil
+}
+
+// refundCancellation refunds a confirmed signup that was just cancelled, as
+// it was before: in full when the workshop was called off, otherwise as
+// much as the workshop's refund policy grants. Signups paid through the
+// provider are refunded through it; the others are recorded as manual
+// refunds to pay back by hand. The zero Refund means nothing is owed.
+func refundCancellation(repo repository.WorkshopDB, p payment.Provider, ws workshop.Workshop, su workshop.SignUp, reason workshop.RefundReason) (workshop.Refund, error) {
+	if su.Status != workshop.StatusConfirmed || su.Price.IsZero() {
+		return workshop.Refund{}, nil
+	}
+	amount := su.Price
+	if reason == workshop.RefundSignupCancelled {
+		amount = ws.RefundFor(su.Price, time.Now())
+	}
+	if amount.IsZero() {
+		return workshop.Refund{}, nil
+	}
+	// The voucher the signup paid with gets back its share of the refund,
+	// class passes their class only when the refund is in full.
+	if su.VoucherCode != "" {
+		share := su.VoucherShare(amount)
+		classes := 0
+		if amount == su.Price {
+			classes = 1
+		}
+		t, err := repo.CreditVoucher(su.ID, share, classes, "refund: "+string(reason))
+		if err != nil {
+			return workshop.Refund{}, err
+		}
+		if t.ID != 0 {
+			log.Printf("credited %v and %d classes back to voucher %s for signup %d", t.Amount, t.Classes, t.Code, su.ID)
+		}
+		amount = money.New(amount.Amount-share.Amount, amount.Currency)
+		if amount.IsZero() {
+			return workshop.Refund{}, nil
+		}
+	}
+	// Signups that have only paid a deposit owe what the policy keeps of
+	// their price, so they get back what they paid beyond that.
+	if ws.TakesDeposit() {
+		kept := su.Due().Amount - amount.Amount
+		amount = money.New(su.AmountPaid.Amount-kept, amount.Currency)
+		if amount.Amount <= 0 {
+			return workshop.Refund{}, nil
+		}
+	}
+	r := workshop.Refund{SignUpID: su.ID, Amount: amount, Reason: reason, Method: workshop.RefundManual}
+	paid, err := paidPayment(repo, p, su.ID)
+	if err != nil {
+		return workshop.Refund{}, err
+	}
+	if paid.ID != 0 {
+		r.Method = workshop.RefundProvider
+	}
+	return issueRefund(repo, p, r)
+}
+
+// refundCancelled is refundCancellation for callers that have already
+// committed the cancellation, so failures are logged for an admin to refund
+// by hand rather than returned.
+func refundCancelled(repo repository.WorkshopDB, p payment.Provider, ws workshop.Workshop, su workshop.SignUp, reason workshop.RefundReason) workshop.Refund {
+	r, err := refundCancellation(repo, p, ws, su, reason)
+	if err != nil {
+		log.Printf("could not refund cancelled signup %d: %v", su.ID, err)
+	} else if r.ID != 0 {
+		log.Printf("refunded %v to signup %d (%s, %s)", r.Amount, su.ID, r.Method, r.Number)
+	}
+	return r
+}
+
+func (h RefundHandler) GetRefunds(w http.ResponseWriter, r *http.Request) error {
+	refunds, err := h.workshopRepo.GetRefunds(r.URL.Query().Get("workshop_id"))
+	if err != nil {
+		return err
+	}
+	var resp RefundListResponse
+	for _, f := range refunds {
+		resp.Refunds = append(resp.Refunds, refundResponse(f))
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func (h RefundHandler) CreateRefund(w http.ResponseWriter, r *http.Request) error {
+	var req RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	f := workshop.Refund{
+		SignUpID: req.SignupID,
+		Amount:   req.Amount.money(),
+		Reason:   workshop.RefundOther,
+		Method:   req.Method,
+		Note:     req.Note,
+	}
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	f, err := issueRefund(h.workshopRepo, h.payments, f)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(refundResponse(f))
+}
+
+// CreditNote serves the credit note of a refund as plain text.
+func (h RefundHandler) CreditNote(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["refund_id"])
+	if err != nil {
+		http.Error(w, errBadRequest.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := h.workshopRepo.RefundByID(id)
+	var (
+		su workshop.SignUp
+		ws workshop.Workshop
+	)
+	if err == nil {
+		su, err = h.workshopRepo.SignUpByID(f.SignUpID)
+	}
+	if err == nil {
+		ws, err = h.workshopRepo.WorkshopByID(f.WorkshopID)
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "refund not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", f.Number+".txt"))
+	io.WriteString(w, workshop.CreditNote(f, su, ws))
+}
+
+func (h RefundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "GET":
+		err = h.GetRefunds(w, r)
+	case "POST":
+		err = h.CreateRefund(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == workshop.ErrInvalidRefund:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "signup not found", http.StatusNotFound)
+	case err == workshop.ErrRefundExceedsPaid, err == errNoProviderPayment:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
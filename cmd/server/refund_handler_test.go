@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// voucherSignup signs up for ws on db paying with a fresh voucher v and
+// cancels the signup again, returning it as it was before.
+func voucherSignup(t *testing.T, db repository.WorkshopDB, ws workshop.Workshop, v workshop.Voucher) workshop.SignUp {
+	if _, err := db.InsertVoucher(v); err != nil {
+		t.Fatal(err)
+	}
+	su, err := db.SignUp(workshop.SignUp{WorkshopID: ws.WorkshopID, FirstName: "Ada", Email: "ada@example.com", Price: ws.Price, VoucherCode: v.Code}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _, _, err := db.UpdateSignUpStatus(su.ID, workshop.StatusCancelled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Status != workshop.StatusConfirmed {
+		t.Fatalf("got signup %s, want it confirmed before it was cancelled", before.Status)
+	}
+	return before
+}
+
+func TestRefundCancellationCreditsVoucherShare(t *testing.T) {
+	start := time.Now().Add(30 * 24 * time.Hour).UTC()
+	ws := workshop.Workshop{
+		WorkshopID:   "vouchers",
+		Name:         "Vouchers",
+		StartTime:    start,
+		EndTime:      start.Add(2 * time.Hour),
+		Cap:          5,
+		Price:        money.New(4999, "EUR"),
+		RefundPolicy: workshop.RefundPolicy{FullDays: 60, PartialDays: 7, PartialPercent: 75},
+	}
+	tests := []struct {
+		name    string
+		voucher workshop.Voucher
+		reason  workshop.RefundReason
+		// balance and classes are what the voucher holds afterwards,
+		// refunded what is paid back as money.
+		balance  int64
+		classes  int
+		refunded int64
+	}{
+		// 75 % of 49.99 is 37.49, of which the voucher paid 2000/4999,
+		// 14.999, rounded to 15.00.
+		{"gift, partial refund", workshop.Voucher{Code: "GIFT", Kind: workshop.VoucherGift, Balance: money.New(2000, "EUR")}, workshop.RefundSignupCancelled, 1500, 0, 2249},
+		{"gift, full refund", workshop.Voucher{Code: "GIFT", Kind: workshop.VoucherGift, Balance: money.New(2000, "EUR")}, workshop.RefundWorkshopCancelled, 2000, 0, 2999},
+		{"gift covering the price", workshop.Voucher{Code: "GIFT", Kind: workshop.VoucherGift, Balance: money.New(6000, "EUR")}, workshop.RefundSignupCancelled, 1001 + 3749, 0, 0},
+		// Passes paid the whole price, and only get their class back on a
+		// full refund.
+		{"pass, full refund", workshop.Voucher{Code: "PASS", Kind: workshop.VoucherPass, Classes: 2}, workshop.RefundWorkshopCancelled, 0, 2, 0},
+		{"pass, partial refund", workshop.Voucher{Code: "PASS", Kind: workshop.VoucherPass, Classes: 2}, workshop.RefundSignupCancelled, 0, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := repository.NewMemoryDB()
+			if err := db.InsertWorkshop(ws); err != nil {
+				t.Fatal(err)
+			}
+			su := voucherSignup(t, db, ws, tt.voucher)
+			r, err := refundCancellation(db, nil, ws, su, tt.reason)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Amount.Amount != tt.refunded || tt.refunded != 0 && r.Method != workshop.RefundManual {
+				t.Errorf("got refund %+v, want %d paid back by hand", r, tt.refunded)
+			}
+			v, _, err := db.VoucherByCode(tt.voucher.Code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Balance.Amount != tt.balance || v.Classes != tt.classes {
+				t.Errorf("voucher holds %v and %d classes, want %d and %d", v.Balance, v.Classes, tt.balance, tt.classes)
+			}
+		})
+	}
+}
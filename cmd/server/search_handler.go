@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/workshop/lib/repository"
+)
+
+// SearchHandler searches the workshops and events in full text.
+type SearchHandler struct {
+	searchRepo repository.SearchDB
+}
+
+// SearchResult is a workshop or event matching a search. Highlights hold
+// the name and the other fields with matches as HTML, the matching words
+// in <mark>.
+type SearchResult struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	StartTime  time.Time         `json:"startTime"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+}
+
+type SuggestResponse struct {
+	Suggestions []string `json:"suggestions"`
+}
+
+const (
+	defaultSearchLimit  = 20
+	maxSearchLimit      = 100
+	defaultSuggestLimit = 10
+)
+
+// searchParams reads the query q and the limit of results.
+func searchParams(r *http.Request, defaultLimit int) (string, int, bool) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	limit := defaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			return q, 0, false
+		}
+		limit = n
+	}
+	return q, limit, q != ""
+}
+
+// Search ranks the upcoming workshops and events matching every word of q.
+func (h SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q, limit, ok := searchParams(r, defaultSearchLimit)
+	if !ok {
+		http.Error(w, "q is required and limit must be between 1 and 100", http.StatusBadRequest)
+		return
+	}
+	hits, err := h.searchRepo.Search(q, time.Now(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := SearchResponse{Results: []SearchResult{}}
+	for _, hit := range hits {
+		resp.Results = append(resp.Results, SearchResult{
+			Type:       hit.Doc.Kind,
+			ID:         hit.Doc.ID,
+			Name:       hit.Doc.Name,
+			StartTime:  hit.Doc.Start,
+			Score:      hit.Score,
+			Highlights: hit.Highlights,
+		})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Suggest completes the last word of q from the upcoming workshops and
+// events.
+func (h SearchHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	// Completions keep what was typed, spaces included.
+	q := r.URL.Query().Get("q")
+	_, limit, ok := searchParams(r, defaultSuggestLimit)
+	if !ok {
+		http.Error(w, "q is required and limit must be between 1 and 100", http.StatusBadRequest)
+		return
+	}
+	suggestions, err := h.searchRepo.Suggest(q, time.Now(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if suggestions == nil {
+		suggestions = []string{}
+	}
+	json.NewEncoder(w).Encode(SuggestResponse{Suggestions: suggestions})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/payment"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// SeriesHandler creates recurring workshops and edits them either one
+// occurrence at a time or from an occurrence onwards.
+type SeriesHandler struct {
+	workshopRepo repository.WorkshopDB
+	mailer       Mailer
+	payments     payment.Provider
+	// checkoutReturnURL is where participants land after the checkout.
+	checkoutReturnURL string
+}
+
+// Series is a template workshop repeated by an iCalendar RRULE. StartTime
+// and EndTime are those of the first occurrence.
+type Series struct {
+	SeriesID string `json:"series_id"`
+	Workshop
+	RRule   string      `json:"rrule"`
+	ExDates []time.Time `json:"exdates,omitempty"`
+}
+
+type SeriesResponse struct {
+	Series
+	Occurrences []Workshop `json:"occurrences"`
+}
+
+func seriesResponse(s workshop.Series, occurrences []workshop.Workshop) SeriesResponse {
+	return SeriesResponse{
+		Series: Series{
+			SeriesID: s.ID,
+			Workshop: workshopResponse(s.Template),
+			RRule:    s.RRule,
+			ExDates:  s.ExDates,
+		},
+		Occurrences: workshopResponses(occurrences),
+	}
+}
+
+// template copies the fields every occurrence shares from the request.
+func (s Series) template() (workshop.Workshop, error) {
+	t := workshop.Workshop{
+		Name:        s.Name,
+		Description: s.Description,
+		Caption:     s.Caption,
+		StartTime:   s.StartTime.UTC(),
+		EndTime:     s.EndTime.UTC(),
+		Timezone:    s.Timezone,
+		Price:       s.Price.money(),
+		Cap:         s.Cap,
+		Location:    s.Location,
+		RoomID:      s.RoomID,
+		Level:       s.Level,
+		CategoryIDs: s.CategoryIDs,
+		Tags:        s.Tags,
+	}
+	if err := workshop.ValidatePrice(t.Price); err != nil {
+		return t, err
+	}
+	return t, validateTaxonomy(&t.CategoryIDs, &t.Tags)
+}
+
+func (h SeriesHandler) CreateSeries(w http.ResponseWriter, r *http.Request) error {
+	var req Series
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if req.SeriesID == "" {
+		return errBadRequest
+	}
+	t, err := req.template()
+	if err != nil {
+		return err
+	}
+	s := workshop.Series{ID: req.SeriesID, Template: t, RRule: req.RRule, ExDates: req.ExDates}
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	occurrences, err := h.workshopRepo.InsertSeries(s)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(seriesResponse(s, occurrences))
+}
+
+func (h SeriesHandler) GetSeries(w http.ResponseWriter, r *http.Request) error {
+	s, err := h.workshopRepo.SeriesByID(mux.Vars(r)["series_id"])
+	if err != nil {
+		return err
+	}
+	occurrences, err := h.workshopRepo.GetWorkshopsBySeriesID(s.ID)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(seriesResponse(s, occurrences))
+}
+
+// UpdateSeries edits the occurrence {workshop_id} alone with ?scope=this, or
+// it and every later one with ?scope=following.
+func (h SeriesHandler) UpdateSeries(w http.ResponseWriter, r *http.Request) error {
+	vars := mux.Vars(r)
+	var req Series
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	s, err := h.workshopRepo.SeriesByID(vars["series_id"])
+	if err != nil {
+		return err
+	}
+	at, err := h.workshopRepo.WorkshopByID(vars["workshop_id"])
+	if err != nil {
+		return err
+	}
+	if at.SeriesID != s.ID {
+		return workshop.ErrNotInSeries
+	}
+	switch workshop.EditScope(r.URL.Query().Get("scope")) {
+	case workshop.ScopeThis:
+		return h.updateOccurrence(w, at, req)
+	case workshop.ScopeFollowing:
+		return h.updateFollowing(w, s, at, req)
+	}
+	return errBadRequest
+}
+
+// updateOccurrence edits a single occurrence, keeping its times unless the
+// request moves it.
+func (h SeriesHandler) updateOccurrence(w http.ResponseWriter, at workshop.Workshop, req Series) error {
+	ws, err := req.template()
+	if err != nil {
+		return err
+	}
+	ws.WorkshopID = at.WorkshopID
+	ws.CancelDeadline = at.CancelDeadline
+	if req.StartTime.IsZero() {
+		ws.StartTime, ws.EndTime, ws.Timezone = at.StartTime, at.EndTime, at.Timezone
+	}
+	if err := workshop.ValidateTimes(ws.StartTime, ws.EndTime, ws.Timezone); err != nil {
+		return err
+	}
+	promoted, err := h.workshopRepo.UpdateWorkshop(ws)
+	if err != nil {
+		return err
+	}
+	settlePromoted(h.workshopRepo, h.mailer, h.payments, h.checkoutReturnURL, ws, promoted)
+	ws, err = h.workshopRepo.WorkshopByID(ws.WorkshopID)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(workshopResponse(ws))
+}
+
+// updateFollowing ends s before at and continues it as a new series built
+// from the request. The rule and exdates carry over unless the request
+// replaces them, and the new series starts at at unless the request moves
+// it.
+func (h SeriesHandler) updateFollowing(w http.ResponseWriter, s workshop.Series, at workshop.Workshop, req Series) error {
+	_, rule, err := s.Truncate(at.StartTime)
+	if err != nil {
+		return err
+	}
+	t, err := req.template()
+	if err != nil {
+		return err
+	}
+	next := workshop.Series{
+		ID:       at.WorkshopID,
+		Template: t,
+		RRule:    req.RRule,
+		ExDates:  req.ExDates,
+	}
+	if req.StartTime.IsZero() {
+		next.Template.StartTime = at.StartTime
+		next.Template.EndTime = at.StartTime.Add(s.Template.Duration())
+		next.Template.Timezone = s.Template.Timezone
+	}
+	if next.RRule == "" {
+		next.RRule = rule.String()
+	}
+	if req.ExDates == nil {
+		for _, ex := range s.ExDates {
+			if !ex.Before(at.StartTime) {
+				next.ExDates = append(next.ExDates, ex)
+			}
+		}
+	}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	occurrences, err := h.workshopRepo.SplitSeries(s.ID, at.WorkshopID, next)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(seriesResponse(next, occurrences))
+}
+
+func isRuleError(err error) bool {
+	_, ok := err.(workshop.RuleError)
+	return ok
+}
+
+func (h SeriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "GET":
+		err = h.GetSeries(w, r)
+	case "POST":
+		err = h.CreateSeries(w, r)
+	case "PUT":
+		err = h.UpdateSeries(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	conflict, _ := err.(*workshop.RoomConflictError)
+	switch {
+	case err == errBadRequest, isValidationError(err), isRuleError(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case conflict != nil:
+		writeRoomConflict(w, conflict)
+	case err == sql.ErrNoRows:
+		http.Error(w, "series not found", http.StatusNotFound)
+	case err == workshop.ErrNotInSeries:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
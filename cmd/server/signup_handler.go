@@ -1,18 +1,32 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/workshop/lib/payment"
 	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/token"
 	"github.com/workshop/lib/workshop"
 )
 
 type SignupHandler struct {
 	workshopRepo repository.WorkshopDB
+	mailer       Mailer
+	tokens       token.Signer
+	// payments takes payment for signups on paid workshops; without one
+	// payment is taken offline and signups are confirmed straight away.
+	payments payment.Provider
+	// checkoutReturnURL is where participants land after the checkout.
+	checkoutReturnURL string
+	// holdTTL is how long a pending signup holds its seat until its checkout
+	// is open; the hold then lasts until the checkout has expired.
+	holdTTL time.Duration
 }
 
 type SignUpListResponse struct {
@@ -21,12 +35,67 @@ type SignUpListResponse struct {
 }
 
 type SignUp struct {
-	FirstName string `json:"FirstName"`
-	LastName  string `json:"LastName"`
-	Email     string `json:"Email"`
-	Message   string `json:"Message"`
+	ID        int             `json:"ID,omitempty"`
+	FirstName string          `json:"FirstName"`
+	LastName  string          `json:"LastName"`
+	Email     string          `json:"Email"`
+	Message   string          `json:"Message"`
+	Status    workshop.Status `json:"Status,omitempty"`
+	Position  int             `json:"Position,omitempty"`
+	// Waitlist asks to join the waitlist when the workshop is full instead
+	// of being turned away.
+	Waitlist bool `json:"Waitlist,omitempty"`
+	// Eligibility claims discounted price tiers, e.g. "concession".
+	Eligibility []workshop.Eligibility `json:"Eligibility,omitempty"`
+	Price       *Price                 `json:"Price,omitempty"`
+	PriceTier   string                 `json:"PriceTier,omitempty"`
+	// DiscountCode is a promotion code to redeem on the price.
+	DiscountCode string `json:"DiscountCode,omitempty"`
+	Discount     *Price `json:"Discount,omitempty"`
+	// VoucherCode is a gift voucher or class pass to pay with.
+	VoucherCode   string `json:"VoucherCode,omitempty"`
+	VoucherAmount *Price `json:"VoucherAmount,omitempty"`
+	// AmountPaid of the price has been received so far and Balance is
+	// left to pay. BalanceOverdue flags signups that missed the balance due
+	// date.
+	AmountPaid     *Price `json:"AmountPaid,omitempty"`
+	Balance        *Price `json:"Balance,omitempty"`
+	BalanceOverdue bool   `json:"BalanceOverdue,omitempty"`
 }
 
+type SignUpResponse struct {
+	ID       int             `json:"ID"`
+	Status   workshop.Status `json:"Status"`
+	Position int             `json:"Position,omitempty"`
+	// Price is what the signup pays, in the tier PriceTier, after Discount
+	// from DiscountCode.
+	Price        Price  `json:"Price"`
+	PriceTier    string `json:"PriceTier"`
+	DiscountCode string `json:"DiscountCode,omitempty"`
+	Discount     Price  `json:"Discount"`
+	// VoucherAmount of Price is paid with VoucherCode, AmountDue is left
+	// to pay.
+	VoucherCode   string `json:"VoucherCode,omitempty"`
+	VoucherAmount Price  `json:"VoucherAmount"`
+	AmountDue     Price  `json:"AmountDue"`
+	// Deposit is what the checkout charges on workshops taking deposits;
+	// the rest of AmountDue is due by BalanceDueAt.
+	Deposit      *Price     `json:"Deposit,omitempty"`
+	BalanceDueAt *time.Time `json:"BalanceDueAt,omitempty"`
+	// CheckoutURL is where a pending signup pays for its seat, which is
+	// held until HoldExpiresAt.
+	CheckoutURL   string     `json:"CheckoutURL,omitempty"`
+	HoldExpiresAt *time.Time `json:"HoldExpiresAt,omitempty"`
+	// CancelToken lets the participant cancel through
+	// DELETE /signup/{workshop_id}/{token}.
+	CancelToken string `json:"CancelToken"`
+}
+
+var (
+	errBadRequest         = errors.New("not a valid request")
+	errCancellationClosed = errors.New("the cancellation deadline for this workshop has passed")
+)
+
 func createSignup(su SignUp, id string) workshop.SignUp {
 	if su.Message == "" {
 		su.Message = "no message"
@@ -37,8 +106,11 @@ func createSignup(su SignUp, id string) workshop.SignUp {
 		LastName:   su.LastName,
 		Email:      su.Email,
 		Message:    su.Message,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		// The code is checked and redeemed by the repository.
+		DiscountCode: workshop.NormalizeCode(su.DiscountCode),
+		VoucherCode:  workshop.NormalizeCode(su.VoucherCode),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
 	}
 }
 
@@ -51,10 +123,24 @@ func (h SignupHandler) GetSignupsByWorkshopID(w http.ResponseWriter, r *http.Req
 	}
 	var sResp []SignUp
 	for _, s := range signups {
+		price, discount, voucher := priceResponse(s.Price), priceResponse(s.Discount), priceResponse(s.VoucherAmount)
+		paid, balance := priceResponse(s.AmountPaid), priceResponse(s.Balance())
 		sResp = append(sResp, SignUp{
-			FirstName: s.FirstName,
-			LastName:  s.LastName,
-			Email:     s.Email,
+			ID:             s.ID,
+			FirstName:      s.FirstName,
+			LastName:       s.LastName,
+			Email:          s.Email,
+			Status:         s.Status,
+			Position:       s.Position,
+			Price:          &price,
+			PriceTier:      s.PriceTier,
+			DiscountCode:   s.DiscountCode,
+			Discount:       &discount,
+			VoucherCode:    s.VoucherCode,
+			VoucherAmount:  &voucher,
+			AmountPaid:     &paid,
+			Balance:        &balance,
+			BalanceOverdue: !s.BalanceOverdueAt.IsZero(),
 		})
 	}
 	resp := SignUpListResponse{SignUps: sResp, WorkshopID: workshopID}
@@ -85,13 +171,108 @@ func (h SignupHandler) CreateSignup(w http.ResponseWriter, r *http.Request) erro
 		return err
 	}
 	defer r.Body.Close()
-	su := createSignup(signup, workshopID)
-	if err := h.workshopRepo.SignUp(su); err != nil {
+	for _, e := range signup.Eligibility {
+		if e == workshop.EligibleAnyone || !e.Valid() {
+			return errBadRequest
+		}
+	}
+	ws, err := h.workshopRepo.WorkshopByID(workshopID)
+	if err != nil {
+		return err
+	}
+	tier := ws.PriceFor(time.Now(), signup.Eligibility)
+	s := createSignup(signup, workshopID)
+	s.Price = tier.Price
+	s.PriceTier = tier.Name
+	if h.payments != nil && !tier.Price.IsZero() {
+		s.Status = workshop.StatusPending
+		s.HoldExpiresAt = time.Now().Add(h.holdTTL).UTC()
+	}
+	su, err := h.workshopRepo.SignUp(s, signup.Waitlist)
+	if err != nil {
+		return err
+	}
+	var (
+		checkout payment.Session
+		deposit  *Price
+	)
+	// Only the deposit is charged now, unless the balance is already due.
+	charge := ws.DepositFor(su.Due(), time.Now())
+	if ws.TakesDeposit() {
+		d := priceResponse(charge)
+		deposit = &d
+	}
+	if su.Status == workshop.StatusPending && su.Due().IsZero() {
+		// A discount or voucher covered the whole price.
+		if _, _, _, err := h.workshopRepo.UpdateSignUpStatus(su.ID, workshop.StatusConfirmed); err != nil {
+			return err
+		}
+		su.Status = workshop.StatusConfirmed
+	} else if su.Status == workshop.StatusPending {
+		checkout, err = openCheckout(h.workshopRepo, h.mailer, h.payments, h.checkoutReturnURL, ws, su, charge)
+		if err != nil {
+			return err
+		}
+		su.HoldExpiresAt = holdUntil(checkout)
+	}
+	return json.NewEncoder(w).Encode(SignUpResponse{
+		ID:            su.ID,
+		Status:        su.Status,
+		Position:      su.Position,
+		Price:         priceResponse(su.Price),
+		PriceTier:     su.PriceTier,
+		DiscountCode:  su.DiscountCode,
+		Discount:      priceResponse(su.Discount),
+		VoucherCode:   su.VoucherCode,
+		VoucherAmount: priceResponse(su.VoucherAmount),
+		AmountDue:     priceResponse(su.Due()),
+		Deposit:       deposit,
+		BalanceDueAt:  timeOrNil(ws.BalanceDueAt),
+		CheckoutURL:   checkout.URL,
+		HoldExpiresAt: timeOrNil(su.HoldExpiresAt),
+		CancelToken:   h.tokens.Issue(workshopID, su.ID, time.Now()),
+	})
+}
+
+// CancelSignup cancels a signup and lets whoever gets promoted off the
+// waitlist know. Participants cancel with the token they got at signup, which
+// is only honoured until the workshop's cancellation deadline. Admins cancel
+// through PUT /signups/{signup_id}/status instead.
+func (h SignupHandler) CancelSignup(w http.ResponseWriter, r *http.Request) error {
+	urlVars := mux.Vars(r)
+	claims, err := h.tokens.Verify(urlVars["token"], time.Now())
+	if err != nil {
+		return err
+	}
+	if claims.WorkshopID != urlVars["workshop_id"] {
+		return token.ErrInvalid
+	}
+	ws, err := h.workshopRepo.WorkshopByID(claims.WorkshopID)
+	if err != nil {
+		return err
+	}
+	if !ws.CancellationOpen(time.Now()) {
+		return errCancellationClosed
+	}
+	su, err := h.workshopRepo.SignUpByID(claims.SignupID)
+	if err != nil {
 		return err
 	}
+	if su.WorkshopID != claims.WorkshopID {
+		return token.ErrInvalid
+	}
+	// Only the request that actually cancelled the signup refunds it, so
+	// repeated cancellations do not pay out twice.
+	before, promoted, changed, err := h.workshopRepo.UpdateSignUpStatus(su.ID, workshop.StatusCancelled)
+	if err != nil {
+		return err
+	}
+	if changed {
+		refundCancelled(h.workshopRepo, h.payments, ws, before, workshop.RefundSignupCancelled)
+	}
+	settlePromoted(h.workshopRepo, h.mailer, h.payments, h.checkoutReturnURL, ws, promoted)
 	io.WriteString(w, "OK")
 	return nil
-
 }
 
 func (h SignupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -104,7 +285,29 @@ func (h SignupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	case "POST":
 		err := h.CreateSignup(w, r)
-		if err != nil {
+		switch {
+		case err == errBadRequest, workshop.IsDiscountError(err), workshop.IsVoucherError(err):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err == repository.ErrWorkshopFull, err == repository.ErrWorkshopCancelled:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err == errCheckoutFailed:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		case err == sql.ErrNoRows:
+			http.Error(w, "workshop not found", http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	case "DELETE":
+		err := h.CancelSignup(w, r)
+		switch {
+		case err == token.ErrInvalid, err == token.ErrExpired, err == errCancellationClosed:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case err == sql.ErrNoRows:
+			http.Error(w, "signup not found", http.StatusNotFound)
+		case isTransitionError(err):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
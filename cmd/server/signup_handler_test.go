@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/token"
+	"github.com/workshop/lib/workshop"
+)
+
+func TestCreateSignupLastSeatConflict(t *testing.T) {
+	db := repository.NewMemoryDB()
+	start := time.Now().Add(30 * 24 * time.Hour).UTC()
+	if err := db.InsertWorkshop(workshop.Workshop{
+		WorkshopID: "last-seat",
+		Name:       "Last seat",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Cap:        1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	router.Handle("/signup/{workshop_id}", SignupHandler{
+		workshopRepo: db,
+		tokens:       token.NewSigner("secret", time.Hour),
+	})
+
+	const n = 20
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"FirstName": "Racer%d", "LastName": "Doe", "Email": "racer%d@example.com"}`, i, i)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("POST", "/signup/last-seat", strings.NewReader(body)))
+			codes <- rec.Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+
+	count := make(map[int]int)
+	for code := range codes {
+		count[code]++
+	}
+	if count[http.StatusOK] != 1 || count[http.StatusConflict] != n-1 {
+		t.Errorf("got status codes %v, want one 200 and %d 409", count, n-1)
+	}
+}
+
+func TestCancelSignupRequiresToken(t *testing.T) {
+	db := repository.NewMemoryDB()
+	start := time.Now().Add(30 * 24 * time.Hour).UTC()
+	if err := db.InsertWorkshop(workshop.Workshop{
+		WorkshopID:     "deadline",
+		Name:           "Deadline",
+		StartTime:      start,
+		EndTime:        start.Add(2 * time.Hour),
+		Cap:            5,
+		CancelDeadline: time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	su, err := db.SignUp(workshop.SignUp{WorkshopID: "deadline", FirstName: "Ada", Email: "ada@example.com"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := SignupHandler{workshopRepo: db, tokens: token.NewSigner("secret", time.Hour)}
+	router := mux.NewRouter()
+	router.Handle("/signup/{workshop_id}", h).Methods("GET", "POST")
+	router.Handle("/signup/{workshop_id}/{token}", h).Methods("DELETE")
+
+	cases := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"by signup id", fmt.Sprintf("/signup/deadline?signup_id=%d", su.ID), http.StatusNotFound},
+		{"forged token", "/signup/deadline/forged", http.StatusForbidden},
+		{"after the deadline", "/signup/deadline/" + h.tokens.Issue("deadline", su.ID, time.Now()), http.StatusForbidden},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("DELETE", c.path, nil))
+		if rec.Code != c.want {
+			t.Errorf("%s: got status %d, want %d", c.name, rec.Code, c.want)
+		}
+	}
+	after, err := db.SignUpByID(su.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Status != workshop.StatusConfirmed {
+		t.Errorf("signup is %s, want it still confirmed", after.Status)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/payment"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// SignupStatusHandler lets admins inspect a signup's lifecycle and move it
+// between states, e.g. to mark attendance after a workshop.
+type SignupStatusHandler struct {
+	workshopRepo repository.WorkshopDB
+	mailer       Mailer
+	payments     payment.Provider
+	// checkoutReturnURL is where participants land after the checkout.
+	checkoutReturnURL string
+}
+
+type StatusRequest struct {
+	Status workshop.Status `json:"Status"`
+}
+
+type Transition struct {
+	From workshop.Status `json:"From"`
+	To   workshop.Status `json:"To"`
+	At   time.Time       `json:"At"`
+}
+
+type SignUpStatusResponse struct {
+	ID         int             `json:"ID"`
+	WorkshopID string          `json:"WorkshopID"`
+	Status     workshop.Status `json:"Status"`
+	// HoldExpiresAt is when a pending signup's seat is released unless it
+	// is paid for.
+	HoldExpiresAt *time.Time   `json:"HoldExpiresAt,omitempty"`
+	History       []Transition `json:"History"`
+}
+
+func signupID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["signup_id"])
+	if err != nil {
+		return 0, errBadRequest
+	}
+	return id, nil
+}
+
+func (h SignupStatusHandler) GetStatus(w http.ResponseWriter, r *http.Request) error {
+	id, err := signupID(r)
+	if err != nil {
+		return err
+	}
+	s, err := h.workshopRepo.SignUpByID(id)
+	if err != nil {
+		return err
+	}
+	resp := SignUpStatusResponse{ID: s.ID, WorkshopID: s.WorkshopID, Status: s.Status, HoldExpiresAt: timeOrNil(s.HoldExpiresAt)}
+	for _, t := range s.History {
+		resp.History = append(resp.History, Transition{From: t.From, To: t.To, At: t.At})
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func (h SignupStatusHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) error {
+	id, err := signupID(r)
+	if err != nil {
+		return err
+	}
+	var req StatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if !req.Status.Valid() {
+		return errBadRequest
+	}
+	before, promoted, changed, err := h.workshopRepo.UpdateSignUpStatus(id, req.Status)
+	if err != nil {
+		return err
+	}
+	cancelled := changed && req.Status == workshop.StatusCancelled
+	if cancelled || len(promoted) > 0 {
+		ws, err := h.workshopRepo.WorkshopByID(before.WorkshopID)
+		if err != nil {
+			return err
+		}
+		if cancelled {
+			refundCancelled(h.workshopRepo, h.payments, ws, before, workshop.RefundSignupCancelled)
+		}
+		settlePromoted(h.workshopRepo, h.mailer, h.payments, h.checkoutReturnURL, ws, promoted)
+	}
+	return h.GetStatus(w, r)
+}
+
+func isTransitionError(err error) bool {
+	_, ok := err.(workshop.TransitionError)
+	return ok
+}
+
+func (h SignupStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "GET":
+		err = h.GetStatus(w, r)
+	case "PUT":
+		err = h.UpdateStatus(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == errBadRequest:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "signup not found", http.StatusNotFound)
+	case err == repository.ErrWorkshopFull, err == repository.ErrWorkshopCancelled, isTransitionError(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/payment"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/token"
+	"github.com/workshop/lib/workshop"
+)
+
+func TestRepeatedCancelRefundsOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel func(su SignUpResponse) *http.Request
+	}{
+		{"by admin", func(su SignUpResponse) *http.Request {
+			return httptest.NewRequest("PUT", "/signups/"+strconv.Itoa(su.ID)+"/status", strings.NewReader(`{"status": "cancelled"}`))
+		}},
+		{"by token", func(su SignUpResponse) *http.Request {
+			return httptest.NewRequest("DELETE", "/signup/paid/"+su.CancelToken, nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := repository.NewMemoryDB()
+			fake := payment.NewFake("whsec", time.Hour)
+			router := paidWorkshop(t, db, fake)
+			router.Handle("/signup/{workshop_id}/{token}", SignupHandler{
+				workshopRepo: db,
+				tokens:       token.NewSigner("secret", time.Hour),
+				payments:     fake,
+			}).Methods("DELETE")
+			router.Handle("/signups/{signup_id}/status", SignupStatusHandler{workshopRepo: db, payments: fake})
+
+			su := checkoutSignup(t, router)
+			payments, err := db.GetPaymentsBySignUpID(su.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload, header, err := fake.Webhook(payments[0].SessionID, workshop.PaymentPaid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec := postWebhook(router, payload, header); rec.Code != http.StatusOK {
+				t.Fatalf("webhook: got %d %s", rec.Code, rec.Body)
+			}
+			for i := 0; i < 2; i++ {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, tt.cancel(su))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("cancel %d: got %d %s", i+1, rec.Code, rec.Body)
+				}
+			}
+			refunds, err := db.GetRefundsBySignUpID(su.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(refunds) != 1 || len(fake.Refunds()) != 1 {
+				t.Errorf("got refunds %+v at the provider %+v, want one", refunds, fake.Refunds())
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/workshop/lib/payment"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// sweepHolds releases the seats of abandoned checkouts every interval,
+// closes the checkouts at the provider p and settles whoever was promoted
+// onto the seats. It runs until stop is closed.
+func sweepHolds(repo repository.WorkshopDB, m Mailer, p payment.Provider, returnURL string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			releaseHolds(repo, m, p, returnURL, now)
+		}
+	}
+}
+
+// releaseHolds is one sweep of sweepHolds.
+func releaseHolds(repo repository.WorkshopDB, m Mailer, p payment.Provider, returnURL string, now time.Time) {
+	released, promoted, err := repo.ReleaseExpiredHolds(now)
+	if err != nil {
+		log.Printf("could not release expired seat holds: %v", err)
+	}
+	for _, s := range released {
+		log.Printf("released seat held by signup %d on workshop %s", s.ID, s.WorkshopID)
+	}
+	if p != nil {
+		expireReleasedCheckouts(repo, p, released)
+	}
+	settlePromotedByWorkshop(repo, m, p, returnURL, promoted)
+}
+
+// sweepBalances reminds signups of their balance lead before it falls due
+// and flags, or releases, the seats still unpaid after it, every interval.
+// Nobody is flagged less than grace after their reminder. It runs until stop
+// is closed.
+func sweepBalances(repo repository.WorkshopDB, m Mailer, p payment.Provider, interval, lead, grace time.Duration, payURL string, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			reminded, err := repo.RemindBalances(now.Add(lead), now)
+			if err != nil {
+				log.Printf("could not send balance reminders: %v", err)
+			}
+			forEachWorkshop(repo, reminded, func(ws workshop.Workshop, signups []workshop.SignUp) {
+				notifyBalanceDue(m, ws, signups, payURL)
+			})
+			overdue, promoted, err := repo.FlagOverdueBalances(now, grace)
+			if err != nil {
+				log.Printf("could not flag overdue balances: %v", err)
+			}
+			forEachWorkshop(repo, overdue, func(ws workshop.Workshop, signups []workshop.SignUp) {
+				for _, s := range signups {
+					log.Printf("signup %d on workshop %s has not paid its balance of %v: %s", s.ID, s.WorkshopID, s.Balance(), s.Status)
+				}
+				notifyBalanceOverdue(m, ws, signups)
+			})
+			settlePromotedByWorkshop(repo, m, p, payURL, promoted)
+		}
+	}
+}
+
+// settlePromotedByWorkshop is settlePromoted for signups promoted on any
+// number of workshops.
+func settlePromotedByWorkshop(repo repository.WorkshopDB, m Mailer, p payment.Provider, returnURL string, promoted []workshop.SignUp) {
+	forEachWorkshop(repo, promoted, func(ws workshop.Workshop, signups []workshop.SignUp) {
+		settlePromoted(repo, m, p, returnURL, ws, signups)
+	})
+}
+
+// forEachWorkshop calls notify with the signups of each workshop among
+// signups, in the order the workshops first appear.
+func forEachWorkshop(repo repository.WorkshopDB, signups []workshop.SignUp, notify func(workshop.Workshop, []workshop.SignUp)) {
+	byWorkshop := map[string][]workshop.SignUp{}
+	var order []string
+	for _, s := range signups {
+		if _, ok := byWorkshop[s.WorkshopID]; !ok {
+			order = append(order, s.WorkshopID)
+		}
+		byWorkshop[s.WorkshopID] = append(byWorkshop[s.WorkshopID], s)
+	}
+	for _, id := range order {
+		ws, err := repo.WorkshopByID(id)
+		if err != nil {
+			log.Printf("could not notify signups on workshop %s: %v", id, err)
+			continue
+		}
+		notify(ws, byWorkshop[id])
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// VenueHandler manages the venues and the rooms workshops and events are
+// booked into.
+type VenueHandler struct {
+	workshopRepo repository.WorkshopDB
+}
+
+type Venue struct {
+	ID                 int        `json:"id"`
+	Name               string     `json:"name"`
+	Address            string     `json:"address"`
+	AccessibilityNotes string     `json:"accessibilityNotes,omitempty"`
+	Rooms              []Room     `json:"rooms"`
+	CreatedAt          *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt          *time.Time `json:"updatedAt,omitempty"`
+}
+
+type Room struct {
+	ID                 int    `json:"id"`
+	VenueID            int    `json:"venueId"`
+	Name               string `json:"name"`
+	Capacity           int    `json:"capacity"`
+	AccessibilityNotes string `json:"accessibilityNotes,omitempty"`
+}
+
+type VenueListResponse struct {
+	Venues []Venue `json:"venues"`
+}
+
+// Booking is a workshop session or event holding a room.
+type Booking struct {
+	Kind      workshop.BookingKind `json:"kind"`
+	ID        string               `json:"id"`
+	Name      string               `json:"name"`
+	StartTime time.Time            `json:"startTime"`
+	EndTime   time.Time            `json:"endTime"`
+}
+
+// RoomConflictResponse is the body of the 409 returned when a workshop or
+// event is booked into a room that is taken.
+type RoomConflictResponse struct {
+	Error     string    `json:"error"`
+	RoomID    int       `json:"roomId"`
+	Conflicts []Booking `json:"conflicts"`
+}
+
+func venueResponse(v workshop.Venue) Venue {
+	resp := Venue{
+		ID:                 v.ID,
+		Name:               v.Name,
+		Address:            v.Address,
+		AccessibilityNotes: v.AccessibilityNotes,
+		Rooms:              []Room{},
+		CreatedAt:          timeOrNil(v.CreatedAt),
+		UpdatedAt:          timeOrNil(v.UpdatedAt),
+	}
+	for _, room := range v.Rooms {
+		resp.Rooms = append(resp.Rooms, roomResponse(room))
+	}
+	return resp
+}
+
+func roomResponse(room workshop.Room) Room {
+	return Room{
+		ID:                 room.ID,
+		VenueID:            room.VenueID,
+		Name:               room.Name,
+		Capacity:           room.Capacity,
+		AccessibilityNotes: room.AccessibilityNotes,
+	}
+}
+
+// writeRoomConflict answers with the bookings that hold the room.
+func writeRoomConflict(w http.ResponseWriter, err *workshop.RoomConflictError) {
+	resp := RoomConflictResponse{Error: err.Error(), RoomID: err.RoomID}
+	for _, b := range err.Conflicts {
+		resp.Conflicts = append(resp.Conflicts, Booking{Kind: b.Kind, ID: b.ID, Name: b.Name, StartTime: b.StartTime, EndTime: b.EndTime})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// pathID reads the integer path variable name.
+func pathID(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		return 0, errBadRequest
+	}
+	return id, nil
+}
+
+func (h VenueHandler) GetVenues(w http.ResponseWriter, r *http.Request) error {
+	venues, err := h.workshopRepo.GetVenues()
+	if err != nil {
+		return err
+	}
+	resp := VenueListResponse{Venues: []Venue{}}
+	for _, v := range venues {
+		resp.Venues = append(resp.Venues, venueResponse(v))
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func (h VenueHandler) GetVenue(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "venue_id")
+	if err != nil {
+		return err
+	}
+	v, err := h.workshopRepo.VenueByID(id)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(venueResponse(v))
+}
+
+// decodeVenue reads and validates the venue in the request body. Rooms
+// sent along are ignored; they are added on their own.
+func decodeVenue(r *http.Request) (workshop.Venue, error) {
+	var req Venue
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return workshop.Venue{}, errBadRequest
+	}
+	defer r.Body.Close()
+	v := workshop.Venue{Name: req.Name, Address: req.Address, AccessibilityNotes: req.AccessibilityNotes}
+	return v, v.Validate()
+}
+
+func (h VenueHandler) CreateVenue(w http.ResponseWriter, r *http.Request) error {
+	v, err := decodeVenue(r)
+	if err != nil {
+		return err
+	}
+	v, err = h.workshopRepo.InsertVenue(v)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(venueResponse(v))
+}
+
+func (h VenueHandler) UpdateVenue(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "venue_id")
+	if err != nil {
+		return err
+	}
+	v, err := decodeVenue(r)
+	if err != nil {
+		return err
+	}
+	v.ID = id
+	v, err = h.workshopRepo.UpdateVenue(v)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(venueResponse(v))
+}
+
+// DeleteVenue removes a venue and its rooms, unless any of them is booked.
+func (h VenueHandler) DeleteVenue(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "venue_id")
+	if err != nil {
+		return err
+	}
+	if err := h.workshopRepo.DeleteVenue(id); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// decodeRoom reads and validates the room in the request body.
+func decodeRoom(r *http.Request) (workshop.Room, error) {
+	var req Room
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return workshop.Room{}, errBadRequest
+	}
+	defer r.Body.Close()
+	room := workshop.Room{Name: req.Name, Capacity: req.Capacity, AccessibilityNotes: req.AccessibilityNotes}
+	return room, room.Validate()
+}
+
+// CreateRoom adds a room to the venue {venue_id}.
+func (h VenueHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	venueID, err := pathID(r, "venue_id")
+	var room workshop.Room
+	if err == nil {
+		room, err = decodeRoom(r)
+	}
+	if err == nil {
+		room.VenueID = venueID
+		room, err = h.workshopRepo.InsertRoom(room)
+	}
+	if err == nil {
+		w.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(w).Encode(roomResponse(room))
+	}
+	h.writeError(w, err, "venue not found")
+}
+
+// UpdateRoom replaces the name, capacity and notes of the room {room_id}.
+// Its capacity cannot drop below the cap of a workshop booked into it.
+func (h VenueHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "room_id")
+	var room workshop.Room
+	if err == nil {
+		room, err = decodeRoom(r)
+	}
+	if err == nil {
+		room.ID = id
+		room, err = h.workshopRepo.UpdateRoom(room)
+	}
+	if err == nil {
+		err = json.NewEncoder(w).Encode(roomResponse(room))
+	}
+	h.writeError(w, err, "room not found")
+}
+
+// DeleteRoom removes the room {room_id}, unless it is booked.
+func (h VenueHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "room_id")
+	if err == nil {
+		err = h.workshopRepo.DeleteRoom(id)
+	}
+	if err == nil {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	h.writeError(w, err, "room not found")
+}
+
+func (h VenueHandler) writeError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case err == errBadRequest, err == workshop.ErrInvalidVenue, err == workshop.ErrInvalidRoom:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	case err == repository.ErrRoomInUse, err == workshop.ErrOverRoomCapacity:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h VenueHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, one := mux.Vars(r)["venue_id"]
+	var err error
+	switch {
+	case r.Method == "GET" && one:
+		err = h.GetVenue(w, r)
+	case r.Method == "GET":
+		err = h.GetVenues(w, r)
+	case r.Method == "POST" && !one:
+		err = h.CreateVenue(w, r)
+	case r.Method == "PUT" && one:
+		err = h.UpdateVenue(w, r)
+	case r.Method == "DELETE" && one:
+		err = h.DeleteVenue(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	h.writeError(w, err, "venue not found")
+}
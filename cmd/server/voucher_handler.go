@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+// VoucherHandler lets admins issue gift vouchers and class passes, look up
+// their balance and void them.
+type VoucherHandler struct {
+	workshopRepo repository.WorkshopDB
+}
+
+// Voucher holds a money Balance for gift vouchers or a number of Classes
+// for passes. Code is generated when left empty.
+type Voucher struct {
+	Code         string               `json:"code"`
+	Kind         workshop.VoucherKind `json:"kind"`
+	Balance      *Price               `json:"balance,omitempty"`
+	Classes      int                  `json:"classes,omitempty"`
+	ExpiresAt    *time.Time           `json:"expiresAt,omitempty"`
+	VoidedAt     *time.Time           `json:"voidedAt,omitempty"`
+	Note         string               `json:"note,omitempty"`
+	CreatedAt    time.Time            `json:"createdAt"`
+	Transactions []VoucherTransaction `json:"transactions,omitempty"`
+}
+
+// VoucherTransaction is one change to a voucher's balance; Amount and
+// Classes are negative when they were taken off.
+type VoucherTransaction struct {
+	Kind       workshop.VoucherTxKind `json:"kind"`
+	Amount     Price                  `json:"amount"`
+	Classes    int                    `json:"classes,omitempty"`
+	SignupID   int                    `json:"signupId,omitempty"`
+	WorkshopID string                 `json:"workshopId,omitempty"`
+	Note       string                 `json:"note,omitempty"`
+	At         time.Time              `json:"at"`
+}
+
+type VoidVoucherRequest struct {
+	Note string `json:"note"`
+}
+
+func voucherResponse(v workshop.Voucher, history []workshop.VoucherTransaction) Voucher {
+	resp := Voucher{
+		Code:      v.Code,
+		Kind:      v.Kind,
+		Classes:   v.Classes,
+		ExpiresAt: timeOrNil(v.ExpiresAt),
+		VoidedAt:  timeOrNil(v.VoidedAt),
+		Note:      v.Note,
+		CreatedAt: v.CreatedAt,
+	}
+	if v.Kind == workshop.VoucherGift {
+		balance := priceResponse(v.Balance)
+		resp.Balance = &balance
+	}
+	for _, t := range history {
+		resp.Transactions = append(resp.Transactions, VoucherTransaction{
+			Kind:       t.Kind,
+			Amount:     priceResponse(t.Amount),
+			Classes:    t.Classes,
+			SignupID:   t.SignUpID,
+			WorkshopID: t.WorkshopID,
+			Note:       t.Note,
+			At:         t.At,
+		})
+	}
+	return resp
+}
+
+func (h VoucherHandler) CreateVoucher(w http.ResponseWriter, r *http.Request) error {
+	var req Voucher
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	v := workshop.Voucher{
+		Code:      workshop.NormalizeCode(req.Code),
+		Kind:      req.Kind,
+		Classes:   req.Classes,
+		ExpiresAt: timeOrZero(req.ExpiresAt),
+		Note:      req.Note,
+	}
+	if req.Balance != nil {
+		v.Balance = req.Balance.money()
+	}
+	if v.Code == "" {
+		code, err := workshop.NewVoucherCode()
+		if err != nil {
+			return err
+		}
+		v.Code = code
+	}
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	v, err := h.workshopRepo.InsertVoucher(v)
+	if err != nil {
+		return err
+	}
+	v, history, err := h.workshopRepo.VoucherByCode(v.Code)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(voucherResponse(v, history))
+}
+
+// GetVoucher looks up the balance of a voucher along with its history.
+func (h VoucherHandler) GetVoucher(w http.ResponseWriter, r *http.Request) error {
+	v, history, err := h.workshopRepo.VoucherByCode(mux.Vars(r)["code"])
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(voucherResponse(v, history))
+}
+
+// VoidVoucher takes the balance left off a voucher so that it can no longer
+// be redeemed, e.g. when it was refunded.
+func (h VoucherHandler) VoidVoucher(w http.ResponseWriter, r *http.Request) {
+	var req VoidVoucherRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, errBadRequest.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	v, err := h.workshopRepo.VoidVoucher(mux.Vars(r)["code"], req.Note)
+	var history []workshop.VoucherTransaction
+	if err == nil {
+		v, history, err = h.workshopRepo.VoucherByCode(v.Code)
+	}
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "voucher not found", http.StatusNotFound)
+	case err == workshop.ErrVoucherVoided:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		json.NewEncoder(w).Encode(voucherResponse(v, history))
+	}
+}
+
+func (h VoucherHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "GET":
+		err = h.GetVoucher(w, r)
+	case "POST":
+		err = h.CreateVoucher(w, r)
+	default:
+		http.Error(w, "not a valid request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == workshop.ErrInvalidVoucher:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == sql.ErrNoRows:
+		http.Error(w, "voucher not found", http.StatusNotFound)
+	case err == repository.ErrDuplicateVoucher:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
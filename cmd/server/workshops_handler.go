@@ -1,74 +1,259 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/payment"
 	"github.com/workshop/lib/repository"
 	"github.com/workshop/lib/workshop"
 )
 
 type WorkshopHandler struct {
 	workshopRepo repository.WorkshopDB
+	mailer       Mailer
+	payments     payment.Provider
+	// checkoutReturnURL is where participants land after the checkout.
+	checkoutReturnURL string
 }
 
 type WorkshopListResponse struct {
-	Workshops []workshop.Workshop `json:"workshops"`
+	Workshops []Workshop `json:"workshops"`
+	// NextCursor fetches the next page; it is left out on the last one.
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type Workshop struct {
 	WorkshopID  string `json:"workshop_id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
-	Time        string `json:"time"`
-	Duration    string `json:"duration"`
-	Caption     string `json:"caption"`
-	Cost        string `json:"cost"`
-	Cap         int    `json:"cap"`
-	IsFull      bool   `json:"isFull"`
-	Location    string `json:"location"`
-	Level       string `json:"level"`
+	// StartTime and EndTime are RFC 3339 with an explicit offset. They are
+	// emitted in the venue's Timezone.
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Timezone  string    `json:"timezone"`
+	Duration  string    `json:"duration"`
+	Caption   string    `json:"caption"`
+	// Price is the standard price. CurrentPrice is the cheapest tier anyone
+	// can book right now and is filled in on responses only.
+	Price          Price       `json:"price"`
+	CurrentPrice   *PriceTier  `json:"currentPrice,omitempty"`
+	PriceTiers     []PriceTier `json:"priceTiers,omitempty"`
+	Cap            int         `json:"cap"`
+	SpotsLeft      int         `json:"spotsLeft"`
+	IsFull         bool        `json:"isFull"`
+	WaitlistLength int         `json:"waitlistLength"`
+	Location       string      `json:"location"`
+	RoomID         int         `json:"roomId,omitempty"`
+	Level          string      `json:"level"`
+	SeriesID       string      `json:"series_id,omitempty"`
+	Sessions       []Session   `json:"sessions,omitempty"`
+	// CancelDeadline is the last moment participants can cancel their own
+	// signup; it is left out when there is none.
+	CancelDeadline *time.Time `json:"cancelDeadline,omitempty"`
+	// RefundPolicy defaults to a full refund until the workshop starts.
+	RefundPolicy *RefundPolicy `json:"refundPolicy,omitempty"`
+	// Deposit is taken at booking and the rest is due by BalanceDueAt.
+	// ReleaseUnpaid releases seats still unpaid then instead of flagging
+	// them.
+	Deposit       *Price     `json:"deposit,omitempty"`
+	BalanceDueAt  *time.Time `json:"balanceDueAt,omitempty"`
+	ReleaseUnpaid bool       `json:"releaseUnpaid,omitempty"`
+	CancelledAt   *time.Time `json:"cancelledAt,omitempty"`
+	// InstructorIDs assigns instructors on requests; responses embed
+	// Instructors instead.
+	InstructorIDs []int               `json:"instructorIds,omitempty"`
+	Instructors   []InstructorSummary `json:"instructors,omitempty"`
+	// CategoryIDs files the workshop under categories on requests;
+	// responses embed Categories instead.
+	CategoryIDs []int             `json:"categoryIds,omitempty"`
+	Categories  []CategorySummary `json:"categories,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+}
+
+// Session is one meeting of a workshop. Location is left empty on requests
+// for sessions held at the workshop's location.
+type Session struct {
+	ID        int       `json:"id,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Location  string    `json:"location,omitempty"`
 }
 
 func createWorkshop(w Workshop) (workshop.Workshop, error) {
-	//TODO validate workshop entry later
-	return workshop.Workshop{
-		WorkshopID:  w.WorkshopID,
-		Name:        w.Name,
-		Caption:     w.Caption,
-		Description: w.Description,
-		Time:        w.Time,
-		Cost:        w.Cost,
-		Cap:         w.Cap,
-		Location:    w.Location,
-		Level:       w.Level,
-	}, nil
-
-}
-
-// Get all workshops that start after TODAY
+	ws := workshop.Workshop{
+		WorkshopID:     w.WorkshopID,
+		Name:           w.Name,
+		Caption:        w.Caption,
+		Description:    w.Description,
+		StartTime:      w.StartTime.UTC(),
+		EndTime:        w.EndTime.UTC(),
+		Timezone:       w.Timezone,
+		Price:          w.Price.money(),
+		PriceTiers:     priceTiers(w.PriceTiers),
+		Cap:            w.Cap,
+		Location:       w.Location,
+		RoomID:         w.RoomID,
+		Level:          w.Level,
+		CancelDeadline: timeOrZero(w.CancelDeadline),
+		RefundPolicy:   refundPolicy(w.RefundPolicy),
+		BalanceDueAt:   timeOrZero(w.BalanceDueAt),
+		ReleaseUnpaid:  w.ReleaseUnpaid,
+		InstructorIDs:  w.InstructorIDs,
+		CategoryIDs:    w.CategoryIDs,
+		Tags:           w.Tags,
+	}
+	if w.Deposit != nil {
+		ws.Deposit = money.New(w.Deposit.Amount, ws.Price.Currency)
+	}
+	for _, s := range w.Sessions {
+		ws.Sessions = append(ws.Sessions, workshop.Session{StartTime: s.StartTime, EndTime: s.EndTime, Location: s.Location})
+	}
+	if err := ws.ValidatePriceTiers(); err != nil {
+		return ws, err
+	}
+	if err := ws.RefundPolicy.Validate(); err != nil {
+		return ws, err
+	}
+	if err := validateSessions(&ws); err != nil {
+		return ws, err
+	}
+	if err := ws.ValidateCancelDeadline(); err != nil {
+		return ws, err
+	}
+	if err := ws.ValidateInstructors(); err != nil {
+		return ws, err
+	}
+	if err := validateTaxonomy(&ws.CategoryIDs, &ws.Tags); err != nil {
+		return ws, err
+	}
+	return ws, ws.ValidateDeposit()
+}
+
+// validateTaxonomy checks the categories of a workshop or event and
+// normalizes its tags.
+func validateTaxonomy(categoryIDs *[]int, tags *[]string) error {
+	if err := workshop.ValidateCategoryIDs(*categoryIDs); err != nil {
+		return err
+	}
+	normalized, err := workshop.NormalizeTags(*tags)
+	*tags = normalized
+	return err
+}
+
+// validateSessions checks the times of ws and, when it has sessions, sets
+// its start and end time to span them.
+func validateSessions(ws *workshop.Workshop) error {
+	if len(ws.Sessions) > 0 {
+		if err := workshop.ValidateSessions(ws.Sessions); err != nil {
+			return err
+		}
+		ws.SetSessions(ws.Sessions)
+	}
+	return workshop.ValidateTimes(ws.StartTime, ws.EndTime, ws.Timezone)
+}
+
+func workshopResponse(w workshop.Workshop) Workshop {
+	current := priceTierResponse(w.PriceFor(time.Now(), nil))
+	resp := Workshop{
+		WorkshopID:     w.WorkshopID,
+		Name:           w.Name,
+		Description:    w.Description,
+		StartTime:      w.LocalStart(),
+		EndTime:        w.LocalEnd(),
+		Timezone:       workshopTimezone(w.Timezone),
+		Duration:       formatDuration(w.Duration()),
+		Price:          priceResponse(w.Price),
+		CurrentPrice:   &current,
+		PriceTiers:     priceTierResponses(w.PriceTiers),
+		Cap:            w.Cap,
+		SpotsLeft:      w.SpotsLeft,
+		IsFull:         w.IsFull,
+		WaitlistLength: w.WaitlistLength,
+		Caption:        w.Caption,
+		Location:       w.Location,
+		RoomID:         w.RoomID,
+		Level:          w.Level,
+		SeriesID:       w.SeriesID,
+		Sessions:       sessionResponses(w),
+		CancelDeadline: timeOrNil(w.CancelDeadline),
+		RefundPolicy:   refundPolicyResponse(w.RefundPolicy),
+		BalanceDueAt:   timeOrNil(w.BalanceDueAt),
+		ReleaseUnpaid:  w.ReleaseUnpaid,
+		CancelledAt:    timeOrNil(w.CancelledAt),
+		Instructors:    instructorSummaries(w.Instructors),
+		Categories:     categorySummaries(w.Categories),
+		Tags:           w.Tags,
+	}
+	if w.TakesDeposit() {
+		deposit := priceResponse(w.Deposit)
+		resp.Deposit = &deposit
+	}
+	return resp
+}
+
+func sessionResponses(w workshop.Workshop) []Session {
+	var resp []Session
+	for _, s := range w.SessionList() {
+		resp = append(resp, Session{
+			ID:        s.ID,
+			StartTime: s.LocalStart(w.Timezone),
+			EndTime:   s.LocalEnd(w.Timezone),
+			Location:  w.SessionLocation(s),
+		})
+	}
+	return resp
+}
+
+func workshopResponses(workshops []workshop.Workshop) []Workshop {
+	var resp []Workshop
+	for _, w := range workshops {
+		resp = append(resp, workshopResponse(w))
+	}
+	return resp
+}
+
+// formatDuration renders d as hours and minutes, e.g. "2h30m".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := d / time.Hour
+	m := (d - h*time.Hour) / time.Minute
+	return fmt.Sprintf("%dh%02dm", h, m)
+}
+
+// isValidationError reports whether err rejects the times, prices,
+// instructors, room, categories or tags of a workshop or event, or holds it
+// while the studio is closed.
+func isValidationError(err error) bool {
+	if _, ok := err.(*workshop.ClosedError); ok {
+		return true
+	}
+	switch err {
+	case workshop.ErrInvalidTimes, workshop.ErrInvalidTimezone, workshop.ErrInvalidSessions, workshop.ErrInvalidPrice, workshop.ErrInvalidPriceTier, workshop.ErrInvalidRefundPolicy, workshop.ErrInvalidDeposit, workshop.ErrInvalidCancelDeadline, workshop.ErrInvalidInstructors, repository.ErrUnknownInstructor, workshop.ErrOverRoomCapacity, repository.ErrUnknownRoom, workshop.ErrInvalidCategories, repository.ErrUnknownCategory, workshop.ErrInvalidTags:
+		return true
+	}
+	return false
+}
+
+// GetWorkshops lists a page of the upcoming workshops, narrowed and sorted
+// by the query parameters.
 func (h WorkshopHandler) GetWorkshops(w http.ResponseWriter, r *http.Request) error {
-	workshops, err := h.workshopRepo.GetWorkshops()
+	filter, page, err := listQuery(r, time.Now())
 	if err != nil {
 		return err
 	}
-	var wsResp []Workshop
-	for _, w := range workshops {
-		wsResp = append(wsResp, Workshop{
-			Name:        w.Name,
-			Description: w.Description,
-			Time:        w.Time,
-			Duration:    "", //TODO deal with this later
-			Cost:        w.Cost,
-			Cap:         w.Cap,
-			Caption:     w.Caption,
-			Location:    w.Location,
-			Level:       w.Level,
-		})
+	workshops, next, err := h.workshopRepo.ListWorkshops(filter, page)
+	if err != nil {
+		return err
 	}
-	resp := WorkshopListResponse{Workshops: workshops}
+	resp := WorkshopListResponse{Workshops: workshopResponses(workshops), NextCursor: next}
 	json.NewEncoder(w).Encode(resp)
 	return nil
 }
@@ -80,7 +265,10 @@ func (h WorkshopHandler) CreateWorkshop(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 	defer r.Body.Close()
-	ws, _ := createWorkshop(workshop)
+	ws, err := createWorkshop(workshop)
+	if err != nil {
+		return err
+	}
 	if err := h.workshopRepo.InsertWorkshop(ws); err != nil {
 		return err
 	}
@@ -90,21 +278,85 @@ func (h WorkshopHandler) CreateWorkshop(w http.ResponseWriter, r *http.Request)
 
 }
 
+// UpdateWorkshop replaces a workshop with the request, which takes the same
+// fields as CreateWorkshop.
 func (h WorkshopHandler) UpdateWorkshop(w http.ResponseWriter, r *http.Request) error {
-	var ws workshop.Workshop
-	err := json.NewDecoder(r.Body).Decode(&ws)
+	var workshop Workshop
+	err := json.NewDecoder(r.Body).Decode(&workshop)
 	if err != nil {
 		return err
 	}
 	defer r.Body.Close()
-	if err = h.workshopRepo.UpdateWorkshop(ws); err != nil {
+	ws, err := createWorkshop(workshop)
+	if err != nil {
+		return err
+	}
+	promoted, err := h.workshopRepo.UpdateWorkshop(ws)
+	if err != nil {
 		return err
 	}
+	settlePromoted(h.workshopRepo, h.mailer, h.payments, h.checkoutReturnURL, ws, promoted)
 	io.WriteString(w, "OK")
 	return nil
 
 }
 
+// Calendar serves the sessions of a workshop as an iCalendar file.
+func (h WorkshopHandler) Calendar(w http.ResponseWriter, r *http.Request) {
+	ws, err := h.workshopRepo.WorkshopByID(mux.Vars(r)["workshop_id"])
+	if err == sql.ErrNoRows {
+		http.Error(w, "workshop not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", ws.WorkshopID+".ics"))
+	io.WriteString(w, workshop.ICalendar(ws, time.Now()))
+}
+
+type CancelWorkshopResponse struct {
+	WorkshopID string   `json:"workshop_id"`
+	Cancelled  int      `json:"cancelled"`
+	Refunds    []Refund `json:"refunds"`
+}
+
+// CancelWorkshop calls a workshop off, cancels its signups and refunds the
+// confirmed ones in full.
+func (h WorkshopHandler) CancelWorkshop(w http.ResponseWriter, r *http.Request) {
+	workshopID := mux.Vars(r)["workshop_id"]
+	cancelled, err := h.workshopRepo.CancelWorkshop(workshopID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "workshop not found", http.StatusNotFound)
+		return
+	}
+	if err == repository.ErrWorkshopCancelled {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ws, err := h.workshopRepo.WorkshopByID(workshopID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := CancelWorkshopResponse{WorkshopID: workshopID, Cancelled: len(cancelled)}
+	refunds := map[int]workshop.Refund{}
+	for _, s := range cancelled {
+		if f := refundCancelled(h.workshopRepo, h.payments, ws, s, workshop.RefundWorkshopCancelled); f.ID != 0 {
+			refunds[s.ID] = f
+			resp.Refunds = append(resp.Refunds, refundResponse(f))
+		}
+	}
+	notifyCancelled(h.mailer, ws, cancelled, refunds)
+	json.NewEncoder(w).Encode(resp)
+}
+
 func (h WorkshopHandler) DeleteWorkshop(w http.ResponseWriter, r *http.Request) error {
 	v := r.URL.Query()
 	workshopID := v.Get("workshop_id")
@@ -120,18 +372,28 @@ func (h WorkshopHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 		err := h.GetWorkshops(w, r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeListError(w, err)
 		}
 		return
 	case "POST":
 		err := h.CreateWorkshop(w, r)
-		if err != nil {
+		if isValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if c, ok := err.(*workshop.RoomConflictError); ok {
+			writeRoomConflict(w, c)
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	case "PUT":
-		err := h.UpdateWorkshop(w, r) //h.UpdateWorkshop(w, r)
-		if err != nil {
+		err := h.UpdateWorkshop(w, r)
+		if err == sql.ErrNoRows {
+			http.Error(w, "workshop not found", http.StatusNotFound)
+		} else if isValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if c, ok := err.(*workshop.RoomConflictError); ok {
+			writeRoomConflict(w, c)
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
@@ -146,3 +408,12 @@ func (h WorkshopHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// workshopTimezone names the zone times are shown in, which is the studio's
+// when none is set.
+func workshopTimezone(name string) string {
+	if name == "" {
+		return workshop.DefaultTimezone
+	}
+	return name
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/repository"
+	"github.com/workshop/lib/workshop"
+)
+
+func TestCreateWorkshopCancelDeadline(t *testing.T) {
+	start := time.Date(2030, 5, 4, 10, 0, 0, 0, time.UTC)
+	req := Workshop{
+		WorkshopID: "deadline",
+		Name:       "Deadline",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Cap:        5,
+	}
+	for _, c := range []struct {
+		deadline time.Time
+		want     error
+	}{
+		{time.Time{}, nil},
+		{start.Add(-24 * time.Hour), nil},
+		{start, workshop.ErrInvalidCancelDeadline},
+		{start.Add(time.Hour), workshop.ErrInvalidCancelDeadline},
+	} {
+		req.CancelDeadline = timeOrNil(c.deadline)
+		ws, err := createWorkshop(req)
+		if err != c.want {
+			t.Errorf("deadline %v: got %v, want %v", c.deadline, err, c.want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !ws.CancelDeadline.Equal(c.deadline) {
+			t.Errorf("deadline %v: stored %v", c.deadline, ws.CancelDeadline)
+		}
+		resp := workshopResponse(ws)
+		if got := timeOrZero(resp.CancelDeadline); !got.Equal(c.deadline) {
+			t.Errorf("deadline %v: responded with %v", c.deadline, got)
+		}
+	}
+}
+
+// TestUpdateWorkshopTakesRequestFields puts a workshop with every field a
+// request can set and checks that each of them is stored.
+func TestUpdateWorkshopTakesRequestFields(t *testing.T) {
+	db := repository.NewMemoryDB()
+	start := time.Date(2030, 5, 4, 10, 0, 0, 0, time.UTC)
+	if err := db.InsertWorkshop(workshop.Workshop{WorkshopID: "update", Name: "Before", StartTime: start, EndTime: start.Add(2 * time.Hour), Cap: 5}); err != nil {
+		t.Fatal(err)
+	}
+	in, err := db.InsertInstructor(workshop.Instructor{Name: "Ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	venue, err := db.InsertVenue(workshop.Venue{Name: "Studio", Address: "Forster Strasse 51, Berlin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	room, err := db.InsertRoom(workshop.Room{VenueID: venue.ID, Name: "Kiln room", Capacity: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat, err := db.InsertCategory(workshop.Category{Name: "Ceramics"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline, due, until := start.Add(-48*time.Hour), start.Add(-72*time.Hour), start.Add(-240*time.Hour)
+	req := Workshop{
+		WorkshopID:     "update",
+		Name:           "After",
+		Description:    "Throwing on the wheel",
+		Caption:        "Wheel",
+		Timezone:       "Europe/Berlin",
+		Price:          Price{Amount: 12000, Currency: "EUR"},
+		PriceTiers:     []PriceTier{{Name: "Early bird", Price: Price{Amount: 9000, Currency: "EUR"}, ValidUntil: &until}},
+		Cap:            6,
+		Location:       "Forster Strasse 51, Berlin",
+		RoomID:         room.ID,
+		Level:          "Beginner",
+		Sessions:       []Session{{StartTime: start, EndTime: start.Add(2 * time.Hour)}, {StartTime: start.Add(24 * time.Hour), EndTime: start.Add(26 * time.Hour)}},
+		CancelDeadline: &deadline,
+		RefundPolicy:   &RefundPolicy{FullDays: 14, PartialDays: 7, PartialPercent: 50},
+		Deposit:        &Price{Amount: 3000},
+		BalanceDueAt:   &due,
+		ReleaseUnpaid:  true,
+		InstructorIDs:  []int{in.ID},
+		CategoryIDs:    []int{cat.ID},
+		Tags:           []string{"Wheel", "clay"},
+	}
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	WorkshopHandler{workshopRepo: db}.ServeHTTP(rec, httptest.NewRequest("PUT", "/workshops", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want 200", rec.Code, rec.Body)
+	}
+	ws, err := db.WorkshopByID("update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := workshopResponse(ws)
+	if got.Name != req.Name || got.Description != req.Description || got.Caption != req.Caption || got.Timezone != req.Timezone || got.Cap != req.Cap || got.Location != req.Location || got.RoomID != room.ID || got.Level != req.Level {
+		t.Errorf("got %+v, want the fields of %+v", got, req)
+	}
+	if ws.Price.Amount != 12000 || len(ws.PriceTiers) != 1 || ws.PriceTiers[0].Price.Amount != 9000 || !ws.PriceTiers[0].ValidUntil.Equal(until) {
+		t.Errorf("got price %v and tiers %+v", ws.Price, ws.PriceTiers)
+	}
+	if len(ws.Sessions) != 2 || !ws.EndTime.Equal(start.Add(26*time.Hour)) {
+		t.Errorf("got sessions %+v ending %v, want two ending %v", ws.Sessions, ws.EndTime, start.Add(26*time.Hour))
+	}
+	if !ws.CancelDeadline.Equal(deadline) || ws.RefundPolicy != (workshop.RefundPolicy{FullDays: 14, PartialDays: 7, PartialPercent: 50}) {
+		t.Errorf("got deadline %v and refund policy %+v", ws.CancelDeadline, ws.RefundPolicy)
+	}
+	if ws.Deposit.Amount != 3000 || ws.Deposit.Currency != "EUR" || !ws.BalanceDueAt.Equal(due) || !ws.ReleaseUnpaid {
+		t.Errorf("got deposit %v due %v, release unpaid %v", ws.Deposit, ws.BalanceDueAt, ws.ReleaseUnpaid)
+	}
+	if len(ws.Instructors) != 1 || ws.Instructors[0].ID != in.ID || len(ws.Categories) != 1 || ws.Categories[0].ID != cat.ID || fmt.Sprint(ws.Tags) != "[clay wheel]" {
+		t.Errorf("got instructors %+v, categories %+v and tags %v", ws.Instructors, ws.Categories, ws.Tags)
+	}
+
+	req.WorkshopID = "missing"
+	body, _ = json.Marshal(req)
+	rec = httptest.NewRecorder()
+	WorkshopHandler{workshopRepo: db}.ServeHTTP(rec, httptest.NewRequest("PUT", "/workshops", bytes.NewReader(body)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("updating a missing workshop: got %d %s, want 404", rec.Code, rec.Body)
+	}
+}
@@ -1,10 +1,10 @@
-INSERT INTO workshop.events VALUES(1, 'jabpf83', 'test1', 'Altman had added that YC is always slightly broken, because we’re always trying to grow; wre always trying to do new things. And while he didn’t offer specifics on what new things YC might try,  today, the outfit is taking the wraps off one of those initiatives: a new growth-stage program designed to help both YC companies and non-YC companies figure out how to scale.', '2018-07-21 23:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
-INSERT INTO workshop.events VALUES(2, 'kerjbpf83', 'test2', 'The idea is partly to address what YC companies have described to YC as a thinning of its network over time, in part because there simply aren’t as many companies that make it to the growth stage. YC estimates that of the more than 1,200 active YC companies in the world today, about 60 or so employ more than 100 people.', '2018-07-21 23:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
-INSERT INTO workshop.events VALUES(3, 'jaE666b3', 'test3', 'YC also sees an opportunity to work with companies that are too busy trying to keep the wheels on the track to think much about the big picture. Some of the questions that founders tell them they could use help with are how to recruit engineers at scale, and how to accelerate user growth and acquisition systematically.', '2018-07-21 23:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
-INSERT INTO workshop.events VALUES(4, '111hwbpf83', 'test4', 'So what does the program entail? Seemingly not more than busy, growth-stage CEOs can handle. The idea is to work with 15 companies two times a year — in spring and fall — largely by bringing them together for weekly dinners where a variety of specific themes will be addressed.', '2010-07-21 23:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
-INSERT INTO workshop.events VALUES(5, 'ja203pf83', 'test5', 'According to partners Ali Rowghani and Anu Hariharan, who oversee YC’s two-year-old, later-stage Continuity Fund, admission will mostly be open to companies with 50 to 100 employees with strong market fit, and the program will be free to those selected.', '2018-08-21 23:29:05','14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
-INSERT INTO workshop.events VALUES(6, '938wbpf83', 'test6', 'YC hasn’t responded to questions yet about whether Continuity Fund might get first crack at these companies’ next funding rounds, but we’d guess that there’s no formal agreement between YC and the startups. (Of course, if YC builds good will with these founders and they turn to the Continuity Fund in the future, all the better, presumably.)', '2018-07-21 23:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
-INSERT INTO workshop.events VALUES(7, 'jaEvv83', 'test7', 'YC also sees an opportunity to work with companies that are too busy trying to keep the wheels on the track to think much about the big picture. Some of the questions that founders tell them they could use help with are how to recruit engineers at scale, and how to accelerate user growth and acquisition systematically.', '2018-07-21 23:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
-INSERT INTO workshop.events VALUES(8, '452pf83', 'test8', 'Listeners will now be greeted by featured playlists including Hip Hop Supreme and DJ mixset-focused In The Mix, the Spotify Discover Weekly-esque personalized tracklist The Upload, and algorithmically generated More Of What You Like and Artists You Should Know. There’s also New & Hot charts and Top 50 charts playlists, Fresh Pressed for new album releases, and editorially selected collections like SoundCloud Next Wave and Playback.', '2018-07-21 23:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
-INSERT INTO workshop.events VALUES(9, 'jpf83', 'test9', 'With funding and a leaner operation giving SoundCloud some runway after years of sluggish performance, it’s up to Trainor to give SoundCloud some momentum. Interface changes are an easy way to start, though a deeper repositioning of SoundCloud around indie creators that its competitors lack will be important.', '2018-07-21 23:29:05',  '14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
-INSERT INTO workshop.events VALUES(10, 'fwbpf83', 'test10', 'What differentiates SoundCloud is our catalog of over 170 million tracks, and new home lets us elevate and celebrate the incredible talent that drives the SoundCloud experience', '2018-07-21 23:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 29.50, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('jabpf83', 'test1', 'Altman had added that YC is always slightly broken, because we’re always trying to grow; wre always trying to do new things. And while he didn’t offer specifics on what new things YC might try,  today, the outfit is taking the wraps off one of those initiatives: a new growth-stage program designed to help both YC companies and non-YC companies figure out how to scale.', '2018-07-21 23:29:05', '2018-07-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('kerjbpf83', 'test2', 'The idea is partly to address what YC companies have described to YC as a thinning of its network over time, in part because there simply aren’t as many companies that make it to the growth stage. YC estimates that of the more than 1,200 active YC companies in the world today, about 60 or so employ more than 100 people.', '2018-07-21 23:29:05', '2018-07-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('jaE666b3', 'test3', 'YC also sees an opportunity to work with companies that are too busy trying to keep the wheels on the track to think much about the big picture. Some of the questions that founders tell them they could use help with are how to recruit engineers at scale, and how to accelerate user growth and acquisition systematically.', '2018-07-21 23:29:05', '2018-07-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('111hwbpf83', 'test4', 'So what does the program entail? Seemingly not more than busy, growth-stage CEOs can handle. The idea is to work with 15 companies two times a year — in spring and fall — largely by bringing them together for weekly dinners where a variety of specific themes will be addressed.', '2010-07-21 23:29:05', '2010-07-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('ja203pf83', 'test5', 'According to partners Ali Rowghani and Anu Hariharan, who oversee YC’s two-year-old, later-stage Continuity Fund, admission will mostly be open to companies with 50 to 100 employees with strong market fit, and the program will be free to those selected.', '2018-08-21 23:29:05', '2018-08-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('938wbpf83', 'test6', 'YC hasn’t responded to questions yet about whether Continuity Fund might get first crack at these companies’ next funding rounds, but we’d guess that there’s no formal agreement between YC and the startups. (Of course, if YC builds good will with these founders and they turn to the Continuity Fund in the future, all the better, presumably.)', '2018-07-21 23:29:05', '2018-07-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('jaEvv83', 'test7', 'YC also sees an opportunity to work with companies that are too busy trying to keep the wheels on the track to think much about the big picture. Some of the questions that founders tell them they could use help with are how to recruit engineers at scale, and how to accelerate user growth and acquisition systematically.', '2018-07-21 23:29:05', '2018-07-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('452pf83', 'test8', 'Listeners will now be greeted by featured playlists including Hip Hop Supreme and DJ mixset-focused In The Mix, the Spotify Discover Weekly-esque personalized tracklist The Upload, and algorithmically generated More Of What You Like and Artists You Should Know. There’s also New & Hot charts and Top 50 charts playlists, Fresh Pressed for new album releases, and editorially selected collections like SoundCloud Next Wave and Playback.', '2018-07-21 23:29:05', '2018-07-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('jpf83', 'test9', 'With funding and a leaner operation giving SoundCloud some runway after years of sluggish performance, it’s up to Trainor to give SoundCloud some momentum. Interface changes are an easy way to start, though a deeper repositioning of SoundCloud around indie creators that its competitors lack will be important.', '2018-07-21 23:29:05', '2018-07-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
+INSERT INTO workshop.events (event_id, name, description, start_time, end_time, created_at, updated_at, price_amount, location) VALUES('fwbpf83', 'test10', 'What differentiates SoundCloud is our catalog of over 170 million tracks, and new home lets us elevate and celebrate the incredible talent that drives the SoundCloud experience', '2018-07-21 23:29:05', '2018-07-22 01:29:05', '14-12-12 21:49:43', '14-12-12 21:50:43', 2950, 'ForesterStrasse 51, Berlin');
@@ -0,0 +1,310 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Migration is one versioned step of the workshop schema. Up and Down hold
+// one statement per entry since the mysql driver runs a single statement per
+// Exec.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// Checksum identifies the contents of a migration so that edits to an
+// already applied step are caught on boot.
+func (m Migration) Checksum() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n", m.Version, m.Name)
+	for _, s := range m.Up {
+		fmt.Fprintf(h, "up\n%s\n", s)
+	}
+	for _, s := range m.Down {
+		fmt.Fprintf(h, "down\n%s\n", s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Applied is a row of the schema_migrations bookkeeping table.
+type Applied struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// DriftError lists every way the live database differs from the schema the
+// code expects.
+type DriftError struct {
+	Problems []string
+}
+
+func (e DriftError) Error() string {
+	return "schema drift detected: " + strings.Join(e.Problems, "; ")
+}
+
+const createBookkeeping = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INT NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	checksum CHAR(64) NOT NULL,
+	applied_at DATETIME NOT NULL,
+	PRIMARY KEY(version)
+) engine=InnoDB`
+
+func sorted() []Migration {
+	ms := make([]Migration, len(All))
+	copy(ms, All)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms
+}
+
+// Status returns the applied migrations ordered by version.
+func Status(db *sql.DB) ([]Applied, error) {
+	var applied []Applied
+	rows, err := db.Query("SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return applied, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a Applied
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return applied, err
+		}
+		applied = append(applied, a)
+	}
+	return applied, rows.Err()
+}
+
+// Up applies every migration that has not been applied yet, in version
+// order. mysql commits DDL implicitly, so a step that fails halfway has to be
+// repaired by hand; the bookkeeping row is only written once all of its
+// statements succeeded.
+func Up(db *sql.DB) error {
+	if _, err := db.Exec(createBookkeeping); err != nil {
+		return err
+	}
+	applied, err := Status(db)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		if applied, err = adopt(db); err != nil {
+			return err
+		}
+	}
+	done := make(map[int]Applied)
+	for _, a := range applied {
+		done[a.Version] = a
+	}
+	for _, m := range sorted() {
+		if a, ok := done[m.Version]; ok {
+			if a.Checksum != m.Checksum() {
+				return fmt.Errorf("migration %d (%s) was changed after it was applied", m.Version, m.Name)
+			}
+			continue
+		}
+		for _, stmt := range m.Up {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("migration %d (%s): %v", m.Version, m.Name, err)
+			}
+		}
+		if _, err := db.Exec(
+			"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, UTC_TIMESTAMP())",
+			m.Version,
+			m.Name,
+			m.Checksum(),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyColumns are the columns of migration 1 that databases created
+// before schema_migrations may lack, with the definition they are added with.
+var legacyColumns = map[string][][2]string{
+	"workshops": {
+		{"time", "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{"start_time", "DATETIME NULL"},
+		{"end_time", "DATETIME NULL"},
+		{"caption", "VARCHAR(1024) NOT NULL DEFAULT ''"},
+	},
+	"events": {
+		{"time", "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{"start_time", "DATETIME NULL"},
+		{"end_time", "DATETIME NULL"},
+		{"caption", "VARCHAR(1024) NOT NULL DEFAULT ''"},
+	},
+	"signups": {{"message", "VARCHAR(1024) NOT NULL DEFAULT ''"}},
+}
+
+// adopt brings a database that predates schema_migrations to the shape of
+// migration 1 and records that step as applied, so that Up carries on from
+// step 2. Migration 1 only creates missing tables and would otherwise be
+// recorded without touching the legacy ones.
+//
+// Legacy databases come in two shapes: the one the code used, with a
+// free-form time column, and the one of the old fixtures/init.sql, with
+// start_time and end_time DATETIME columns. Both end up with all three
+// columns, as migration 1 creates them, and keep whatever times they had;
+// migration 6 prefers start_time and end_time over the time string.
+// A database without a workshops table is new and left to migration 1.
+func adopt(db *sql.DB) ([]Applied, error) {
+	columns, err := liveColumns(db, "workshops")
+	if err != nil || len(columns) == 0 {
+		return nil, err
+	}
+	for _, table := range []string{"workshops", "events", "signups"} {
+		columns, err := liveColumns(db, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("adopting legacy schema: table %s is missing", table)
+		}
+		for _, stmt := range adoptStatements(table, columns) {
+			if _, err := db.Exec(stmt); err != nil {
+				return nil, fmt.Errorf("adopting legacy schema: %v", err)
+			}
+		}
+	}
+	m := sorted()[0]
+	if _, err := db.Exec(
+		"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, UTC_TIMESTAMP())",
+		m.Version,
+		m.Name,
+		m.Checksum(),
+	); err != nil {
+		return nil, err
+	}
+	log.Printf("adopted legacy schema as migration %d (%s)", m.Version, m.Name)
+	return Status(db)
+}
+
+// adoptStatements returns the statements that turn a legacy table with the
+// given columns into its shape as of migration 1. Nothing is dropped.
+func adoptStatements(table string, columns map[string]bool) []string {
+	var stmts []string
+	for _, c := range legacyColumns[table] {
+		if !columns[c[0]] {
+			stmts = append(stmts, "ALTER TABLE "+table+" ADD COLUMN "+c[0]+" "+c[1])
+		}
+	}
+	return stmts
+}
+
+// Down reverts the last steps applied migrations, newest first.
+func Down(db *sql.DB, steps int) error {
+	applied, err := Status(db)
+	if err != nil {
+		return err
+	}
+	known := make(map[int]Migration)
+	for _, m := range All {
+		known[m.Version] = m
+	}
+	for i := len(applied) - 1; i >= 0 && steps > 0; i, steps = i-1, steps-1 {
+		a := applied[i]
+		m, ok := known[a.Version]
+		if !ok {
+			return fmt.Errorf("migration %d (%s) is unknown to this build", a.Version, a.Name)
+		}
+		if a.Checksum != m.Checksum() {
+			return fmt.Errorf("migration %d (%s) was changed after it was applied", m.Version, m.Name)
+		}
+		for _, stmt := range m.Down {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("reverting migration %d (%s): %v", m.Version, m.Name, err)
+			}
+		}
+		if _, err := db.Exec("DELETE FROM schema_migrations WHERE version = ?", a.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify compares the live database with the migrations and the expected
+// Schema, and returns a DriftError describing any difference.
+func Verify(db *sql.DB) error {
+	var problems []string
+
+	applied, err := Status(db)
+	if err != nil {
+		return DriftError{Problems: []string{fmt.Sprintf("cannot read schema_migrations (%v), run the migrations first", err)}}
+	}
+	done := make(map[int]bool)
+	known := make(map[int]Migration)
+	for _, m := range All {
+		known[m.Version] = m
+	}
+	for _, a := range applied {
+		done[a.Version] = true
+		m, ok := known[a.Version]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("migration %d (%s) is applied but unknown to this build", a.Version, a.Name))
+		case m.Checksum() != a.Checksum:
+			problems = append(problems, fmt.Sprintf("migration %d (%s) checksum mismatch", a.Version, a.Name))
+		}
+	}
+	for _, m := range sorted() {
+		if !done[m.Version] {
+			problems = append(problems, fmt.Sprintf("migration %d (%s) is pending", m.Version, m.Name))
+		}
+	}
+
+	tables := make([]string, 0, len(Schema))
+	for table := range Schema {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		columns, err := liveColumns(db, table)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			problems = append(problems, fmt.Sprintf("table %s is missing", table))
+			continue
+		}
+		for _, c := range Schema[table] {
+			if !columns[c] {
+				problems = append(problems, fmt.Sprintf("column %s.%s is missing", table, c))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return DriftError{Problems: problems}
+	}
+	return nil
+}
+
+func liveColumns(db *sql.DB, table string) (map[string]bool, error) {
+	columns := make(map[string]bool)
+	rows, err := db.Query("SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?", table)
+	if err != nil {
+		return columns, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return columns, err
+		}
+		columns[strings.ToLower(c)] = true
+	}
+	return columns, rows.Err()
+}
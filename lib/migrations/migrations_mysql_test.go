@@ -0,0 +1,202 @@
+//go:build mysql
+// +build mysql
+
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// scratchDB creates an empty database next to the one in MYSQL_TEST_DNS and
+// drops it when the test is done.
+func scratchDB(t *testing.T) *sql.DB {
+	dns := os.Getenv("MYSQL_TEST_DNS")
+	if dns == "" {
+		t.Skip("MYSQL_TEST_DNS not set")
+	}
+	cfg, err := mysql.ParseDSN(dns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := sql.Open("mysql", dns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DBName = fmt.Sprintf("workshop_adopt_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + cfg.DBName); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		admin.Exec("DROP DATABASE " + cfg.DBName)
+		admin.Close()
+	})
+	return db
+}
+
+// downBelow rolls db back to before migration version.
+func downBelow(t *testing.T, db *sql.DB, version int) {
+	steps := 0
+	for _, m := range sorted() {
+		if m.Version >= version {
+			steps++
+		}
+	}
+	if err := Down(db, steps); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAdoptInitSQLSchemaMySQL adopts a database created by the old
+// fixtures/init.sql and checks that its start and end times survive every
+// migration.
+func TestAdoptInitSQLSchemaMySQL(t *testing.T) {
+	db := scratchDB(t)
+	for _, stmt := range []string{
+		`CREATE TABLE workshops (
+			id INT NOT NULL AUTO_INCREMENT,
+			workshop_id VARCHAR(255) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			description VARCHAR(1024) NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			created_at DATETIME,
+			updated_at DATETIME,
+			cap INT UNSIGNED NOT NULL,
+			cost DECIMAL(10, 2) NOT NULL,
+			location VARCHAR(255) NOT NULL,
+			level VARCHAR(255) NOT NULL,
+			PRIMARY KEY(id),
+			INDEX(name),
+			INDEX(start_time),
+			CONSTRAINT name_workshop_id UNIQUE(workshop_id, name)
+		) engine=InnoDB`,
+		`CREATE TABLE events (
+			id INT NOT NULL AUTO_INCREMENT,
+			event_id VARCHAR(255) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			description VARCHAR(1024) NOT NULL,
+			start_time DATETIME NOT NULL,
+			created_at DATETIME,
+			updated_at DATETIME,
+			cost DECIMAL(10, 2) NOT NULL,
+			location VARCHAR(255) NOT NULL,
+			PRIMARY KEY(id),
+			INDEX(event_id),
+			INDEX(name),
+			INDEX(start_time),
+			CONSTRAINT name_event_id UNIQUE(event_id, name)
+		) engine=InnoDB`,
+		`CREATE TABLE signups (
+			id INT NOT NULL AUTO_INCREMENT,
+			workshop_id VARCHAR(255) NOT NULL,
+			first_name VARCHAR(255) NOT NULL,
+			last_name VARCHAR(255) NOT NULL,
+			email VARCHAR(255) NOT NULL,
+			created_at DATETIME,
+			updated_at DATETIME,
+			PRIMARY KEY(id),
+			FOREIGN KEY(workshop_id) REFERENCES workshops(workshop_id) ON DELETE CASCADE,
+			INDEX(workshop_id),
+			CONSTRAINT name_email_workshop UNIQUE(first_name, workshop_id, email)
+		) engine=InnoDB`,
+		// A four hour workshop in Berlin summer time, UTC+2.
+		`INSERT INTO workshops (workshop_id, name, description, start_time, end_time, created_at, updated_at, cap, cost, location, level)
+			VALUES ('long', 'Long', '', '2018-07-21 10:00:00', '2018-07-21 14:00:00', NOW(), NOW(), 15, 29.50, 'Berlin', 'Advanced')`,
+		`INSERT INTO events (event_id, name, description, start_time, created_at, updated_at, cost, location)
+			VALUES ('talk', 'Talk', '', '2018-12-01 19:00:00', NOW(), NOW(), 0, 'Berlin')`,
+		`INSERT INTO signups (workshop_id, first_name, last_name, email, created_at, updated_at)
+			VALUES ('long', 'Ada', 'Lovelace', 'ada@example.com', NOW(), NOW())`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Up(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(db); err != nil {
+		t.Fatal(err)
+	}
+	var start, end time.Time
+	if err := db.QueryRow("SELECT start_time, end_time FROM workshops WHERE workshop_id = 'long'").Scan(&start, &end); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2018, 7, 21, 8, 0, 0, 0, time.UTC); !start.Equal(want) || !end.Equal(want.Add(4*time.Hour)) {
+		t.Errorf("workshop runs %v to %v, want %v for four hours", start, end, want)
+	}
+	if err := db.QueryRow("SELECT start_time, end_time FROM events WHERE event_id = 'talk'").Scan(&start, &end); err != nil {
+		t.Fatal(err)
+	}
+	// Legacy events had no end time.
+	if want := time.Date(2018, 12, 1, 18, 0, 0, 0, time.UTC); !start.Equal(want) || !end.Equal(want.Add(2*time.Hour)) {
+		t.Errorf("event runs %v to %v, want %v for two hours", start, end, want)
+	}
+	// Sessions are derived from the workshop times by a later step.
+	if err := db.QueryRow("SELECT end_time FROM workshop_sessions WHERE workshop_id = 'long'").Scan(&end); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2018, 7, 21, 12, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Errorf("session ends %v, want %v", end, want)
+	}
+}
+
+// TestDiscountCodeScopesMySQL moves the comma separated scopes of a code
+// into the join tables and back.
+func TestDiscountCodeScopesMySQL(t *testing.T) {
+	db := scratchDB(t)
+	if err := Up(db); err != nil {
+		t.Fatal(err)
+	}
+	downBelow(t, db, 23)
+	var categoryID int64
+	res, err := db.Exec("INSERT INTO categories (name, slug, description, created_at, updated_at) VALUES ('Glazing', 'glazing', '', NOW(), NOW())")
+	if err == nil {
+		categoryID, err = res.LastInsertId()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(
+		"INSERT INTO discount_codes (code, kind, percent, workshop_ids, category_ids, created_at) VALUES ('SPRING', 'percent', 10, 'wheel,glaze,wheel', ?, NOW())",
+		fmt.Sprintf("%d,%d", categoryID, categoryID+1),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := Up(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(db); err != nil {
+		t.Fatal(err)
+	}
+	var workshops, categories string
+	if err := db.QueryRow("SELECT GROUP_CONCAT(workshop_id ORDER BY workshop_id) FROM discount_code_workshops WHERE code = 'SPRING'").Scan(&workshops); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT GROUP_CONCAT(category_id) FROM discount_code_categories WHERE code = 'SPRING'").Scan(&categories); err != nil {
+		t.Fatal(err)
+	}
+	// The category deleted before the migration is dropped.
+	if workshops != "glaze,wheel" || categories != fmt.Sprint(categoryID) {
+		t.Errorf("got workshops %s and categories %s, want glaze,wheel and %d", workshops, categories, categoryID)
+	}
+
+	downBelow(t, db, 23)
+	if err := db.QueryRow("SELECT workshop_ids, category_ids FROM discount_codes WHERE code = 'SPRING'").Scan(&workshops, &categories); err != nil {
+		t.Fatal(err)
+	}
+	if workshops != "glaze,wheel" || categories != fmt.Sprint(categoryID) {
+		t.Errorf("rolled back to workshops %s and categories %s, want glaze,wheel and %d", workshops, categories, categoryID)
+	}
+}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAdoptStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		columns []string
+		want    []string
+	}{
+		{
+			name:    "init.sql workshops",
+			table:   "workshops",
+			columns: []string{"id", "workshop_id", "name", "description", "start_time", "end_time", "created_at", "updated_at", "cap", "cost", "location", "level"},
+			want: []string{
+				"ALTER TABLE workshops ADD COLUMN time VARCHAR(255) NOT NULL DEFAULT ''",
+				"ALTER TABLE workshops ADD COLUMN caption VARCHAR(1024) NOT NULL DEFAULT ''",
+			},
+		},
+		{
+			name:    "init.sql events",
+			table:   "events",
+			columns: []string{"id", "event_id", "name", "description", "start_time", "created_at", "updated_at", "cost", "location"},
+			want: []string{
+				"ALTER TABLE events ADD COLUMN time VARCHAR(255) NOT NULL DEFAULT ''",
+				"ALTER TABLE events ADD COLUMN end_time DATETIME NULL",
+				"ALTER TABLE events ADD COLUMN caption VARCHAR(1024) NOT NULL DEFAULT ''",
+			},
+		},
+		{
+			name:    "free-form time",
+			table:   "workshops",
+			columns: []string{"id", "workshop_id", "name", "description", "created_at", "updated_at", "cap", "location", "level", "time", "cost", "caption"},
+			want: []string{
+				"ALTER TABLE workshops ADD COLUMN start_time DATETIME NULL",
+				"ALTER TABLE workshops ADD COLUMN end_time DATETIME NULL",
+			},
+		},
+		{
+			name:    "signups",
+			table:   "signups",
+			columns: []string{"id", "workshop_id", "first_name", "last_name", "email", "created_at", "updated_at"},
+			want:    []string{"ALTER TABLE signups ADD COLUMN message VARCHAR(1024) NOT NULL DEFAULT ''"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns := make(map[string]bool)
+			for _, c := range tt.columns {
+				columns[c] = true
+			}
+			got := adoptStatements(tt.table, columns)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			for _, stmt := range got {
+				if strings.Contains(stmt, "DROP") {
+					t.Errorf("adoption drops a column: %s", stmt)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,852 @@
+package migrations
+
+// All is the ordered history of the workshop schema. Never edit a step that
+// has shipped; add a new one instead.
+var All = []Migration{
+	{
+		// Databases that predate schema_migrations already have these
+		// tables; Up adopts them instead of running this step. start_time
+		// and end_time are only set on tables adopted from the shape of
+		// the old fixtures/init.sql; migration 6 fills in the rest.
+		Version: 1,
+		Name:    "initial schema",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS workshops (
+				id INT NOT NULL AUTO_INCREMENT,
+				workshop_id VARCHAR(255) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				description VARCHAR(1024) NOT NULL,
+				created_at DATETIME,
+				updated_at DATETIME,
+				cap INT UNSIGNED NOT NULL,
+				location VARCHAR(255) NOT NULL,
+				level VARCHAR(255) NOT NULL,
+				time VARCHAR(255) NOT NULL,
+				start_time DATETIME NULL,
+				end_time DATETIME NULL,
+				cost DECIMAL(10, 2) NOT NULL,
+				caption VARCHAR(1024) NOT NULL DEFAULT '',
+				PRIMARY KEY(id),
+				INDEX(name),
+				CONSTRAINT name_workshop_id UNIQUE(workshop_id, name)
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS events (
+				id INT NOT NULL AUTO_INCREMENT,
+				event_id VARCHAR(255) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				description VARCHAR(1024) NOT NULL,
+				created_at DATETIME,
+				updated_at DATETIME,
+				location VARCHAR(255) NOT NULL,
+				time VARCHAR(255) NOT NULL,
+				start_time DATETIME NULL,
+				end_time DATETIME NULL,
+				cost DECIMAL(10, 2) NOT NULL,
+				caption VARCHAR(1024) NOT NULL DEFAULT '',
+				PRIMARY KEY(id),
+				INDEX(event_id),
+				INDEX(name),
+				CONSTRAINT name_event_id UNIQUE(event_id, name)
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS signups (
+				id INT NOT NULL AUTO_INCREMENT,
+				workshop_id VARCHAR(255) NOT NULL,
+				first_name VARCHAR(255) NOT NULL,
+				last_name VARCHAR(255) NOT NULL,
+				email VARCHAR(255) NOT NULL,
+				created_at DATETIME,
+				updated_at DATETIME,
+				message VARCHAR(1024) NOT NULL DEFAULT '',
+				PRIMARY KEY(id),
+				FOREIGN KEY(workshop_id) REFERENCES workshops(workshop_id) ON DELETE CASCADE,
+				INDEX(workshop_id),
+				CONSTRAINT name_email_workshop UNIQUE(first_name, workshop_id, email)
+			) engine=InnoDB`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS signups`,
+			`DROP TABLE IF EXISTS events`,
+			`DROP TABLE IF EXISTS workshops`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "signup status for waitlist",
+		Up: []string{
+			`ALTER TABLE signups ADD COLUMN status VARCHAR(32) NOT NULL DEFAULT 'confirmed'`,
+			`ALTER TABLE signups ADD INDEX workshop_status (workshop_id, status)`,
+		},
+		Down: []string{
+			`ALTER TABLE signups DROP INDEX workshop_status`,
+			`ALTER TABLE signups DROP COLUMN status`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "self-service cancellation",
+		Up: []string{
+			`ALTER TABLE workshops ADD COLUMN cancel_deadline DATETIME NULL`,
+			`ALTER TABLE signups ADD COLUMN cancelled_at DATETIME NULL`,
+		},
+		Down: []string{
+			`ALTER TABLE signups DROP COLUMN cancelled_at`,
+			`ALTER TABLE workshops DROP COLUMN cancel_deadline`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "signup status history",
+		Up: []string{
+			`CREATE TABLE signup_transitions (
+				id INT NOT NULL AUTO_INCREMENT,
+				signup_id INT NOT NULL,
+				from_status VARCHAR(32) NOT NULL,
+				to_status VARCHAR(32) NOT NULL,
+				at DATETIME NOT NULL,
+				PRIMARY KEY(id),
+				FOREIGN KEY(signup_id) REFERENCES signups(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+			`INSERT INTO signup_transitions (signup_id, from_status, to_status, at)
+				SELECT id, '', status, COALESCE(created_at, UTC_TIMESTAMP()) FROM signups`,
+		},
+		Down: []string{
+			`DROP TABLE signup_transitions`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "signup counters on workshops",
+		Up: []string{
+			`ALTER TABLE workshops
+				ADD COLUMN signup_count INT NOT NULL DEFAULT 0,
+				ADD COLUMN waitlist_count INT NOT NULL DEFAULT 0`,
+			`UPDATE workshops w SET
+				signup_count = (SELECT count(*) FROM signups s WHERE s.workshop_id = w.workshop_id AND s.status IN ('pending', 'confirmed', 'attended', 'no_show')),
+				waitlist_count = (SELECT count(*) FROM signups s WHERE s.workshop_id = w.workshop_id AND s.status = 'waitlisted')`,
+		},
+		Down: []string{
+			`ALTER TABLE workshops DROP COLUMN signup_count, DROP COLUMN waitlist_count`,
+		},
+	},
+	{
+		// Legacy times were Berlin wall clock times. The start_time and
+		// end_time adopted from init.sql-shaped databases win over the
+		// free-form time strings; strings that do not parse fall back to
+		// the row's creation time, and end times default to two hours
+		// after the start only where there is no later one.
+		Version: 6,
+		Name:    "start and end times in utc",
+		Up: []string{
+			`ALTER TABLE workshops ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'Europe/Berlin'`,
+			`UPDATE workshops SET
+				end_time = COALESCE(CONVERT_TZ(end_time, 'Europe/Berlin', '+00:00'), end_time),
+				start_time = CASE
+					WHEN start_time IS NOT NULL THEN COALESCE(CONVERT_TZ(start_time, 'Europe/Berlin', '+00:00'), start_time)
+					WHEN time REGEXP '^[0-9]{4}-[0-9]{2}-[0-9]{2} [0-9]{2}:[0-9]{2}:[0-9]{2}$'
+					THEN COALESCE(CONVERT_TZ(CAST(time AS DATETIME), 'Europe/Berlin', '+00:00'), CAST(time AS DATETIME))
+					ELSE created_at END`,
+			`UPDATE workshops SET end_time = start_time + INTERVAL 2 HOUR WHERE end_time IS NULL OR end_time <= start_time`,
+			`ALTER TABLE workshops
+				MODIFY start_time DATETIME NOT NULL,
+				MODIFY end_time DATETIME NOT NULL,
+				DROP COLUMN time`,
+			`ALTER TABLE events ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'Europe/Berlin'`,
+			`UPDATE events SET
+				end_time = COALESCE(CONVERT_TZ(end_time, 'Europe/Berlin', '+00:00'), end_time),
+				start_time = CASE
+					WHEN start_time IS NOT NULL THEN COALESCE(CONVERT_TZ(start_time, 'Europe/Berlin', '+00:00'), start_time)
+					WHEN time REGEXP '^[0-9]{4}-[0-9]{2}-[0-9]{2} [0-9]{2}:[0-9]{2}:[0-9]{2}$'
+					THEN COALESCE(CONVERT_TZ(CAST(time AS DATETIME), 'Europe/Berlin', '+00:00'), CAST(time AS DATETIME))
+					ELSE created_at END`,
+			`UPDATE events SET end_time = start_time + INTERVAL 2 HOUR WHERE end_time IS NULL OR end_time <= start_time`,
+			`ALTER TABLE events
+				MODIFY start_time DATETIME NOT NULL,
+				MODIFY end_time DATETIME NOT NULL,
+				DROP COLUMN time`,
+		},
+		// Reverting goes back to Berlin wall clock times in both the time
+		// string and the columns of migration 1, so that Up can run again.
+		Down: []string{
+			`ALTER TABLE workshops ADD COLUMN time VARCHAR(255) NULL`,
+			`UPDATE workshops SET time = DATE_FORMAT(COALESCE(CONVERT_TZ(start_time, '+00:00', timezone), start_time), '%Y-%m-%d %H:%i:%s')`,
+			`ALTER TABLE workshops MODIFY time VARCHAR(255) NOT NULL, MODIFY start_time DATETIME NULL, MODIFY end_time DATETIME NULL, DROP COLUMN timezone`,
+			`UPDATE workshops SET
+				start_time = COALESCE(CONVERT_TZ(start_time, '+00:00', 'Europe/Berlin'), start_time),
+				end_time = COALESCE(CONVERT_TZ(end_time, '+00:00', 'Europe/Berlin'), end_time)`,
+			`ALTER TABLE events ADD COLUMN time VARCHAR(255) NULL`,
+			`UPDATE events SET time = DATE_FORMAT(COALESCE(CONVERT_TZ(start_time, '+00:00', timezone), start_time), '%Y-%m-%d %H:%i:%s')`,
+			`ALTER TABLE events MODIFY time VARCHAR(255) NOT NULL, MODIFY start_time DATETIME NULL, MODIFY end_time DATETIME NULL, DROP COLUMN timezone`,
+			`UPDATE events SET
+				start_time = COALESCE(CONVERT_TZ(start_time, '+00:00', 'Europe/Berlin'), start_time),
+				end_time = COALESCE(CONVERT_TZ(end_time, '+00:00', 'Europe/Berlin'), end_time)`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "workshop series",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS workshop_series (
+				id INT NOT NULL AUTO_INCREMENT,
+				series_id VARCHAR(255) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				description VARCHAR(1024) NOT NULL,
+				caption VARCHAR(1024) NOT NULL DEFAULT '',
+				cap INT UNSIGNED NOT NULL,
+				cost DECIMAL(10, 2) NOT NULL,
+				location VARCHAR(255) NOT NULL,
+				level VARCHAR(255) NOT NULL,
+				start_time DATETIME NOT NULL,
+				end_time DATETIME NOT NULL,
+				timezone VARCHAR(64) NOT NULL DEFAULT 'Europe/Berlin',
+				rrule VARCHAR(255) NOT NULL,
+				exdates TEXT NOT NULL,
+				created_at DATETIME,
+				updated_at DATETIME,
+				PRIMARY KEY(id),
+				CONSTRAINT series_id UNIQUE(series_id)
+			) engine=InnoDB`,
+			`ALTER TABLE workshops
+				ADD COLUMN series_id VARCHAR(255) NULL,
+				ADD INDEX series_start (series_id, start_time)`,
+		},
+		Down: []string{
+			`ALTER TABLE workshops DROP INDEX series_start, DROP COLUMN series_id`,
+			`DROP TABLE IF EXISTS workshop_series`,
+		},
+	},
+	{
+		Version: 8,
+		Name:    "workshop sessions and attendance",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS workshop_sessions (
+				id INT NOT NULL AUTO_INCREMENT,
+				workshop_id VARCHAR(255) NOT NULL,
+				position INT NOT NULL,
+				start_time DATETIME NOT NULL,
+				end_time DATETIME NOT NULL,
+				location VARCHAR(255) NOT NULL DEFAULT '',
+				PRIMARY KEY(id),
+				FOREIGN KEY(workshop_id) REFERENCES workshops(workshop_id) ON DELETE CASCADE,
+				INDEX workshop_position (workshop_id, position)
+			) engine=InnoDB`,
+			`INSERT INTO workshop_sessions (workshop_id, position, start_time, end_time)
+				SELECT workshop_id, 1, start_time, end_time FROM workshops`,
+			`CREATE TABLE IF NOT EXISTS session_attendance (
+				session_id INT NOT NULL,
+				signup_id INT NOT NULL,
+				attended TINYINT(1) NOT NULL,
+				recorded_at DATETIME NOT NULL,
+				PRIMARY KEY(session_id, signup_id),
+				FOREIGN KEY(session_id) REFERENCES workshop_sessions(id) ON DELETE CASCADE,
+				FOREIGN KEY(signup_id) REFERENCES signups(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS session_attendance`,
+			`DROP TABLE IF EXISTS workshop_sessions`,
+		},
+	},
+	{
+		// Prices move from DECIMAL euros to integer minor units. Existing
+		// signups are taken to have paid the standard price.
+		Version: 9,
+		Name:    "money in minor units and price tiers",
+		Up: []string{
+			`ALTER TABLE workshops
+				ADD COLUMN price_amount BIGINT NOT NULL DEFAULT 0,
+				ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'EUR'`,
+			`UPDATE workshops SET price_amount = ROUND(cost * 100)`,
+			`ALTER TABLE workshops DROP COLUMN cost`,
+			`ALTER TABLE events
+				ADD COLUMN price_amount BIGINT NOT NULL DEFAULT 0,
+				ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'EUR'`,
+			`UPDATE events SET price_amount = ROUND(cost * 100)`,
+			`ALTER TABLE events DROP COLUMN cost`,
+			`ALTER TABLE workshop_series
+				ADD COLUMN price_amount BIGINT NOT NULL DEFAULT 0,
+				ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'EUR'`,
+			`UPDATE workshop_series SET price_amount = ROUND(cost * 100)`,
+			`ALTER TABLE workshop_series DROP COLUMN cost`,
+			`ALTER TABLE signups
+				ADD COLUMN price_amount BIGINT NOT NULL DEFAULT 0,
+				ADD COLUMN price_currency CHAR(3) NOT NULL DEFAULT 'EUR',
+				ADD COLUMN price_tier VARCHAR(64) NOT NULL DEFAULT ''`,
+			`UPDATE signups s JOIN workshops w ON w.workshop_id = s.workshop_id
+				SET s.price_amount = w.price_amount, s.price_currency = w.currency, s.price_tier = 'standard'`,
+			`CREATE TABLE IF NOT EXISTS workshop_price_tiers (
+				id INT NOT NULL AUTO_INCREMENT,
+				workshop_id VARCHAR(255) NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				price_amount BIGINT NOT NULL,
+				currency CHAR(3) NOT NULL,
+				valid_from DATETIME NULL,
+				valid_until DATETIME NULL,
+				eligibility VARCHAR(32) NOT NULL DEFAULT '',
+				PRIMARY KEY(id),
+				FOREIGN KEY(workshop_id) REFERENCES workshops(workshop_id) ON DELETE CASCADE,
+				CONSTRAINT workshop_tier_name UNIQUE(workshop_id, name)
+			) engine=InnoDB`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS workshop_price_tiers`,
+			`ALTER TABLE signups DROP COLUMN price_amount, DROP COLUMN price_currency, DROP COLUMN price_tier`,
+			`ALTER TABLE workshop_series ADD COLUMN cost DECIMAL(10, 2) NOT NULL DEFAULT 0`,
+			`UPDATE workshop_series SET cost = price_amount / 100`,
+			`ALTER TABLE workshop_series DROP COLUMN price_amount, DROP COLUMN currency`,
+			`ALTER TABLE events ADD COLUMN cost DECIMAL(10, 2) NOT NULL DEFAULT 0`,
+			`UPDATE events SET cost = price_amount / 100`,
+			`ALTER TABLE events DROP COLUMN price_amount, DROP COLUMN currency`,
+			`ALTER TABLE workshops ADD COLUMN cost DECIMAL(10, 2) NOT NULL DEFAULT 0`,
+			`UPDATE workshops SET cost = price_amount / 100`,
+			`ALTER TABLE workshops DROP COLUMN price_amount, DROP COLUMN currency`,
+		},
+	},
+	{
+		Version: 10,
+		Name:    "discount codes",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS discount_codes (
+				id INT NOT NULL AUTO_INCREMENT,
+				code VARCHAR(64) NOT NULL,
+				kind VARCHAR(16) NOT NULL,
+				percent INT NOT NULL DEFAULT 0,
+				amount BIGINT NOT NULL DEFAULT 0,
+				currency CHAR(3) NOT NULL DEFAULT 'EUR',
+				max_redemptions INT NOT NULL DEFAULT 0,
+				redemptions INT NOT NULL DEFAULT 0,
+				expires_at DATETIME NULL,
+				workshop_ids TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY(id),
+				CONSTRAINT discount_code UNIQUE(code)
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS discount_redemptions (
+				id INT NOT NULL AUTO_INCREMENT,
+				code VARCHAR(64) NOT NULL,
+				signup_id INT NOT NULL,
+				workshop_id VARCHAR(255) NOT NULL,
+				amount BIGINT NOT NULL,
+				currency CHAR(3) NOT NULL,
+				redeemed_at DATETIME NOT NULL,
+				PRIMARY KEY(id),
+				INDEX redemption_code (code),
+				FOREIGN KEY(signup_id) REFERENCES signups(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+			`ALTER TABLE signups
+				ADD COLUMN discount_code VARCHAR(64) NOT NULL DEFAULT '',
+				ADD COLUMN discount_amount BIGINT NOT NULL DEFAULT 0`,
+		},
+		Down: []string{
+			`ALTER TABLE signups DROP COLUMN discount_code, DROP COLUMN discount_amount`,
+			`DROP TABLE IF EXISTS discount_redemptions`,
+			`DROP TABLE IF EXISTS discount_codes`,
+		},
+	},
+	{
+		Version: 11,
+		Name:    "payments",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS payments (
+				id INT NOT NULL AUTO_INCREMENT,
+				signup_id INT NOT NULL,
+				provider VARCHAR(32) NOT NULL,
+				session_id VARCHAR(255) NOT NULL,
+				amount BIGINT NOT NULL,
+				currency CHAR(3) NOT NULL,
+				status VARCHAR(16) NOT NULL,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY(id),
+				CONSTRAINT payment_session UNIQUE(provider, session_id),
+				FOREIGN KEY(signup_id) REFERENCES signups(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS payments`,
+		},
+	},
+	{
+		Version: 12,
+		Name:    "seat holds",
+		Up: []string{
+			`ALTER TABLE signups
+				ADD COLUMN hold_expires_at DATETIME NULL,
+				ADD INDEX status_hold (status, hold_expires_at)`,
+		},
+		Down: []string{
+			`ALTER TABLE signups DROP INDEX status_hold, DROP COLUMN hold_expires_at`,
+		},
+	},
+	{
+		Version: 13,
+		Name:    "refunds and workshop cancellation",
+		Up: []string{
+			`ALTER TABLE workshops
+				ADD COLUMN refund_full_days INT NOT NULL DEFAULT 0,
+				ADD COLUMN refund_partial_days INT NOT NULL DEFAULT 0,
+				ADD COLUMN refund_partial_percent INT NOT NULL DEFAULT 0,
+				ADD COLUMN cancelled_at DATETIME NULL`,
+			`CREATE TABLE IF NOT EXISTS refunds (
+				id INT NOT NULL AUTO_INCREMENT,
+				signup_id INT NOT NULL,
+				workshop_id VARCHAR(255) NOT NULL,
+				payment_id INT NULL,
+				method VARCHAR(16) NOT NULL,
+				provider_refund_id VARCHAR(255) NOT NULL DEFAULT '',
+				amount BIGINT NOT NULL,
+				currency CHAR(3) NOT NULL,
+				reason VARCHAR(32) NOT NULL,
+				note TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY(id),
+				INDEX refund_workshop (workshop_id),
+				FOREIGN KEY(signup_id) REFERENCES signups(id) ON DELETE CASCADE,
+				FOREIGN KEY(payment_id) REFERENCES payments(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS refunds`,
+			`ALTER TABLE workshops
+				DROP COLUMN refund_full_days,
+				DROP COLUMN refund_partial_days,
+				DROP COLUMN refund_partial_percent,
+				DROP COLUMN cancelled_at`,
+		},
+	},
+	{
+		Version: 14,
+		Name:    "invoices",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS invoice_sequences (
+				year INT NOT NULL,
+				last INT NOT NULL,
+				PRIMARY KEY(year)
+			) engine=InnoDB`,
+			// Invoices are snapshots and must be kept even when the workshop
+			// or signup they bill is deleted, so there are no foreign keys.
+			`CREATE TABLE IF NOT EXISTS invoices (
+				id INT NOT NULL AUTO_INCREMENT,
+				number VARCHAR(32) NOT NULL,
+				signup_id INT NOT NULL,
+				workshop_id VARCHAR(255) NOT NULL,
+				issued_at DATETIME NOT NULL,
+				service_date DATETIME NOT NULL,
+				seller_name VARCHAR(255) NOT NULL,
+				seller_address TEXT NOT NULL,
+				seller_email VARCHAR(255) NOT NULL,
+				seller_vat_id VARCHAR(32) NOT NULL,
+				seller_tax_number VARCHAR(32) NOT NULL,
+				buyer_name VARCHAR(255) NOT NULL,
+				buyer_email VARCHAR(255) NOT NULL,
+				buyer_company VARCHAR(255) NOT NULL,
+				buyer_address TEXT NOT NULL,
+				description TEXT NOT NULL,
+				vat_rate INT NOT NULL,
+				net_amount BIGINT NOT NULL,
+				vat_amount BIGINT NOT NULL,
+				gross_amount BIGINT NOT NULL,
+				currency CHAR(3) NOT NULL,
+				PRIMARY KEY(id),
+				UNIQUE KEY invoice_number (number),
+				UNIQUE KEY invoice_signup (signup_id),
+				INDEX invoice_workshop (workshop_id)
+			) engine=InnoDB`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS invoices`,
+			`DROP TABLE IF EXISTS invoice_sequences`,
+		},
+	},
+	{
+		Version: 15,
+		Name:    "gift vouchers and class passes",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS vouchers (
+				id INT NOT NULL AUTO_INCREMENT,
+				code VARCHAR(64) NOT NULL,
+				kind VARCHAR(16) NOT NULL,
+				balance_amount BIGINT NOT NULL DEFAULT 0,
+				currency CHAR(3) NOT NULL,
+				classes INT NOT NULL DEFAULT 0,
+				expires_at DATETIME NULL,
+				voided_at DATETIME NULL,
+				note TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY(id),
+				UNIQUE KEY voucher_code (code)
+			) engine=InnoDB`,
+			// The history outlives the signups it mentions.
+			`CREATE TABLE IF NOT EXISTS voucher_transactions (
+				id INT NOT NULL AUTO_INCREMENT,
+				code VARCHAR(64) NOT NULL,
+				kind VARCHAR(16) NOT NULL,
+				amount BIGINT NOT NULL,
+				currency CHAR(3) NOT NULL,
+				classes INT NOT NULL,
+				signup_id INT NULL,
+				workshop_id VARCHAR(255) NOT NULL DEFAULT '',
+				note TEXT NOT NULL,
+				at DATETIME NOT NULL,
+				PRIMARY KEY(id),
+				INDEX voucher_tx_signup (signup_id),
+				FOREIGN KEY(code) REFERENCES vouchers(code) ON DELETE CASCADE
+			) engine=InnoDB`,
+			`ALTER TABLE signups
+				ADD COLUMN voucher_code VARCHAR(64) NOT NULL DEFAULT '',
+				ADD COLUMN voucher_amount BIGINT NOT NULL DEFAULT 0`,
+		},
+		Down: []string{
+			`ALTER TABLE signups
+				DROP COLUMN voucher_code,
+				DROP COLUMN voucher_amount`,
+			`DROP TABLE IF EXISTS voucher_transactions`,
+			`DROP TABLE IF EXISTS vouchers`,
+		},
+	},
+	{
+		Version: 16,
+		Name:    "deposits and balances",
+		Up: []string{
+			`ALTER TABLE workshops
+				ADD COLUMN deposit_amount BIGINT NOT NULL DEFAULT 0,
+				ADD COLUMN balance_due_at DATETIME NULL,
+				ADD COLUMN release_unpaid BOOLEAN NOT NULL DEFAULT FALSE`,
+			`ALTER TABLE signups
+				ADD COLUMN amount_paid BIGINT NOT NULL DEFAULT 0,
+				ADD COLUMN balance_reminded_at DATETIME NULL,
+				ADD COLUMN balance_overdue_at DATETIME NULL`,
+			`UPDATE signups s SET amount_paid = (
+				SELECT COALESCE(SUM(p.amount), 0) FROM payments p WHERE p.signup_id = s.id AND p.status = 'paid'
+			)`,
+		},
+		Down: []string{
+			`ALTER TABLE signups
+				DROP COLUMN amount_paid,
+				DROP COLUMN balance_reminded_at,
+				DROP COLUMN balance_overdue_at`,
+			`ALTER TABLE workshops
+				DROP COLUMN deposit_amount,
+				DROP COLUMN balance_due_at,
+				DROP COLUMN release_unpaid`,
+		},
+	},
+	{
+		Version: 17,
+		Name:    "instructors",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS instructors (
+				id INT NOT NULL AUTO_INCREMENT,
+				name VARCHAR(255) NOT NULL,
+				bio TEXT NOT NULL,
+				photo_key VARCHAR(1024) NOT NULL DEFAULT '',
+				email VARCHAR(255) NOT NULL DEFAULT '',
+				phone VARCHAR(64) NOT NULL DEFAULT '',
+				website VARCHAR(1024) NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY(id)
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS workshop_instructors (
+				workshop_id VARCHAR(255) NOT NULL,
+				instructor_id INT NOT NULL,
+				position INT NOT NULL,
+				PRIMARY KEY(workshop_id, instructor_id),
+				INDEX workshop_instructors_instructor (instructor_id),
+				FOREIGN KEY(workshop_id) REFERENCES workshops(workshop_id) ON DELETE CASCADE,
+				FOREIGN KEY(instructor_id) REFERENCES instructors(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS workshop_instructors`,
+			`DROP TABLE IF EXISTS instructors`,
+		},
+	},
+	{
+		Version: 18,
+		Name:    "venues and rooms",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS venues (
+				id INT NOT NULL AUTO_INCREMENT,
+				name VARCHAR(255) NOT NULL,
+				address VARCHAR(1024) NOT NULL,
+				accessibility_notes TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY(id)
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS rooms (
+				id INT NOT NULL AUTO_INCREMENT,
+				venue_id INT NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				capacity INT NOT NULL,
+				accessibility_notes TEXT NOT NULL,
+				PRIMARY KEY(id),
+				FOREIGN KEY(venue_id) REFERENCES venues(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+			// Rooms that are booked cannot be deleted.
+			`ALTER TABLE workshops
+				ADD COLUMN room_id INT NULL,
+				ADD CONSTRAINT workshops_room FOREIGN KEY (room_id) REFERENCES rooms(id)`,
+			`ALTER TABLE events
+				ADD COLUMN room_id INT NULL,
+				ADD CONSTRAINT events_room FOREIGN KEY (room_id) REFERENCES rooms(id)`,
+		},
+		Down: []string{
+			`ALTER TABLE events
+				DROP FOREIGN KEY events_room,
+				DROP COLUMN room_id`,
+			`ALTER TABLE workshops
+				DROP FOREIGN KEY workshops_room,
+				DROP COLUMN room_id`,
+			`DROP TABLE IF EXISTS rooms`,
+			`DROP TABLE IF EXISTS venues`,
+		},
+	},
+	{
+		Version: 19,
+		Name:    "opening hours and closures",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS opening_hours (
+				id INT NOT NULL AUTO_INCREMENT,
+				weekday TINYINT NOT NULL,
+				opens_minute INT NOT NULL,
+				closes_minute INT NOT NULL,
+				PRIMARY KEY(id)
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS closures (
+				id INT NOT NULL AUTO_INCREMENT,
+				from_date DATE NOT NULL,
+				until_date DATE NOT NULL,
+				reason VARCHAR(255) NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY(id),
+				INDEX closures_until (until_date)
+			) engine=InnoDB`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS closures`,
+			`DROP TABLE IF EXISTS opening_hours`,
+		},
+	},
+	{
+		Version: 20,
+		Name:    "categories and tags",
+		Up: []string{
+			// Categories with subcategories cannot be deleted.
+			`CREATE TABLE IF NOT EXISTS categories (
+				id INT NOT NULL AUTO_INCREMENT,
+				parent_id INT NULL,
+				name VARCHAR(255) NOT NULL,
+				slug VARCHAR(255) NOT NULL,
+				description TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY(id),
+				UNIQUE KEY category_slug (slug),
+				FOREIGN KEY(parent_id) REFERENCES categories(id)
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS tags (
+				id INT NOT NULL AUTO_INCREMENT,
+				name VARCHAR(50) NOT NULL,
+				PRIMARY KEY(id),
+				UNIQUE KEY tag_name (name)
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS workshop_categories (
+				workshop_id VARCHAR(255) NOT NULL,
+				category_id INT NOT NULL,
+				PRIMARY KEY(workshop_id, category_id),
+				INDEX workshop_categories_category (category_id),
+				FOREIGN KEY(workshop_id) REFERENCES workshops(workshop_id) ON DELETE CASCADE,
+				FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS event_categories (
+				event_id VARCHAR(255) NOT NULL,
+				category_id INT NOT NULL,
+				PRIMARY KEY(event_id, category_id),
+				INDEX event_categories_category (category_id),
+				FOREIGN KEY(event_id) REFERENCES events(event_id) ON DELETE CASCADE,
+				FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS workshop_tags (
+				workshop_id VARCHAR(255) NOT NULL,
+				tag_id INT NOT NULL,
+				PRIMARY KEY(workshop_id, tag_id),
+				INDEX workshop_tags_tag (tag_id),
+				FOREIGN KEY(workshop_id) REFERENCES workshops(workshop_id) ON DELETE CASCADE,
+				FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS event_tags (
+				event_id VARCHAR(255) NOT NULL,
+				tag_id INT NOT NULL,
+				PRIMARY KEY(event_id, tag_id),
+				INDEX event_tags_tag (tag_id),
+				FOREIGN KEY(event_id) REFERENCES events(event_id) ON DELETE CASCADE,
+				FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+			) engine=InnoDB`,
+			`ALTER TABLE discount_codes ADD COLUMN category_ids VARCHAR(1024) NOT NULL DEFAULT ''`,
+		},
+		Down: []string{
+			`ALTER TABLE discount_codes DROP COLUMN category_ids`,
+			`DROP TABLE IF EXISTS event_tags`,
+			`DROP TABLE IF EXISTS workshop_tags`,
+			`DROP TABLE IF EXISTS event_categories`,
+			`DROP TABLE IF EXISTS workshop_categories`,
+			`DROP TABLE IF EXISTS tags`,
+			`DROP TABLE IF EXISTS categories`,
+		},
+	},
+	{
+		Version: 21,
+		Name:    "credit note numbers",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS credit_note_sequences (
+				year INT NOT NULL,
+				last INT NOT NULL,
+				PRIMARY KEY(year)
+			) engine=InnoDB`,
+			`ALTER TABLE refunds ADD COLUMN number VARCHAR(32) NOT NULL DEFAULT ''`,
+			// Credit notes already issued keep the numbers they went out
+			// with, which were taken from the refund ID; new ones continue
+			// after the highest of them in each year.
+			`UPDATE refunds SET number = CONCAT('CN-', YEAR(created_at), '-', LPAD(id, 5, '0'))`,
+			`INSERT INTO credit_note_sequences (year, last)
+				SELECT YEAR(created_at), MAX(id) FROM refunds GROUP BY YEAR(created_at)`,
+			`ALTER TABLE refunds ADD UNIQUE KEY refund_number (number)`,
+		},
+		Down: []string{
+			`ALTER TABLE refunds DROP INDEX refund_number, DROP COLUMN number`,
+			`DROP TABLE IF EXISTS credit_note_sequences`,
+		},
+	},
+	{
+		Version: 22,
+		Name:    "series rooms",
+		Up: []string{
+			`ALTER TABLE workshop_series
+				ADD COLUMN room_id INT NULL,
+				ADD CONSTRAINT workshop_series_room FOREIGN KEY (room_id) REFERENCES rooms(id)`,
+			// Series take the room of their latest occurrence, which got it
+			// from the template when the series was created.
+			`UPDATE workshop_series s SET room_id = (
+				SELECT w.room_id FROM workshops w WHERE w.series_id = s.series_id ORDER BY w.start_time DESC LIMIT 1)`,
+		},
+		Down: []string{
+			`ALTER TABLE workshop_series
+				DROP FOREIGN KEY workshop_series_room,
+				DROP COLUMN room_id`,
+		},
+	},
+	{
+		Version: 23,
+		Name:    "discount code scopes",
+		Up: []string{
+			// Workshops have no foreign key: a code restricted to a workshop
+			// that is deleted stays restricted rather than opening up to
+			// every workshop. Categories in use by a code cannot be deleted.
+			`CREATE TABLE IF NOT EXISTS discount_code_workshops (
+				code VARCHAR(64) NOT NULL,
+				workshop_id VARCHAR(255) NOT NULL,
+				PRIMARY KEY(code, workshop_id),
+				FOREIGN KEY(code) REFERENCES discount_codes(code) ON DELETE CASCADE
+			) engine=InnoDB`,
+			`CREATE TABLE IF NOT EXISTS discount_code_categories (
+				code VARCHAR(64) NOT NULL,
+				category_id INT NOT NULL,
+				PRIMARY KEY(code, category_id),
+				INDEX discount_code_categories_category (category_id),
+				FOREIGN KEY(code) REFERENCES discount_codes(code) ON DELETE CASCADE,
+				FOREIGN KEY(category_id) REFERENCES categories(id)
+			) engine=InnoDB`,
+			// Split the comma separated lists, which hold at most a
+			// thousand entries; categories deleted since are left out.
+			`INSERT IGNORE INTO discount_code_workshops (code, workshop_id)
+				SELECT d.code, SUBSTRING_INDEX(SUBSTRING_INDEX(d.workshop_ids, ',', n.n), ',', -1)
+				FROM discount_codes d JOIN ` + listPositions + ` n
+					ON n.n <= 1 + LENGTH(d.workshop_ids) - LENGTH(REPLACE(d.workshop_ids, ',', ''))
+				WHERE d.workshop_ids <> ''`,
+			`INSERT IGNORE INTO discount_code_categories (code, category_id)
+				SELECT d.code, c.id
+				FROM discount_codes d JOIN ` + listPositions + ` n
+					ON n.n <= 1 + LENGTH(d.category_ids) - LENGTH(REPLACE(d.category_ids, ',', ''))
+				JOIN categories c ON c.id = SUBSTRING_INDEX(SUBSTRING_INDEX(d.category_ids, ',', n.n), ',', -1)
+				WHERE d.category_ids <> ''`,
+			`ALTER TABLE discount_codes DROP COLUMN workshop_ids, DROP COLUMN category_ids`,
+		},
+		Down: []string{
+			`ALTER TABLE discount_codes
+				ADD COLUMN workshop_ids TEXT NULL,
+				ADD COLUMN category_ids VARCHAR(1024) NOT NULL DEFAULT ''`,
+			`UPDATE discount_codes d SET
+				workshop_ids = COALESCE((SELECT GROUP_CONCAT(w.workshop_id ORDER BY w.workshop_id) FROM discount_code_workshops w WHERE w.code = d.code), ''),
+				category_ids = COALESCE((SELECT GROUP_CONCAT(c.category_id ORDER BY c.category_id) FROM discount_code_categories c WHERE c.code = d.code), '')`,
+			`ALTER TABLE discount_codes MODIFY workshop_ids TEXT NOT NULL`,
+			`DROP TABLE IF EXISTS discount_code_categories`,
+			`DROP TABLE IF EXISTS discount_code_workshops`,
+		},
+	},
+	{
+		Version: 24,
+		Name:    "refunds outlive signups",
+		Up: []string{
+			// Refunds are accounting records, like invoices: they stay in
+			// the ledger when their workshop, and with it the signup and
+			// payment, is deleted. The keys were left unnamed by migration
+			// 13, so they carry InnoDB's names; their indexes stay.
+			`ALTER TABLE refunds
+				DROP FOREIGN KEY refunds_ibfk_1,
+				DROP FOREIGN KEY refunds_ibfk_2`,
+		},
+		Down: []string{
+			`DELETE FROM refunds
+				WHERE signup_id NOT IN (SELECT id FROM signups)
+				OR payment_id NOT IN (SELECT id FROM payments)`,
+			`ALTER TABLE refunds
+				ADD CONSTRAINT refunds_ibfk_1 FOREIGN KEY(signup_id) REFERENCES signups(id) ON DELETE CASCADE,
+				ADD CONSTRAINT refunds_ibfk_2 FOREIGN KEY(payment_id) REFERENCES payments(id) ON DELETE CASCADE`,
+		},
+	},
+}
+
+// listPositions is a derived table of the numbers 1 to 1000, for splitting
+// comma separated lists with SUBSTRING_INDEX.
+const listPositions = `(SELECT a.d + 10*b.d + 100*c.d + 1 AS n FROM
+	(SELECT 0 AS d UNION ALL SELECT 1 UNION ALL SELECT 2 UNION ALL SELECT 3 UNION ALL SELECT 4 UNION ALL SELECT 5 UNION ALL SELECT 6 UNION ALL SELECT 7 UNION ALL SELECT 8 UNION ALL SELECT 9) a,
+	(SELECT 0 AS d UNION ALL SELECT 1 UNION ALL SELECT 2 UNION ALL SELECT 3 UNION ALL SELECT 4 UNION ALL SELECT 5 UNION ALL SELECT 6 UNION ALL SELECT 7 UNION ALL SELECT 8 UNION ALL SELECT 9) b,
+	(SELECT 0 AS d UNION ALL SELECT 1 UNION ALL SELECT 2 UNION ALL SELECT 3 UNION ALL SELECT 4 UNION ALL SELECT 5 UNION ALL SELECT 6 UNION ALL SELECT 7 UNION ALL SELECT 8 UNION ALL SELECT 9) c)`
+
+// Schema lists the columns the repository reads and writes, per table, as of
+// the latest migration. Verify refuses to boot against a database missing any
+// of them.
+var Schema = map[string][]string{
+	"workshops":                {"id", "workshop_id", "name", "description", "created_at", "updated_at", "cap", "location", "level", "start_time", "end_time", "timezone", "price_amount", "currency", "caption", "cancel_deadline", "refund_full_days", "refund_partial_days", "refund_partial_percent", "deposit_amount", "balance_due_at", "release_unpaid", "cancelled_at", "signup_count", "waitlist_count", "series_id", "room_id"},
+	"events":                   {"id", "event_id", "name", "description", "created_at", "updated_at", "location", "start_time", "end_time", "timezone", "price_amount", "currency", "caption", "room_id"},
+	"workshop_series":          {"id", "series_id", "name", "description", "caption", "cap", "price_amount", "currency", "location", "level", "start_time", "end_time", "timezone", "rrule", "exdates", "created_at", "updated_at", "room_id"},
+	"workshop_sessions":        {"id", "workshop_id", "position", "start_time", "end_time", "location"},
+	"session_attendance":       {"session_id", "signup_id", "attended", "recorded_at"},
+	"workshop_price_tiers":     {"id", "workshop_id", "name", "price_amount", "currency", "valid_from", "valid_until", "eligibility"},
+	"signup_transitions":       {"id", "signup_id", "from_status", "to_status", "at"},
+	"signups":                  {"id", "workshop_id", "first_name", "last_name", "email", "created_at", "updated_at", "message", "status", "cancelled_at", "price_amount", "price_currency", "price_tier", "discount_code", "discount_amount", "hold_expires_at", "voucher_code", "voucher_amount", "amount_paid", "balance_reminded_at", "balance_overdue_at"},
+	"discount_codes":           {"id", "code", "kind", "percent", "amount", "currency", "max_redemptions", "redemptions", "expires_at", "created_at"},
+	"discount_code_workshops":  {"code", "workshop_id"},
+	"discount_code_categories": {"code", "category_id"},
+	"discount_redemptions":     {"id", "code", "signup_id", "workshop_id", "amount", "currency", "redeemed_at"},
+	"refunds":                  {"id", "signup_id", "workshop_id", "payment_id", "method", "provider_refund_id", "amount", "currency", "reason", "note", "number", "created_at"},
+	"invoices":                 {"id", "number", "signup_id", "workshop_id", "issued_at", "service_date", "seller_name", "seller_address", "seller_email", "seller_vat_id", "seller_tax_number", "buyer_name", "buyer_email", "buyer_company", "buyer_address", "description", "vat_rate", "net_amount", "vat_amount", "gross_amount", "currency"},
+	"invoice_sequences":        {"year", "last"},
+	"credit_note_sequences":    {"year", "last"},
+	"vouchers":                 {"id", "code", "kind", "balance_amount", "currency", "classes", "expires_at", "voided_at", "note", "created_at"},
+	"voucher_transactions":     {"id", "code", "kind", "amount", "currency", "classes", "signup_id", "workshop_id", "note", "at"},
+	"instructors":              {"id", "name", "bio", "photo_key", "email", "phone", "website", "created_at", "updated_at"},
+	"workshop_instructors":     {"workshop_id", "instructor_id", "position"},
+	"venues":                   {"id", "name", "address", "accessibility_notes", "created_at", "updated_at"},
+	"rooms":                    {"id", "venue_id", "name", "capacity", "accessibility_notes"},
+	"opening_hours":            {"id", "weekday", "opens_minute", "closes_minute"},
+	"closures":                 {"id", "from_date", "until_date", "reason", "created_at"},
+	"categories":               {"id", "parent_id", "name", "slug", "description", "created_at", "updated_at"},
+	"tags":                     {"id", "name"},
+	"workshop_categories":      {"workshop_id", "category_id"},
+	"event_categories":         {"event_id", "category_id"},
+	"workshop_tags":            {"workshop_id", "tag_id"},
+	"event_tags":               {"event_id", "tag_id"},
+	"payments":                 {"id", "signup_id", "provider", "session_id", "amount", "currency", "status", "created_at", "updated_at"},
+}
@@ -0,0 +1,146 @@
+// Package money represents amounts of money as integer minor units of an ISO
+// 4217 currency, so that prices add up exactly.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts that do not name one.
+const DefaultCurrency = "EUR"
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+)
+
+// currencies are the ISO 4217 codes amounts can be in.
+var currencies = map[string]bool{
+	"EUR": true, "USD": true, "GBP": true, "CHF": true, "DKK": true, "SEK": true,
+	"NOK": true, "PLN": true, "CZK": true, "HUF": true, "CAD": true, "AUD": true,
+	"JPY": true, "KRW": true, "BHD": true, "KWD": true,
+}
+
+// decimals lists the currencies whose minor unit is not a hundredth.
+var decimals = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
+
+// Money is an amount in the minor units of Currency, e.g. cents for EUR.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// New returns amount minor units of currency, or of DefaultCurrency when
+// currency is empty.
+func New(amount int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// ValidCurrency reports whether currency is a supported ISO 4217 code, in
+// upper case.
+func ValidCurrency(currency string) bool {
+	return currencies[currency]
+}
+
+// Decimals is the number of digits after the decimal point of the currency.
+func Decimals(currency string) int {
+	if d, ok := decimals[strings.ToUpper(currency)]; ok {
+		return d
+	}
+	return 2
+}
+
+// Parse reads a decimal amount such as "29.50" in currency. It refuses more
+// digits after the point than the currency has.
+func Parse(s, currency string) (Money, error) {
+	m := New(0, currency)
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	d := Decimals(m.Currency)
+	if whole == "" || len(frac) > d {
+		return m, fmt.Errorf("%v: %q", ErrInvalidAmount, s)
+	}
+	digits := whole + frac + strings.Repeat("0", d-len(frac))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return m, fmt.Errorf("%v: %q", ErrInvalidAmount, s)
+		}
+	}
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return m, fmt.Errorf("%v: %q", ErrInvalidAmount, s)
+	}
+	if neg {
+		amount = -amount
+	}
+	m.Amount = amount
+	return m, nil
+}
+
+// Decimal renders the amount without currency, e.g. "29.50".
+func (m Money) Decimal() string {
+	d := Decimals(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	if d == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	s := fmt.Sprintf("%0*d", d+1, amount)
+	return sign + s[:len(s)-d] + "." + s[len(s)-d:]
+}
+
+// String renders the amount with its currency, e.g. "29.50 EUR".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return m, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(o.Neg())
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Mul returns m times n.
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Less reports whether m is less than o. Amounts in different currencies
+// are not comparable and are never less.
+func (m Money) Less(o Money) bool {
+	return m.Currency == o.Currency && m.Amount < o.Amount
+}
@@ -0,0 +1,187 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a fake callback body.
+const FakeSignatureHeader = "Fake-Signature"
+
+// ErrUnknownSession is returned by Fake for sessions it did not open.
+var ErrUnknownSession = errors.New("unknown checkout session")
+
+// Fake is a Provider that keeps checkouts in memory and never talks to
+// anyone. Tests and local setups drive it by building the callbacks the real
+// provider would send with Webhook, and posting them to the server.
+type Fake struct {
+	mu        sync.Mutex
+	secret    []byte
+	ttl       time.Duration
+	checkouts map[string]Checkout
+	// refunds are keyed by idempotency key.
+	refunds map[string]FakeRefund
+	expired map[string]bool
+	next    int
+	// fail is returned by the next CreateCheckout.
+	fail error
+}
+
+func NewFake(secret string, ttl time.Duration) *Fake {
+	return &Fake{secret: []byte(secret), ttl: ttl, checkouts: make(map[string]Checkout), refunds: make(map[string]FakeRefund), expired: make(map[string]bool)}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) CreateCheckout(c Checkout) (Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail; err != nil {
+		f.fail = nil
+		return Session{}, err
+	}
+	f.next++
+	id := fmt.Sprintf("fake_cs_%d", f.next)
+	f.checkouts[id] = c
+	ttl := f.ttl
+	if c.TTL > 0 {
+		ttl = c.TTL
+	}
+	return Session{ID: id, URL: "https://checkout.invalid/" + id, ExpiresAt: time.Now().Add(ttl).UTC()}, nil
+}
+
+// Checkout returns what session id was opened for.
+func (f *Fake) Checkout(id string) (Checkout, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.checkouts[id]
+	return c, ok
+}
+
+// FailNext makes the next CreateCheckout or Refund fail with err, as if the
+// provider were unavailable.
+func (f *Fake) FailNext(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = err
+}
+
+type fakeEvent struct {
+	SessionID string                 `json:"session_id"`
+	Status    workshop.PaymentStatus `json:"status"`
+	Amount    int64                  `json:"amount"`
+	Currency  string                 `json:"currency"`
+}
+
+// Webhook returns the signed callback reporting that session id reached
+// status, for the full amount of its checkout.
+func (f *Fake) Webhook(id string, status workshop.PaymentStatus) ([]byte, http.Header, error) {
+	c, ok := f.Checkout(id)
+	if !ok {
+		return nil, nil, ErrUnknownSession
+	}
+	return f.WebhookAmount(id, status, c.Amount)
+}
+
+// WebhookAmount is Webhook reporting amount instead of the amount of the
+// checkout.
+func (f *Fake) WebhookAmount(id string, status workshop.PaymentStatus, amount money.Money) ([]byte, http.Header, error) {
+	if _, ok := f.Checkout(id); !ok {
+		return nil, nil, ErrUnknownSession
+	}
+	payload, err := json.Marshal(fakeEvent{SessionID: id, Status: status, Amount: amount.Amount, Currency: amount.Currency})
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, hex.EncodeToString(f.mac(payload)))
+	return payload, header, nil
+}
+
+func (f *Fake) VerifyWebhook(payload []byte, header http.Header, now time.Time) (Event, error) {
+	sig, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(sig, f.mac(payload)) {
+		return Event{}, ErrInvalidSignature
+	}
+	var e fakeEvent
+	if err := json.Unmarshal(payload, &e); err != nil || e.SessionID == "" || !e.Status.Settled() {
+		return Event{}, ErrInvalidEvent
+	}
+	return Event{SessionID: e.SessionID, Status: e.Status, Amount: money.New(e.Amount, e.Currency)}, nil
+}
+
+func (f *Fake) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, f.secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// FakeRefund is a refund issued through Fake.
+type FakeRefund struct {
+	ID        string
+	SessionID string
+	Amount    money.Money
+}
+
+func (f *Fake) Refund(sessionID string, amount money.Money, key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r, ok := f.refunds[key]; ok {
+		return r.ID, nil
+	}
+	if err := f.fail; err != nil {
+		f.fail = nil
+		return "", err
+	}
+	c, ok := f.checkouts[sessionID]
+	if !ok {
+		return "", ErrUnknownSession
+	}
+	if amount.Currency != c.Amount.Currency || amount.Amount > c.Amount.Amount {
+		return "", fmt.Errorf("refund of %v exceeds payment of %v", amount, c.Amount)
+	}
+	f.next++
+	r := FakeRefund{ID: fmt.Sprintf("fake_re_%d", f.next), SessionID: sessionID, Amount: amount}
+	f.refunds[key] = r
+	return r.ID, nil
+}
+
+// Refunds returns the refunds issued, in no particular order.
+func (f *Fake) Refunds() []FakeRefund {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var refunds []FakeRefund
+	for _, r := range f.refunds {
+		refunds = append(refunds, r)
+	}
+	return refunds
+}
+
+func (f *Fake) Expire(sessionID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.checkouts[sessionID]; !ok {
+		return ErrUnknownSession
+	}
+	f.expired[sessionID] = true
+	return nil
+}
+
+// Expired reports whether session id was expired through Expire.
+func (f *Fake) Expired(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.expired[id]
+}
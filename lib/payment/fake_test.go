@@ -0,0 +1,89 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+func TestFakeWebhook(t *testing.T) {
+	f := NewFake("secret", time.Hour)
+	session, err := f.CreateCheckout(Checkout{SignUpID: 1, Amount: money.New(4500, "EUR")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, header, err := f.Webhook(session.ID, workshop.PaymentPaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.VerifyWebhook(payload, header, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Event{SessionID: session.ID, Status: workshop.PaymentPaid, Amount: money.New(4500, "EUR")}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := NewFake("other", time.Hour).VerifyWebhook(payload, header, time.Now()); err != ErrInvalidSignature {
+		t.Errorf("got %v for another secret, want %v", err, ErrInvalidSignature)
+	}
+	if _, _, err := f.Webhook("fake_cs_missing", workshop.PaymentPaid); err != ErrUnknownSession {
+		t.Errorf("got %v for an unknown session, want %v", err, ErrUnknownSession)
+	}
+	payload, header, err = f.Webhook(session.ID, workshop.PaymentOpen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.VerifyWebhook(payload, header, time.Now()); err != ErrInvalidEvent {
+		t.Errorf("got %v for an unsettled status, want %v", err, ErrInvalidEvent)
+	}
+}
+
+func TestFakeRefund(t *testing.T) {
+	f := NewFake("secret", time.Hour)
+	session, err := f.CreateCheckout(Checkout{SignUpID: 1, Amount: money.New(4500, "EUR")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Refund(session.ID, money.New(5000, "EUR"), "too-much"); err == nil {
+		t.Error("refunded more than was paid")
+	}
+	f.FailNext(errors.New("unavailable"))
+	if _, err := f.Refund(session.ID, money.New(2000, "EUR"), "key"); err == nil {
+		t.Error("FailNext did not fail the refund")
+	}
+	first, err := f.Refund(session.ID, money.New(2000, "EUR"), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := f.Refund(session.ID, money.New(2000, "EUR"), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first || len(f.Refunds()) != 1 {
+		t.Errorf("retry with the same key refunded again: %q, %q, %v", first, again, f.Refunds())
+	}
+}
+
+func TestFakeExpire(t *testing.T) {
+	f := NewFake("secret", time.Hour)
+	session, err := f.CreateCheckout(Checkout{SignUpID: 1, Amount: money.New(4500, "EUR")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Expired(session.ID) {
+		t.Fatal("new session is expired")
+	}
+	if err := f.Expire(session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Expired(session.ID) {
+		t.Error("Expire did not expire the session")
+	}
+	if err := f.Expire("fake_cs_missing"); err != ErrUnknownSession {
+		t.Errorf("got %v for an unknown session, want %v", err, ErrUnknownSession)
+	}
+}
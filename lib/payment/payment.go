@@ -0,0 +1,67 @@
+// Package payment takes payments for signups through a checkout page hosted
+// by a payment provider. The provider reports the outcome through signed
+// webhook callbacks, which settle the signup's seat.
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidEvent     = errors.New("invalid webhook event")
+)
+
+// Checkout describes what a participant is asked to pay for one signup.
+type Checkout struct {
+	SignUpID    int
+	WorkshopID  string
+	Description string
+	Email       string
+	Amount      money.Money
+	// SuccessURL and CancelURL are where the provider sends the participant
+	// back to after paying or giving up.
+	SuccessURL string
+	CancelURL  string
+	// TTL is how long the session stays open; zero leaves it to the
+	// provider's default.
+	TTL time.Duration
+}
+
+// Session is a checkout opened at the provider.
+type Session struct {
+	ID string
+	// URL is the provider's payment page for the participant.
+	URL       string
+	ExpiresAt time.Time
+}
+
+// Event is the outcome of a checkout reported by a webhook callback.
+type Event struct {
+	SessionID string
+	// Status is empty for callbacks that do not settle a checkout, which
+	// should be acknowledged and otherwise ignored.
+	Status workshop.PaymentStatus
+	Amount money.Money
+}
+
+// Provider opens checkout sessions and verifies the provider's callbacks.
+type Provider interface {
+	// Name identifies the provider on stored payments.
+	Name() string
+	CreateCheckout(c Checkout) (Session, error)
+	// VerifyWebhook checks the signature of a callback received at now and
+	// returns the event it reports.
+	VerifyWebhook(payload []byte, header http.Header, now time.Time) (Event, error)
+	// Refund pays amount of the checkout session back and returns the
+	// provider's ID for the refund. Calls with the same key refund once.
+	Refund(sessionID string, amount money.Money, key string) (string, error)
+	// Expire closes an open checkout session, so that it can no longer be
+	// paid.
+	Expire(sessionID string) error
+}
@@ -0,0 +1,279 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+const (
+	// StripeURL is the base URL of Stripe's API.
+	StripeURL = "https://api.stripe.com"
+	// StripeSignatureHeader carries the timestamp and signatures of a
+	// callback as "t=<unix>,v1=<hex>".
+	StripeSignatureHeader = "Stripe-Signature"
+	// StripeTolerance is how old a callback's timestamp may be, to limit
+	// replays.
+	StripeTolerance = 5 * time.Minute
+	// StripeMinTTL and StripeMaxTTL bound how long Stripe keeps a checkout
+	// session open, by its own clock.
+	StripeMinTTL = 30 * time.Minute
+	StripeMaxTTL = 24 * time.Hour
+	// stripeClockSlack keeps expires_at clear of those bounds, so that clock
+	// skew and latency do not get sessions rejected.
+	stripeClockSlack = 2 * time.Minute
+)
+
+// Stripe is a Provider speaking the Stripe Checkout API. BaseURL is
+// configurable so that it can run against a stand-in such as an
+// httptest.Server.
+type Stripe struct {
+	BaseURL       string
+	APIKey        string
+	WebhookSecret string
+	// TTL is how long checkout sessions stay open. It is kept a little
+	// inside the range Stripe accepts; zero leaves it to Stripe.
+	TTL    time.Duration
+	Client *http.Client
+}
+
+func NewStripe(baseURL, apiKey, webhookSecret string, ttl time.Duration) Stripe {
+	return Stripe{
+		BaseURL:       strings.TrimSuffix(baseURL, "/"),
+		APIKey:        apiKey,
+		WebhookSecret: webhookSecret,
+		TTL:           ttl,
+		Client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s Stripe) Name() string {
+	return "stripe"
+}
+
+type stripeSession struct {
+	ID                string            `json:"id"`
+	URL               string            `json:"url"`
+	ExpiresAt         int64             `json:"expires_at"`
+	PaymentStatus     string            `json:"payment_status"`
+	AmountTotal       int64             `json:"amount_total"`
+	Currency          string            `json:"currency"`
+	ClientReferenceID string            `json:"client_reference_id"`
+	PaymentIntent     string            `json:"payment_intent"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+type stripeError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (s Stripe) CreateCheckout(c Checkout) (Session, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("client_reference_id", strconv.Itoa(c.SignUpID))
+	form.Set("metadata[signup_id]", strconv.Itoa(c.SignUpID))
+	form.Set("metadata[workshop_id]", c.WorkshopID)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(c.Amount.Currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(c.Amount.Amount, 10))
+	form.Set("line_items[0][price_data][product_data][name]", c.Description)
+	form.Set("success_url", c.SuccessURL)
+	form.Set("cancel_url", c.CancelURL)
+	if c.Email != "" {
+		form.Set("customer_email", c.Email)
+	}
+	if ttl := s.ttl(c.TTL); ttl > 0 {
+		form.Set("expires_at", strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	}
+	// Retrying a signup must not open a second checkout for it.
+	var cs stripeSession
+	if err := s.call("POST", "/v1/checkout/sessions", form, fmt.Sprintf("signup-%d", c.SignUpID), &cs); err != nil {
+		return Session{}, err
+	}
+	return Session{ID: cs.ID, URL: cs.URL, ExpiresAt: time.Unix(cs.ExpiresAt, 0).UTC()}, nil
+}
+
+// ttl is how long a checkout asking for ttl stays open, TTL by default,
+// moved inside the range Stripe accepts. Zero leaves it to Stripe.
+func (s Stripe) ttl(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = s.TTL
+	}
+	switch {
+	case ttl <= 0:
+		return 0
+	case ttl < StripeMinTTL+stripeClockSlack:
+		return StripeMinTTL + stripeClockSlack
+	case ttl > StripeMaxTTL-stripeClockSlack:
+		return StripeMaxTTL - stripeClockSlack
+	}
+	return ttl
+}
+
+// Expire closes an open checkout session. Stripe refuses sessions that are
+// no longer open, e.g. because they were paid meanwhile.
+func (s Stripe) Expire(sessionID string) error {
+	var cs stripeSession
+	return s.call("POST", "/v1/checkout/sessions/"+url.PathEscape(sessionID)+"/expire", url.Values{}, "", &cs)
+}
+
+type stripeRefund struct {
+	ID string `json:"id"`
+}
+
+// Refund looks up the payment intent behind the checkout session and
+// refunds amount of it.
+func (s Stripe) Refund(sessionID string, amount money.Money, key string) (string, error) {
+	var cs stripeSession
+	if err := s.call("GET", "/v1/checkout/sessions/"+url.PathEscape(sessionID), nil, "", &cs); err != nil {
+		return "", err
+	}
+	if cs.PaymentIntent == "" {
+		return "", fmt.Errorf("stripe: checkout session %s has no payment", sessionID)
+	}
+	form := url.Values{}
+	form.Set("payment_intent", cs.PaymentIntent)
+	form.Set("amount", strconv.FormatInt(amount.Amount, 10))
+	var r stripeRefund
+	if err := s.call("POST", "/v1/refunds", form, key, &r); err != nil {
+		return "", err
+	}
+	return r.ID, nil
+}
+
+// call sends form to the API and decodes the response into out. A non-empty
+// idempotency key makes retries of the call safe.
+func (s Stripe) call(method, path string, form url.Values, key string, out interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, s.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var e stripeError
+		if json.Unmarshal(data, &e) == nil && e.Error.Message != "" {
+			return fmt.Errorf("stripe: %s", e.Error.Message)
+		}
+		return fmt.Errorf("stripe: %s", resp.Status)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("stripe: %v", err)
+	}
+	return nil
+}
+
+type stripeEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		Object stripeSession `json:"object"`
+	} `json:"data"`
+}
+
+// VerifyWebhook checks the Stripe-Signature of a callback and maps the
+// checkout.session events onto payment statuses. A completed session that
+// is still unpaid, e.g. a bank transfer, settles with the async payment
+// events later.
+func (s Stripe) VerifyWebhook(payload []byte, header http.Header, now time.Time) (Event, error) {
+	if err := s.verifySignature(payload, header.Get(StripeSignatureHeader), now); err != nil {
+		return Event{}, err
+	}
+	var e stripeEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return Event{}, ErrInvalidEvent
+	}
+	cs := e.Data.Object
+	event := Event{SessionID: cs.ID, Amount: money.New(cs.AmountTotal, cs.Currency)}
+	switch e.Type {
+	case "checkout.session.completed":
+		if cs.PaymentStatus == "paid" || cs.PaymentStatus == "no_payment_required" {
+			event.Status = workshop.PaymentPaid
+		}
+	case "checkout.session.async_payment_succeeded":
+		event.Status = workshop.PaymentPaid
+	case "checkout.session.async_payment_failed":
+		event.Status = workshop.PaymentFailed
+	case "checkout.session.expired":
+		event.Status = workshop.PaymentExpired
+	}
+	if event.Status != "" && event.SessionID == "" {
+		return Event{}, ErrInvalidEvent
+	}
+	return event, nil
+}
+
+func (s Stripe) verifySignature(payload []byte, header string, now time.Time) error {
+	var (
+		timestamp string
+		sigs      [][]byte
+	)
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			if sig, err := hex.DecodeString(kv[1]); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(t, 0)); age > StripeTolerance || age < -StripeTolerance {
+		return ErrInvalidSignature
+	}
+	mac := StripeSignature(s.WebhookSecret, t, payload)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, mac) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// StripeSignature is the v1 signature Stripe sends for payload at unix time
+// t. Stand-ins use it to sign the callbacks they send.
+func StripeSignature(secret string, t int64, payload []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", t)
+	h.Write(payload)
+	return h.Sum(nil)
+}
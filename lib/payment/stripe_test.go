@@ -0,0 +1,275 @@
+package payment
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// stripeStandIn answers the Stripe API calls made by the client and records
+// the requests it got.
+type stripeStandIn struct {
+	t        *testing.T
+	requests []*http.Request
+}
+
+func (s *stripeStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.t.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	s.requests = append(s.requests, r)
+	if got := r.Header.Get("Authorization"); got != "Bearer sk_test" {
+		s.t.Errorf("%s %s: Authorization %q", r.Method, r.URL.Path, got)
+	}
+	if r.Method == "POST" {
+		if got := r.Header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+			s.t.Errorf("%s %s: Content-Type %q", r.Method, r.URL.Path, got)
+		}
+	}
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/v1/checkout/sessions":
+		fmt.Fprintf(w, `{"id": "cs_test_1", "url": "https://checkout.stripe.com/c/pay/cs_test_1", "expires_at": %s}`, r.PostForm.Get("expires_at"))
+	case r.Method == "GET" && r.URL.Path == "/v1/checkout/sessions/cs_test_1":
+		io.WriteString(w, `{"id": "cs_test_1", "payment_intent": "pi_test_1"}`)
+	case r.Method == "GET" && r.URL.Path == "/v1/checkout/sessions/cs_test_unpaid":
+		io.WriteString(w, `{"id": "cs_test_unpaid", "payment_intent": null}`)
+	case r.Method == "POST" && r.URL.Path == "/v1/checkout/sessions/cs_test_1/expire":
+		io.WriteString(w, `{"id": "cs_test_1", "status": "expired"}`)
+	case r.Method == "POST" && r.URL.Path == "/v1/checkout/sessions/cs_test_paid/expire":
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error": {"message": "Only Checkout Sessions with a status of open can be expired."}}`)
+	case r.Method == "POST" && r.URL.Path == "/v1/refunds":
+		io.WriteString(w, `{"id": "re_test_1"}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error": {"message": "No such resource"}}`)
+	}
+}
+
+func newStripeStandIn(t *testing.T) (*stripeStandIn, Stripe) {
+	standIn := &stripeStandIn{t: t}
+	srv := httptest.NewServer(standIn)
+	t.Cleanup(srv.Close)
+	return standIn, NewStripe(srv.URL+"/", "sk_test", "whsec_test", time.Hour)
+}
+
+func TestStripeCreateCheckout(t *testing.T) {
+	standIn, s := newStripeStandIn(t)
+	before := time.Now()
+	session, err := s.CreateCheckout(Checkout{
+		SignUpID:    42,
+		WorkshopID:  "pottery",
+		Description: "Pottery & glazes",
+		Email:       "ada@example.com",
+		Amount:      money.New(4500, "eur"),
+		SuccessURL:  "https://example.com/paid?signup_id=42&checkout=success",
+		CancelURL:   "https://example.com/paid?signup_id=42&checkout=cancel",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ID != "cs_test_1" || session.URL != "https://checkout.stripe.com/c/pay/cs_test_1" {
+		t.Errorf("got session %+v", session)
+	}
+	if len(standIn.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(standIn.requests))
+	}
+	r := standIn.requests[0]
+	if got := r.Header.Get("Idempotency-Key"); got != "signup-42" {
+		t.Errorf("got Idempotency-Key %q, want signup-42", got)
+	}
+	for field, want := range map[string]string{
+		"mode":                                          "payment",
+		"client_reference_id":                           "42",
+		"metadata[signup_id]":                           "42",
+		"metadata[workshop_id]":                         "pottery",
+		"line_items[0][quantity]":                       "1",
+		"line_items[0][price_data][currency]":           "eur",
+		"line_items[0][price_data][unit_amount]":        "4500",
+		"line_items[0][price_data][product_data][name]": "Pottery & glazes",
+		"success_url":                                   "https://example.com/paid?signup_id=42&checkout=success",
+		"cancel_url":                                    "https://example.com/paid?signup_id=42&checkout=cancel",
+		"customer_email":                                "ada@example.com",
+	} {
+		if got := r.PostForm.Get(field); got != want {
+			t.Errorf("got %s=%q, want %q", field, got, want)
+		}
+	}
+	expiresAt, err := strconv.ParseInt(r.PostForm.Get("expires_at"), 10, 64)
+	if err != nil {
+		t.Fatalf("expires_at: %v", err)
+	}
+	if at := time.Unix(expiresAt, 0); at.Before(before.Add(time.Hour).Truncate(time.Second)) || at.After(time.Now().Add(time.Hour)) {
+		t.Errorf("got expires_at %v, want an hour from now", at)
+	}
+	if !session.ExpiresAt.Equal(time.Unix(expiresAt, 0)) {
+		t.Errorf("got session expiring at %v, want %v", session.ExpiresAt, time.Unix(expiresAt, 0))
+	}
+}
+
+func TestStripeRefund(t *testing.T) {
+	standIn, s := newStripeStandIn(t)
+	id, err := s.Refund("cs_test_1", money.New(2000, "EUR"), "refund-7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "re_test_1" {
+		t.Errorf("got refund %q, want re_test_1", id)
+	}
+	if len(standIn.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(standIn.requests))
+	}
+	if get := standIn.requests[0]; get.Header.Get("Idempotency-Key") != "" {
+		t.Errorf("session lookup sent Idempotency-Key %q", get.Header.Get("Idempotency-Key"))
+	}
+	post := standIn.requests[1]
+	if got := post.Header.Get("Idempotency-Key"); got != "refund-7" {
+		t.Errorf("got Idempotency-Key %q, want refund-7", got)
+	}
+	if got := post.PostForm.Get("payment_intent"); got != "pi_test_1" {
+		t.Errorf("got payment_intent %q, want pi_test_1", got)
+	}
+	if got := post.PostForm.Get("amount"); got != "2000" {
+		t.Errorf("got amount %q, want 2000", got)
+	}
+}
+
+func TestStripeRefundErrors(t *testing.T) {
+	standIn, s := newStripeStandIn(t)
+	if _, err := s.Refund("cs_test_unpaid", money.New(2000, "EUR"), "refund-8"); err == nil {
+		t.Error("refunded a session without payment")
+	}
+	_, err := s.Refund("cs_missing", money.New(2000, "EUR"), "refund-9")
+	if err == nil || err.Error() != "stripe: No such resource" {
+		t.Errorf("got error %v, want the API's message", err)
+	}
+	for _, r := range standIn.requests {
+		if r.URL.Path == "/v1/refunds" {
+			t.Errorf("refund posted for %v", r.URL)
+		}
+	}
+}
+
+func TestStripeCheckoutTTL(t *testing.T) {
+	tests := []struct {
+		ttl, checkoutTTL, want time.Duration
+	}{
+		{30 * time.Minute, 0, StripeMinTTL + stripeClockSlack},
+		{time.Hour, 0, time.Hour},
+		{24 * time.Hour, 0, StripeMaxTTL - stripeClockSlack},
+		// A checkout's own TTL wins, within the same range.
+		{time.Hour, 3 * time.Hour, 3 * time.Hour},
+		{time.Hour, 48 * time.Hour, StripeMaxTTL - stripeClockSlack},
+		{0, 10 * time.Minute, StripeMinTTL + stripeClockSlack},
+	}
+	for _, tt := range tests {
+		standIn, s := newStripeStandIn(t)
+		s.TTL = tt.ttl
+		before := time.Now()
+		if _, err := s.CreateCheckout(Checkout{SignUpID: 1, Amount: money.New(4500, "EUR"), TTL: tt.checkoutTTL}); err != nil {
+			t.Fatal(err)
+		}
+		expiresAt, err := strconv.ParseInt(standIn.requests[0].PostForm.Get("expires_at"), 10, 64)
+		if err != nil {
+			t.Fatalf("expires_at: %v", err)
+		}
+		if got := time.Unix(expiresAt, 0).Sub(before.Truncate(time.Second)); got < tt.want || got > tt.want+time.Second {
+			t.Errorf("TTL %v, checkout TTL %v: got a session open for %v, want %v", tt.ttl, tt.checkoutTTL, got, tt.want)
+		}
+	}
+}
+
+func TestStripeExpire(t *testing.T) {
+	standIn, s := newStripeStandIn(t)
+	if err := s.Expire("cs_test_1"); err != nil {
+		t.Fatal(err)
+	}
+	if r := standIn.requests[0]; r.Method != "POST" || r.URL.Path != "/v1/checkout/sessions/cs_test_1/expire" {
+		t.Errorf("got %s %s", r.Method, r.URL.Path)
+	}
+	err := s.Expire("cs_test_paid")
+	if err == nil || err.Error() != "stripe: Only Checkout Sessions with a status of open can be expired." {
+		t.Errorf("got error %v for a paid session, want the API's message", err)
+	}
+}
+
+// stripeHeader signs payload the way Stripe does at time t, with any extra
+// v1 signatures first.
+func stripeHeader(secret string, t time.Time, payload []byte, extra ...string) http.Header {
+	v := fmt.Sprintf("t=%d", t.Unix())
+	for _, sig := range extra {
+		v += ",v1=" + sig
+	}
+	v += ",v1=" + hex.EncodeToString(StripeSignature(secret, t.Unix(), payload))
+	header := http.Header{}
+	header.Set(StripeSignatureHeader, v)
+	return header
+}
+
+func TestStripeVerifyWebhookSignature(t *testing.T) {
+	s := NewStripe(StripeURL, "sk_test", "whsec_test", time.Hour)
+	payload := []byte(`{"type": "checkout.session.expired", "data": {"object": {"id": "cs_test_1"}}}`)
+	now := time.Now()
+	tests := []struct {
+		name    string
+		payload []byte
+		header  http.Header
+		wantErr error
+	}{
+		{"valid", payload, stripeHeader("whsec_test", now, payload), nil},
+		{"one of several signatures", payload, stripeHeader("whsec_test", now, payload, "00ff"), nil},
+		{"slightly ahead", payload, stripeHeader("whsec_test", now.Add(time.Minute), payload), nil},
+		{"tampered", []byte(`{"type": "checkout.session.completed"}`), stripeHeader("whsec_test", now, payload), ErrInvalidSignature},
+		{"other secret", payload, stripeHeader("whsec_other", now, payload), ErrInvalidSignature},
+		{"stale", payload, stripeHeader("whsec_test", now.Add(-StripeTolerance-time.Second), payload), ErrInvalidSignature},
+		{"from the future", payload, stripeHeader("whsec_test", now.Add(StripeTolerance+time.Second), payload), ErrInvalidSignature},
+		{"missing", payload, http.Header{}, ErrInvalidSignature},
+		{"no timestamp", payload, http.Header{StripeSignatureHeader: {"v1=" + hex.EncodeToString(StripeSignature("whsec_test", now.Unix(), payload))}}, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.VerifyWebhook(tt.payload, tt.header, now)
+			if err != tt.wantErr {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStripeVerifyWebhookEvents(t *testing.T) {
+	s := NewStripe(StripeURL, "sk_test", "whsec_test", time.Hour)
+	event := func(typ, paymentStatus string) string {
+		return fmt.Sprintf(`{"type": %q, "data": {"object": {"id": "cs_test_1", "payment_status": %q, "amount_total": 4500, "currency": "eur"}}}`, typ, paymentStatus)
+	}
+	tests := []struct {
+		payload string
+		want    Event
+		wantErr error
+	}{
+		{event("checkout.session.completed", "paid"), Event{SessionID: "cs_test_1", Status: workshop.PaymentPaid, Amount: money.New(4500, "EUR")}, nil},
+		{event("checkout.session.completed", "no_payment_required"), Event{SessionID: "cs_test_1", Status: workshop.PaymentPaid, Amount: money.New(4500, "EUR")}, nil},
+		{event("checkout.session.completed", "unpaid"), Event{SessionID: "cs_test_1", Amount: money.New(4500, "EUR")}, nil},
+		{event("checkout.session.async_payment_succeeded", "paid"), Event{SessionID: "cs_test_1", Status: workshop.PaymentPaid, Amount: money.New(4500, "EUR")}, nil},
+		{event("checkout.session.async_payment_failed", "unpaid"), Event{SessionID: "cs_test_1", Status: workshop.PaymentFailed, Amount: money.New(4500, "EUR")}, nil},
+		{event("checkout.session.expired", "unpaid"), Event{SessionID: "cs_test_1", Status: workshop.PaymentExpired, Amount: money.New(4500, "EUR")}, nil},
+		{event("customer.created", ""), Event{SessionID: "cs_test_1", Amount: money.New(4500, "EUR")}, nil},
+		{`{"type": "checkout.session.expired", "data": {"object": {}}}`, Event{}, ErrInvalidEvent},
+		{`not json`, Event{}, ErrInvalidEvent},
+	}
+	now := time.Now()
+	for _, tt := range tests {
+		payload := []byte(tt.payload)
+		got, err := s.VerifyWebhook(payload, stripeHeader("whsec_test", now, payload), now)
+		if err != tt.wantErr || got != tt.want {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", tt.payload, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+// unpaidBalances matches the confirmed signups that have not paid off
+// their balance on workshops taking deposits whose balance is due by dueBy.
+func unpaidBalances(dueBy time.Time) (string, []interface{}) {
+	return "status = ? AND amount_paid < price_amount - voucher_amount AND workshop_id IN (SELECT workshop_id FROM workshops WHERE deposit_amount > 0 AND cancelled_at IS NULL AND balance_due_at <= ?)",
+		[]interface{}{workshop.StatusConfirmed, dueBy.UTC()}
+}
+
+// overdueBalances matches the unpaid balances due by now that have not been
+// flagged yet and were reminded at least grace ago.
+func overdueBalances(now time.Time, grace time.Duration) (string, []interface{}) {
+	unpaid, args := unpaidBalances(now)
+	return unpaid + " AND balance_overdue_at IS NULL AND balance_reminded_at <= ?", append(args, now.Add(-grace).UTC())
+}
+
+// RemindBalances returns the signups whose balance falls due by until and
+// who have not been reminded of it yet, and marks them reminded at now.
+func (w workshopDB) RemindBalances(until, now time.Time) ([]workshop.SignUp, error) {
+	var reminded []workshop.SignUp
+	err := transact(w.db, func(tx *sql.Tx) error {
+		unpaid, args := unpaidBalances(until)
+		rows, err := tx.Query("SELECT "+signupColumns+" FROM signups WHERE "+unpaid+" AND balance_reminded_at IS NULL ORDER BY id FOR UPDATE", args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			s, err := scanSignUp(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			s.BalanceRemindedAt = now.UTC()
+			reminded = append(reminded, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, s := range reminded {
+			if _, err := tx.Exec("UPDATE signups SET balance_reminded_at = ? WHERE id = ?", s.BalanceRemindedAt, s.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return reminded, err
+}
+
+// FlagOverdueBalances flags the signups that have not paid off their
+// balance by its due date, and returns them along with whoever was promoted
+// onto a seat. Signups are only flagged once they were reminded at least
+// grace ago, so that signups promoted late get a chance to pay. On
+// workshops that release unpaid seats the flagged signups are cancelled,
+// keeping what they paid, and the waitlist promoted. Each workshop is settled
+// in its own transaction, like ReleaseExpiredHolds.
+func (w workshopDB) FlagOverdueBalances(now time.Time, grace time.Duration) ([]workshop.SignUp, []workshop.SignUp, error) {
+	var overdue, promoted []workshop.SignUp
+	unpaid, args := overdueBalances(now, grace)
+	rows, err := w.db.Query("SELECT DISTINCT workshop_id FROM signups WHERE "+unpaid, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var workshopIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		workshopIDs = append(workshopIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	var failed error
+	for _, id := range workshopIDs {
+		var o, p []workshop.SignUp
+		err := transact(w.db, func(tx *sql.Tx) error {
+			var err error
+			o, p, err = w.flagOverdueBalances(tx, id, now, grace)
+			return err
+		})
+		if err != nil {
+			if failed == nil {
+				failed = err
+			}
+			continue
+		}
+		overdue = append(overdue, o...)
+		promoted = append(promoted, p...)
+	}
+	return overdue, promoted, failed
+}
+
+func (w workshopDB) flagOverdueBalances(tx *sql.Tx, workshopID string, now time.Time, grace time.Duration) ([]workshop.SignUp, []workshop.SignUp, error) {
+	if _, err := lockWorkshop(tx, workshopID); err != nil {
+		return nil, nil, err
+	}
+	var release bool
+	if err := tx.QueryRow("SELECT release_unpaid FROM workshops WHERE workshop_id = ?", workshopID).Scan(&release); err != nil {
+		return nil, nil, err
+	}
+	unpaid, args := overdueBalances(now, grace)
+	rows, err := tx.Query(
+		"SELECT "+signupColumns+" FROM signups WHERE workshop_id = ? AND "+unpaid+" ORDER BY id FOR UPDATE",
+		append([]interface{}{workshopID}, args...)...,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	var overdue []workshop.SignUp
+	for rows.Next() {
+		s, err := scanSignUp(rows)
+		if err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		overdue = append(overdue, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	for i := range overdue {
+		s := &overdue[i]
+		s.BalanceOverdueAt = now.UTC()
+		if _, err := tx.Exec("UPDATE signups SET balance_overdue_at = ? WHERE id = ?", s.BalanceOverdueAt, s.ID); err != nil {
+			return nil, nil, err
+		}
+		if !release {
+			continue
+		}
+		if err := setStatus(tx, s, workshop.StatusCancelled); err != nil {
+			return nil, nil, err
+		}
+		if _, err := tx.Exec(
+			"UPDATE payments SET status = ?, updated_at = ? WHERE signup_id = ? AND status = ?",
+			workshop.PaymentExpired, s.UpdatedAt, s.ID, workshop.PaymentOpen,
+		); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !release || len(overdue) == 0 {
+		return overdue, nil, nil
+	}
+	promoted, err := w.promote(tx, workshopID)
+	return overdue, promoted, err
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// signUpIDsOn lists the IDs of the signups on workshopID, as sweeps also
+// return signups on other workshops in a shared database.
+func signUpIDsOn(signups []workshop.SignUp, workshopID string) []int {
+	var ids []int
+	for _, s := range signups {
+		if s.WorkshopID == workshopID {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
+
+// balancesRemindedAndFlagged has one signup pay in full and one pay only its
+// deposit on a workshop that releases unpaid seats, and checks that only
+// the second is reminded, once, and released a grace period after its
+// reminder, promoting the waitlist.
+func balancesRemindedAndFlagged(t *testing.T, db WorkshopDB) {
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	ws := workshop.Workshop{
+		WorkshopID:    fmt.Sprintf("balances-%d", time.Now().UnixNano()),
+		Name:          "Balances",
+		StartTime:     start,
+		EndTime:       start.Add(2 * time.Hour),
+		Cap:           2,
+		Price:         money.New(10000, "EUR"),
+		Deposit:       money.New(3000, "EUR"),
+		BalanceDueAt:  start.AddDate(0, 0, -14),
+		ReleaseUnpaid: true,
+	}
+	if err := db.InsertWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	var signups []workshop.SignUp
+	for _, name := range []string{"ada", "grace", "hedy"} {
+		su, err := db.SignUp(workshop.SignUp{WorkshopID: ws.WorkshopID, FirstName: name, Email: name + "@example.com", Price: ws.Price}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signups = append(signups, su)
+	}
+	paid, deposit, waitlisted := signups[0], signups[1], signups[2]
+	for _, p := range []struct {
+		su     workshop.SignUp
+		amount int64
+	}{{paid, 10000}, {deposit, 3000}} {
+		session := fmt.Sprintf("%s-%d", ws.WorkshopID, p.su.ID)
+		if _, err := db.InsertPayment(workshop.Payment{SignUpID: p.su.ID, Provider: "fake", SessionID: session, Amount: money.New(p.amount, "EUR")}); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := db.SettlePayment("fake", session, workshop.PaymentPaid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	due := ws.BalanceDueAt
+	remindedAt := due.Add(-2 * time.Hour)
+	for _, c := range []struct {
+		until time.Time
+		want  []int
+	}{
+		{due.Add(-time.Second), nil},
+		{due, []int{deposit.ID}},
+		// Nobody is reminded twice.
+		{due, nil},
+	} {
+		reminded, err := db.RemindBalances(c.until, remindedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := signUpIDsOn(reminded, ws.WorkshopID); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("reminding balances due by %v: got %v, want %v", c.until, got, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		now      time.Time
+		grace    time.Duration
+		overdue  []int
+		promoted []int
+	}{
+		{due.Add(-time.Second), time.Hour, nil, nil},
+		// The reminder went out two hours before.
+		{due, 3 * time.Hour, nil, nil},
+		{due, time.Hour, []int{deposit.ID}, []int{waitlisted.ID}},
+		// The promoted signup has not been reminded yet.
+		{due.Add(time.Hour), time.Hour, nil, nil},
+	} {
+		overdue, promoted, err := db.FlagOverdueBalances(c.now, c.grace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := signUpIDsOn(overdue, ws.WorkshopID); fmt.Sprint(got) != fmt.Sprint(c.overdue) {
+			t.Errorf("flagging at %v with %v grace: got overdue %v, want %v", c.now, c.grace, got, c.overdue)
+		}
+		if got := signUpIDsOn(promoted, ws.WorkshopID); fmt.Sprint(got) != fmt.Sprint(c.promoted) {
+			t.Errorf("flagging at %v with %v grace: got promoted %v, want %v", c.now, c.grace, got, c.promoted)
+		}
+	}
+
+	released, err := db.SignUpByID(deposit.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.Status != workshop.StatusCancelled || released.BalanceOverdueAt.IsZero() || released.AmountPaid != money.New(3000, "EUR") {
+		t.Errorf("got %s signup overdue at %v having paid %v, want it released keeping its deposit", released.Status, released.BalanceOverdueAt, released.AmountPaid)
+	}
+	full, err := db.SignUpByID(paid.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Status != workshop.StatusConfirmed || !full.BalanceRemindedAt.IsZero() || !full.Balance().IsZero() {
+		t.Errorf("got %s signup reminded at %v owing %v, want it left alone", full.Status, full.BalanceRemindedAt, full.Balance())
+	}
+	if err := db.DeleteWorkshop(ws.WorkshopID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBalancesRemindedAndFlaggedMemory(t *testing.T) {
+	balancesRemindedAndFlagged(t, NewMemoryDB())
+}
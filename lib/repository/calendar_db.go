@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+const closureColumns = "id, from_date, until_date, reason, created_at"
+
+func scanClosure(r rowScanner) (workshop.Closure, error) {
+	var c workshop.Closure
+	err := r.Scan(&c.ID, &c.From, &c.Until, &c.Reason, &c.CreatedAt)
+	c.From, c.Until = workshop.Date(c.From), workshop.Date(c.Until)
+	return c, err
+}
+
+// loadCalendar reads the opening hours and every closure.
+func loadCalendar(db queryer) (workshop.Calendar, error) {
+	var c workshop.Calendar
+	rows, err := db.Query("SELECT weekday, opens_minute, closes_minute FROM opening_hours ORDER BY weekday, opens_minute")
+	if err != nil {
+		return c, err
+	}
+	for rows.Next() {
+		var h workshop.OpeningHours
+		if err := rows.Scan(&h.Weekday, &h.Opens, &h.Closes); err != nil {
+			rows.Close()
+			return c, err
+		}
+		c.Hours = append(c.Hours, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return c, err
+	}
+	c.Closures, err = queryClosures(db, "ORDER BY from_date, id")
+	return c, err
+}
+
+func queryClosures(db queryer, where string, args ...interface{}) ([]workshop.Closure, error) {
+	var closures []workshop.Closure
+	rows, err := db.Query("SELECT "+closureColumns+" FROM closures "+where, args...)
+	if err != nil {
+		return closures, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c, err := scanClosure(rows)
+		if err != nil {
+			return closures, err
+		}
+		closures = append(closures, c)
+	}
+	return closures, rows.Err()
+}
+
+// GetCalendar returns the opening hours and every closure.
+func (w workshopDB) GetCalendar() (workshop.Calendar, error) {
+	return loadCalendar(w.db)
+}
+
+// SetOpeningHours replaces the weekly opening hours; none leaves the studio
+// open around the clock.
+func (w workshopDB) SetOpeningHours(hours []workshop.OpeningHours) error {
+	return transact(w.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM opening_hours"); err != nil {
+			return err
+		}
+		for _, h := range hours {
+			if _, err := tx.Exec("INSERT INTO opening_hours (weekday, opens_minute, closes_minute) VALUES (?,?,?)", int(h.Weekday), h.Opens, h.Closes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// InsertClosure closes the studio on the days of c. Workshops and events
+// already scheduled then are kept.
+func (w workshopDB) InsertClosure(c workshop.Closure) (workshop.Closure, error) {
+	c.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	res, err := w.db.Exec(
+		"INSERT INTO closures (from_date, until_date, reason, created_at) VALUES (?,?,?,?)",
+		c.From.Format(workshop.DateLayout), c.Until.Format(workshop.DateLayout), c.Reason, c.CreatedAt,
+	)
+	if err != nil {
+		return c, err
+	}
+	id, err := res.LastInsertId()
+	c.ID = int(id)
+	return c, err
+}
+
+func (w workshopDB) DeleteClosure(closureID int) error {
+	res, err := w.db.Exec("DELETE FROM closures WHERE id = ?", closureID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetClosures lists the closures that end on or after the date of from,
+// earliest first.
+func (w workshopDB) GetClosures(from time.Time) ([]workshop.Closure, error) {
+	return queryClosures(w.db, "WHERE until_date >= ? ORDER BY from_date, id", workshop.Date(from).Format(workshop.DateLayout))
+}
@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/workshop"
+)
+
+var (
+	// ErrUnknownCategory is returned when a workshop or event is filed under,
+	// or a listing filtered by, a category that does not exist.
+	ErrUnknownCategory = errors.New("unknown category")
+	// ErrDuplicateCategory is returned when two categories share a slug.
+	ErrDuplicateCategory = errors.New("category slug already exists")
+	// ErrCategoryHasChildren is returned when a category with subcategories
+	// is deleted.
+	ErrCategoryHasChildren = errors.New("category has subcategories")
+	// ErrCategoryInUse is returned when a category a discount code is
+	// restricted to is deleted.
+	ErrCategoryInUse = errors.New("category is used by a discount code")
+)
+
+const categoryColumns = "id, parent_id, name, slug, description, created_at, updated_at"
+
+// Offerings are filed under categories and tags through join tables named
+// after them: workshop_categories, event_tags and so on.
+const (
+	workshopOffering = "workshop"
+	eventOffering    = "event"
+)
+
+func scanCategory(r rowScanner) (workshop.Category, error) {
+	var (
+		c        workshop.Category
+		parentID sql.NullInt64
+	)
+	err := r.Scan(&c.ID, &parentID, &c.Name, &c.Slug, &c.Description, &c.CreatedAt, &c.UpdatedAt)
+	c.ParentID = int(parentID.Int64)
+	return c, err
+}
+
+// categoryError maps the key violations of saving a category.
+func categoryError(err error) error {
+	if e, ok := err.(*mysql.MySQLError); ok {
+		switch e.Number {
+		case 1062:
+			return ErrDuplicateCategory
+		case 1452:
+			return ErrUnknownCategory
+		case 1451:
+			return ErrCategoryHasChildren
+		}
+	}
+	return err
+}
+
+func queryCategories(db queryer, where string, args ...interface{}) ([]workshop.Category, error) {
+	var categories []workshop.Category
+	rows, err := db.Query("SELECT "+categoryColumns+" FROM categories "+where, args...)
+	if err != nil {
+		return categories, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return categories, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (w workshopDB) InsertCategory(c workshop.Category) (workshop.Category, error) {
+	c.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	c.UpdatedAt = c.CreatedAt
+	res, err := w.db.Exec(
+		"INSERT INTO categories (parent_id, name, slug, description, created_at, updated_at) VALUES (?,?,?,?,?,?)",
+		nullInt(c.ParentID), c.Name, c.Slug, c.Description, c.CreatedAt, c.UpdatedAt,
+	)
+	if err != nil {
+		return c, categoryError(err)
+	}
+	id, err := res.LastInsertId()
+	c.ID = int(id)
+	return c, err
+}
+
+func (w workshopDB) CategoryByID(categoryID int) (workshop.Category, error) {
+	return scanCategory(w.db.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE id = ?", categoryID))
+}
+
+// GetCategories lists every category by name, with the number of workshops
+// and events in it or below it that have not ended by now.
+func (w workshopDB) GetCategories(now time.Time) ([]workshop.Category, error) {
+	categories, err := queryCategories(w.db, "ORDER BY name, id")
+	if err != nil {
+		return categories, err
+	}
+	workshops, err := categoryAssignments(w.db, "SELECT wc.category_id, wc.workshop_id FROM workshop_categories wc JOIN workshops w ON w.workshop_id = wc.workshop_id WHERE w.cancelled_at IS NULL AND w.end_time > ?", now.UTC())
+	if err != nil {
+		return categories, err
+	}
+	events, err := categoryAssignments(w.db, "SELECT ec.category_id, ec.event_id FROM event_categories ec JOIN events e ON e.event_id = ec.event_id WHERE e.end_time > ?", now.UTC())
+	if err != nil {
+		return categories, err
+	}
+	workshop.CountOfferings(categories, workshops, events)
+	return categories, nil
+}
+
+// categoryAssignments reads (category id, offering id) pairs.
+func categoryAssignments(db queryer, query string, args ...interface{}) (map[int][]string, error) {
+	assigned := make(map[int][]string)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return assigned, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			categoryID int
+			id         string
+		)
+		if err := rows.Scan(&categoryID, &id); err != nil {
+			return assigned, err
+		}
+		assigned[categoryID] = append(assigned[categoryID], id)
+	}
+	return assigned, rows.Err()
+}
+
+// UpdateCategory saves c and returns it as stored. It cannot be moved under
+// itself or one of its subcategories.
+func (w workshopDB) UpdateCategory(c workshop.Category) (workshop.Category, error) {
+	var saved workshop.Category
+	err := transact(w.db, func(tx *sql.Tx) error {
+		if err := tx.QueryRow("SELECT id FROM categories WHERE id = ? FOR UPDATE", c.ID).Scan(&c.ID); err != nil {
+			return err
+		}
+		if c.ParentID != 0 {
+			all, err := queryCategories(tx, "")
+			if err != nil {
+				return err
+			}
+			for _, id := range workshop.Subtree(all, c.ID) {
+				if id == c.ParentID {
+					return workshop.ErrInvalidCategory
+				}
+			}
+		}
+		if _, err := tx.Exec(
+			"UPDATE categories SET parent_id=?, name=?, slug=?, description=?, updated_at=? WHERE id=?",
+			nullInt(c.ParentID), c.Name, c.Slug, c.Description, time.Now().UTC(), c.ID,
+		); err != nil {
+			return categoryError(err)
+		}
+		var err error
+		saved, err = scanCategory(tx.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE id = ?", c.ID))
+		return err
+	})
+	return saved, err
+}
+
+// DeleteCategory removes a category, unless it has subcategories or a
+// discount code is restricted to it, and takes the workshops and events
+// filed under it out of it.
+func (w workshopDB) DeleteCategory(categoryID int) error {
+	var codes int
+	if err := w.db.QueryRow("SELECT COUNT(*) FROM discount_code_categories WHERE category_id = ?", categoryID).Scan(&codes); err != nil {
+		return err
+	}
+	if codes > 0 {
+		return ErrCategoryInUse
+	}
+	res, err := w.db.Exec("DELETE FROM categories WHERE id = ?", categoryID)
+	if err != nil {
+		return categoryError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// workshopCategoryAncestry returns the categories a workshop is filed under
+// and every category above them.
+func workshopCategoryAncestry(db queryer, workshopID string) ([]int, error) {
+	all, err := queryCategories(db, "")
+	if err != nil {
+		return nil, err
+	}
+	assigned, err := categoryAssignments(db, "SELECT category_id, workshop_id FROM workshop_categories WHERE workshop_id = ?", workshopID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for id := range assigned {
+		ids = append(ids, id)
+	}
+	return workshop.Ancestry(all, ids), nil
+}
+
+// loadTaxonomy reads the categories, by name, and tags of the offerings of
+// kind with ids.
+func loadTaxonomy(db queryer, kind string, ids []string) (map[string][]workshop.Category, map[string][]string, error) {
+	categories := make(map[string][]workshop.Category)
+	tags := make(map[string][]string)
+	if len(ids) == 0 {
+		return categories, tags, nil
+	}
+	placeholders, args := inList(ids)
+	rows, err := db.Query("SELECT oc."+kind+"_id, c.id, c.parent_id, c.name, c.slug, c.description, c.created_at, c.updated_at FROM "+kind+"_categories oc JOIN categories c ON c.id = oc.category_id WHERE oc."+kind+"_id IN ("+placeholders+") ORDER BY c.name, c.id", args...)
+	if err != nil {
+		return categories, tags, err
+	}
+	for rows.Next() {
+		var (
+			id       string
+			c        workshop.Category
+			parentID sql.NullInt64
+		)
+		if err := rows.Scan(&id, &c.ID, &parentID, &c.Name, &c.Slug, &c.Description, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			rows.Close()
+			return categories, tags, err
+		}
+		c.ParentID = int(parentID.Int64)
+		categories[id] = append(categories[id], c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return categories, tags, err
+	}
+	rows, err = db.Query("SELECT ot."+kind+"_id, t.name FROM "+kind+"_tags ot JOIN tags t ON t.id = ot.tag_id WHERE ot."+kind+"_id IN ("+placeholders+") ORDER BY t.name", args...)
+	if err != nil {
+		return categories, tags, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return categories, tags, err
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	return categories, tags, rows.Err()
+}
+
+// loadWorkshopTaxonomy fills in the categories and tags of workshops.
+func loadWorkshopTaxonomy(db queryer, workshops []workshop.Workshop) error {
+	ids := make([]string, len(workshops))
+	for i, ws := range workshops {
+		ids[i] = ws.WorkshopID
+	}
+	categories, tags, err := loadTaxonomy(db, workshopOffering, ids)
+	for i := range workshops {
+		id := workshops[i].WorkshopID
+		workshops[i].Categories, workshops[i].CategoryIDs = categories[id], categoryIDs(categories[id])
+		workshops[i].Tags = tags[id]
+	}
+	return err
+}
+
+// loadEventTaxonomy fills in the categories and tags of events.
+func loadEventTaxonomy(db queryer, events []workshop.Event) error {
+	ids := make([]string, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	categories, tags, err := loadTaxonomy(db, eventOffering, ids)
+	for i := range events {
+		id := events[i].ID
+		events[i].Categories, events[i].CategoryIDs = categories[id], categoryIDs(categories[id])
+		events[i].Tags = tags[id]
+	}
+	return err
+}
+
+func categoryIDs(categories []workshop.Category) []int {
+	var ids []int
+	for _, c := range categories {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+// insertTaxonomy files the offering of kind with id under categoryIDs and
+// tags, creating the tags it is the first to carry.
+func insertTaxonomy(db execer, kind, id string, categoryIDs []int, tags []string) error {
+	for _, categoryID := range categoryIDs {
+		_, err := db.Exec("INSERT INTO "+kind+"_categories ("+kind+"_id, category_id) VALUES (?,?)", id, categoryID)
+		if e, ok := err.(*mysql.MySQLError); ok && e.Number == 1452 {
+			return ErrUnknownCategory
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, tag := range tags {
+		res, err := db.Exec("INSERT INTO tags (name) VALUES (?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)", tag)
+		if err != nil {
+			return err
+		}
+		tagID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec("INSERT INTO "+kind+"_tags ("+kind+"_id, tag_id) VALUES (?,?)", id, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceTaxonomy files the offering under categoryIDs and tags in place of
+// the current ones. Nil leaves the current categories or tags alone.
+func replaceTaxonomy(tx *sql.Tx, kind, id string, categoryIDs []int, tags []string) error {
+	if categoryIDs != nil {
+		if _, err := tx.Exec("DELETE FROM "+kind+"_categories WHERE "+kind+"_id = ?", id); err != nil {
+			return err
+		}
+	}
+	if tags != nil {
+		if _, err := tx.Exec("DELETE FROM "+kind+"_tags WHERE "+kind+"_id = ?", id); err != nil {
+			return err
+		}
+	}
+	return insertTaxonomy(tx, kind, id, categoryIDs, tags)
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+// categoriesAndTagsFiltered files workshops and an event under a tree of
+// categories and under tags, and checks that listings filtered by a
+// category take in its subcategories, that filters by several tags want
+// all of them, and that the tree counts each offering once.
+func categoriesAndTagsFiltered(t *testing.T, db WorkshopDB) {
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	insert := func(name string, parentID int) workshop.Category {
+		c := workshop.Category{Name: name + " " + suffix, ParentID: parentID}
+		if err := c.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		c, err := db.InsertCategory(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	ceramics := insert("Ceramics", 0)
+	wheel := insert("Wheel", ceramics.ID)
+	raku := insert("Raku", wheel.ID)
+	kids := insert("Kids", 0)
+
+	if _, err := db.InsertCategory(workshop.Category{Name: "Again", Slug: kids.Slug}); err != ErrDuplicateCategory {
+		t.Errorf("duplicate slug: got %v, want %v", err, ErrDuplicateCategory)
+	}
+	if _, err := db.InsertCategory(workshop.Category{Name: "Orphan", Slug: "orphan-" + suffix, ParentID: kids.ID + 1000}); err != ErrUnknownCategory {
+		t.Errorf("unknown parent: got %v, want %v", err, ErrUnknownCategory)
+	}
+	moved := ceramics
+	moved.ParentID = raku.ID
+	if _, err := db.UpdateCategory(moved); err != workshop.ErrInvalidCategory {
+		t.Errorf("moving ceramics under raku: got %v, want %v", err, workshop.ErrInvalidCategory)
+	}
+
+	evening, beginner := "evening "+suffix, "beginner "+suffix
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	prefix := "taxonomy-" + suffix
+	var workshopIDs []string
+	for i, w := range []struct {
+		categoryIDs []int
+		tags        []string
+	}{
+		{[]int{raku.ID}, []string{beginner, evening}},
+		{[]int{wheel.ID, kids.ID}, []string{evening}},
+		{[]int{kids.ID}, nil},
+	} {
+		ws := workshop.Workshop{
+			WorkshopID:  fmt.Sprintf("%s-%d", prefix, i),
+			Name:        "Taxonomy",
+			StartTime:   start.Add(time.Duration(i) * time.Hour),
+			EndTime:     start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			Cap:         5,
+			CategoryIDs: w.categoryIDs,
+			Tags:        w.tags,
+		}
+		if err := db.InsertWorkshop(ws); err != nil {
+			t.Fatal(err)
+		}
+		workshopIDs = append(workshopIDs, ws.WorkshopID)
+	}
+	event := workshop.Event{ID: prefix + "-event", Name: "Open day", StartTime: start, EndTime: start.Add(time.Hour), CategoryIDs: []int{ceramics.ID}, Tags: []string{beginner}}
+	if err := db.InsertEvent(event); err != nil {
+		t.Fatal(err)
+	}
+	unknown := workshop.Workshop{WorkshopID: prefix + "-unknown", Name: "Unknown", StartTime: start, EndTime: start.Add(time.Hour), Cap: 5, CategoryIDs: []int{kids.ID + 1000}}
+	if err := db.InsertWorkshop(unknown); err != ErrUnknownCategory {
+		t.Errorf("filing under an unknown category: got %v, want %v", err, ErrUnknownCategory)
+	}
+
+	listed := func(f workshop.Filter) (string, string) {
+		workshops, _, err := db.ListWorkshops(f, workshop.Page{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, _, err := db.ListEvents(f, workshop.Page{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var w, e []string
+		for _, ws := range workshops {
+			w = append(w, ws.WorkshopID[len(prefix)+1:])
+		}
+		for _, ev := range events {
+			e = append(e, ev.ID[len(prefix)+1:])
+		}
+		return fmt.Sprint(w), fmt.Sprint(e)
+	}
+	for _, c := range []struct {
+		name              string
+		filter            workshop.Filter
+		workshops, events string
+	}{
+		{"ceramics", workshop.Filter{Category: ceramics.Slug}, "[0 1]", "[event]"},
+		{"wheel", workshop.Filter{Category: wheel.Slug}, "[0 1]", "[]"},
+		{"raku", workshop.Filter{Category: raku.Slug}, "[0]", "[]"},
+		{"kids", workshop.Filter{Category: kids.Slug}, "[1 2]", "[]"},
+		{"evening", workshop.Filter{Tags: []string{evening}}, "[0 1]", "[]"},
+		{"beginner", workshop.Filter{Tags: []string{beginner}}, "[0]", "[event]"},
+		{"evening and beginner", workshop.Filter{Tags: []string{evening, beginner}}, "[0]", "[]"},
+		{"kids in the evening", workshop.Filter{Category: kids.Slug, Tags: []string{evening}}, "[1]", "[]"},
+		{"raku in the evening", workshop.Filter{Category: raku.Slug, Tags: []string{evening}}, "[0]", "[]"},
+		{"unknown tag", workshop.Filter{Category: ceramics.Slug, Tags: []string{"unknown " + suffix}}, "[]", "[]"},
+	} {
+		if w, e := listed(c.filter); w != c.workshops || e != c.events {
+			t.Errorf("%s: got workshops %s and events %s, want %s and %s", c.name, w, e, c.workshops, c.events)
+		}
+	}
+	if _, _, err := db.ListWorkshops(workshop.Filter{Category: "unknown-" + suffix}, workshop.Page{}); err != ErrUnknownCategory {
+		t.Errorf("unknown category: got %v, want %v", err, ErrUnknownCategory)
+	}
+
+	ws, err := db.WorkshopByID(workshopIDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Categories are loaded by name.
+	if fmt.Sprint(ws.CategoryIDs) != fmt.Sprint([]int{kids.ID, wheel.ID}) || len(ws.Categories) != 2 || ws.Categories[0].Slug != kids.Slug || fmt.Sprint(ws.Tags) != fmt.Sprint([]string{evening}) {
+		t.Errorf("got categories %v %+v and tags %q", ws.CategoryIDs, ws.Categories, ws.Tags)
+	}
+
+	counts := func() map[int]string {
+		categories, err := db.GetCategories(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[int]string)
+		for _, c := range categories {
+			got[c.ID] = fmt.Sprintf("%d/%d", c.WorkshopCount, c.EventCount)
+		}
+		return got
+	}
+	want := map[int]string{ceramics.ID: "2/1", wheel.ID: "2/0", raku.ID: "1/0", kids.ID: "2/0"}
+	got := counts()
+	for id, w := range want {
+		if got[id] != w {
+			t.Errorf("category %d counts %s, want %s", id, got[id], w)
+		}
+	}
+
+	// Saving nil keeps the categories and tags, empty clears them.
+	ws.Categories = nil
+	ws.CategoryIDs, ws.Tags = nil, nil
+	if _, err := db.UpdateWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := listed(workshop.Filter{Category: kids.Slug, Tags: []string{evening}}); w != "[1]" {
+		t.Errorf("saving nil dropped the categories or tags of 1: %s", w)
+	}
+	ws.CategoryIDs, ws.Tags = []int{}, []string{}
+	if _, err := db.UpdateWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := listed(workshop.Filter{Category: kids.Slug}); w != "[2]" {
+		t.Errorf("kids after clearing 1: got %s, want [2]", w)
+	}
+	if w, _ := listed(workshop.Filter{Tags: []string{evening}}); w != "[0]" {
+		t.Errorf("evening after clearing 1: got %s, want [0]", w)
+	}
+
+	if err := db.DeleteCategory(wheel.ID); err != ErrCategoryHasChildren {
+		t.Errorf("deleting wheel: got %v, want %v", err, ErrCategoryHasChildren)
+	}
+	if err := db.DeleteCategory(raku.ID); err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := listed(workshop.Filter{Category: ceramics.Slug}); w != "[]" {
+		t.Errorf("ceramics after deleting raku: got %s, want []", w)
+	}
+	if ws, err := db.WorkshopByID(workshopIDs[0]); err != nil || len(ws.CategoryIDs) != 0 {
+		t.Errorf("after deleting raku 0 is filed under %v, %v", ws.CategoryIDs, err)
+	}
+
+	for _, id := range workshopIDs {
+		if err := db.DeleteWorkshop(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.DeleteEvent(event.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{wheel.ID, ceramics.ID, kids.ID} {
+		if err := db.DeleteCategory(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCategoriesAndTagsFilteredMemory(t *testing.T) {
+	categoriesAndTagsFiltered(t, NewMemoryDB())
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// ErrDuplicateCode is returned when a discount code is created twice.
+var ErrDuplicateCode = errors.New("discount code already exists")
+
+const discountColumns = "code, kind, percent, amount, currency, max_redemptions, redemptions, expires_at, created_at"
+
+func scanDiscountCode(r rowScanner) (workshop.DiscountCode, error) {
+	var (
+		d         workshop.DiscountCode
+		expiresAt mysql.NullTime
+	)
+	err := r.Scan(&d.Code, &d.Kind, &d.Percent, &d.Amount.Amount, &d.Amount.Currency, &d.MaxRedemptions, &d.Redemptions, &expiresAt, &d.CreatedAt)
+	d.ExpiresAt = expiresAt.Time
+	return d, err
+}
+
+// loadDiscountScopes fills in the workshops and categories codes are
+// restricted to, in one query per table whatever the number of codes.
+func loadDiscountScopes(db queryer, codes []workshop.DiscountCode) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	index := make(map[string]int)
+	ids := make([]string, len(codes))
+	for i, d := range codes {
+		index[d.Code] = i
+		ids[i] = d.Code
+	}
+	placeholders, args := inList(ids)
+	in := " WHERE code IN (" + placeholders + ")"
+	rows, err := db.Query("SELECT code, workshop_id FROM discount_code_workshops"+in+" ORDER BY code, workshop_id", args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var code, workshopID string
+		if err := rows.Scan(&code, &workshopID); err != nil {
+			rows.Close()
+			return err
+		}
+		d := &codes[index[code]]
+		d.WorkshopIDs = append(d.WorkshopIDs, workshopID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows, err = db.Query("SELECT code, category_id FROM discount_code_categories"+in+" ORDER BY code, category_id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			code       string
+			categoryID int
+		)
+		if err := rows.Scan(&code, &categoryID); err != nil {
+			return err
+		}
+		d := &codes[index[code]]
+		d.CategoryIDs = append(d.CategoryIDs, categoryID)
+	}
+	return rows.Err()
+}
+
+func (w workshopDB) InsertDiscountCode(d workshop.DiscountCode) error {
+	d.Code = workshop.NormalizeCode(d.Code)
+	err := transact(w.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			"INSERT INTO discount_codes ("+discountColumns+") VALUES (?,?,?,?,?,?,0,?,NOW())",
+			d.Code,
+			d.Kind,
+			d.Percent,
+			d.Amount.Amount,
+			currency(d.Amount.Currency),
+			d.MaxRedemptions,
+			nullTime(d.ExpiresAt),
+		)
+		if err != nil {
+			return err
+		}
+		seen := make(map[string]bool)
+		for _, id := range d.WorkshopIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if _, err := tx.Exec("INSERT INTO discount_code_workshops (code, workshop_id) VALUES (?, ?)", d.Code, id); err != nil {
+				return err
+			}
+		}
+		for _, id := range d.CategoryIDs {
+			if _, err := tx.Exec("INSERT INTO discount_code_categories (code, category_id) VALUES (?, ?)", d.Code, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if e, ok := err.(*mysql.MySQLError); ok {
+		switch e.Number {
+		case 1062:
+			return ErrDuplicateCode
+		case 1452:
+			return ErrUnknownCategory
+		}
+	}
+	return err
+}
+
+func (w workshopDB) GetDiscountCodes() ([]workshop.DiscountCode, error) {
+	var codes []workshop.DiscountCode
+	rows, err := w.db.Query("SELECT " + discountColumns + " FROM discount_codes ORDER BY id")
+	if err != nil {
+		return codes, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		d, err := scanDiscountCode(rows)
+		if err != nil {
+			return codes, err
+		}
+		codes = append(codes, d)
+	}
+	if err := rows.Err(); err != nil {
+		return codes, err
+	}
+	return codes, loadDiscountScopes(w.db, codes)
+}
+
+// GetRedemptions returns the signups a code has been used on.
+func (w workshopDB) GetRedemptions(code string) ([]workshop.Redemption, error) {
+	var redemptions []workshop.Redemption
+	code = workshop.NormalizeCode(code)
+	if err := w.db.QueryRow("SELECT code FROM discount_codes WHERE code = ?", code).Scan(&code); err != nil {
+		return redemptions, err
+	}
+	rows, err := w.db.Query("SELECT code, signup_id, workshop_id, amount, currency, redeemed_at FROM discount_redemptions WHERE code = ? ORDER BY id", code)
+	if err != nil {
+		return redemptions, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r workshop.Redemption
+		if err := rows.Scan(&r.Code, &r.SignUpID, &r.WorkshopID, &r.Discount.Amount, &r.Discount.Currency, &r.RedeemedAt); err != nil {
+			return redemptions, err
+		}
+		redemptions = append(redemptions, r)
+	}
+	return redemptions, rows.Err()
+}
+
+// applyDiscount takes the discount code of signup off its price. The code
+// stays locked until the transaction ends; it is only used up once the
+// signup takes a seat, see adjustRedemption.
+func applyDiscount(tx *sql.Tx, signup *workshop.SignUp, now time.Time) error {
+	signup.DiscountCode = workshop.NormalizeCode(signup.DiscountCode)
+	signup.Discount = money.Money{Currency: signup.Price.Currency}
+	if signup.DiscountCode == "" {
+		return nil
+	}
+	d, err := scanDiscountCode(tx.QueryRow("SELECT "+discountColumns+" FROM discount_codes WHERE code = ? FOR UPDATE", signup.DiscountCode))
+	if err == sql.ErrNoRows {
+		return workshop.ErrCodeUnknown
+	}
+	if err != nil {
+		return err
+	}
+	codes := []workshop.DiscountCode{d}
+	if err := loadDiscountScopes(tx, codes); err != nil {
+		return err
+	}
+	d = codes[0]
+	var categoryIDs []int
+	if len(d.CategoryIDs) > 0 {
+		if categoryIDs, err = workshopCategoryAncestry(tx, signup.WorkshopID); err != nil {
+			return err
+		}
+	}
+	discount, err := d.Apply(signup.WorkshopID, categoryIDs, signup.Price, now)
+	if err != nil {
+		return err
+	}
+	signup.Discount = discount
+	signup.Price, err = signup.Price.Sub(discount)
+	return err
+}
+
+// adjustRedemption uses up a redemption of the discount code of s when it
+// takes a seat and gives it back when it gives the seat up, so that
+// waitlisted and cancelled signups do not count against the code. A
+// waitlisted signup keeps the discount it was quoted when it is promoted,
+// even if the code has been used up meanwhile. from is empty for a new
+// signup.
+func adjustRedemption(tx *sql.Tx, s workshop.SignUp, from, to workshop.Status) error {
+	if s.DiscountCode == "" {
+		return nil
+	}
+	switch delta(from.HoldsSeat(), to.HoldsSeat()) {
+	case 1:
+		if _, err := tx.Exec("UPDATE discount_codes SET redemptions = redemptions + 1 WHERE code = ?", s.DiscountCode); err != nil {
+			return err
+		}
+		_, err := tx.Exec(
+			"INSERT INTO discount_redemptions (code, signup_id, workshop_id, amount, currency, redeemed_at) VALUES (?,?,?,?,?,?)",
+			s.DiscountCode,
+			s.ID,
+			s.WorkshopID,
+			s.Discount.Amount,
+			s.Discount.Currency,
+			s.UpdatedAt,
+		)
+		return err
+	case -1:
+		if _, err := tx.Exec("UPDATE discount_codes SET redemptions = redemptions - 1 WHERE code = ?", s.DiscountCode); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM discount_redemptions WHERE signup_id = ?", s.ID)
+		return err
+	}
+	return nil
+}
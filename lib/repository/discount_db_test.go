@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// discountRedeemedBySeats has a code given on a seat and on two waitlist
+// places and checks that only the signups holding a seat use it up, that
+// cancelling gives the redemption back and that promotion takes it.
+func discountRedeemedBySeats(t *testing.T, db WorkshopDB) {
+	suffix := time.Now().UnixNano()
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	cat, err := db.InsertCategory(workshop.Category{Name: fmt.Sprintf("Glazing %d", suffix)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := workshop.Workshop{
+		WorkshopID:  fmt.Sprintf("discount-%d", suffix),
+		Name:        "Discount",
+		StartTime:   start,
+		EndTime:     start.Add(2 * time.Hour),
+		Cap:         1,
+		Price:       money.New(10000, "EUR"),
+		CategoryIDs: []int{cat.ID},
+	}
+	if err := db.InsertWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	code := fmt.Sprintf("SPRING%d", suffix)
+	if err := db.InsertDiscountCode(workshop.DiscountCode{
+		Code:           code,
+		Kind:           workshop.DiscountPercent,
+		Percent:        10,
+		MaxRedemptions: 2,
+		WorkshopIDs:    []string{"elsewhere", "elsewhere"},
+		CategoryIDs:    []int{cat.ID},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	redeemed := func() (int, []int) {
+		codes, err := db.GetDiscountCodes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for _, d := range codes {
+			if d.Code == code {
+				n = d.Redemptions
+				if fmt.Sprint(d.WorkshopIDs) != "[elsewhere]" || fmt.Sprint(d.CategoryIDs) != fmt.Sprint([]int{cat.ID}) {
+					t.Errorf("code is restricted to workshops %v and categories %v", d.WorkshopIDs, d.CategoryIDs)
+				}
+			}
+		}
+		redemptions, err := db.GetRedemptions(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, r := range redemptions {
+			ids = append(ids, r.SignUpID)
+		}
+		return n, ids
+	}
+
+	var signups []workshop.SignUp
+	for _, name := range []string{"ada", "grace", "hedy"} {
+		su, err := db.SignUp(workshop.SignUp{WorkshopID: ws.WorkshopID, FirstName: name, Email: name + "@example.com", Price: ws.Price, DiscountCode: code}, true)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if su.Discount.Amount != 1000 || su.Price.Amount != 9000 {
+			t.Errorf("%s got %v off, paying %v; want 10.00 off", name, su.Discount, su.Price)
+		}
+		signups = append(signups, su)
+	}
+	if n, ids := redeemed(); n != 1 || fmt.Sprint(ids) != fmt.Sprint([]int{signups[0].ID}) {
+		t.Errorf("got %d redemptions by %v, want only the seated signup %d", n, ids, signups[0].ID)
+	}
+
+	if _, _, _, err := db.UpdateSignUpStatus(signups[0].ID, workshop.StatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+	if n, ids := redeemed(); n != 1 || fmt.Sprint(ids) != fmt.Sprint([]int{signups[1].ID}) {
+		t.Errorf("after a cancellation got %d redemptions by %v, want only the promoted signup %d", n, ids, signups[1].ID)
+	}
+	if _, _, _, err := db.UpdateSignUpStatus(signups[2].ID, workshop.StatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := redeemed(); n != 1 {
+		t.Errorf("cancelling a waitlisted signup left %d redemptions, want 1", n)
+	}
+
+	if err := db.DeleteCategory(cat.ID); err != ErrCategoryInUse {
+		t.Errorf("deleting the category of a code: got %v, want %v", err, ErrCategoryInUse)
+	}
+	if err := db.InsertDiscountCode(workshop.DiscountCode{Code: code + "X", Kind: workshop.DiscountPercent, Percent: 5, CategoryIDs: []int{cat.ID + 1000}}); err != ErrUnknownCategory {
+		t.Errorf("code for a missing category: got %v, want %v", err, ErrUnknownCategory)
+	}
+	if err := db.DeleteWorkshop(ws.WorkshopID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscountRedeemedBySeatsMemory(t *testing.T) {
+	discountRedeemedBySeats(t, NewMemoryDB())
+}
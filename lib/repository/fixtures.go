@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// LoadFixtures seeds the store from the INSERT statements in the sql fixtures
+// under dir. Workshops are loaded before signups so the same constraints the
+// mysql schema enforces apply; rows that violate them are logged and skipped.
+func (m *memoryDB) LoadFixtures(dir string) error {
+	for _, name := range []string{"workshops.sql", "events.sql", "signups.sql"} {
+		rows, err := readFixture(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := m.loadFixtureRow(row); err != nil {
+				log.Printf("skipping fixture row from %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+type fixtureRow struct {
+	table  string
+	values map[string]string
+}
+
+func (m *memoryDB) loadFixtureRow(row fixtureRow) error {
+	v := row.values
+	switch row.table {
+	case "workshops":
+		cap, err := strconv.Atoi(v["cap"])
+		if err != nil {
+			return err
+		}
+		start, end, err := fixtureTimes(v)
+		if err != nil {
+			return err
+		}
+		price, err := fixturePrice(v)
+		if err != nil {
+			return err
+		}
+		return m.InsertWorkshop(workshop.Workshop{
+			WorkshopID:  v["workshop_id"],
+			Name:        v["name"],
+			Description: v["description"],
+			StartTime:   start,
+			EndTime:     end,
+			Timezone:    v["timezone"],
+			Cap:         cap,
+			Price:       price,
+			Location:    v["location"],
+			Level:       v["level"],
+			Caption:     v["caption"],
+		})
+	case "events":
+		start, end, err := fixtureTimes(v)
+		if err != nil {
+			return err
+		}
+		price, err := fixturePrice(v)
+		if err != nil {
+			return err
+		}
+		return m.InsertEvent(workshop.Event{
+			ID:          v["event_id"],
+			Name:        v["name"],
+			Description: v["description"],
+			StartTime:   start,
+			EndTime:     end,
+			Timezone:    v["timezone"],
+			Price:       price,
+			Location:    v["location"],
+			Caption:     v["caption"],
+		})
+	case "signups":
+		_, err := m.SignUp(workshop.SignUp{
+			WorkshopID: v["workshop_id"],
+			FirstName:  v["first_name"],
+			LastName:   v["last_name"],
+			Email:      v["email"],
+			Message:    v["message"],
+		}, true)
+		return err
+	}
+	return fmt.Errorf("unknown table %q", row.table)
+}
+
+func fixtureTimes(v map[string]string) (time.Time, time.Time, error) {
+	start, err := parseTime(v["start_time"])
+	if err != nil {
+		return start, start, err
+	}
+	end, err := parseTime(v["end_time"])
+	return start, end, err
+}
+
+func fixturePrice(v map[string]string) (money.Money, error) {
+	amount, err := strconv.ParseInt(v["price_amount"], 10, 64)
+	return money.New(amount, v["currency"]), err
+}
+
+// readFixture parses one
+// `INSERT INTO workshop.<table> (<columns>) VALUES(...);` statement per line.
+func readFixture(path string) ([]fixtureRow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows []fixtureRow
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		row, err := parseInsert(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+func parseInsert(stmt string) (fixtureRow, error) {
+	const prefix = "INSERT INTO "
+	if !strings.HasPrefix(stmt, prefix) {
+		return fixtureRow{}, fmt.Errorf("not an insert statement: %.40q", stmt)
+	}
+	rest := stmt[len(prefix):]
+	colsOpen := strings.Index(rest, "(")
+	colsEnd := strings.Index(rest, ")")
+	valuesOpen := strings.Index(rest, "VALUES(")
+	end := strings.LastIndex(rest, ")")
+	if colsOpen < 0 || colsEnd < colsOpen || valuesOpen < colsEnd || end < valuesOpen {
+		return fixtureRow{}, fmt.Errorf("malformed insert statement: %.40q", stmt)
+	}
+	table := strings.TrimSpace(rest[:colsOpen])
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+	columns := strings.Split(rest[colsOpen+1:colsEnd], ",")
+	values, err := splitValues(rest[valuesOpen+len("VALUES(") : end])
+	if err != nil {
+		return fixtureRow{}, err
+	}
+	if len(columns) != len(values) {
+		return fixtureRow{}, fmt.Errorf("%s row has %d columns but %d values", table, len(columns), len(values))
+	}
+	row := fixtureRow{table: table, values: make(map[string]string)}
+	for i, c := range columns {
+		row.values[strings.TrimSpace(c)] = values[i]
+	}
+	return row, nil
+}
+
+// splitValues splits a sql value list on commas outside of single quoted
+// strings and unquotes the strings.
+func splitValues(list string) ([]string, error) {
+	var (
+		values  []string
+		cur     strings.Builder
+		inQuote bool
+	)
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+		switch {
+		case inQuote && c == '\\' && i+1 < len(list):
+			i++
+			cur.WriteByte(list[i])
+		case inQuote && c == '\'' && i+1 < len(list) && list[i+1] == '\'':
+			i++
+			cur.WriteByte('\'')
+		case c == '\'':
+			inQuote = !inQuote
+		case !inQuote && c == ',':
+			values = append(values, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated string in %.40q", list)
+	}
+	return append(values, strings.TrimSpace(cur.String())), nil
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"06-01-02 15:04:05",
+}
+
+// parseTime reads fixture DATETIME values, which are in UTC, in the formats
+// mysql accepts for them.
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised time %q", s)
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+// HoldSeatUntil moves the end of a pending signup's seat hold to until, e.g.
+// to when its checkout expires. Signups that are no longer pending are left
+// alone.
+func (w workshopDB) HoldSeatUntil(signupID int, until time.Time) error {
+	_, err := w.db.Exec(
+		"UPDATE signups SET hold_expires_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		until.UTC(), time.Now().UTC(), signupID, workshop.StatusPending,
+	)
+	return err
+}
+
+// ReleaseExpiredHolds cancels the pending signups whose hold expired by now,
+// expires their open payments and promotes from the waitlists onto the freed
+// seats. It returns the released and the promoted signups. Each workshop is
+// released in its own transaction, so one busy workshop does not hold up the
+// others: a workshop that fails is left for the next sweep and the first
+// error is returned once the rest have been released.
+func (w workshopDB) ReleaseExpiredHolds(now time.Time) ([]workshop.SignUp, []workshop.SignUp, error) {
+	var released, promoted []workshop.SignUp
+	rows, err := w.db.Query("SELECT DISTINCT workshop_id FROM signups WHERE status = ? AND hold_expires_at <= ?", workshop.StatusPending, now.UTC())
+	if err != nil {
+		return nil, nil, err
+	}
+	var workshopIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		workshopIDs = append(workshopIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	var failed error
+	for _, id := range workshopIDs {
+		var r, p []workshop.SignUp
+		err := transact(w.db, func(tx *sql.Tx) error {
+			var err error
+			r, p, err = w.releaseExpiredHolds(tx, id, now)
+			return err
+		})
+		if err != nil {
+			if failed == nil {
+				failed = err
+			}
+			continue
+		}
+		released = append(released, r...)
+		promoted = append(promoted, p...)
+	}
+	return released, promoted, failed
+}
+
+func (w workshopDB) releaseExpiredHolds(tx *sql.Tx, workshopID string, now time.Time) ([]workshop.SignUp, []workshop.SignUp, error) {
+	if _, err := lockWorkshop(tx, workshopID); err != nil {
+		return nil, nil, err
+	}
+	rows, err := tx.Query(
+		"SELECT "+signupColumns+" FROM signups WHERE workshop_id = ? AND status = ? AND hold_expires_at <= ? ORDER BY id FOR UPDATE",
+		workshopID, workshop.StatusPending, now.UTC(),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	var released []workshop.SignUp
+	for rows.Next() {
+		s, err := scanSignUp(rows)
+		if err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		released = append(released, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(released) == 0 {
+		return nil, nil, nil
+	}
+	for i := range released {
+		if err := setStatus(tx, &released[i], workshop.StatusCancelled); err != nil {
+			return nil, nil, err
+		}
+		if _, err := tx.Exec(
+			"UPDATE payments SET status = ?, updated_at = ? WHERE signup_id = ? AND status = ?",
+			workshop.PaymentExpired, released[i].UpdatedAt, released[i].ID, workshop.PaymentOpen,
+		); err != nil {
+			return nil, nil, err
+		}
+	}
+	promoted, err := w.promote(tx, workshopID)
+	return released, promoted, err
+}
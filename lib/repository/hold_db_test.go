@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReleaseExpiredHoldsSkipsFailedWorkshops(t *testing.T) {
+	db, store := openCountingDB(t, 3)
+	defer db.db.Close()
+	// The counting driver answers every workshop's query with the same
+	// three signups, so each workshop released adds three of them.
+	busy := errors.New("lock wait timeout")
+	store.fail = func(query string, args []driver.Value) error {
+		// Fail the first workshop once its signups have been cancelled,
+		// when it promotes onto the freed seats.
+		if strings.HasPrefix(query, "SELECT cap, signup_count FROM workshops") && args[0] == "workshop-0" {
+			return busy
+		}
+		return nil
+	}
+	released, promoted, err := db.ReleaseExpiredHolds(time.Now())
+	if err != busy {
+		t.Errorf("got error %v, want %v", err, busy)
+	}
+	if len(released) != 6 || len(promoted) != 0 {
+		t.Errorf("got %d released and %d promoted, want the 6 signups of the two workshops that committed", len(released), len(promoted))
+	}
+}
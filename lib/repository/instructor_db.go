@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/workshop"
+)
+
+// ErrUnknownInstructor is returned when a workshop is assigned an
+// instructor that does not exist.
+var ErrUnknownInstructor = errors.New("unknown instructor")
+
+const instructorColumns = "id, name, bio, photo_key, email, phone, website, created_at, updated_at"
+
+func scanInstructor(r rowScanner) (workshop.Instructor, error) {
+	var i workshop.Instructor
+	err := r.Scan(&i.ID, &i.Name, &i.Bio, &i.PhotoKey, &i.Email, &i.Phone, &i.Website, &i.CreatedAt, &i.UpdatedAt)
+	return i, err
+}
+
+func (w workshopDB) InsertInstructor(i workshop.Instructor) (workshop.Instructor, error) {
+	i.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	i.UpdatedAt = i.CreatedAt
+	res, err := w.db.Exec(
+		"INSERT INTO instructors (name, bio, photo_key, email, phone, website, created_at, updated_at) VALUES (?,?,?,?,?,?,?,?)",
+		i.Name, i.Bio, i.PhotoKey, i.Email, i.Phone, i.Website, i.CreatedAt, i.UpdatedAt,
+	)
+	if err != nil {
+		return i, err
+	}
+	id, err := res.LastInsertId()
+	i.ID = int(id)
+	return i, err
+}
+
+func (w workshopDB) InstructorByID(instructorID int) (workshop.Instructor, error) {
+	return scanInstructor(w.db.QueryRow("SELECT "+instructorColumns+" FROM instructors WHERE id = ?", instructorID))
+}
+
+// GetInstructors lists every instructor by name.
+func (w workshopDB) GetInstructors() ([]workshop.Instructor, error) {
+	var instructors []workshop.Instructor
+	rows, err := w.db.Query("SELECT " + instructorColumns + " FROM instructors ORDER BY name, id")
+	if err != nil {
+		return instructors, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		i, err := scanInstructor(rows)
+		if err != nil {
+			return instructors, err
+		}
+		instructors = append(instructors, i)
+	}
+	return instructors, rows.Err()
+}
+
+// UpdateInstructor saves the profile of i and returns it as stored.
+func (w workshopDB) UpdateInstructor(i workshop.Instructor) (workshop.Instructor, error) {
+	var saved workshop.Instructor
+	err := transact(w.db, func(tx *sql.Tx) error {
+		if err := tx.QueryRow("SELECT id FROM instructors WHERE id = ? FOR UPDATE", i.ID).Scan(&i.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE instructors SET name=?, bio=?, photo_key=?, email=?, phone=?, website=?, updated_at=? WHERE id=?",
+			i.Name, i.Bio, i.PhotoKey, i.Email, i.Phone, i.Website, time.Now().UTC(), i.ID,
+		); err != nil {
+			return err
+		}
+		var err error
+		saved, err = scanInstructor(tx.QueryRow("SELECT "+instructorColumns+" FROM instructors WHERE id = ?", i.ID))
+		return err
+	})
+	return saved, err
+}
+
+// DeleteInstructor removes an instructor along with their assignments.
+func (w workshopDB) DeleteInstructor(instructorID int) error {
+	res, err := w.db.Exec("DELETE FROM instructors WHERE id = ?", instructorID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetWorkshopsByInstructorID lists the workshops an instructor is assigned
+// to, earliest first.
+func (w workshopDB) GetWorkshopsByInstructorID(instructorID int) ([]workshop.Workshop, error) {
+	if err := w.db.QueryRow("SELECT id FROM instructors WHERE id = ?", instructorID).Scan(&instructorID); err != nil {
+		return nil, err
+	}
+	return queryWorkshops(w.db, "WHERE w.workshop_id IN (SELECT workshop_id FROM workshop_instructors WHERE instructor_id = ?) ORDER BY w.start_time, w.id", instructorID)
+}
+
+// loadInstructors fills in the instructors of workshops in one query.
+func loadInstructors(db queryer, workshops []workshop.Workshop) error {
+	if len(workshops) == 0 {
+		return nil
+	}
+	ids := make([]string, len(workshops))
+	for i, ws := range workshops {
+		ids[i] = ws.WorkshopID
+	}
+	placeholders, args := inList(ids)
+	rows, err := db.Query("SELECT wi.workshop_id, i.id, i.name, i.bio, i.photo_key, i.email, i.phone, i.website, i.created_at, i.updated_at FROM workshop_instructors wi JOIN instructors i ON i.id = wi.instructor_id WHERE wi.workshop_id IN ("+placeholders+") ORDER BY wi.workshop_id, wi.position", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	instructors := make(map[string][]workshop.Instructor)
+	for rows.Next() {
+		var (
+			workshopID string
+			i          workshop.Instructor
+		)
+		if err := rows.Scan(&workshopID, &i.ID, &i.Name, &i.Bio, &i.PhotoKey, &i.Email, &i.Phone, &i.Website, &i.CreatedAt, &i.UpdatedAt); err != nil {
+			return err
+		}
+		instructors[workshopID] = append(instructors[workshopID], i)
+	}
+	for i := range workshops {
+		workshops[i].Instructors = instructors[workshops[i].WorkshopID]
+		workshops[i].InstructorIDs = nil
+		for _, in := range workshops[i].Instructors {
+			workshops[i].InstructorIDs = append(workshops[i].InstructorIDs, in.ID)
+		}
+	}
+	return rows.Err()
+}
+
+func insertInstructorAssignments(db execer, ws workshop.Workshop) error {
+	for i, id := range ws.InstructorIDs {
+		_, err := db.Exec("INSERT INTO workshop_instructors (workshop_id, instructor_id, position) VALUES (?,?,?)", ws.WorkshopID, id, i+1)
+		if e, ok := err.(*mysql.MySQLError); ok && e.Number == 1452 {
+			return ErrUnknownInstructor
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceInstructorAssignments assigns the instructors of ws in place of
+// the current ones. Nil InstructorIDs leave the current ones alone.
+func replaceInstructorAssignments(tx *sql.Tx, ws workshop.Workshop) error {
+	if ws.InstructorIDs == nil {
+		return nil
+	}
+	if _, err := tx.Exec("DELETE FROM workshop_instructors WHERE workshop_id = ?", ws.WorkshopID); err != nil {
+		return err
+	}
+	return insertInstructorAssignments(tx, ws)
+}
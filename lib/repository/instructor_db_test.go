@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+// instructorsAssigned assigns two instructors to a workshop in the order
+// given, reassigns them and deletes one, checking what the workshop and
+// each instructor's schedule show along the way.
+func instructorsAssigned(t *testing.T, db WorkshopDB) {
+	var ids []int
+	for _, name := range []string{"Anna", "Ben"} {
+		in, err := db.InsertInstructor(workshop.Instructor{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, in.ID)
+	}
+	anna, ben := ids[0], ids[1]
+	instructorsOf := func(workshopID string) []int {
+		ws, err := db.WorkshopByID(workshopID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, in := range ws.Instructors {
+			got = append(got, in.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(ws.InstructorIDs) {
+			t.Errorf("workshop lists instructors %v and IDs %v", got, ws.InstructorIDs)
+		}
+		return got
+	}
+	workshopsOf := func(instructorID int) []string {
+		workshops, err := db.GetWorkshopsByInstructorID(instructorID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, ws := range workshops {
+			got = append(got, ws.WorkshopID)
+		}
+		return got
+	}
+
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	prefix := fmt.Sprintf("instructors-%d", time.Now().UnixNano())
+	var workshopIDs []string
+	for i, assigned := range [][]int{{ben, anna}, {anna}} {
+		ws := workshop.Workshop{
+			WorkshopID:    fmt.Sprintf("%s-%d", prefix, i),
+			Name:          "Instructors",
+			StartTime:     start.AddDate(0, 0, 1-i),
+			EndTime:       start.AddDate(0, 0, 1-i).Add(2 * time.Hour),
+			Cap:           5,
+			InstructorIDs: assigned,
+		}
+		if err := db.InsertWorkshop(ws); err != nil {
+			t.Fatal(err)
+		}
+		workshopIDs = append(workshopIDs, ws.WorkshopID)
+	}
+	if got := instructorsOf(workshopIDs[0]); fmt.Sprint(got) != fmt.Sprint([]int{ben, anna}) {
+		t.Errorf("got instructors %v, want %v in the order assigned", got, []int{ben, anna})
+	}
+	// Earliest first.
+	if got := workshopsOf(anna); fmt.Sprint(got) != fmt.Sprint([]string{workshopIDs[1], workshopIDs[0]}) {
+		t.Errorf("Anna teaches %v, want %v", got, []string{workshopIDs[1], workshopIDs[0]})
+	}
+
+	unknown := workshop.Workshop{WorkshopID: prefix + "-unknown", Name: "Unknown", StartTime: start, EndTime: start.Add(time.Hour), Cap: 5, InstructorIDs: []int{anna, ben + 1000}}
+	if err := db.InsertWorkshop(unknown); err != ErrUnknownInstructor {
+		t.Errorf("assigning an unknown instructor: got %v, want %v", err, ErrUnknownInstructor)
+	}
+	if _, err := db.WorkshopByID(unknown.WorkshopID); err != sql.ErrNoRows {
+		t.Errorf("workshop with an unknown instructor was stored: %v", err)
+	}
+
+	ws, err := db.WorkshopByID(workshopIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Updates without instructors keep the ones assigned.
+	ws.InstructorIDs, ws.Instructors = nil, nil
+	if _, err := db.UpdateWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	if got := instructorsOf(ws.WorkshopID); fmt.Sprint(got) != fmt.Sprint([]int{ben, anna}) {
+		t.Errorf("update without instructors left %v, want %v", got, []int{ben, anna})
+	}
+	ws.InstructorIDs = []int{anna, ben}
+	if _, err := db.UpdateWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	if got := instructorsOf(ws.WorkshopID); fmt.Sprint(got) != fmt.Sprint([]int{anna, ben}) {
+		t.Errorf("reordering instructors left %v, want %v", got, []int{anna, ben})
+	}
+	ws.InstructorIDs = []int{ben + 1000}
+	if _, err := db.UpdateWorkshop(ws); err != ErrUnknownInstructor {
+		t.Errorf("reassigning to an unknown instructor: got %v, want %v", err, ErrUnknownInstructor)
+	}
+	if got := instructorsOf(ws.WorkshopID); fmt.Sprint(got) != fmt.Sprint([]int{anna, ben}) {
+		t.Errorf("failed update left %v, want %v", got, []int{anna, ben})
+	}
+
+	if err := db.DeleteInstructor(anna); err != nil {
+		t.Fatal(err)
+	}
+	if got := instructorsOf(workshopIDs[0]); fmt.Sprint(got) != fmt.Sprint([]int{ben}) {
+		t.Errorf("after deleting Anna got instructors %v, want %v", got, []int{ben})
+	}
+	if got := instructorsOf(workshopIDs[1]); len(got) != 0 {
+		t.Errorf("after deleting Anna got instructors %v, want none", got)
+	}
+	if _, err := db.GetWorkshopsByInstructorID(anna); err != sql.ErrNoRows {
+		t.Errorf("schedule of a deleted instructor: got %v, want %v", err, sql.ErrNoRows)
+	}
+	if err := db.DeleteInstructor(anna); err != sql.ErrNoRows {
+		t.Errorf("deleting Anna again: got %v, want %v", err, sql.ErrNoRows)
+	}
+	for _, id := range workshopIDs {
+		if err := db.DeleteWorkshop(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.DeleteInstructor(ben); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstructorsAssignedMemory(t *testing.T) {
+	instructorsAssigned(t, NewMemoryDB())
+}
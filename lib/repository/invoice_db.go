@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/workshop"
+)
+
+const invoiceColumns = "id, number, signup_id, workshop_id, issued_at, service_date, seller_name, seller_address, seller_email, seller_vat_id, seller_tax_number, buyer_name, buyer_email, buyer_company, buyer_address, description, vat_rate, net_amount, vat_amount, gross_amount, currency"
+
+func scanInvoice(r rowScanner) (workshop.Invoice, error) {
+	var inv workshop.Invoice
+	err := r.Scan(
+		&inv.ID, &inv.Number, &inv.SignUpID, &inv.WorkshopID, &inv.IssuedAt, &inv.ServiceDate,
+		&inv.Seller.Name, &inv.Seller.Address, &inv.Seller.Email, &inv.Seller.VATID, &inv.Seller.TaxNumber,
+		&inv.Buyer.Name, &inv.Buyer.Email, &inv.Buyer.Company, &inv.Buyer.Address,
+		&inv.Description, &inv.VATRate, &inv.Net.Amount, &inv.VAT.Amount, &inv.Gross.Amount, &inv.Gross.Currency,
+	)
+	inv.Net.Currency = inv.Gross.Currency
+	inv.VAT.Currency = inv.Gross.Currency
+	return inv, err
+}
+
+// InsertInvoice numbers and stores an invoice. Numbers run without gaps
+// per year of issue: the sequence is bumped in the same transaction as the
+// insert, so a failed insert gives its number back. A signup is invoiced at
+// most once.
+func (w workshopDB) InsertInvoice(inv workshop.Invoice) (workshop.Invoice, error) {
+	err := transact(w.db, func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRow("SELECT id FROM invoices WHERE signup_id = ?", inv.SignUpID).Scan(&exists)
+		if err == nil {
+			return workshop.ErrInvoiceExists
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+		year := inv.IssuedAt.Year()
+		if _, err := tx.Exec("INSERT INTO invoice_sequences (year, last) VALUES (?, 1) ON DUPLICATE KEY UPDATE last = last + 1", year); err != nil {
+			return err
+		}
+		var seq int
+		if err := tx.QueryRow("SELECT last FROM invoice_sequences WHERE year = ?", year).Scan(&seq); err != nil {
+			return err
+		}
+		inv.Number = workshop.InvoiceNumber(year, seq)
+		res, err := tx.Exec(
+			"INSERT INTO invoices (number, signup_id, workshop_id, issued_at, service_date, seller_name, seller_address, seller_email, seller_vat_id, seller_tax_number, buyer_name, buyer_email, buyer_company, buyer_address, description, vat_rate, net_amount, vat_amount, gross_amount, currency) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+			inv.Number,
+			inv.SignUpID,
+			inv.WorkshopID,
+			inv.IssuedAt,
+			inv.ServiceDate,
+			inv.Seller.Name,
+			inv.Seller.Address,
+			inv.Seller.Email,
+			inv.Seller.VATID,
+			inv.Seller.TaxNumber,
+			inv.Buyer.Name,
+			inv.Buyer.Email,
+			inv.Buyer.Company,
+			inv.Buyer.Address,
+			inv.Description,
+			inv.VATRate,
+			inv.Net.Amount,
+			inv.VAT.Amount,
+			inv.Gross.Amount,
+			currency(inv.Gross.Currency),
+		)
+		if e, ok := err.(*mysql.MySQLError); ok && e.Number == 1062 {
+			return workshop.ErrInvoiceExists
+		}
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		inv.ID = int(id)
+		return err
+	})
+	return inv, err
+}
+
+func (w workshopDB) InvoiceByNumber(number string) (workshop.Invoice, error) {
+	return scanInvoice(w.db.QueryRow("SELECT "+invoiceColumns+" FROM invoices WHERE number = ?", number))
+}
+
+func (w workshopDB) InvoiceBySignUpID(signupID int) (workshop.Invoice, error) {
+	return scanInvoice(w.db.QueryRow("SELECT "+invoiceColumns+" FROM invoices WHERE signup_id = ?", signupID))
+}
+
+// GetInvoices lists invoices in the order they were issued, of one workshop
+// or, for an empty workshopID, of all of them.
+func (w workshopDB) GetInvoices(workshopID string) ([]workshop.Invoice, error) {
+	where, args := "", []interface{}{}
+	if workshopID != "" {
+		where, args = "WHERE workshop_id = ?", append(args, workshopID)
+	}
+	var invoices []workshop.Invoice
+	rows, err := w.db.Query("SELECT "+invoiceColumns+" FROM invoices "+where+" ORDER BY id", args...)
+	if err != nil {
+		return invoices, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			return invoices, err
+		}
+		invoices = append(invoices, inv)
+	}
+	return invoices, rows.Err()
+}
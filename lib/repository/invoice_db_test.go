@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// invoicesNumberedPerYear issues invoices in year and the year after, out of
+// order, and checks that each year is numbered from one without gaps and
+// that a signup is invoiced only once. Neither year may have invoices yet.
+func invoicesNumberedPerYear(t *testing.T, db WorkshopDB, year int) {
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	ws := workshop.Workshop{
+		WorkshopID: fmt.Sprintf("invoices-%d", time.Now().UnixNano()),
+		Name:       "Invoices",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Cap:        5,
+		Price:      money.New(4500, "EUR"),
+	}
+	if err := db.InsertWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	newYear := time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, c := range []struct {
+		at   time.Time
+		want string
+	}{
+		{newYear.Add(-time.Second), workshop.InvoiceNumber(year, 1)},
+		{newYear, workshop.InvoiceNumber(year+1, 1)},
+		// Late invoices for the old year carry on its sequence.
+		{newYear.Add(-time.Hour), workshop.InvoiceNumber(year, 2)},
+		{newYear.Add(time.Hour), workshop.InvoiceNumber(year+1, 2)},
+	} {
+		su, err := db.SignUp(workshop.SignUp{
+			WorkshopID: ws.WorkshopID,
+			FirstName:  fmt.Sprintf("Invoiced%d", i),
+			Email:      fmt.Sprintf("invoiced%d@example.com", i),
+			Price:      ws.Price,
+		}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inv := workshop.Invoice{SignUpID: su.ID, WorkshopID: ws.WorkshopID, IssuedAt: c.at, VATRate: workshop.StandardVATRate, Gross: su.Price}
+		inv.Net, inv.VAT = workshop.SplitVAT(inv.Gross, inv.VATRate)
+		issued, err := db.InsertInvoice(inv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if issued.Number != c.want {
+			t.Errorf("invoice issued at %v: got %s, want %s", c.at, issued.Number, c.want)
+		}
+		stored, err := db.InvoiceByNumber(c.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.SignUpID != su.ID || stored.Net != inv.Net || stored.VAT != inv.VAT {
+			t.Errorf("stored %+v, issued %+v", stored, issued)
+		}
+		inv.IssuedAt = c.at.Add(time.Minute)
+		if _, err := db.InsertInvoice(inv); err != workshop.ErrInvoiceExists {
+			t.Errorf("invoicing signup %d again: got %v, want %v", su.ID, err, workshop.ErrInvoiceExists)
+		}
+	}
+	if err := db.DeleteWorkshop(ws.WorkshopID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvoicesNumberedPerYearMemory(t *testing.T) {
+	invoicesNumberedPerYear(t, NewMemoryDB(), 2030)
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/workshop/lib/workshop"
+)
+
+// sortColumns are the columns each sort field orders by.
+var sortColumns = map[string]string{
+	"start": "start_time",
+	"price": "price_amount",
+	"name":  "name",
+}
+
+// ListWorkshops returns a page of the workshops matching filter and the
+// cursor of the next page, or "" on the last one.
+func (w workshopDB) ListWorkshops(filter workshop.Filter, page workshop.Page) ([]workshop.Workshop, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	where, args, err := listWhere(w.db, filter, page, workshopOffering, "w")
+	if err != nil {
+		return nil, "", err
+	}
+	workshops, err := queryWorkshops(w.db, where, args...)
+	if err != nil || len(workshops) <= page.Limit {
+		return workshops, "", err
+	}
+	workshops = workshops[:page.Limit]
+	last := workshops[page.Limit-1]
+	return workshops, workshop.Cursor{Sort: page.Sort, Key: last.SortKey(page.Sort), ID: last.WorkshopID}.Encode(), nil
+}
+
+// ListEvents returns a page of the events matching filter and the cursor of
+// the next page, or "" on the last one.
+func (w workshopDB) ListEvents(filter workshop.Filter, page workshop.Page) ([]workshop.Event, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	where, args, err := listWhere(w.db, filter, page, eventOffering, "e")
+	if err != nil {
+		return nil, "", err
+	}
+	events, err := queryEvents(w.db, where, args...)
+	if err != nil || len(events) <= page.Limit {
+		return events, "", err
+	}
+	events = events[:page.Limit]
+	last := events[page.Limit-1]
+	return events, workshop.Cursor{Sort: page.Sort, Key: last.SortKey(page.Sort), ID: last.ID}.Encode(), nil
+}
+
+// listWhere turns filter and page into the WHERE, ORDER BY and LIMIT of a
+// listing of the offerings of kind, selected as alias, after page has been
+// validated. One more row than the page holds is asked for, to tell
+// whether another page follows.
+func listWhere(db queryer, filter workshop.Filter, page workshop.Page, kind, alias string) (string, []interface{}, error) {
+	conds, args, err := filterWhere(db, filter, kind, alias)
+	if err != nil {
+		return "", nil, err
+	}
+	column := alias + "." + sortColumns[page.Sort.Field()]
+	id := alias + "." + kind + "_id"
+	op, dir := ">", "ASC"
+	if page.Sort.Desc() {
+		op, dir = "<", "DESC"
+	}
+	if page.Cursor != "" {
+		c, err := workshop.DecodeCursor(page.Cursor, page.Sort)
+		if err != nil {
+			return "", nil, err
+		}
+		v, _ := c.Value()
+		conds = append(conds, "("+column+" "+op+" ? OR ("+column+" = ? AND "+id+" "+op+" ?))")
+		args = append(args, v, v, c.ID)
+	}
+	var where string
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ") + " "
+	}
+	args = append(args, page.Limit+1)
+	return where + "ORDER BY " + column + " " + dir + ", " + id + " " + dir + " LIMIT ?", args, nil
+}
+
+// filterWhere turns f into conditions on the offerings of kind, selected
+// as alias. An unknown category is ErrUnknownCategory.
+func filterWhere(db queryer, f workshop.Filter, kind, alias string) ([]string, []interface{}, error) {
+	if err := f.Validate(); err != nil {
+		return nil, nil, err
+	}
+	var (
+		conds  []string
+		args   []interface{}
+		column = alias + "." + kind + "_id"
+	)
+	if f.Category != "" {
+		all, err := queryCategories(db, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		id := 0
+		for _, c := range all {
+			if c.Slug == f.Category {
+				id = c.ID
+			}
+		}
+		if id == 0 {
+			return nil, nil, ErrUnknownCategory
+		}
+		ids := workshop.Subtree(all, id)
+		conds = append(conds, column+" IN (SELECT "+kind+"_id FROM "+kind+"_categories WHERE category_id IN ("+strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")+"))")
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	for _, tag := range f.Tags {
+		conds = append(conds, column+" IN (SELECT ot."+kind+"_id FROM "+kind+"_tags ot JOIN tags t ON t.id = ot.tag_id WHERE t.name = ?)")
+		args = append(args, tag)
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, alias+".start_time >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, alias+".start_time < ?")
+		args = append(args, f.To.UTC())
+	}
+	if f.Location != "" {
+		conds = append(conds, alias+".location LIKE ?")
+		args = append(args, "%"+likeEscaper.Replace(f.Location)+"%")
+	}
+	if f.VenueID != 0 {
+		conds = append(conds, alias+".room_id IN (SELECT id FROM rooms WHERE venue_id = ?)")
+		args = append(args, f.VenueID)
+	}
+	if f.MinPrice != nil {
+		conds = append(conds, alias+".price_amount >= ?")
+		args = append(args, *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		conds = append(conds, alias+".price_amount <= ?")
+		args = append(args, *f.MaxPrice)
+	}
+	if kind == eventOffering && (f.Level != "" || f.Available) {
+		// Events have neither a level nor seats.
+		conds = append(conds, "FALSE")
+	}
+	if kind == workshopOffering && f.Level != "" {
+		conds = append(conds, alias+".level = ?")
+		args = append(args, f.Level)
+	}
+	if kind == workshopOffering && f.Available {
+		conds = append(conds, alias+".cancelled_at IS NULL AND "+alias+".signup_count < "+alias+".cap")
+	}
+	return conds, args, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
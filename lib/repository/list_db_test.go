@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// listingsPaged pages through workshops sharing start times, prices and
+// names, one sort after another and in both directions, and checks that
+// ties are broken by id, that filters hold across pages and that cursors
+// which were tampered with or made for another sort are refused.
+func listingsPaged(t *testing.T, db WorkshopDB) {
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	tag, evening := "paged "+suffix, "evening "+suffix
+	prefix := "paged-" + suffix
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	for _, w := range []struct {
+		id    string
+		start time.Time
+		price int64
+		name  string
+		tags  []string
+	}{
+		{"a", start, 4500, "Raku", []string{tag}},
+		{"b", start, 4500, "raku", []string{tag}},
+		{"c", start, 3000, "Wheel", []string{evening, tag}},
+		{"d", start.Add(time.Hour), 4500, "Glaze", []string{evening, tag}},
+		{"e", start.Add(-time.Hour), 6000, "Kids", []string{tag}},
+	} {
+		ws := workshop.Workshop{
+			WorkshopID: prefix + "-" + w.id,
+			Name:       w.name,
+			StartTime:  w.start,
+			EndTime:    w.start.Add(time.Hour),
+			Cap:        5,
+			Price:      money.New(w.price, "EUR"),
+			Tags:       w.tags,
+		}
+		if err := db.InsertWorkshop(ws); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"y", "x"} {
+		e := workshop.Event{ID: prefix + "-" + id, Name: "Open day", StartTime: start, EndTime: start.Add(time.Hour), Tags: []string{tag}}
+		if err := db.InsertEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// walk lists every page of f sorted by s, limit at a time, and returns
+	// the ids, a page to a group.
+	walk := func(f workshop.Filter, s workshop.Sort, limit int) string {
+		var pages []string
+		cursor := ""
+		for {
+			workshops, next, err := db.ListWorkshops(f, workshop.Page{Sort: s, Limit: limit, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("%s: %v", s, err)
+			}
+			var ids []string
+			for _, ws := range workshops {
+				ids = append(ids, strings.TrimPrefix(ws.WorkshopID, prefix+"-"))
+			}
+			pages = append(pages, strings.Join(ids, ""))
+			if next == "" {
+				return strings.Join(pages, " ")
+			}
+			if len(pages) > 10 {
+				t.Fatalf("%s: pages do not end: %v", s, pages)
+			}
+			cursor = next
+		}
+	}
+	all := workshop.Filter{Tags: []string{tag}}
+	for _, c := range []struct {
+		s    workshop.Sort
+		want string
+	}{
+		{workshop.SortStart, "ea bc d"},
+		{workshop.SortStartDesc, "dc ba e"},
+		{workshop.SortPrice, "ca bd e"},
+		{workshop.SortPriceDesc, "ed ba c"},
+		// Raku and raku tie, as names ignore case.
+		{workshop.SortName, "de ab c"},
+		{workshop.SortNameDesc, "cb ae d"},
+	} {
+		if got := walk(all, c.s, 2); got != c.want {
+			t.Errorf("%s: got pages %q, want %q", c.s, got, c.want)
+		}
+		// One item at a time, each tie is crossed by a cursor.
+		if got, want := walk(all, c.s, 1), strings.Join(strings.Split(strings.Replace(c.want, " ", "", -1), ""), " "); got != want {
+			t.Errorf("%s, one at a time: got pages %q, want %q", c.s, got, want)
+		}
+		// A page holding the rest has no next one.
+		if got, want := walk(all, c.s, 5), strings.Replace(c.want, " ", "", -1); got != want {
+			t.Errorf("%s, all at once: got pages %q, want %q", c.s, got, want)
+		}
+	}
+	minPrice := int64(4500)
+	for _, c := range []struct {
+		name string
+		f    workshop.Filter
+		s    workshop.Sort
+		want string
+	}{
+		{"both tags", workshop.Filter{Tags: []string{tag, evening}}, workshop.SortPrice, "c d"},
+		{"both tags, descending", workshop.Filter{Tags: []string{tag, evening}}, workshop.SortStartDesc, "d c"},
+		{"from a price", workshop.Filter{Tags: []string{tag}, MinPrice: &minPrice}, workshop.SortPrice, "a b d e"},
+		{"from the start", workshop.Filter{Tags: []string{tag}, From: start}, workshop.SortNameDesc, "c b a d"},
+	} {
+		if got := walk(c.f, c.s, 1); got != c.want {
+			t.Errorf("%s: got pages %q, want %q", c.name, got, c.want)
+		}
+	}
+	// A cursor marks a position, not a filter: the next page of a wider
+	// listing carries on from it under a narrower one.
+	_, next, err := db.ListWorkshops(all, workshop.Page{Sort: workshop.SortPrice, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workshops, _, err := db.ListWorkshops(workshop.Filter{Tags: []string{tag, evening}}, workshop.Page{Sort: workshop.SortPrice, Limit: 2, Cursor: next})
+	if err != nil || len(workshops) != 1 || workshops[0].WorkshopID != prefix+"-d" {
+		t.Errorf("got %d workshops and %v after c and a, want d", len(workshops), err)
+	}
+
+	// Events tie on their start and are ordered by id.
+	events, next, err := db.ListEvents(all, workshop.Page{Limit: 1})
+	if err != nil || len(events) != 1 || events[0].ID != prefix+"-x" {
+		t.Fatalf("got %d events and %v, want x first", len(events), err)
+	}
+	events, next, err = db.ListEvents(all, workshop.Page{Limit: 1, Cursor: next})
+	if err != nil || len(events) != 1 || events[0].ID != prefix+"-y" || next != "" {
+		t.Errorf("got %d events, %q and %v, want y last", len(events), next, err)
+	}
+
+	_, price, err := db.ListWorkshops(all, workshop.Page{Sort: workshop.SortPrice, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		page workshop.Page
+	}{
+		{"not a cursor", workshop.Page{Cursor: "not a cursor"}},
+		{"truncated", workshop.Page{Sort: workshop.SortPrice, Cursor: price[:len(price)-2]}},
+		{"tampered", workshop.Page{Sort: workshop.SortPrice, Cursor: workshop.Cursor{Sort: workshop.SortPrice, Key: "' OR 1=1 --", ID: prefix + "-a"}.Encode()}},
+		{"another sort", workshop.Page{Sort: workshop.SortName, Cursor: price}},
+		{"another direction", workshop.Page{Sort: workshop.SortPriceDesc, Cursor: price}},
+	} {
+		if workshops, _, err := db.ListWorkshops(all, c.page); err != workshop.ErrInvalidCursor || len(workshops) != 0 {
+			t.Errorf("%s: got %d workshops and %v, want %v", c.name, len(workshops), err, workshop.ErrInvalidCursor)
+		}
+		if _, _, err := db.ListEvents(all, c.page); err != workshop.ErrInvalidCursor {
+			t.Errorf("%s: got %v listing events, want %v", c.name, err, workshop.ErrInvalidCursor)
+		}
+	}
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if err := db.DeleteWorkshop(prefix + "-" + id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"x", "y"} {
+		if err := db.DeleteEvent(prefix + "-" + id); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListingsPagedMemory(t *testing.T) {
+	listingsPaged(t, NewMemoryDB())
+}
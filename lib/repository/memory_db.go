@@ -0,0 +1,2237 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// memoryDB is an in-memory WorkshopDB for tests and local development. It
+// mirrors the constraints of the mysql schema: workshop_id and event_id are
+// unique, signups must reference an existing workshop and are unique per
+// first name and email, and deleting a workshop cascades to its signups.
+type memoryDB struct {
+	mu        sync.RWMutex
+	workshops []workshop.Workshop
+	events    []workshop.Event
+	signups   []workshop.SignUp
+	// counts are the signup_count and waitlist_count of each workshop,
+	// kept up to date on every signup change like the mysql columns rather
+	// than derived from signups, so that they can drift the same way.
+	counts    map[string]seatCounts
+	history   map[int][]workshop.Transition
+	series    []workshop.Series
+	discounts []workshop.DiscountCode
+	// redemptions are in the order the codes were used.
+	redemptions []workshop.Redemption
+	payments    []workshop.Payment
+	refunds     []workshop.Refund
+	// invoices are kept when their workshop is deleted, like in mysql.
+	invoices []workshop.Invoice
+	// invoiceSeqs is the last invoice number issued, per year.
+	invoiceSeqs map[int]int
+	// creditNoteSeqs is the last credit note number issued, per year.
+	creditNoteSeqs map[int]int
+	vouchers       []workshop.Voucher
+	// voucherTxs is the history of every voucher, oldest first.
+	voucherTxs  []workshop.VoucherTransaction
+	instructors []workshop.Instructor
+	// venues hold their rooms.
+	venues   []workshop.Venue
+	hours    []workshop.OpeningHours
+	closures []workshop.Closure
+	// categories are kept by id; workshops and events hold the ids and
+	// their normalized tags.
+	categories []workshop.Category
+	// attendance is keyed by session ID.
+	attendance       map[int][]workshop.Attendance
+	nextID           int
+	nextSessionID    int
+	nextPaymentID    int
+	nextRefundID     int
+	nextInstructorID int
+	nextVenueID      int
+	nextRoomID       int
+	nextClosureID    int
+	nextCategoryID   int
+	// promotionHold is as in workshopDB.
+	promotionHold time.Duration
+}
+
+// SetPromotionHold makes signups that owe money pending when they are
+// promoted off the waitlist, holding their seat for hold while they pay.
+func (m *memoryDB) SetPromotionHold(hold time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.promotionHold = hold
+}
+
+func NewMemoryDB() *memoryDB {
+	return &memoryDB{
+		history:        make(map[int][]workshop.Transition),
+		attendance:     make(map[int][]workshop.Attendance),
+		invoiceSeqs:    make(map[int]int),
+		creditNoteSeqs: make(map[int]int),
+		counts:         make(map[string]seatCounts),
+	}
+}
+
+func (m *memoryDB) GetDB() interface{} {
+	return m
+}
+
+func (m *memoryDB) workshopIndex(workshopID string) int {
+	for i, ws := range m.workshops {
+		if ws.WorkshopID == workshopID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *memoryDB) eventIndex(eventID string) int {
+	for i, e := range m.events {
+		if e.ID == eventID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *memoryDB) countSignUps(workshopID string, status workshop.Status) int {
+	var count int
+	for _, s := range m.signups {
+		if s.WorkshopID == workshopID && s.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// seatCounts are the counters of a workshop.
+type seatCounts struct {
+	seats, waitlist int
+}
+
+// adjustCounts moves the counters of workshopID for a signup going from one
+// status to another, like adjustCounts does in mysql.
+func (m *memoryDB) adjustCounts(workshopID string, from, to workshop.Status) {
+	c := m.counts[workshopID]
+	c.seats += delta(from.HoldsSeat(), to.HoldsSeat())
+	c.waitlist += delta(from == workshop.StatusWaitlisted, to == workshop.StatusWaitlisted)
+	m.counts[workshopID] = c
+}
+
+func (m *memoryDB) countSeats(workshopID string) int {
+	var count int
+	for _, s := range m.signups {
+		if s.WorkshopID == workshopID && s.Status.HoldsSeat() {
+			count++
+		}
+	}
+	return count
+}
+
+// withFullness returns a copy of ws with its capacity fields set from its
+// counters.
+func (m *memoryDB) withFullness(ws workshop.Workshop) workshop.Workshop {
+	ws.Sessions = append([]workshop.Session(nil), ws.Sessions...)
+	ws.PriceTiers = append([]workshop.PriceTier(nil), ws.PriceTiers...)
+	ws.InstructorIDs = append([]int(nil), ws.InstructorIDs...)
+	ws.Instructors = nil
+	for _, id := range ws.InstructorIDs {
+		ws.Instructors = append(ws.Instructors, m.instructors[m.instructorIndex(id)])
+	}
+	ws.Categories, ws.CategoryIDs = m.categoriesOf(ws.CategoryIDs)
+	ws.Tags = append([]string(nil), ws.Tags...)
+	c := m.counts[ws.WorkshopID]
+	ws.SetSeats(c.seats, c.waitlist)
+	return ws
+}
+
+func (m *memoryDB) WorkshopByID(workshopID string) (workshop.Workshop, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.workshopIndex(workshopID)
+	if i < 0 {
+		return workshop.Workshop{}, sql.ErrNoRows
+	}
+	return m.withFullness(m.workshops[i]), nil
+}
+
+func (m *memoryDB) EventByID(eventID string) (workshop.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.eventIndex(eventID)
+	if i < 0 {
+		return workshop.Event{}, sql.ErrNoRows
+	}
+	return m.withTaxonomy(m.events[i]), nil
+}
+
+func (m *memoryDB) InsertWorkshop(ws workshop.Workshop) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.calendar().CheckWorkshop(ws, nil); err != nil {
+		return err
+	}
+	return m.insertWorkshop(ws)
+}
+
+func (m *memoryDB) insertWorkshop(ws workshop.Workshop) error {
+	if m.workshopIndex(ws.WorkshopID) >= 0 {
+		return fmt.Errorf("duplicate workshop_id %q", ws.WorkshopID)
+	}
+	if err := m.checkInstructors(ws.InstructorIDs); err != nil {
+		return err
+	}
+	if err := m.checkCategories(ws.CategoryIDs); err != nil {
+		return err
+	}
+	planned := ws
+	planned.SetSessions(ws.SessionList())
+	if err := m.bookRoom(ws.RoomID, ws.Cap, planned.RoomBookings()); err != nil {
+		return err
+	}
+	now := time.Now()
+	m.setSessions(&ws, ws.SessionList())
+	ws.Timezone = timezone(ws.Timezone)
+	ws.Price = money.New(ws.Price.Amount, ws.Price.Currency)
+	ws.Deposit = money.New(ws.Deposit.Amount, ws.Price.Currency)
+	ws.PriceTiers = append([]workshop.PriceTier(nil), ws.PriceTiers...)
+	ws.InstructorIDs = append([]int(nil), ws.InstructorIDs...)
+	ws.Instructors = nil
+	ws.CategoryIDs = append([]int(nil), ws.CategoryIDs...)
+	ws.Categories = nil
+	ws.Tags = append([]string(nil), ws.Tags...)
+	ws.CreatedAt = now
+	ws.UpdatedAt = now
+	ws.IsFull = false
+	ws.CancelledAt = time.Time{}
+	m.workshops = append(m.workshops, ws)
+	return nil
+}
+
+func (m *memoryDB) InsertEvent(e workshop.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.eventIndex(e.ID) >= 0 {
+		return fmt.Errorf("duplicate event_id %q", e.ID)
+	}
+	if err := m.calendar().CheckEvent(e, workshop.Event{}); err != nil {
+		return err
+	}
+	if err := m.checkCategories(e.CategoryIDs); err != nil {
+		return err
+	}
+	if err := m.bookRoom(e.RoomID, 0, e.RoomBookings()); err != nil {
+		return err
+	}
+	now := time.Now()
+	e.StartTime = e.StartTime.UTC()
+	e.EndTime = e.EndTime.UTC()
+	e.Timezone = timezone(e.Timezone)
+	e.Price = money.New(e.Price.Amount, e.Price.Currency)
+	e.CategoryIDs = append([]int(nil), e.CategoryIDs...)
+	e.Categories = nil
+	e.Tags = append([]string(nil), e.Tags...)
+	e.CreatedAt = now
+	e.UpdatedAt = now
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *memoryDB) UpdateWorkshop(ws workshop.Workshop) ([]workshop.SignUp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.workshopIndex(ws.WorkshopID)
+	if i < 0 {
+		return nil, sql.ErrNoRows
+	}
+	if err := m.checkInstructors(ws.InstructorIDs); err != nil {
+		return nil, err
+	}
+	if err := m.checkCategories(ws.CategoryIDs); err != nil {
+		return nil, err
+	}
+	planned := planSessions(m.workshops[i].Sessions, ws)
+	booked := ws
+	booked.SetSessions(planned)
+	if err := m.calendar().CheckWorkshop(booked, m.workshops[i].Sessions); err != nil {
+		return nil, err
+	}
+	if err := m.bookRoom(ws.RoomID, ws.Cap, booked.RoomBookings()); err != nil {
+		return nil, err
+	}
+	cur := &m.workshops[i]
+	cur.Name = ws.Name
+	cur.Description = ws.Description
+	cur.StartTime = ws.StartTime
+	cur.EndTime = ws.EndTime
+	keep := make(map[int]bool)
+	for _, s := range planned {
+		keep[s.ID] = true
+	}
+	for _, s := range cur.Sessions {
+		if !keep[s.ID] {
+			delete(m.attendance, s.ID)
+		}
+	}
+	m.setSessions(cur, planned)
+	cur.Timezone = timezone(ws.Timezone)
+	cur.Cap = ws.Cap
+	cur.Level = ws.Level
+	cur.Price = money.New(ws.Price.Amount, ws.Price.Currency)
+	if ws.PriceTiers != nil {
+		cur.PriceTiers = append([]workshop.PriceTier(nil), ws.PriceTiers...)
+	}
+	if ws.InstructorIDs != nil {
+		cur.InstructorIDs = append([]int(nil), ws.InstructorIDs...)
+	}
+	if ws.CategoryIDs != nil {
+		cur.CategoryIDs = append([]int(nil), ws.CategoryIDs...)
+	}
+	if ws.Tags != nil {
+		cur.Tags = append([]string(nil), ws.Tags...)
+	}
+	cur.Location = ws.Location
+	cur.RoomID = ws.RoomID
+	cur.Caption = ws.Caption
+	cur.CancelDeadline = ws.CancelDeadline
+	cur.RefundPolicy = ws.RefundPolicy
+	cur.Deposit = money.New(ws.Deposit.Amount, cur.Price.Currency)
+	cur.BalanceDueAt = ws.BalanceDueAt
+	cur.ReleaseUnpaid = ws.ReleaseUnpaid
+	cur.UpdatedAt = time.Now()
+	return m.promote(*cur)
+}
+
+func (m *memoryDB) UpdateEvent(e workshop.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.eventIndex(e.ID)
+	if i < 0 {
+		return nil
+	}
+	if err := m.calendar().CheckEvent(e, m.events[i]); err != nil {
+		return err
+	}
+	if err := m.checkCategories(e.CategoryIDs); err != nil {
+		return err
+	}
+	if err := m.bookRoom(e.RoomID, 0, e.RoomBookings()); err != nil {
+		return err
+	}
+	cur := &m.events[i]
+	cur.Name = e.Name
+	cur.Description = e.Description
+	cur.StartTime = e.StartTime.UTC()
+	cur.EndTime = e.EndTime.UTC()
+	cur.Timezone = timezone(e.Timezone)
+	cur.Price = money.New(e.Price.Amount, e.Price.Currency)
+	cur.Location = e.Location
+	cur.RoomID = e.RoomID
+	cur.Caption = e.Caption
+	if e.CategoryIDs != nil {
+		cur.CategoryIDs = append([]int(nil), e.CategoryIDs...)
+	}
+	if e.Tags != nil {
+		cur.Tags = append([]string(nil), e.Tags...)
+	}
+	cur.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *memoryDB) DeleteWorkshop(workshopID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.workshopIndex(workshopID)
+	if i < 0 {
+		return nil
+	}
+	ws := m.workshops[i]
+	m.workshops = append(m.workshops[:i], m.workshops[i+1:]...)
+	m.cascadeDelete(ws)
+	return nil
+}
+
+// cascadeDelete removes what hangs off the deleted workshop ws, as the
+// foreign keys do in mysql.
+func (m *memoryDB) cascadeDelete(ws workshop.Workshop) {
+	workshopID := ws.WorkshopID
+	for _, s := range ws.Sessions {
+		delete(m.attendance, s.ID)
+	}
+	signups := m.signups[:0]
+	for _, s := range m.signups {
+		if s.WorkshopID != workshopID {
+			signups = append(signups, s)
+		} else {
+			delete(m.history, s.ID)
+		}
+	}
+	m.signups = signups
+	delete(m.counts, workshopID)
+	payments := m.payments[:0]
+	for _, p := range m.payments {
+		if m.signUpIndex(p.SignUpID) >= 0 {
+			payments = append(payments, p)
+		}
+	}
+	m.payments = payments
+	redemptions := m.redemptions[:0]
+	for _, r := range m.redemptions {
+		if r.WorkshopID != workshopID {
+			redemptions = append(redemptions, r)
+		}
+	}
+	m.redemptions = redemptions
+}
+
+func (m *memoryDB) DeleteEvent(eventID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.eventIndex(eventID)
+	if i < 0 {
+		return nil
+	}
+	m.events = append(m.events[:i], m.events[i+1:]...)
+	return nil
+}
+
+func (m *memoryDB) GetWorkshops() ([]workshop.Workshop, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var workshops []workshop.Workshop
+	for _, ws := range m.workshops {
+		workshops = append(workshops, m.withFullness(ws))
+	}
+	return workshops, nil
+}
+
+func (m *memoryDB) GetWorkshopsAfterDate(date time.Time) ([]workshop.Workshop, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var workshops []workshop.Workshop
+	for _, ws := range m.workshops {
+		if !ws.StartTime.After(date) {
+			continue
+		}
+		workshops = append(workshops, m.withFullness(ws))
+	}
+	return workshops, nil
+}
+
+func (m *memoryDB) GetEvents() ([]workshop.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var events []workshop.Event
+	for _, e := range m.events {
+		events = append(events, m.withTaxonomy(e))
+	}
+	return events, nil
+}
+
+func (m *memoryDB) GetEventsAfterDate(date time.Time) ([]workshop.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var events []workshop.Event
+	for _, e := range m.events {
+		if !e.StartTime.After(date) {
+			continue
+		}
+		events = append(events, m.withTaxonomy(e))
+	}
+	return events, nil
+}
+
+func (m *memoryDB) SignUp(signup workshop.SignUp, waitlist bool) (workshop.SignUp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.workshopIndex(signup.WorkshopID)
+	if i < 0 {
+		return signup, sql.ErrNoRows
+	}
+	if !m.workshops[i].CancelledAt.IsZero() {
+		return signup, ErrWorkshopCancelled
+	}
+	status := workshop.StatusConfirmed
+	if signup.Status == workshop.StatusPending {
+		status = workshop.StatusPending
+	}
+	signup.Status = status
+	if m.counts[signup.WorkshopID].seats >= m.workshops[i].Cap {
+		if !waitlist {
+			return signup, ErrWorkshopFull
+		}
+		signup.Status = workshop.StatusWaitlisted
+	}
+	// Only a seat can be held.
+	if signup.Status != workshop.StatusPending {
+		signup.HoldExpiresAt = time.Time{}
+	}
+	for _, s := range m.signups {
+		if s.WorkshopID == signup.WorkshopID && s.FirstName == signup.FirstName && s.Email == signup.Email {
+			return signup, fmt.Errorf("duplicate signup for %s on workshop %q", signup.Email, signup.WorkshopID)
+		}
+	}
+	now := time.Now().UTC()
+	signup.Price.Currency = currency(signup.Price.Currency)
+	signup.AmountPaid = money.Money{Currency: signup.Price.Currency}
+	signup.BalanceRemindedAt, signup.BalanceOverdueAt = time.Time{}, time.Time{}
+	if err := m.applyDiscount(&signup, now); err != nil {
+		return signup, err
+	}
+	voucherClasses, err := m.applyVoucher(&signup, now)
+	if err != nil {
+		return signup, err
+	}
+	m.nextID++
+	signup.ID = m.nextID
+	signup.Position = 0
+	signup.History = nil
+	signup.CreatedAt = now
+	signup.UpdatedAt = now
+	m.signups = append(m.signups, signup)
+	m.adjustCounts(signup.WorkshopID, "", signup.Status)
+	m.history[signup.ID] = []workshop.Transition{{To: signup.Status, At: now}}
+	m.adjustRedemption(signup, "", signup.Status)
+	if signup.VoucherCode != "" {
+		t := workshop.VoucherTransaction{
+			Code:       signup.VoucherCode,
+			Kind:       workshop.VoucherRedeemed,
+			Amount:     signup.VoucherAmount.Neg(),
+			Classes:    -voucherClasses,
+			SignUpID:   signup.ID,
+			WorkshopID: signup.WorkshopID,
+			At:         now,
+		}
+		if voucherClasses > 0 {
+			t.Amount.Amount = 0
+		}
+		m.voucherTxs = append(m.voucherTxs, t)
+		m.voucherTxs[len(m.voucherTxs)-1].ID = len(m.voucherTxs)
+	}
+	if signup.Status == workshop.StatusWaitlisted {
+		signup.Position = m.countSignUps(signup.WorkshopID, workshop.StatusWaitlisted)
+	}
+	return signup, nil
+}
+
+func (m *memoryDB) signUpIndex(signupID int) int {
+	for i, s := range m.signups {
+		if s.ID == signupID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *memoryDB) SignUpByID(signupID int) (workshop.SignUp, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.signUpIndex(signupID)
+	if i < 0 {
+		return workshop.SignUp{}, sql.ErrNoRows
+	}
+	s := m.signups[i]
+	s.History = append([]workshop.Transition(nil), m.history[signupID]...)
+	return s, nil
+}
+
+func (m *memoryDB) UpdateSignUpStatus(signupID int, status workshop.Status) (workshop.SignUp, []workshop.SignUp, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.signUpIndex(signupID)
+	if i < 0 {
+		return workshop.SignUp{}, nil, false, sql.ErrNoRows
+	}
+	s := &m.signups[i]
+	before := *s
+	if s.Status == status {
+		return before, nil, false, nil
+	}
+	from := s.Status
+	ws := m.workshops[m.workshopIndex(s.WorkshopID)]
+	if !from.HoldsSeat() && status.HoldsSeat() && !ws.CancelledAt.IsZero() {
+		return before, nil, false, ErrWorkshopCancelled
+	}
+	if !from.HoldsSeat() && status.HoldsSeat() && m.counts[ws.WorkshopID].seats >= ws.Cap {
+		return before, nil, false, ErrWorkshopFull
+	}
+	if err := m.setStatus(s, status); err != nil {
+		return before, nil, false, err
+	}
+	if from.HoldsSeat() && !status.HoldsSeat() {
+		promoted, err := m.promote(ws)
+		return before, promoted, true, err
+	}
+	return before, nil, true, nil
+}
+
+// setStatus applies a lifecycle transition to s and records it.
+func (m *memoryDB) setStatus(s *workshop.SignUp, status workshop.Status) error {
+	from := s.Status
+	if err := s.Transition(status, time.Now().UTC()); err != nil {
+		return err
+	}
+	m.adjustCounts(s.WorkshopID, from, status)
+	m.adjustRedemption(*s, from, status)
+	if status == workshop.StatusCancelled && from != workshop.StatusConfirmed {
+		m.creditVoucher(*s, s.VoucherAmount, 1, "signup cancelled before it was confirmed")
+	}
+	m.history[s.ID] = append(m.history[s.ID], s.History[len(s.History)-1])
+	s.History = nil
+	return nil
+}
+
+// promote moves people from the waitlist onto free seats of ws, oldest
+// first, and returns them, like workshopDB.promote.
+func (m *memoryDB) promote(ws workshop.Workshop) ([]workshop.SignUp, error) {
+	free := ws.Cap - m.counts[ws.WorkshopID].seats
+	var promoted []workshop.SignUp
+	for i := range m.signups {
+		if free <= 0 {
+			break
+		}
+		s := &m.signups[i]
+		if s.WorkshopID != ws.WorkshopID || s.Status != workshop.StatusWaitlisted {
+			continue
+		}
+		if err := m.setStatus(s, promotedStatus(s, m.promotionHold, time.Now().UTC())); err != nil {
+			return promoted, err
+		}
+		promoted = append(promoted, *s)
+		free--
+	}
+	return promoted, nil
+}
+
+func (m *memoryDB) GetSignUpsByWorkshopID(workshopID string) ([]workshop.SignUp, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.signUpsByWorkshopID(workshopID), nil
+}
+
+func (m *memoryDB) signUpsByWorkshopID(workshopID string) []workshop.SignUp {
+	var signups []workshop.SignUp
+	position := 0
+	for _, s := range m.signups {
+		if s.WorkshopID != workshopID {
+			continue
+		}
+		if s.Status == workshop.StatusWaitlisted {
+			position++
+			s.Position = position
+		}
+		signups = append(signups, s)
+	}
+	return signups
+}
+
+func (m *memoryDB) GetNumSignUpsByWorkshopID(workshopID string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.counts[workshopID].seats, nil
+}
+
+func (m *memoryDB) GetAllSignUps() ([]workshop.SignUpTable, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var table []workshop.SignUpTable
+	for _, ws := range m.workshops {
+		table = append(table, workshop.SignUpTable{
+			WorkshopName: ws.Name,
+			SignUps:      m.signUpsByWorkshopID(ws.WorkshopID),
+		})
+	}
+	return table, nil
+}
+
+// ReconcileSignUpCounts checks the counters against the signups, as in
+// workshopDB.
+func (m *memoryDB) ReconcileSignUpCounts(fix bool) ([]CountDrift, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var drifts []CountDrift
+	for _, ws := range m.workshops {
+		c := m.counts[ws.WorkshopID]
+		d := CountDrift{
+			WorkshopID:     ws.WorkshopID,
+			StoredSeats:    c.seats,
+			StoredWaitlist: c.waitlist,
+			Seats:          m.countSeats(ws.WorkshopID),
+			Waitlist:       m.countSignUps(ws.WorkshopID, workshop.StatusWaitlisted),
+		}
+		if d.StoredSeats != d.Seats || d.StoredWaitlist != d.Waitlist {
+			drifts = append(drifts, d)
+		}
+	}
+	if fix {
+		for _, d := range drifts {
+			m.counts[d.WorkshopID] = seatCounts{seats: d.Seats, waitlist: d.Waitlist}
+		}
+	}
+	return drifts, nil
+}
+
+func (m *memoryDB) seriesIndex(seriesID string) int {
+	for i, s := range m.series {
+		if s.ID == seriesID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *memoryDB) InsertSeries(s workshop.Series) ([]workshop.Workshop, error) {
+	occurrences, err := s.Occurrences()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if occurrences, err = m.calendar().Schedule(occurrences); err != nil {
+		return nil, err
+	}
+	if err := m.insertSeries(s, occurrences); err != nil {
+		return nil, err
+	}
+	return occurrences, nil
+}
+
+// insertSeries adds s and its occurrences, or nothing if any of them
+// clashes with what is stored.
+func (m *memoryDB) insertSeries(s workshop.Series, occurrences []workshop.Workshop) error {
+	if m.seriesIndex(s.ID) >= 0 {
+		return fmt.Errorf("duplicate series_id %q", s.ID)
+	}
+	for _, ws := range occurrences {
+		if m.workshopIndex(ws.WorkshopID) >= 0 {
+			return fmt.Errorf("duplicate workshop_id %q", ws.WorkshopID)
+		}
+	}
+	if err := m.checkInstructors(s.Template.InstructorIDs); err != nil {
+		return err
+	}
+	// Each occurrence is checked against what is stored and, as mysql
+	// inserts them one at a time, against the occurrences before it.
+	var planned []workshop.Booking
+	for _, ws := range occurrences {
+		wanted := ws.RoomBookings()
+		if err := m.bookRoom(ws.RoomID, ws.Cap, wanted); err != nil {
+			return err
+		}
+		if ws.RoomID == 0 {
+			continue
+		}
+		if conflicts := workshop.Overlapping(planned, wanted); len(conflicts) > 0 {
+			return &workshop.RoomConflictError{RoomID: ws.RoomID, Conflicts: conflicts}
+		}
+		planned = append(planned, wanted...)
+	}
+	now := time.Now()
+	s.Template.StartTime = s.Template.StartTime.UTC()
+	s.Template.EndTime = s.Template.EndTime.UTC()
+	s.Template.Timezone = timezone(s.Template.Timezone)
+	s.Template.SeriesID = s.ID
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	m.series = append(m.series, s)
+	for _, ws := range occurrences {
+		m.insertWorkshop(ws)
+	}
+	return nil
+}
+
+func (m *memoryDB) SeriesByID(seriesID string) (workshop.Series, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.seriesIndex(seriesID)
+	if i < 0 {
+		return workshop.Series{}, sql.ErrNoRows
+	}
+	return m.series[i], nil
+}
+
+func (m *memoryDB) GetWorkshopsBySeriesID(seriesID string) ([]workshop.Workshop, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var workshops []workshop.Workshop
+	for _, ws := range m.workshops {
+		if ws.SeriesID == seriesID {
+			workshops = append(workshops, m.withFullness(ws))
+		}
+	}
+	return workshops, nil
+}
+
+func (m *memoryDB) SplitSeries(seriesID, workshopID string, next workshop.Series) ([]workshop.Workshop, error) {
+	occurrences, err := next.Occurrences()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	si := m.seriesIndex(seriesID)
+	wi := m.workshopIndex(workshopID)
+	if si < 0 || wi < 0 {
+		return nil, sql.ErrNoRows
+	}
+	at := m.workshops[wi]
+	if at.SeriesID != seriesID {
+		return nil, workshop.ErrNotInSeries
+	}
+	if occurrences, err = m.calendar().Schedule(occurrences); err != nil {
+		return nil, err
+	}
+	truncated, _, err := m.series[si].Truncate(at.StartTime)
+	if err != nil {
+		return nil, err
+	}
+
+	var following []workshop.Workshop
+	signedUp, booked := make(map[string]bool), make(map[string]bool)
+	for _, ws := range m.workshops {
+		if ws.SeriesID != seriesID || ws.StartTime.Before(at.StartTime) {
+			continue
+		}
+		following = append(following, ws)
+		for _, s := range m.signups {
+			if s.WorkshopID == ws.WorkshopID {
+				signedUp[ws.WorkshopID] = true
+				booked[ws.WorkshopID] = booked[ws.WorkshopID] || s.Status.Booked()
+			}
+		}
+	}
+	relink, cancel, drop, add := planSplit(following, signedUp, booked, occurrences)
+	// The dropped occurrences go first, as in mysql, so that the new ones
+	// can take their rooms.
+	kept := append([]workshop.Workshop(nil), m.workshops...)
+	var dropped []workshop.Workshop
+	for _, id := range drop {
+		i := m.workshopIndex(id)
+		dropped = append(dropped, m.workshops[i])
+		m.workshops = append(m.workshops[:i], m.workshops[i+1:]...)
+	}
+	now := time.Now().UTC()
+	for _, id := range cancel {
+		i := m.workshopIndex(id)
+		m.workshops[i].CancelledAt = now
+		m.workshops[i].UpdatedAt = now
+	}
+	// Relinked occurrences move into the room of next, one at a time.
+	for _, ws := range relink {
+		if err := m.bookRoom(ws.RoomID, ws.Cap, ws.RoomBookings()); err != nil {
+			m.workshops = kept
+			return nil, err
+		}
+		ws.UpdatedAt = time.Now()
+		m.workshops[m.workshopIndex(ws.WorkshopID)] = ws
+	}
+	if err := m.insertSeries(next, add); err != nil {
+		m.workshops = kept
+		return nil, err
+	}
+	for _, ws := range dropped {
+		m.cascadeDelete(ws)
+	}
+	truncated.UpdatedAt = time.Now()
+	m.series[si] = truncated
+	return append(relink, add...), nil
+}
+
+// setSessions gives the new sessions of ws IDs and stores them on it.
+func (m *memoryDB) setSessions(ws *workshop.Workshop, sessions []workshop.Session) {
+	sessions = append([]workshop.Session(nil), sessions...)
+	for i := range sessions {
+		if sessions[i].ID == 0 {
+			m.nextSessionID++
+			sessions[i].ID = m.nextSessionID
+		}
+	}
+	ws.SetSessions(sessions)
+}
+
+// sessionWorkshop returns the workshop the session belongs to.
+func (m *memoryDB) sessionWorkshop(sessionID int) (workshop.Workshop, bool) {
+	for _, ws := range m.workshops {
+		for _, s := range ws.Sessions {
+			if s.ID == sessionID {
+				return ws, true
+			}
+		}
+	}
+	return workshop.Workshop{}, false
+}
+
+func (m *memoryDB) RecordAttendance(a workshop.Attendance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ws, ok := m.sessionWorkshop(a.SessionID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	i := m.signUpIndex(a.SignUpID)
+	if i < 0 || m.signups[i].WorkshopID != ws.WorkshopID || !m.signups[i].Status.HoldsSeat() {
+		return ErrNotEnrolled
+	}
+	a.RecordedAt = time.Now().UTC()
+	records := m.attendance[a.SessionID]
+	for j := range records {
+		if records[j].SignUpID == a.SignUpID {
+			records[j] = a
+			return nil
+		}
+	}
+	m.attendance[a.SessionID] = append(records, a)
+	return nil
+}
+
+func (m *memoryDB) GetAttendanceBySessionID(sessionID int) ([]workshop.Attendance, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.sessionWorkshop(sessionID); !ok {
+		return nil, sql.ErrNoRows
+	}
+	return append([]workshop.Attendance(nil), m.attendance[sessionID]...), nil
+}
+
+func (m *memoryDB) discountIndex(code string) int {
+	for i, d := range m.discounts {
+		if d.Code == code {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *memoryDB) InsertDiscountCode(d workshop.DiscountCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.Code = workshop.NormalizeCode(d.Code)
+	if m.discountIndex(d.Code) >= 0 {
+		return ErrDuplicateCode
+	}
+	d.Amount.Currency = currency(d.Amount.Currency)
+	if err := m.checkCategories(d.CategoryIDs); err != nil {
+		return err
+	}
+	// Each workshop is listed once, as in the mysql join table.
+	var workshopIDs []string
+	seen := make(map[string]bool)
+	for _, id := range d.WorkshopIDs {
+		if !seen[id] {
+			seen[id] = true
+			workshopIDs = append(workshopIDs, id)
+		}
+	}
+	d.WorkshopIDs = workshopIDs
+	d.CategoryIDs = append([]int(nil), d.CategoryIDs...)
+	d.Redemptions = 0
+	d.CreatedAt = time.Now().UTC()
+	m.discounts = append(m.discounts, d)
+	return nil
+}
+
+func (m *memoryDB) GetDiscountCodes() ([]workshop.DiscountCode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]workshop.DiscountCode(nil), m.discounts...), nil
+}
+
+func (m *memoryDB) GetRedemptions(code string) ([]workshop.Redemption, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	code = workshop.NormalizeCode(code)
+	if m.discountIndex(code) < 0 {
+		return nil, sql.ErrNoRows
+	}
+	var redemptions []workshop.Redemption
+	for _, r := range m.redemptions {
+		if r.Code == code {
+			redemptions = append(redemptions, r)
+		}
+	}
+	return redemptions, nil
+}
+
+// applyDiscount mirrors the mysql applyDiscount; the caller holds the lock.
+func (m *memoryDB) applyDiscount(signup *workshop.SignUp, now time.Time) error {
+	signup.DiscountCode = workshop.NormalizeCode(signup.DiscountCode)
+	signup.Discount = money.Money{Currency: signup.Price.Currency}
+	if signup.DiscountCode == "" {
+		return nil
+	}
+	i := m.discountIndex(signup.DiscountCode)
+	if i < 0 {
+		return workshop.ErrCodeUnknown
+	}
+	var categoryIDs []int
+	if w := m.workshopIndex(signup.WorkshopID); w >= 0 {
+		categoryIDs = workshop.Ancestry(m.categories, m.workshops[w].CategoryIDs)
+	}
+	discount, err := m.discounts[i].Apply(signup.WorkshopID, categoryIDs, signup.Price, now)
+	if err != nil {
+		return err
+	}
+	price, err := signup.Price.Sub(discount)
+	if err != nil {
+		return err
+	}
+	signup.Discount = discount
+	signup.Price = price
+	return nil
+}
+
+// adjustRedemption mirrors the mysql adjustRedemption; the caller holds the
+// lock.
+func (m *memoryDB) adjustRedemption(s workshop.SignUp, from, to workshop.Status) {
+	if s.DiscountCode == "" {
+		return
+	}
+	i := m.discountIndex(s.DiscountCode)
+	switch delta(from.HoldsSeat(), to.HoldsSeat()) {
+	case 1:
+		m.discounts[i].Redemptions++
+		m.redemptions = append(m.redemptions, workshop.Redemption{
+			Code:       s.DiscountCode,
+			SignUpID:   s.ID,
+			WorkshopID: s.WorkshopID,
+			Discount:   s.Discount,
+			RedeemedAt: s.UpdatedAt,
+		})
+	case -1:
+		m.discounts[i].Redemptions--
+		redemptions := m.redemptions[:0]
+		for _, r := range m.redemptions {
+			if r.SignUpID != s.ID {
+				redemptions = append(redemptions, r)
+			}
+		}
+		m.redemptions = redemptions
+	}
+}
+
+func (m *memoryDB) InsertPayment(p workshop.Payment) (workshop.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.signUpIndex(p.SignUpID) < 0 {
+		return p, sql.ErrNoRows
+	}
+	for _, q := range m.payments {
+		if q.Provider == p.Provider && q.SessionID == p.SessionID {
+			return p, fmt.Errorf("duplicate payment session %q", p.SessionID)
+		}
+	}
+	now := time.Now().UTC()
+	m.nextPaymentID++
+	p.ID = m.nextPaymentID
+	p.Amount.Currency = currency(p.Amount.Currency)
+	p.Status = workshop.PaymentOpen
+	p.CreatedAt, p.UpdatedAt = now, now
+	m.payments = append(m.payments, p)
+	return p, nil
+}
+
+func (m *memoryDB) GetPaymentsBySignUpID(signupID int) ([]workshop.Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var payments []workshop.Payment
+	for _, p := range m.payments {
+		if p.SignUpID == signupID {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
+
+func (m *memoryDB) GetPaymentsByStatus(status workshop.PaymentStatus) ([]workshop.Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var payments []workshop.Payment
+	for _, p := range m.payments {
+		if p.Status == status {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
+
+func (m *memoryDB) PaymentBySessionID(provider, sessionID string) (workshop.Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, p := range m.payments {
+		if p.Provider == provider && p.SessionID == sessionID {
+			return p, nil
+		}
+	}
+	return workshop.Payment{}, sql.ErrNoRows
+}
+
+func (m *memoryDB) SettlePayment(provider, sessionID string, status workshop.PaymentStatus) (workshop.Payment, []workshop.SignUp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.payments {
+		p := &m.payments[i]
+		if p.Provider != provider || p.SessionID != sessionID {
+			continue
+		}
+		if !canSettle(p.Status, status) {
+			return *p, nil, nil
+		}
+		j := m.signUpIndex(p.SignUpID)
+		if j < 0 {
+			return *p, nil, sql.ErrNoRows
+		}
+		s := &m.signups[j]
+		p.Status = status
+		p.UpdatedAt = time.Now().UTC()
+		if status == workshop.PaymentPaid {
+			s.AmountPaid.Amount += p.Amount.Amount
+		}
+		if s.Status != workshop.StatusPending {
+			return *p, nil, nil
+		}
+		if status == workshop.PaymentMismatched {
+			s.HoldExpiresAt = time.Time{}
+			return *p, nil, nil
+		}
+		to := status.SignUpStatus()
+		if err := m.setStatus(s, to); err != nil {
+			return *p, nil, err
+		}
+		if to.HoldsSeat() {
+			return *p, nil, nil
+		}
+		promoted, err := m.promote(m.workshops[m.workshopIndex(s.WorkshopID)])
+		return *p, promoted, err
+	}
+	return workshop.Payment{}, nil, sql.ErrNoRows
+}
+
+func (m *memoryDB) HoldSeatUntil(signupID int, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.signUpIndex(signupID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	if s := &m.signups[i]; s.Status == workshop.StatusPending {
+		s.HoldExpiresAt = until.UTC()
+		s.UpdatedAt = time.Now().UTC()
+	}
+	return nil
+}
+
+func (m *memoryDB) ReleaseExpiredHolds(now time.Time) ([]workshop.SignUp, []workshop.SignUp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var (
+		released, promoted []workshop.SignUp
+		failed             error
+	)
+	freed := map[string]bool{}
+	for i := range m.signups {
+		s := &m.signups[i]
+		if !s.HoldExpired(now) {
+			continue
+		}
+		if err := m.setStatus(s, workshop.StatusCancelled); err != nil {
+			if failed == nil {
+				failed = err
+			}
+			continue
+		}
+		for j := range m.payments {
+			if p := &m.payments[j]; p.SignUpID == s.ID && p.Status == workshop.PaymentOpen {
+				p.Status = workshop.PaymentExpired
+				p.UpdatedAt = s.UpdatedAt
+			}
+		}
+		released = append(released, *s)
+		freed[s.WorkshopID] = true
+	}
+	for _, ws := range m.workshops {
+		if freed[ws.WorkshopID] {
+			p, err := m.promote(ws)
+			promoted = append(promoted, p...)
+			if err != nil && failed == nil {
+				failed = err
+			}
+		}
+	}
+	return released, promoted, failed
+}
+
+func (m *memoryDB) CancelWorkshop(workshopID string) ([]workshop.SignUp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.workshopIndex(workshopID)
+	if i < 0 {
+		return nil, sql.ErrNoRows
+	}
+	if !m.workshops[i].CancelledAt.IsZero() {
+		return nil, ErrWorkshopCancelled
+	}
+	now := time.Now().UTC()
+	var cancelled []workshop.SignUp
+	for j := range m.signups {
+		s := &m.signups[j]
+		if s.WorkshopID != workshopID || !s.Status.CanTransitionTo(workshop.StatusCancelled) {
+			continue
+		}
+		cancelled = append(cancelled, *s)
+		if err := m.setStatus(s, workshop.StatusCancelled); err != nil {
+			return nil, err
+		}
+		for k := range m.payments {
+			if p := &m.payments[k]; p.SignUpID == s.ID && p.Status == workshop.PaymentOpen {
+				p.Status = workshop.PaymentExpired
+				p.UpdatedAt = now
+			}
+		}
+	}
+	m.workshops[i].CancelledAt = now
+	return cancelled, nil
+}
+
+func (m *memoryDB) InsertRefund(r workshop.Refund) (workshop.Refund, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.signUpIndex(r.SignUpID)
+	if i < 0 {
+		return r, sql.ErrNoRows
+	}
+	s := m.signups[i]
+	var refunded int64
+	for _, f := range m.refunds {
+		if f.SignUpID == s.ID {
+			refunded += f.Amount.Amount
+		}
+	}
+	r.Amount.Currency = currency(r.Amount.Currency)
+	if r.Amount.Currency != s.Price.Currency || refunded+r.Amount.Amount > s.Due().Amount {
+		return r, workshop.ErrRefundExceedsPaid
+	}
+	m.nextRefundID++
+	r.ID = m.nextRefundID
+	r.WorkshopID = s.WorkshopID
+	r.CreatedAt = time.Now().UTC()
+	m.creditNoteSeqs[r.CreatedAt.Year()]++
+	r.Number = workshop.CreditNoteNumber(r.CreatedAt.Year(), m.creditNoteSeqs[r.CreatedAt.Year()])
+	m.refunds = append(m.refunds, r)
+	return r, nil
+}
+
+func (m *memoryDB) RefundByID(refundID int) (workshop.Refund, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, r := range m.refunds {
+		if r.ID == refundID {
+			return r, nil
+		}
+	}
+	return workshop.Refund{}, sql.ErrNoRows
+}
+
+func (m *memoryDB) GetRefunds(workshopID string) ([]workshop.Refund, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var refunds []workshop.Refund
+	for _, r := range m.refunds {
+		if workshopID == "" || r.WorkshopID == workshopID {
+			refunds = append(refunds, r)
+		}
+	}
+	return refunds, nil
+}
+
+func (m *memoryDB) GetRefundsBySignUpID(signupID int) ([]workshop.Refund, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var refunds []workshop.Refund
+	for _, r := range m.refunds {
+		if r.SignUpID == signupID {
+			refunds = append(refunds, r)
+		}
+	}
+	return refunds, nil
+}
+
+func (m *memoryDB) InsertInvoice(inv workshop.Invoice) (workshop.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.invoices {
+		if other.SignUpID == inv.SignUpID {
+			return inv, workshop.ErrInvoiceExists
+		}
+	}
+	year := inv.IssuedAt.Year()
+	m.invoiceSeqs[year]++
+	inv.ID = len(m.invoices) + 1
+	inv.Number = workshop.InvoiceNumber(year, m.invoiceSeqs[year])
+	inv.Gross.Currency = currency(inv.Gross.Currency)
+	inv.Net.Currency = inv.Gross.Currency
+	inv.VAT.Currency = inv.Gross.Currency
+	m.invoices = append(m.invoices, inv)
+	return inv, nil
+}
+
+func (m *memoryDB) InvoiceByNumber(number string) (workshop.Invoice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, inv := range m.invoices {
+		if inv.Number == number {
+			return inv, nil
+		}
+	}
+	return workshop.Invoice{}, sql.ErrNoRows
+}
+
+func (m *memoryDB) InvoiceBySignUpID(signupID int) (workshop.Invoice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, inv := range m.invoices {
+		if inv.SignUpID == signupID {
+			return inv, nil
+		}
+	}
+	return workshop.Invoice{}, sql.ErrNoRows
+}
+
+func (m *memoryDB) GetInvoices(workshopID string) ([]workshop.Invoice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var invoices []workshop.Invoice
+	for _, inv := range m.invoices {
+		if workshopID == "" || inv.WorkshopID == workshopID {
+			invoices = append(invoices, inv)
+		}
+	}
+	return invoices, nil
+}
+
+func (m *memoryDB) voucherIndex(code string) int {
+	for i, v := range m.vouchers {
+		if v.Code == code {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveVoucher mirrors the mysql moveVoucher; the caller holds the lock.
+func (m *memoryDB) moveVoucher(t workshop.VoucherTransaction) workshop.VoucherTransaction {
+	v := &m.vouchers[m.voucherIndex(t.Code)]
+	v.Balance.Amount += t.Amount.Amount
+	v.Classes += t.Classes
+	t.ID = len(m.voucherTxs) + 1
+	m.voucherTxs = append(m.voucherTxs, t)
+	return t
+}
+
+func (m *memoryDB) InsertVoucher(v workshop.Voucher) (workshop.Voucher, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v.Code = workshop.NormalizeCode(v.Code)
+	if m.voucherIndex(v.Code) >= 0 {
+		return v, ErrDuplicateVoucher
+	}
+	v.Balance.Currency = currency(v.Balance.Currency)
+	v.CreatedAt = time.Now().UTC()
+	v.VoidedAt = time.Time{}
+	opening := workshop.VoucherTransaction{Code: v.Code, Kind: workshop.VoucherIssued, Amount: v.Balance, Classes: v.Classes, Note: v.Note, At: v.CreatedAt}
+	stored := v
+	stored.Balance.Amount, stored.Classes = 0, 0
+	m.vouchers = append(m.vouchers, stored)
+	m.moveVoucher(opening)
+	return v, nil
+}
+
+func (m *memoryDB) VoucherByCode(code string) (workshop.Voucher, []workshop.VoucherTransaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.voucherIndex(workshop.NormalizeCode(code))
+	if i < 0 {
+		return workshop.Voucher{}, nil, sql.ErrNoRows
+	}
+	var history []workshop.VoucherTransaction
+	for _, t := range m.voucherTxs {
+		if t.Code == m.vouchers[i].Code {
+			history = append(history, t)
+		}
+	}
+	return m.vouchers[i], history, nil
+}
+
+func (m *memoryDB) VoidVoucher(code, note string) (workshop.Voucher, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.voucherIndex(workshop.NormalizeCode(code))
+	if i < 0 {
+		return workshop.Voucher{}, sql.ErrNoRows
+	}
+	v := m.vouchers[i]
+	if !v.VoidedAt.IsZero() {
+		return v, workshop.ErrVoucherVoided
+	}
+	m.vouchers[i].VoidedAt = time.Now().UTC()
+	m.moveVoucher(workshop.VoucherTransaction{Code: v.Code, Kind: workshop.VoucherVoided, Amount: v.Balance.Neg(), Classes: -v.Classes, Note: note, At: m.vouchers[i].VoidedAt})
+	return m.vouchers[i], nil
+}
+
+func (m *memoryDB) CreditVoucher(signupID int, amount money.Money, classes int, note string) (workshop.VoucherTransaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.signUpIndex(signupID)
+	if i < 0 {
+		return workshop.VoucherTransaction{}, sql.ErrNoRows
+	}
+	return m.creditVoucher(m.signups[i], amount, classes, note), nil
+}
+
+// creditVoucher mirrors the mysql creditVoucher; the caller holds the lock.
+func (m *memoryDB) creditVoucher(s workshop.SignUp, amount money.Money, classes int, note string) workshop.VoucherTransaction {
+	i := m.voucherIndex(s.VoucherCode)
+	if s.VoucherCode == "" || i < 0 {
+		return workshop.VoucherTransaction{}
+	}
+	var owedAmount int64
+	var owedClasses int
+	for _, t := range m.voucherTxs {
+		if t.Code == s.VoucherCode && t.SignUpID == s.ID {
+			owedAmount -= t.Amount.Amount
+			owedClasses -= t.Classes
+		}
+	}
+	t := creditFor(m.vouchers[i], s, amount, classes, owedAmount, owedClasses, note)
+	if t.Amount.IsZero() && t.Classes == 0 {
+		return workshop.VoucherTransaction{}
+	}
+	return m.moveVoucher(t)
+}
+
+// applyVoucher mirrors the mysql applyVoucher; the caller holds the lock.
+func (m *memoryDB) applyVoucher(signup *workshop.SignUp, now time.Time) (int, error) {
+	signup.VoucherCode = workshop.NormalizeCode(signup.VoucherCode)
+	signup.VoucherAmount = money.Money{Currency: signup.Price.Currency}
+	if signup.Price.IsZero() {
+		signup.VoucherCode = ""
+	}
+	if signup.VoucherCode == "" {
+		return 0, nil
+	}
+	i := m.voucherIndex(signup.VoucherCode)
+	if i < 0 {
+		return 0, workshop.ErrVoucherUnknown
+	}
+	amount, classes, err := m.vouchers[i].Redeem(signup.Price, now)
+	if err != nil {
+		return 0, err
+	}
+	if m.vouchers[i].Kind == workshop.VoucherGift {
+		m.vouchers[i].Balance.Amount -= amount.Amount
+	} else {
+		m.vouchers[i].Classes -= classes
+	}
+	signup.VoucherAmount = amount
+	return classes, nil
+}
+
+// unpaidBalance mirrors the mysql unpaidBalances; the caller holds the lock.
+func (m *memoryDB) unpaidBalance(s workshop.SignUp, dueBy time.Time) bool {
+	if s.Status != workshop.StatusConfirmed || s.Balance().IsZero() {
+		return false
+	}
+	i := m.workshopIndex(s.WorkshopID)
+	if i < 0 {
+		return false
+	}
+	ws := m.workshops[i]
+	return ws.TakesDeposit() && ws.CancelledAt.IsZero() && !ws.BalanceDueAt.After(dueBy)
+}
+
+func (m *memoryDB) RemindBalances(until, now time.Time) ([]workshop.SignUp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var reminded []workshop.SignUp
+	for i := range m.signups {
+		s := &m.signups[i]
+		if !m.unpaidBalance(*s, until) || !s.BalanceRemindedAt.IsZero() {
+			continue
+		}
+		s.BalanceRemindedAt = now.UTC()
+		reminded = append(reminded, *s)
+	}
+	return reminded, nil
+}
+
+func (m *memoryDB) FlagOverdueBalances(now time.Time, grace time.Duration) ([]workshop.SignUp, []workshop.SignUp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var (
+		overdue, promoted []workshop.SignUp
+		failed            error
+	)
+	freed := map[string]bool{}
+	for i := range m.signups {
+		s := &m.signups[i]
+		if !m.unpaidBalance(*s, now) || !s.BalanceOverdueAt.IsZero() || s.BalanceRemindedAt.IsZero() || s.BalanceRemindedAt.After(now.Add(-grace)) {
+			continue
+		}
+		if m.workshops[m.workshopIndex(s.WorkshopID)].ReleaseUnpaid {
+			if err := m.setStatus(s, workshop.StatusCancelled); err != nil {
+				if failed == nil {
+					failed = err
+				}
+				continue
+			}
+			for j := range m.payments {
+				if p := &m.payments[j]; p.SignUpID == s.ID && p.Status == workshop.PaymentOpen {
+					p.Status = workshop.PaymentExpired
+					p.UpdatedAt = s.UpdatedAt
+				}
+			}
+			freed[s.WorkshopID] = true
+		}
+		s.BalanceOverdueAt = now.UTC()
+		overdue = append(overdue, *s)
+	}
+	for _, ws := range m.workshops {
+		if freed[ws.WorkshopID] {
+			p, err := m.promote(ws)
+			promoted = append(promoted, p...)
+			if err != nil && failed == nil {
+				failed = err
+			}
+		}
+	}
+	return overdue, promoted, failed
+}
+
+func (m *memoryDB) instructorIndex(instructorID int) int {
+	for i, in := range m.instructors {
+		if in.ID == instructorID {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkInstructors stands in for the foreign key on workshop_instructors.
+func (m *memoryDB) checkInstructors(instructorIDs []int) error {
+	for _, id := range instructorIDs {
+		if m.instructorIndex(id) < 0 {
+			return ErrUnknownInstructor
+		}
+	}
+	return nil
+}
+
+func (m *memoryDB) InsertInstructor(in workshop.Instructor) (workshop.Instructor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextInstructorID++
+	in.ID = m.nextInstructorID
+	in.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	in.UpdatedAt = in.CreatedAt
+	m.instructors = append(m.instructors, in)
+	return in, nil
+}
+
+func (m *memoryDB) InstructorByID(instructorID int) (workshop.Instructor, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.instructorIndex(instructorID)
+	if i < 0 {
+		return workshop.Instructor{}, sql.ErrNoRows
+	}
+	return m.instructors[i], nil
+}
+
+func (m *memoryDB) GetInstructors() ([]workshop.Instructor, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	instructors := append([]workshop.Instructor(nil), m.instructors...)
+	sort.SliceStable(instructors, func(i, j int) bool { return instructors[i].Name < instructors[j].Name })
+	return instructors, nil
+}
+
+func (m *memoryDB) UpdateInstructor(in workshop.Instructor) (workshop.Instructor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.instructorIndex(in.ID)
+	if i < 0 {
+		return workshop.Instructor{}, sql.ErrNoRows
+	}
+	in.CreatedAt = m.instructors[i].CreatedAt
+	in.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	m.instructors[i] = in
+	return in, nil
+}
+
+func (m *memoryDB) DeleteInstructor(instructorID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.instructorIndex(instructorID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	m.instructors = append(m.instructors[:i], m.instructors[i+1:]...)
+	for w := range m.workshops {
+		ids := m.workshops[w].InstructorIDs[:0]
+		for _, id := range m.workshops[w].InstructorIDs {
+			if id != instructorID {
+				ids = append(ids, id)
+			}
+		}
+		m.workshops[w].InstructorIDs = ids
+	}
+	return nil
+}
+
+func (m *memoryDB) GetWorkshopsByInstructorID(instructorID int) ([]workshop.Workshop, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.instructorIndex(instructorID) < 0 {
+		return nil, sql.ErrNoRows
+	}
+	var workshops []workshop.Workshop
+	for _, ws := range m.workshops {
+		for _, id := range ws.InstructorIDs {
+			if id == instructorID {
+				workshops = append(workshops, m.withFullness(ws))
+				break
+			}
+		}
+	}
+	sort.SliceStable(workshops, func(i, j int) bool { return workshops[i].StartTime.Before(workshops[j].StartTime) })
+	return workshops, nil
+}
+
+// roomIndex returns the index of the venue of roomID and of the room in
+// it.
+func (m *memoryDB) roomIndex(roomID int) (int, int) {
+	for v, venue := range m.venues {
+		for r, room := range venue.Rooms {
+			if room.ID == roomID {
+				return v, r
+			}
+		}
+	}
+	return -1, -1
+}
+
+func (m *memoryDB) venueIndex(venueID int) int {
+	for i, v := range m.venues {
+		if v.ID == venueID {
+			return i
+		}
+	}
+	return -1
+}
+
+// roomInUse stands in for the foreign keys on workshops.room_id and
+// events.room_id.
+func (m *memoryDB) roomInUse(roomID int) bool {
+	for _, ws := range m.workshops {
+		if ws.RoomID == roomID {
+			return true
+		}
+	}
+	for _, e := range m.events {
+		if e.RoomID == roomID {
+			return true
+		}
+	}
+	return false
+}
+
+// bookRoom checks that a workshop with cap seats, or an event for a cap of
+// 0, can hold roomID during wanted.
+func (m *memoryDB) bookRoom(roomID, cap int, wanted []workshop.Booking) error {
+	if roomID == 0 {
+		return nil
+	}
+	v, r := m.roomIndex(roomID)
+	if v < 0 {
+		return ErrUnknownRoom
+	}
+	if cap > m.venues[v].Rooms[r].Capacity {
+		return workshop.ErrOverRoomCapacity
+	}
+	var existing []workshop.Booking
+	for _, ws := range m.workshops {
+		if ws.RoomID == roomID && ws.CancelledAt.IsZero() {
+			existing = append(existing, ws.RoomBookings()...)
+		}
+	}
+	for _, e := range m.events {
+		if e.RoomID == roomID {
+			existing = append(existing, e.RoomBookings()...)
+		}
+	}
+	if conflicts := workshop.Overlapping(existing, wanted); len(conflicts) > 0 {
+		return &workshop.RoomConflictError{RoomID: roomID, Conflicts: conflicts}
+	}
+	return nil
+}
+
+// withRooms returns a copy of v with a copy of its rooms, by name.
+func withRooms(v workshop.Venue) workshop.Venue {
+	v.Rooms = append([]workshop.Room(nil), v.Rooms...)
+	sort.SliceStable(v.Rooms, func(i, j int) bool { return v.Rooms[i].Name < v.Rooms[j].Name })
+	return v
+}
+
+func (m *memoryDB) InsertVenue(v workshop.Venue) (workshop.Venue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextVenueID++
+	v.ID = m.nextVenueID
+	v.Rooms = nil
+	v.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	v.UpdatedAt = v.CreatedAt
+	m.venues = append(m.venues, v)
+	return v, nil
+}
+
+func (m *memoryDB) VenueByID(venueID int) (workshop.Venue, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.venueIndex(venueID)
+	if i < 0 {
+		return workshop.Venue{}, sql.ErrNoRows
+	}
+	return withRooms(m.venues[i]), nil
+}
+
+func (m *memoryDB) GetVenues() ([]workshop.Venue, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var venues []workshop.Venue
+	for _, v := range m.venues {
+		venues = append(venues, withRooms(v))
+	}
+	sort.SliceStable(venues, func(i, j int) bool { return venues[i].Name < venues[j].Name })
+	return venues, nil
+}
+
+func (m *memoryDB) UpdateVenue(v workshop.Venue) (workshop.Venue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.venueIndex(v.ID)
+	if i < 0 {
+		return workshop.Venue{}, sql.ErrNoRows
+	}
+	cur := &m.venues[i]
+	cur.Name = v.Name
+	cur.Address = v.Address
+	cur.AccessibilityNotes = v.AccessibilityNotes
+	cur.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	return withRooms(*cur), nil
+}
+
+func (m *memoryDB) DeleteVenue(venueID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.venueIndex(venueID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	for _, room := range m.venues[i].Rooms {
+		if m.roomInUse(room.ID) {
+			return ErrRoomInUse
+		}
+	}
+	m.venues = append(m.venues[:i], m.venues[i+1:]...)
+	return nil
+}
+
+func (m *memoryDB) InsertRoom(room workshop.Room) (workshop.Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.venueIndex(room.VenueID)
+	if i < 0 {
+		return room, sql.ErrNoRows
+	}
+	m.nextRoomID++
+	room.ID = m.nextRoomID
+	m.venues[i].Rooms = append(m.venues[i].Rooms, room)
+	return room, nil
+}
+
+func (m *memoryDB) RoomByID(roomID int) (workshop.Room, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, r := m.roomIndex(roomID)
+	if v < 0 {
+		return workshop.Room{}, sql.ErrNoRows
+	}
+	return m.venues[v].Rooms[r], nil
+}
+
+func (m *memoryDB) UpdateRoom(room workshop.Room) (workshop.Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, r := m.roomIndex(room.ID)
+	if v < 0 {
+		return room, sql.ErrNoRows
+	}
+	for _, ws := range m.workshops {
+		if ws.RoomID == room.ID && ws.CancelledAt.IsZero() && ws.Cap > room.Capacity {
+			return room, workshop.ErrOverRoomCapacity
+		}
+	}
+	room.VenueID = m.venues[v].ID
+	m.venues[v].Rooms[r] = room
+	return room, nil
+}
+
+func (m *memoryDB) DeleteRoom(roomID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, r := m.roomIndex(roomID)
+	if v < 0 {
+		return sql.ErrNoRows
+	}
+	if m.roomInUse(roomID) {
+		return ErrRoomInUse
+	}
+	m.venues[v].Rooms = append(m.venues[v].Rooms[:r], m.venues[v].Rooms[r+1:]...)
+	return nil
+}
+
+// calendar returns a copy of the opening hours and closures.
+func (m *memoryDB) calendar() workshop.Calendar {
+	return workshop.Calendar{
+		Hours:    append([]workshop.OpeningHours(nil), m.hours...),
+		Closures: append([]workshop.Closure(nil), m.closures...),
+	}
+}
+
+func (m *memoryDB) GetCalendar() (workshop.Calendar, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c := m.calendar()
+	sort.SliceStable(c.Hours, func(i, j int) bool {
+		if c.Hours[i].Weekday != c.Hours[j].Weekday {
+			return c.Hours[i].Weekday < c.Hours[j].Weekday
+		}
+		return c.Hours[i].Opens < c.Hours[j].Opens
+	})
+	sort.SliceStable(c.Closures, func(i, j int) bool { return c.Closures[i].From.Before(c.Closures[j].From) })
+	return c, nil
+}
+
+func (m *memoryDB) SetOpeningHours(hours []workshop.OpeningHours) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hours = append([]workshop.OpeningHours(nil), hours...)
+	return nil
+}
+
+func (m *memoryDB) InsertClosure(c workshop.Closure) (workshop.Closure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextClosureID++
+	c.ID = m.nextClosureID
+	c.From, c.Until = workshop.Date(c.From), workshop.Date(c.Until)
+	c.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	m.closures = append(m.closures, c)
+	return c, nil
+}
+
+func (m *memoryDB) DeleteClosure(closureID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, c := range m.closures {
+		if c.ID == closureID {
+			m.closures = append(m.closures[:i], m.closures[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *memoryDB) GetClosures(from time.Time) ([]workshop.Closure, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var closures []workshop.Closure
+	for _, c := range m.closures {
+		if !c.Until.Before(workshop.Date(from)) {
+			closures = append(closures, c)
+		}
+	}
+	sort.SliceStable(closures, func(i, j int) bool { return closures[i].From.Before(closures[j].From) })
+	return closures, nil
+}
+
+// checkCategories stands in for the foreign keys on workshop_categories and
+// event_categories.
+func (m *memoryDB) checkCategories(categoryIDs []int) error {
+	for _, id := range categoryIDs {
+		if m.categoryIndex(id) < 0 {
+			return ErrUnknownCategory
+		}
+	}
+	return nil
+}
+
+func (m *memoryDB) categoryIndex(categoryID int) int {
+	for i, c := range m.categories {
+		if c.ID == categoryID {
+			return i
+		}
+	}
+	return -1
+}
+
+// categoriesOf returns the categories with ids by name, and their ids in
+// that order.
+func (m *memoryDB) categoriesOf(ids []int) ([]workshop.Category, []int) {
+	var categories []workshop.Category
+	for _, id := range ids {
+		categories = append(categories, m.categories[m.categoryIndex(id)])
+	}
+	sortCategories(categories)
+	var sorted []int
+	for _, c := range categories {
+		sorted = append(sorted, c.ID)
+	}
+	return categories, sorted
+}
+
+func sortCategories(categories []workshop.Category) {
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].Name != categories[j].Name {
+			return categories[i].Name < categories[j].Name
+		}
+		return categories[i].ID < categories[j].ID
+	})
+}
+
+// withTaxonomy returns a copy of e with its categories filled in.
+func (m *memoryDB) withTaxonomy(e workshop.Event) workshop.Event {
+	e.Categories, e.CategoryIDs = m.categoriesOf(e.CategoryIDs)
+	e.Tags = append([]string(nil), e.Tags...)
+	return e
+}
+
+// checkCategory stands in for the keys on categories: unique slugs and an
+// existing parent.
+func (m *memoryDB) checkCategory(c workshop.Category) error {
+	for _, other := range m.categories {
+		if other.ID != c.ID && other.Slug == c.Slug {
+			return ErrDuplicateCategory
+		}
+	}
+	if c.ParentID != 0 && m.categoryIndex(c.ParentID) < 0 {
+		return ErrUnknownCategory
+	}
+	return nil
+}
+
+func (m *memoryDB) InsertCategory(c workshop.Category) (workshop.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkCategory(c); err != nil {
+		return c, err
+	}
+	m.nextCategoryID++
+	c.ID = m.nextCategoryID
+	c.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	c.UpdatedAt = c.CreatedAt
+	c.WorkshopCount, c.EventCount = 0, 0
+	m.categories = append(m.categories, c)
+	return c, nil
+}
+
+func (m *memoryDB) CategoryByID(categoryID int) (workshop.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.categoryIndex(categoryID)
+	if i < 0 {
+		return workshop.Category{}, sql.ErrNoRows
+	}
+	return m.categories[i], nil
+}
+
+func (m *memoryDB) GetCategories(now time.Time) ([]workshop.Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	categories := append([]workshop.Category(nil), m.categories...)
+	sortCategories(categories)
+	workshops := make(map[int][]string)
+	for _, ws := range m.workshops {
+		if !ws.CancelledAt.IsZero() || !ws.EndTime.After(now) {
+			continue
+		}
+		for _, id := range ws.CategoryIDs {
+			workshops[id] = append(workshops[id], ws.WorkshopID)
+		}
+	}
+	events := make(map[int][]string)
+	for _, e := range m.events {
+		if !e.EndTime.After(now) {
+			continue
+		}
+		for _, id := range e.CategoryIDs {
+			events[id] = append(events[id], e.ID)
+		}
+	}
+	workshop.CountOfferings(categories, workshops, events)
+	return categories, nil
+}
+
+func (m *memoryDB) UpdateCategory(c workshop.Category) (workshop.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.categoryIndex(c.ID)
+	if i < 0 {
+		return workshop.Category{}, sql.ErrNoRows
+	}
+	if c.ParentID != 0 {
+		for _, id := range workshop.Subtree(m.categories, c.ID) {
+			if id == c.ParentID {
+				return workshop.Category{}, workshop.ErrInvalidCategory
+			}
+		}
+	}
+	if err := m.checkCategory(c); err != nil {
+		return workshop.Category{}, err
+	}
+	cur := &m.categories[i]
+	cur.ParentID = c.ParentID
+	cur.Name = c.Name
+	cur.Slug = c.Slug
+	cur.Description = c.Description
+	cur.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	return *cur, nil
+}
+
+func (m *memoryDB) DeleteCategory(categoryID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.categoryIndex(categoryID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	for _, c := range m.categories {
+		if c.ParentID == categoryID {
+			return ErrCategoryHasChildren
+		}
+	}
+	for _, d := range m.discounts {
+		for _, id := range d.CategoryIDs {
+			if id == categoryID {
+				return ErrCategoryInUse
+			}
+		}
+	}
+	m.categories = append(m.categories[:i], m.categories[i+1:]...)
+	without := func(ids []int) []int {
+		var out []int
+		for _, id := range ids {
+			if id != categoryID {
+				out = append(out, id)
+			}
+		}
+		return out
+	}
+	for w := range m.workshops {
+		m.workshops[w].CategoryIDs = without(m.workshops[w].CategoryIDs)
+	}
+	for e := range m.events {
+		m.events[e].CategoryIDs = without(m.events[e].CategoryIDs)
+	}
+	return nil
+}
+
+// listing is what filters and sorts look at of a workshop or event.
+type listing struct {
+	id          string
+	categoryIDs []int
+	tags        []string
+	start       time.Time
+	price       int64
+	location    string
+	roomID      int
+	// level and available are those of workshops; events have neither.
+	workshop  bool
+	level     string
+	available bool
+}
+
+func workshopListing(ws workshop.Workshop) listing {
+	return listing{
+		id:          ws.WorkshopID,
+		categoryIDs: ws.CategoryIDs,
+		tags:        ws.Tags,
+		start:       ws.StartTime,
+		price:       ws.Price.Amount,
+		location:    ws.Location,
+		roomID:      ws.RoomID,
+		workshop:    true,
+		level:       ws.Level,
+		available:   ws.CancelledAt.IsZero() && !ws.IsFull,
+	}
+}
+
+func eventListing(e workshop.Event) listing {
+	return listing{id: e.ID, categoryIDs: e.CategoryIDs, tags: e.Tags, start: e.StartTime, price: e.Price.Amount, location: e.Location, roomID: e.RoomID}
+}
+
+// matcher mirrors filterWhere: it returns whether a listing matches f.
+func (m *memoryDB) matcher(f workshop.Filter) (func(listing) bool, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	in := make(map[int]bool)
+	if f.Category != "" {
+		id := 0
+		for _, c := range m.categories {
+			if c.Slug == f.Category {
+				id = c.ID
+			}
+		}
+		if id == 0 {
+			return nil, ErrUnknownCategory
+		}
+		for _, id := range workshop.Subtree(m.categories, id) {
+			in[id] = true
+		}
+	}
+	return func(l listing) bool {
+		if f.Category != "" {
+			found := false
+			for _, id := range l.categoryIDs {
+				found = found || in[id]
+			}
+			if !found {
+				return false
+			}
+		}
+		for _, want := range f.Tags {
+			found := false
+			for _, t := range l.tags {
+				found = found || t == want
+			}
+			if !found {
+				return false
+			}
+		}
+		switch {
+		case !f.From.IsZero() && l.start.Before(f.From),
+			!f.To.IsZero() && !l.start.Before(f.To),
+			f.Location != "" && !strings.Contains(strings.ToLower(l.location), strings.ToLower(f.Location)),
+			f.MinPrice != nil && l.price < *f.MinPrice,
+			f.MaxPrice != nil && l.price > *f.MaxPrice,
+			f.Level != "" && (!l.workshop || !strings.EqualFold(l.level, f.Level)),
+			f.Available && !l.available:
+			return false
+		}
+		if f.VenueID != 0 {
+			v, _ := m.roomIndex(l.roomID)
+			if l.roomID == 0 || v < 0 || m.venues[v].ID != f.VenueID {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// page sorts the keys of the matching items by p.Sort and returns the
+// indexes of those on the page, and the cursor of the next page. p must
+// have been validated.
+func page(p workshop.Page, keys, ids []string) ([]int, string) {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return p.Sort.Compare(keys[order[a]], ids[order[a]], keys[order[b]], ids[order[b]]) < 0
+	})
+	if p.Cursor != "" {
+		c, _ := workshop.DecodeCursor(p.Cursor, p.Sort)
+		after := order[:0]
+		for _, i := range order {
+			if p.Sort.Compare(keys[i], ids[i], c.Key, c.ID) > 0 {
+				after = append(after, i)
+			}
+		}
+		order = after
+	}
+	if len(order) <= p.Limit {
+		return order, ""
+	}
+	order = order[:p.Limit]
+	last := order[p.Limit-1]
+	return order, workshop.Cursor{Sort: p.Sort, Key: keys[last], ID: ids[last]}.Encode()
+}
+
+func (m *memoryDB) ListWorkshops(filter workshop.Filter, p workshop.Page) ([]workshop.Workshop, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := p.Validate(); err != nil {
+		return nil, "", err
+	}
+	matches, err := m.matcher(filter)
+	if err != nil {
+		return nil, "", err
+	}
+	var (
+		matched   []workshop.Workshop
+		keys, ids []string
+	)
+	for _, ws := range m.workshops {
+		ws = m.withFullness(ws)
+		if matches(workshopListing(ws)) {
+			matched = append(matched, ws)
+			keys = append(keys, ws.SortKey(p.Sort))
+			ids = append(ids, ws.WorkshopID)
+		}
+	}
+	order, next := page(p, keys, ids)
+	var workshops []workshop.Workshop
+	for _, i := range order {
+		workshops = append(workshops, matched[i])
+	}
+	return workshops, next, nil
+}
+
+func (m *memoryDB) ListEvents(filter workshop.Filter, p workshop.Page) ([]workshop.Event, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := p.Validate(); err != nil {
+		return nil, "", err
+	}
+	matches, err := m.matcher(filter)
+	if err != nil {
+		return nil, "", err
+	}
+	var (
+		matched   []workshop.Event
+		keys, ids []string
+	)
+	for _, e := range m.events {
+		if matches(eventListing(e)) {
+			matched = append(matched, m.withTaxonomy(e))
+			keys = append(keys, e.SortKey(p.Sort))
+			ids = append(ids, e.ID)
+		}
+	}
+	order, next := page(p, keys, ids)
+	var events []workshop.Event
+	for _, i := range order {
+		events = append(events, matched[i])
+	}
+	return events, next, nil
+}
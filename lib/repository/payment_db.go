@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+const paymentColumns = "id, signup_id, provider, session_id, amount, currency, status, created_at, updated_at"
+
+func scanPayment(r rowScanner) (workshop.Payment, error) {
+	var p workshop.Payment
+	err := r.Scan(&p.ID, &p.SignUpID, &p.Provider, &p.SessionID, &p.Amount.Amount, &p.Amount.Currency, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// InsertPayment stores the checkout session opened for a signup.
+func (w workshopDB) InsertPayment(p workshop.Payment) (workshop.Payment, error) {
+	now := time.Now().UTC()
+	p.Amount.Currency = currency(p.Amount.Currency)
+	p.Status = workshop.PaymentOpen
+	p.CreatedAt, p.UpdatedAt = now, now
+	res, err := w.db.Exec(
+		"INSERT INTO payments (signup_id, provider, session_id, amount, currency, status, created_at, updated_at) VALUES (?,?,?,?,?,?,?,?)",
+		p.SignUpID,
+		p.Provider,
+		p.SessionID,
+		p.Amount.Amount,
+		p.Amount.Currency,
+		p.Status,
+		p.CreatedAt,
+		p.UpdatedAt,
+	)
+	if err != nil {
+		return p, err
+	}
+	id, err := res.LastInsertId()
+	p.ID = int(id)
+	return p, err
+}
+
+func (w workshopDB) GetPaymentsBySignUpID(signupID int) ([]workshop.Payment, error) {
+	var payments []workshop.Payment
+	rows, err := w.db.Query("SELECT "+paymentColumns+" FROM payments WHERE signup_id = ? ORDER BY id", signupID)
+	if err != nil {
+		return payments, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return payments, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// GetPaymentsByStatus lists the payments in status, oldest first.
+func (w workshopDB) GetPaymentsByStatus(status workshop.PaymentStatus) ([]workshop.Payment, error) {
+	var payments []workshop.Payment
+	rows, err := w.db.Query("SELECT "+paymentColumns+" FROM payments WHERE status = ? ORDER BY id", status)
+	if err != nil {
+		return payments, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return payments, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// PaymentBySessionID returns the payment of a provider's checkout session.
+func (w workshopDB) PaymentBySessionID(provider, sessionID string) (workshop.Payment, error) {
+	return scanPayment(w.db.QueryRow("SELECT "+paymentColumns+" FROM payments WHERE provider = ? AND session_id = ?", provider, sessionID))
+}
+
+// SettlePayment records the outcome a provider reported for a checkout
+// session, adds what was paid to its signup and settles the seat of the
+// signup: a paid signup is confirmed, otherwise it is cancelled and the
+// waitlist promoted. Callbacks for a payment that is already settled change
+// nothing, as providers deliver them at least once, and a signup that is no
+// longer pending keeps its status, e.g. when it pays off its balance.
+// A payment that arrives after its hold was released is still recorded as
+// paid, so that it can be refunded. A mismatched payment changes nothing
+// but its status, except that a pending signup stops being held, so that
+// its seat is kept until an admin resolves it.
+func (w workshopDB) SettlePayment(provider, sessionID string, status workshop.PaymentStatus) (workshop.Payment, []workshop.SignUp, error) {
+	var (
+		p        workshop.Payment
+		promoted []workshop.SignUp
+	)
+	err := transact(w.db, func(tx *sql.Tx) error {
+		var workshopID string
+		if err := tx.QueryRow(
+			"SELECT s.workshop_id FROM payments p JOIN signups s ON s.id = p.signup_id WHERE p.provider = ? AND p.session_id = ?",
+			provider, sessionID,
+		).Scan(&workshopID); err != nil {
+			return err
+		}
+		if _, err := lockWorkshop(tx, workshopID); err != nil {
+			return err
+		}
+		var err error
+		p, err = scanPayment(tx.QueryRow("SELECT "+paymentColumns+" FROM payments WHERE provider = ? AND session_id = ? FOR UPDATE", provider, sessionID))
+		if err != nil {
+			return err
+		}
+		if !canSettle(p.Status, status) {
+			return nil
+		}
+		p.Status = status
+		p.UpdatedAt = time.Now().UTC()
+		if _, err := tx.Exec("UPDATE payments SET status = ?, updated_at = ? WHERE id = ?", p.Status, p.UpdatedAt, p.ID); err != nil {
+			return err
+		}
+		if status == workshop.PaymentPaid {
+			if _, err := tx.Exec("UPDATE signups SET amount_paid = amount_paid + ? WHERE id = ?", p.Amount.Amount, p.SignUpID); err != nil {
+				return err
+			}
+		}
+		s, err := scanSignUp(tx.QueryRow("SELECT "+signupColumns+" FROM signups WHERE id = ? FOR UPDATE", p.SignUpID))
+		if err != nil {
+			return err
+		}
+		if s.Status != workshop.StatusPending {
+			return nil
+		}
+		if status == workshop.PaymentMismatched {
+			_, err := tx.Exec("UPDATE signups SET hold_expires_at = NULL WHERE id = ?", s.ID)
+			return err
+		}
+		to := status.SignUpStatus()
+		if err := setStatus(tx, &s, to); err != nil {
+			return err
+		}
+		if !to.HoldsSeat() {
+			promoted, err = w.promote(tx, workshopID)
+		}
+		return err
+	})
+	return p, promoted, err
+}
+
+// canSettle reports whether a payment in status from takes the outcome to.
+// Expired payments still take money that arrives late.
+func canSettle(from, to workshop.PaymentStatus) bool {
+	return !from.Settled() || from == workshop.PaymentExpired && (to == workshop.PaymentPaid || to == workshop.PaymentMismatched)
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/workshop"
+)
+
+const priceTierColumns = "id, workshop_id, name, price_amount, currency, valid_from, valid_until, eligibility"
+
+// inList returns the placeholders and arguments of a sql IN list of ids.
+func inList(ids []string) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","), args
+}
+
+func scanPriceTier(r rowScanner) (workshop.PriceTier, error) {
+	var (
+		t                     workshop.PriceTier
+		validFrom, validUntil mysql.NullTime
+	)
+	err := r.Scan(&t.ID, &t.WorkshopID, &t.Name, &t.Price.Amount, &t.Price.Currency, &validFrom, &validUntil, &t.Eligibility)
+	t.ValidFrom = validFrom.Time
+	t.ValidUntil = validUntil.Time
+	return t, err
+}
+
+// loadPriceTiers fills in the price tiers of workshops in one query.
+func loadPriceTiers(db queryer, workshops []workshop.Workshop) error {
+	if len(workshops) == 0 {
+		return nil
+	}
+	ids := make([]string, len(workshops))
+	for i, ws := range workshops {
+		ids[i] = ws.WorkshopID
+	}
+	placeholders, args := inList(ids)
+	rows, err := db.Query("SELECT "+priceTierColumns+" FROM workshop_price_tiers WHERE workshop_id IN ("+placeholders+") ORDER BY workshop_id, id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	tiers := make(map[string][]workshop.PriceTier)
+	for rows.Next() {
+		t, err := scanPriceTier(rows)
+		if err != nil {
+			return err
+		}
+		tiers[t.WorkshopID] = append(tiers[t.WorkshopID], t)
+	}
+	for i := range workshops {
+		workshops[i].PriceTiers = tiers[workshops[i].WorkshopID]
+	}
+	return rows.Err()
+}
+
+func insertPriceTiers(db execer, ws workshop.Workshop) error {
+	for _, t := range ws.PriceTiers {
+		if _, err := db.Exec(
+			"INSERT INTO workshop_price_tiers (workshop_id, name, price_amount, currency, valid_from, valid_until, eligibility) VALUES (?,?,?,?,?,?,?)",
+			ws.WorkshopID,
+			t.Name,
+			t.Price.Amount,
+			currency(t.Price.Currency),
+			nullTime(t.ValidFrom),
+			nullTime(t.ValidUntil),
+			t.Eligibility,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replacePriceTiers stores the price tiers of ws in place of the current
+// ones. Nil tiers leave the current ones alone; signups keep a copy of the
+// price they booked, so nothing refers to a tier.
+func replacePriceTiers(tx *sql.Tx, ws workshop.Workshop) error {
+	if ws.PriceTiers == nil {
+		return nil
+	}
+	if _, err := tx.Exec("DELETE FROM workshop_price_tiers WHERE workshop_id = ?", ws.WorkshopID); err != nil {
+		return err
+	}
+	return insertPriceTiers(tx, ws)
+}
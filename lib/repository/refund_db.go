@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+const refundColumns = "id, signup_id, workshop_id, payment_id, method, provider_refund_id, amount, currency, reason, note, number, created_at"
+
+// CancelWorkshop calls off a workshop: every signup holding or waiting for a
+// seat is cancelled, without promoting anyone, and their open checkouts are
+// expired. The cancelled signups are returned as they were before, so that
+// the caller can refund and notify them.
+func (w workshopDB) CancelWorkshop(workshopID string) ([]workshop.SignUp, error) {
+	var cancelled []workshop.SignUp
+	err := transact(w.db, func(tx *sql.Tx) error {
+		seats, err := lockWorkshop(tx, workshopID)
+		if err != nil {
+			return err
+		}
+		if seats.cancelled {
+			return ErrWorkshopCancelled
+		}
+		rows, err := tx.Query(
+			"SELECT "+signupColumns+" FROM signups WHERE workshop_id = ? AND status IN (?, ?, ?) ORDER BY id FOR UPDATE",
+			workshopID, workshop.StatusPending, workshop.StatusConfirmed, workshop.StatusWaitlisted,
+		)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			s, err := scanSignUp(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			cancelled = append(cancelled, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		for _, s := range cancelled {
+			if err := setStatus(tx, &s, workshop.StatusCancelled); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(
+				"UPDATE payments SET status = ?, updated_at = ? WHERE signup_id = ? AND status = ?",
+				workshop.PaymentExpired, now, s.ID, workshop.PaymentOpen,
+			); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec("UPDATE workshops SET cancelled_at = ?, updated_at = ? WHERE workshop_id = ?", now, now, workshopID)
+		return err
+	})
+	return cancelled, err
+}
+
+func scanRefund(r rowScanner) (workshop.Refund, error) {
+	var (
+		f         workshop.Refund
+		paymentID sql.NullInt64
+	)
+	err := r.Scan(&f.ID, &f.SignUpID, &f.WorkshopID, &paymentID, &f.Method, &f.ProviderRefundID, &f.Amount.Amount, &f.Amount.Currency, &f.Reason, &f.Note, &f.Number, &f.CreatedAt)
+	f.PaymentID = int(paymentID.Int64)
+	return f, err
+}
+
+// InsertRefund records money paid back on a signup and numbers its credit
+// note. Refunds on a signup never add up to more than was paid for it
+// besides its voucher. Credit note numbers run without gaps per year, like
+// invoice numbers.
+func (w workshopDB) InsertRefund(r workshop.Refund) (workshop.Refund, error) {
+	err := transact(w.db, func(tx *sql.Tx) error {
+		s, err := scanSignUp(tx.QueryRow("SELECT "+signupColumns+" FROM signups WHERE id = ? FOR UPDATE", r.SignUpID))
+		if err != nil {
+			return err
+		}
+		var refunded int64
+		if err := tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE signup_id = ?", s.ID).Scan(&refunded); err != nil {
+			return err
+		}
+		r.Amount.Currency = currency(r.Amount.Currency)
+		if r.Amount.Currency != s.Price.Currency || refunded+r.Amount.Amount > s.Due().Amount {
+			return workshop.ErrRefundExceedsPaid
+		}
+		r.WorkshopID = s.WorkshopID
+		r.CreatedAt = time.Now().UTC()
+		year := r.CreatedAt.Year()
+		if _, err := tx.Exec("INSERT INTO credit_note_sequences (year, last) VALUES (?, 1) ON DUPLICATE KEY UPDATE last = last + 1", year); err != nil {
+			return err
+		}
+		var seq int
+		if err := tx.QueryRow("SELECT last FROM credit_note_sequences WHERE year = ?", year).Scan(&seq); err != nil {
+			return err
+		}
+		r.Number = workshop.CreditNoteNumber(year, seq)
+		var paymentID interface{}
+		if r.PaymentID != 0 {
+			paymentID = r.PaymentID
+		}
+		res, err := tx.Exec(
+			"INSERT INTO refunds (signup_id, workshop_id, payment_id, method, provider_refund_id, amount, currency, reason, note, number, created_at) VALUES (?,?,?,?,?,?,?,?,?,?,?)",
+			r.SignUpID,
+			r.WorkshopID,
+			paymentID,
+			r.Method,
+			r.ProviderRefundID,
+			r.Amount.Amount,
+			r.Amount.Currency,
+			r.Reason,
+			r.Note,
+			r.Number,
+			r.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		r.ID = int(id)
+		return err
+	})
+	return r, err
+}
+
+func (w workshopDB) RefundByID(refundID int) (workshop.Refund, error) {
+	return scanRefund(w.db.QueryRow("SELECT "+refundColumns+" FROM refunds WHERE id = ?", refundID))
+}
+
+// GetRefunds is the refund ledger, oldest first, of one workshop or, for an
+// empty workshopID, of all of them.
+func (w workshopDB) GetRefunds(workshopID string) ([]workshop.Refund, error) {
+	if workshopID == "" {
+		return w.queryRefunds("")
+	}
+	return w.queryRefunds("WHERE workshop_id = ?", workshopID)
+}
+
+func (w workshopDB) GetRefundsBySignUpID(signupID int) ([]workshop.Refund, error) {
+	return w.queryRefunds("WHERE signup_id = ?", signupID)
+}
+
+func (w workshopDB) queryRefunds(where string, args ...interface{}) ([]workshop.Refund, error) {
+	var refunds []workshop.Refund
+	rows, err := w.db.Query("SELECT "+refundColumns+" FROM refunds "+where+" ORDER BY id", args...)
+	if err != nil {
+		return refunds, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanRefund(rows)
+		if err != nil {
+			return refunds, err
+		}
+		refunds = append(refunds, r)
+	}
+	return refunds, rows.Err()
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// creditNotesWithoutGaps refunds two signups, with a rejected refund in
+// between, and checks that their credit notes got consecutive numbers of the
+// current year, and that the refunds stay in the ledger once their workshop
+// is deleted.
+func creditNotesWithoutGaps(t *testing.T, db WorkshopDB) {
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	ws := workshop.Workshop{
+		WorkshopID: fmt.Sprintf("refunds-%d", time.Now().UnixNano()),
+		Name:       "Refunds",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Cap:        5,
+		Price:      money.New(4500, "EUR"),
+	}
+	if err := db.InsertWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	var numbers []string
+	for i := 0; i < 2; i++ {
+		su, err := db.SignUp(workshop.SignUp{
+			WorkshopID: ws.WorkshopID,
+			FirstName:  fmt.Sprintf("Refunded%d", i),
+			Email:      fmt.Sprintf("refunded%d@example.com", i),
+			Price:      ws.Price,
+		}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.InsertRefund(workshop.Refund{SignUpID: su.ID, Amount: money.New(5000, "EUR"), Reason: workshop.RefundOther, Method: workshop.RefundManual}); err != workshop.ErrRefundExceedsPaid {
+			t.Fatalf("got %v refunding more than was paid, want %v", err, workshop.ErrRefundExceedsPaid)
+		}
+		r, err := db.InsertRefund(workshop.Refund{SignUpID: su.ID, Amount: money.New(2000, "EUR"), Reason: workshop.RefundOther, Method: workshop.RefundManual})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, err := db.RefundByID(r.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Number != r.Number {
+			t.Errorf("stored credit note %q, issued %q", stored.Number, r.Number)
+		}
+		numbers = append(numbers, r.Number)
+	}
+	var year, first, second int
+	if _, err := fmt.Sscanf(numbers[0], "CN-%d-%d", &year, &first); err != nil || year != time.Now().UTC().Year() {
+		t.Fatalf("got credit note %q, want one of this year", numbers[0])
+	}
+	if _, err := fmt.Sscanf(numbers[1], "CN-%d-%d", &year, &second); err != nil || second != first+1 {
+		t.Errorf("got credit notes %v, want consecutive numbers", numbers)
+	}
+
+	if err := db.DeleteWorkshop(ws.WorkshopID); err != nil {
+		t.Fatal(err)
+	}
+	kept, err := db.GetRefunds(ws.WorkshopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 2 || kept[0].Number != numbers[0] || kept[1].Number != numbers[1] {
+		t.Errorf("got refunds %+v after deleting the workshop, want credit notes %v kept", kept, numbers)
+	}
+}
+
+func TestCreditNotesWithoutGapsMemory(t *testing.T) {
+	creditNotesWithoutGaps(t, NewMemoryDB())
+}
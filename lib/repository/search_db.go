@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/workshop/lib/search"
+	"github.com/workshop/lib/workshop"
+)
+
+// SearchDB is a WorkshopDB whose workshops and events can be searched in
+// full text.
+type SearchDB interface {
+	WorkshopDB
+	// Search ranks the workshops and events matching query that are neither
+	// over by now nor cancelled.
+	Search(query string, now time.Time, limit int) ([]search.Hit, error)
+	// Suggest completes the last word of prefix from the words of the
+	// workshops and events that are neither over nor cancelled.
+	Suggest(prefix string, now time.Time, limit int) ([]string, error)
+}
+
+// searchDB keeps a search index of the workshops and events of a WorkshopDB
+// in sync with every write to them through it. A write whose offerings
+// cannot be read back marks the index stale, and the next search rebuilds
+// it.
+type searchDB struct {
+	WorkshopDB
+	index *search.Index
+}
+
+// NewSearchDB indexes the workshops and events of db. Writes have to go
+// through the returned store to be searchable.
+func NewSearchDB(db WorkshopDB) (SearchDB, error) {
+	s := searchDB{WorkshopDB: db, index: search.NewIndex()}
+	return s, s.reindex()
+}
+
+func workshopDoc(ws workshop.Workshop) search.Doc {
+	d := search.Doc{
+		Kind:        search.KindWorkshop,
+		ID:          ws.WorkshopID,
+		Name:        ws.Name,
+		Caption:     ws.Caption,
+		Description: ws.Description,
+		Start:       ws.LocalStart(),
+		End:         ws.EndTime,
+		Cancelled:   !ws.CancelledAt.IsZero(),
+	}
+	for _, c := range ws.Categories {
+		d.Categories = append(d.Categories, c.Name)
+	}
+	for _, in := range ws.Instructors {
+		d.Instructors = append(d.Instructors, in.Name)
+	}
+	return d
+}
+
+func eventDoc(e workshop.Event) search.Doc {
+	d := search.Doc{
+		Kind:        search.KindEvent,
+		ID:          e.ID,
+		Name:        e.Name,
+		Caption:     e.Caption,
+		Description: e.Description,
+		Start:       e.LocalStart(),
+		End:         e.EndTime,
+	}
+	for _, c := range e.Categories {
+		d.Categories = append(d.Categories, c.Name)
+	}
+	return d
+}
+
+// reindex rebuilds the index from every workshop and event.
+func (s searchDB) reindex() error {
+	workshops, err := s.WorkshopDB.GetWorkshops()
+	if err != nil {
+		return err
+	}
+	events, err := s.WorkshopDB.GetEvents()
+	if err != nil {
+		return err
+	}
+	var docs []search.Doc
+	for _, ws := range workshops {
+		docs = append(docs, workshopDoc(ws))
+	}
+	for _, e := range events {
+		docs = append(docs, eventDoc(e))
+	}
+	s.index.Reset(docs)
+	return nil
+}
+
+// indexWorkshops reads the workshops back after a write, dropping those
+// that are gone.
+func (s searchDB) indexWorkshops(workshopIDs ...string) {
+	for _, id := range workshopIDs {
+		ws, err := s.WorkshopDB.WorkshopByID(id)
+		switch {
+		case err == sql.ErrNoRows:
+			s.index.Remove(search.KindWorkshop, id)
+		case err != nil:
+			s.index.Invalidate()
+		default:
+			s.index.Put(workshopDoc(ws))
+		}
+	}
+}
+
+// indexEvent reads an event back after a write, dropping it if it is gone.
+func (s searchDB) indexEvent(eventID string) {
+	e, err := s.WorkshopDB.EventByID(eventID)
+	switch {
+	case err == sql.ErrNoRows:
+		s.index.Remove(search.KindEvent, eventID)
+	case err != nil:
+		s.index.Invalidate()
+	default:
+		s.index.Put(eventDoc(e))
+	}
+}
+
+// reindexAfter rebuilds the index after a write to what many offerings
+// share, like the name of a category or an instructor.
+func (s searchDB) reindexAfter(err error) error {
+	if err == nil && s.reindex() != nil {
+		s.index.Invalidate()
+	}
+	return err
+}
+
+func (s searchDB) Search(query string, now time.Time, limit int) ([]search.Hit, error) {
+	if s.index.Stale() {
+		if err := s.reindex(); err != nil {
+			return nil, err
+		}
+	}
+	return s.index.Search(query, now, limit), nil
+}
+
+func (s searchDB) Suggest(prefix string, now time.Time, limit int) ([]string, error) {
+	if s.index.Stale() {
+		if err := s.reindex(); err != nil {
+			return nil, err
+		}
+	}
+	return s.index.Suggest(prefix, now, limit), nil
+}
+
+func (s searchDB) InsertWorkshop(ws workshop.Workshop) error {
+	if err := s.WorkshopDB.InsertWorkshop(ws); err != nil {
+		return err
+	}
+	s.indexWorkshops(ws.WorkshopID)
+	return nil
+}
+
+func (s searchDB) UpdateWorkshop(ws workshop.Workshop) ([]workshop.SignUp, error) {
+	promoted, err := s.WorkshopDB.UpdateWorkshop(ws)
+	if err == nil {
+		s.indexWorkshops(ws.WorkshopID)
+	}
+	return promoted, err
+}
+
+func (s searchDB) DeleteWorkshop(workshopID string) error {
+	if err := s.WorkshopDB.DeleteWorkshop(workshopID); err != nil {
+		return err
+	}
+	s.index.Remove(search.KindWorkshop, workshopID)
+	return nil
+}
+
+func (s searchDB) CancelWorkshop(workshopID string) ([]workshop.SignUp, error) {
+	signups, err := s.WorkshopDB.CancelWorkshop(workshopID)
+	if err == nil {
+		s.indexWorkshops(workshopID)
+	}
+	return signups, err
+}
+
+func (s searchDB) InsertEvent(e workshop.Event) error {
+	if err := s.WorkshopDB.InsertEvent(e); err != nil {
+		return err
+	}
+	s.indexEvent(e.ID)
+	return nil
+}
+
+func (s searchDB) UpdateEvent(e workshop.Event) error {
+	if err := s.WorkshopDB.UpdateEvent(e); err != nil {
+		return err
+	}
+	s.indexEvent(e.ID)
+	return nil
+}
+
+func (s searchDB) DeleteEvent(eventID string) error {
+	if err := s.WorkshopDB.DeleteEvent(eventID); err != nil {
+		return err
+	}
+	s.index.Remove(search.KindEvent, eventID)
+	return nil
+}
+
+func (s searchDB) InsertSeries(series workshop.Series) ([]workshop.Workshop, error) {
+	workshops, err := s.WorkshopDB.InsertSeries(series)
+	if err == nil {
+		for _, ws := range workshops {
+			s.indexWorkshops(ws.WorkshopID)
+		}
+	}
+	return workshops, err
+}
+
+// SplitSeries reindexes the occurrences of the series from before the
+// split, some of which it moves or deletes, and those it creates.
+func (s searchDB) SplitSeries(seriesID, workshopID string, next workshop.Series) ([]workshop.Workshop, error) {
+	before, err := s.WorkshopDB.GetWorkshopsBySeriesID(seriesID)
+	if err != nil {
+		return nil, err
+	}
+	workshops, err := s.WorkshopDB.SplitSeries(seriesID, workshopID, next)
+	if err != nil {
+		return workshops, err
+	}
+	for _, ws := range append(before, workshops...) {
+		s.indexWorkshops(ws.WorkshopID)
+	}
+	return workshops, nil
+}
+
+func (s searchDB) UpdateCategory(c workshop.Category) (workshop.Category, error) {
+	c, err := s.WorkshopDB.UpdateCategory(c)
+	return c, s.reindexAfter(err)
+}
+
+func (s searchDB) DeleteCategory(categoryID int) error {
+	return s.reindexAfter(s.WorkshopDB.DeleteCategory(categoryID))
+}
+
+func (s searchDB) UpdateInstructor(in workshop.Instructor) (workshop.Instructor, error) {
+	in, err := s.WorkshopDB.UpdateInstructor(in)
+	return in, s.reindexAfter(err)
+}
+
+func (s searchDB) DeleteInstructor(instructorID int) error {
+	return s.reindexAfter(s.WorkshopDB.DeleteInstructor(instructorID))
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+const seriesColumns = "series_id, name, description, caption, cap, price_amount, currency, location, level, start_time, end_time, timezone, rrule, exdates, created_at, updated_at, room_id"
+
+// execer is the part of *sql.DB and *sql.Tx that inserts and updates use.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// InsertSeries stores the series and creates its occurrences, which are
+// returned.
+func (w workshopDB) InsertSeries(s workshop.Series) ([]workshop.Workshop, error) {
+	occurrences, err := s.Occurrences()
+	if err != nil {
+		return nil, err
+	}
+	err = transact(w.db, func(tx *sql.Tx) error {
+		cal, err := loadCalendar(tx)
+		if err != nil {
+			return err
+		}
+		if occurrences, err = cal.Schedule(occurrences); err != nil {
+			return err
+		}
+		if err := insertSeries(tx, s); err != nil {
+			return err
+		}
+		for _, ws := range occurrences {
+			if err := insertWorkshop(tx, ws); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return occurrences, err
+}
+
+func insertSeries(db execer, s workshop.Series) error {
+	t := s.Template
+	_, err := db.Exec(
+		"INSERT INTO workshop_series ("+seriesColumns+") VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,NOW(),NOW(),?)",
+		s.ID,
+		t.Name,
+		t.Description,
+		t.Caption,
+		t.Cap,
+		t.Price.Amount,
+		currency(t.Price.Currency),
+		t.Location,
+		t.Level,
+		t.StartTime.UTC(),
+		t.EndTime.UTC(),
+		timezone(t.Timezone),
+		s.RRule,
+		workshop.FormatExDates(s.ExDates),
+		nullInt(t.RoomID),
+	)
+	return err
+}
+
+func (w workshopDB) SeriesByID(seriesID string) (workshop.Series, error) {
+	return scanSeries(w.db.QueryRow("SELECT "+seriesColumns+" FROM workshop_series WHERE series_id = ?", seriesID))
+}
+
+func scanSeries(r rowScanner) (workshop.Series, error) {
+	var (
+		s       workshop.Series
+		exdates string
+		roomID  sql.NullInt64
+	)
+	t := &s.Template
+	err := r.Scan(&s.ID, &t.Name, &t.Description, &t.Caption, &t.Cap, &t.Price.Amount, &t.Price.Currency, &t.Location, &t.Level, &t.StartTime, &t.EndTime, &t.Timezone, &s.RRule, &exdates, &s.CreatedAt, &s.UpdatedAt, &roomID)
+	if err != nil {
+		return s, err
+	}
+	t.SeriesID = s.ID
+	t.RoomID = int(roomID.Int64)
+	s.ExDates, err = workshop.ParseExDates(exdates)
+	return s, err
+}
+
+// GetWorkshopsBySeriesID returns the occurrences of the series.
+func (w workshopDB) GetWorkshopsBySeriesID(seriesID string) ([]workshop.Workshop, error) {
+	return w.listWorkshops("WHERE w.series_id = ?", seriesID)
+}
+
+// SplitSeries applies a "this and following" edit: the series is ended
+// before the occurrence workshopID and next takes over from there. The
+// following occurrences nobody ever signed up for are replaced by those of
+// next. Those whose signups were all cancelled are called off instead, so
+// that their signups, payments and refunds stay on record. Booked
+// occurrences are kept with their times, capacity and signups; those next
+// also produces are moved over to it and take its description. The
+// occurrences of next are returned.
+func (w workshopDB) SplitSeries(seriesID, workshopID string, next workshop.Series) ([]workshop.Workshop, error) {
+	occurrences, err := next.Occurrences()
+	if err != nil {
+		return nil, err
+	}
+	err = transact(w.db, func(tx *sql.Tx) error {
+		s, err := scanSeries(tx.QueryRow("SELECT "+seriesColumns+" FROM workshop_series WHERE series_id = ? FOR UPDATE", seriesID))
+		if err != nil {
+			return err
+		}
+		at, err := scanWorkshop(tx.QueryRow("SELECT "+workshopColumns+" FROM workshops WHERE workshop_id = ?", workshopID))
+		if err != nil {
+			return err
+		}
+		if at.SeriesID != seriesID {
+			return workshop.ErrNotInSeries
+		}
+		s, _, err = s.Truncate(at.StartTime)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE workshop_series SET rrule = ?, exdates = ?, updated_at = NOW() WHERE series_id = ?", s.RRule, workshop.FormatExDates(s.ExDates), seriesID); err != nil {
+			return err
+		}
+		cal, err := loadCalendar(tx)
+		if err != nil {
+			return err
+		}
+		if occurrences, err = cal.Schedule(occurrences); err != nil {
+			return err
+		}
+		if err := insertSeries(tx, next); err != nil {
+			return err
+		}
+
+		following, err := queryWorkshops(tx, "WHERE w.series_id = ? AND w.start_time >= ? ORDER BY w.id FOR UPDATE", seriesID, at.StartTime.UTC())
+		if err != nil {
+			return err
+		}
+		signedUp, booked := make(map[string]bool), make(map[string]bool)
+		rows, err := tx.Query("SELECT s.workshop_id, MAX(s."+statusIn(workshop.BookedStatuses)+") FROM signups s JOIN workshops w ON w.workshop_id = s.workshop_id WHERE w.series_id = ? AND w.start_time >= ? GROUP BY s.workshop_id", seriesID, at.StartTime.UTC())
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id string
+			var b bool
+			if err := rows.Scan(&id, &b); err != nil {
+				rows.Close()
+				return err
+			}
+			signedUp[id], booked[id] = true, b
+		}
+		rows.Close()
+
+		relink, cancel, drop, add := planSplit(following, signedUp, booked, occurrences)
+		for _, id := range drop {
+			if _, err := tx.Exec("DELETE FROM workshops WHERE workshop_id = ?", id); err != nil {
+				return err
+			}
+		}
+		now := time.Now().UTC()
+		for _, id := range cancel {
+			if _, err := tx.Exec("UPDATE workshops SET cancelled_at = ?, updated_at = ? WHERE workshop_id = ?", now, now, id); err != nil {
+				return err
+			}
+		}
+		for _, ws := range relink {
+			if err := bookRoom(tx, ws.RoomID, ws.Cap, ws.RoomBookings()); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(
+				"UPDATE workshops SET name=?, description=?, caption=?, price_amount=?, currency=?, location=?, room_id=?, level=?, series_id=?, updated_at=NOW() WHERE workshop_id=?",
+				ws.Name,
+				ws.Description,
+				ws.Caption,
+				ws.Price.Amount,
+				currency(ws.Price.Currency),
+				ws.Location,
+				nullInt(ws.RoomID),
+				ws.Level,
+				ws.SeriesID,
+				ws.WorkshopID,
+			); err != nil {
+				return err
+			}
+		}
+		for _, ws := range add {
+			if err := insertWorkshop(tx, ws); err != nil {
+				return err
+			}
+		}
+		occurrences = append(relink, add...)
+		return nil
+	})
+	return occurrences, err
+}
+
+// planSplit works out what happens to the following occurrences of a series
+// that is split: booked ones that next produces too are relinked to it with
+// its description and room, other booked ones are left alone, those with
+// only cancelled signups are called off unless they already are, and the
+// rest are dropped. add are the occurrences of next that are still to be
+// created.
+func planSplit(following []workshop.Workshop, signedUp, booked map[string]bool, next []workshop.Workshop) (relink []workshop.Workshop, cancel, drop []string, add []workshop.Workshop) {
+	byStart := make(map[int64]int)
+	for i, ws := range next {
+		byStart[ws.StartTime.Unix()] = i
+	}
+	taken := make(map[int]bool)
+	for _, ws := range following {
+		if !signedUp[ws.WorkshopID] {
+			drop = append(drop, ws.WorkshopID)
+			continue
+		}
+		if !booked[ws.WorkshopID] {
+			if ws.CancelledAt.IsZero() {
+				cancel = append(cancel, ws.WorkshopID)
+			}
+			continue
+		}
+		i, ok := byStart[ws.StartTime.Unix()]
+		if !ok {
+			continue
+		}
+		n := next[i]
+		ws.Name = n.Name
+		ws.Description = n.Description
+		ws.Caption = n.Caption
+		ws.Price = n.Price
+		ws.Location = n.Location
+		ws.RoomID = n.RoomID
+		ws.Level = n.Level
+		ws.SeriesID = n.SeriesID
+		relink = append(relink, ws)
+		taken[i] = true
+	}
+	for i, ws := range next {
+		if !taken[i] {
+			add = append(add, ws)
+		}
+	}
+	return relink, cancel, drop, add
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+func TestSplitSeriesCallsOffOccurrencesWithCancelledSignups(t *testing.T) {
+	db := NewMemoryDB()
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	s := workshop.Series{
+		ID: "pottery",
+		Template: workshop.Workshop{
+			Name:        "Pottery",
+			Description: "Wheel throwing",
+			StartTime:   start,
+			EndTime:     start.Add(2 * time.Hour),
+			Timezone:    "Europe/Berlin",
+			Cap:         5,
+		},
+		RRule: "FREQ=WEEKLY;COUNT=4",
+	}
+	occurrences, err := db.InsertSeries(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 4 {
+		t.Fatalf("got %d occurrences, want 4", len(occurrences))
+	}
+	cancelled, err := db.SignUp(workshop.SignUp{WorkshopID: occurrences[1].WorkshopID, FirstName: "Ada", Email: "ada@example.com"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := db.UpdateSignUpStatus(cancelled.ID, workshop.StatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SignUp(workshop.SignUp{WorkshopID: occurrences[2].WorkshopID, FirstName: "Grace", Email: "grace@example.com"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	next := s
+	next.ID = "pottery-2"
+	next.Template.Description = "Wheel throwing and glazing"
+	next.Template.StartTime = occurrences[1].StartTime
+	next.Template.EndTime = occurrences[1].EndTime
+	next.RRule = "FREQ=WEEKLY;COUNT=3"
+	moved, err := db.SplitSeries(s.ID, occurrences[1].WorkshopID, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 3 {
+		t.Fatalf("next series has %d occurrences, want 3", len(moved))
+	}
+	// Only the occurrence with a confirmed signup is kept as it was.
+	if moved[0].WorkshopID != occurrences[2].WorkshopID {
+		t.Errorf("relinked %s, want %s", moved[0].WorkshopID, occurrences[2].WorkshopID)
+	}
+	for _, ws := range moved[1:] {
+		if ws.WorkshopID == occurrences[1].WorkshopID || ws.WorkshopID == occurrences[3].WorkshopID {
+			t.Errorf("occurrence %s was kept, want it replaced", ws.WorkshopID)
+		}
+	}
+	// The occurrence with only a cancelled signup is called off and keeps
+	// its signup; the one nobody signed up for is dropped.
+	called, err := db.WorkshopByID(occurrences[1].WorkshopID)
+	if err != nil {
+		t.Fatalf("occurrence with only a cancelled signup: got %v, want it kept", err)
+	}
+	if called.CancelledAt.IsZero() || called.SeriesID != s.ID {
+		t.Errorf("occurrence with only a cancelled signup: got cancelled at %v in series %q, want it called off in %q", called.CancelledAt, called.SeriesID, s.ID)
+	}
+	if _, err := db.SignUpByID(cancelled.ID); err != nil {
+		t.Errorf("cancelled signup of a called off occurrence: got %v, want it kept", err)
+	}
+	if _, err := db.WorkshopByID(occurrences[3].WorkshopID); err != sql.ErrNoRows {
+		t.Errorf("occurrence nobody signed up for: got %v, want it dropped", err)
+	}
+	for _, ws := range moved {
+		if ws.SeriesID != next.ID || ws.Description != next.Template.Description {
+			t.Errorf("occurrence %s is in series %q with description %q", ws.WorkshopID, ws.SeriesID, ws.Description)
+		}
+	}
+}
+
+func TestSeriesBooksItsRoomPerOccurrence(t *testing.T) {
+	db := NewMemoryDB()
+	venue, err := db.InsertVenue(workshop.Venue{Name: "Studio", Address: "Forster Strasse 51, Berlin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rooms []workshop.Room
+	for _, name := range []string{"Wheel room", "Kiln room"} {
+		room, err := db.InsertRoom(workshop.Room{VenueID: venue.ID, Name: name, Capacity: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rooms = append(rooms, room)
+	}
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	// The third occurrence of the series clashes with an event in its room.
+	if err := db.InsertEvent(workshop.Event{ID: "firing", Name: "Firing", StartTime: start.AddDate(0, 0, 14), EndTime: start.AddDate(0, 0, 14).Add(time.Hour), RoomID: rooms[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	s := workshop.Series{
+		ID: "pottery",
+		Template: workshop.Workshop{
+			Name:      "Pottery",
+			StartTime: start,
+			EndTime:   start.Add(2 * time.Hour),
+			Timezone:  "Europe/Berlin",
+			Cap:       5,
+			RoomID:    rooms[0].ID,
+		},
+		RRule: "FREQ=WEEKLY;COUNT=4",
+	}
+	_, err = db.InsertSeries(s)
+	if conflict, ok := err.(*workshop.RoomConflictError); !ok || len(conflict.Conflicts) != 1 || conflict.Conflicts[0].ID != "firing" {
+		t.Fatalf("got %v, want a conflict with the event", err)
+	}
+	if _, err := db.SeriesByID(s.ID); err != sql.ErrNoRows {
+		t.Errorf("series with a clashing occurrence: got %v, want it not stored", err)
+	}
+
+	s.RRule = "FREQ=WEEKLY;COUNT=2"
+	occurrences, err := db.InsertSeries(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := db.SeriesByID(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Template.RoomID != rooms[0].ID {
+		t.Errorf("series stored in room %d, want %d", stored.Template.RoomID, rooms[0].ID)
+	}
+	if _, err := db.SignUp(workshop.SignUp{WorkshopID: occurrences[1].WorkshopID, FirstName: "Grace", Email: "grace@example.com"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The booked occurrence moves into the new room with the rest.
+	next := s
+	next.ID = "pottery-2"
+	next.Template.RoomID = rooms[1].ID
+	next.Template.StartTime = occurrences[1].StartTime
+	next.Template.EndTime = occurrences[1].EndTime
+	moved, err := db.SplitSeries(s.ID, occurrences[1].WorkshopID, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ws := range moved {
+		stored, err := db.WorkshopByID(ws.WorkshopID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.RoomID != rooms[1].ID {
+			t.Errorf("occurrence %s is in room %d, want %d", ws.WorkshopID, stored.RoomID, rooms[1].ID)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+// ErrNotEnrolled is returned when attendance is recorded for a signup that
+// holds no seat on the session's workshop.
+var ErrNotEnrolled = errors.New("signup holds no seat on the session's workshop")
+
+const sessionColumns = "id, workshop_id, position, start_time, end_time, location"
+
+func scanSession(r rowScanner) (workshop.Session, error) {
+	var s workshop.Session
+	err := r.Scan(&s.ID, &s.WorkshopID, &s.Position, &s.StartTime, &s.EndTime, &s.Location)
+	return s, err
+}
+
+func insertSession(db execer, s workshop.Session) error {
+	_, err := db.Exec(
+		"INSERT INTO workshop_sessions (workshop_id, position, start_time, end_time, location) VALUES (?,?,?,?,?)",
+		s.WorkshopID,
+		s.Position,
+		s.StartTime.UTC(),
+		s.EndTime.UTC(),
+		s.Location,
+	)
+	return err
+}
+
+// sessionsByWorkshopID returns the sessions of the workshops, in order.
+func sessionsByWorkshopID(db queryer, workshopIDs []string) (map[string][]workshop.Session, error) {
+	sessions := make(map[string][]workshop.Session)
+	if len(workshopIDs) == 0 {
+		return sessions, nil
+	}
+	placeholders, args := inList(workshopIDs)
+	rows, err := db.Query("SELECT "+sessionColumns+" FROM workshop_sessions WHERE workshop_id IN ("+placeholders+") ORDER BY workshop_id, position", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions[s.WorkshopID] = append(sessions[s.WorkshopID], s)
+	}
+	return sessions, rows.Err()
+}
+
+// loadSessions fills in the sessions of workshops in one query.
+func loadSessions(db queryer, workshops []workshop.Workshop) error {
+	ids := make([]string, len(workshops))
+	for i, ws := range workshops {
+		ids[i] = ws.WorkshopID
+	}
+	sessions, err := sessionsByWorkshopID(db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range workshops {
+		workshops[i].Sessions = sessions[workshops[i].WorkshopID]
+	}
+	return nil
+}
+
+// planSessions matches the sessions ws is being saved with against the
+// existing ones. Sessions keep their ID, and with it their attendance, when
+// they name an existing session; the rest get ID 0 and are created. A
+// workshop saved without sessions keeps a single session spanning its times,
+// or, if it has several, the sessions it has.
+func planSessions(existing []workshop.Session, ws workshop.Workshop) []workshop.Session {
+	if len(ws.Sessions) == 0 && len(existing) > 1 {
+		return existing
+	}
+	if len(ws.Sessions) == 0 {
+		sessions := ws.SessionList()
+		if len(existing) == 1 {
+			sessions[0].ID = existing[0].ID
+			sessions[0].Location = existing[0].Location
+		}
+		return sessions
+	}
+	known := make(map[int]bool)
+	for _, s := range existing {
+		known[s.ID] = true
+	}
+	sessions := append([]workshop.Session(nil), ws.Sessions...)
+	for i := range sessions {
+		if !known[sessions[i].ID] {
+			sessions[i].ID = 0
+		}
+	}
+	return sessions
+}
+
+// syncSessions stores the sessions of ws, which have been through
+// planSessions, replacing those in existing.
+func syncSessions(tx *sql.Tx, existing []workshop.Session, ws workshop.Workshop) error {
+	keep := make(map[int]bool)
+	for _, s := range ws.Sessions {
+		keep[s.ID] = true
+	}
+	for _, s := range existing {
+		if keep[s.ID] {
+			continue
+		}
+		if _, err := tx.Exec("DELETE FROM workshop_sessions WHERE id = ?", s.ID); err != nil {
+			return err
+		}
+	}
+	for _, s := range ws.Sessions {
+		if s.ID == 0 {
+			if err := insertSession(tx, s); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := tx.Exec(
+			"UPDATE workshop_sessions SET position = ?, start_time = ?, end_time = ?, location = ? WHERE id = ?",
+			s.Position,
+			s.StartTime.UTC(),
+			s.EndTime.UTC(),
+			s.Location,
+			s.ID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordAttendance records whether a signup came to a session, replacing
+// anything recorded before. The signup must hold a seat on the session's
+// workshop.
+func (w workshopDB) RecordAttendance(a workshop.Attendance) error {
+	return transact(w.db, func(tx *sql.Tx) error {
+		var workshopID string
+		if err := tx.QueryRow("SELECT workshop_id FROM workshop_sessions WHERE id = ?", a.SessionID).Scan(&workshopID); err != nil {
+			return err
+		}
+		s, err := scanSignUp(tx.QueryRow("SELECT "+signupColumns+" FROM signups WHERE id = ?", a.SignUpID))
+		if err == sql.ErrNoRows || (err == nil && (s.WorkshopID != workshopID || !s.Status.HoldsSeat())) {
+			return ErrNotEnrolled
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO session_attendance (session_id, signup_id, attended, recorded_at) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE attended = VALUES(attended), recorded_at = VALUES(recorded_at)",
+			a.SessionID,
+			a.SignUpID,
+			a.Attended,
+			time.Now().UTC(),
+		)
+		return err
+	})
+}
+
+// GetAttendanceBySessionID returns what has been recorded for a session.
+func (w workshopDB) GetAttendanceBySessionID(sessionID int) ([]workshop.Attendance, error) {
+	var attendance []workshop.Attendance
+	if err := w.db.QueryRow("SELECT id FROM workshop_sessions WHERE id = ?", sessionID).Scan(&sessionID); err != nil {
+		return attendance, err
+	}
+	rows, err := w.db.Query("SELECT session_id, signup_id, attended, recorded_at FROM session_attendance WHERE session_id = ? ORDER BY signup_id", sessionID)
+	if err != nil {
+		return attendance, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a workshop.Attendance
+		if err := rows.Scan(&a.SessionID, &a.SignUpID, &a.Attended, &a.RecordedAt); err != nil {
+			return attendance, err
+		}
+		attendance = append(attendance, a)
+	}
+	return attendance, rows.Err()
+}
@@ -0,0 +1,419 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// seatCondition matches the signups that count against a workshop's cap.
+var seatCondition = statusIn(workshop.SeatStatuses)
+
+func statusIn(statuses []workshop.Status) string {
+	quoted := make([]string, len(statuses))
+	for i, s := range statuses {
+		quoted[i] = "'" + string(s) + "'"
+	}
+	return "status IN (" + strings.Join(quoted, ", ") + ")"
+}
+
+// seats is the capacity bookkeeping stored on a workshop row.
+type seats struct {
+	cap        int
+	taken      int
+	waitlisted int
+	cancelled  bool
+}
+
+func (s seats) free() int {
+	return s.cap - s.taken
+}
+
+// lockWorkshop takes the row lock that serialises every change to the seats
+// of a workshop and returns its counters. Always take it before locking
+// signups.
+func lockWorkshop(tx *sql.Tx, workshopID string) (seats, error) {
+	var (
+		s           seats
+		cancelledAt mysql.NullTime
+	)
+	err := tx.QueryRow("SELECT cap, signup_count, waitlist_count, cancelled_at FROM workshops WHERE workshop_id = ? FOR UPDATE", workshopID).Scan(&s.cap, &s.taken, &s.waitlisted, &cancelledAt)
+	s.cancelled = cancelledAt.Valid
+	return s, err
+}
+
+// adjustCounts keeps the signup_count and waitlist_count columns of a
+// workshop in step with a signup moving between statuses. from is empty for
+// a new signup. It must run in the transaction that changes the signup.
+func adjustCounts(tx *sql.Tx, workshopID string, from, to workshop.Status) error {
+	taken := delta(from.HoldsSeat(), to.HoldsSeat())
+	waitlisted := delta(from == workshop.StatusWaitlisted, to == workshop.StatusWaitlisted)
+	if taken == 0 && waitlisted == 0 {
+		return nil
+	}
+	_, err := tx.Exec("UPDATE workshops SET signup_count = signup_count + ?, waitlist_count = waitlist_count + ? WHERE workshop_id = ?", taken, waitlisted, workshopID)
+	return err
+}
+
+func delta(before, after bool) int {
+	switch {
+	case before && !after:
+		return -1
+	case !before && after:
+		return 1
+	}
+	return 0
+}
+
+// SignUp reserves a seat on the workshop. The workshop row is locked for the
+// duration of the transaction so that concurrent signups racing for the last
+// seat are serialised. When the workshop is full the signup either joins the
+// waitlist or, without waitlist, fails with ErrWorkshopFull. A signup
+// created as pending, e.g. until it is paid, stays pending on its seat;
+// otherwise it is confirmed.
+func (w workshopDB) SignUp(signup workshop.SignUp, waitlist bool) (workshop.SignUp, error) {
+	err := transact(w.db, func(tx *sql.Tx) error {
+		seats, err := lockWorkshop(tx, signup.WorkshopID)
+		if err != nil {
+			return err
+		}
+		if seats.cancelled {
+			return ErrWorkshopCancelled
+		}
+		status := workshop.StatusConfirmed
+		if signup.Status == workshop.StatusPending {
+			status = workshop.StatusPending
+		}
+		signup.Status = status
+		if seats.free() <= 0 {
+			if !waitlist {
+				return ErrWorkshopFull
+			}
+			signup.Status = workshop.StatusWaitlisted
+		}
+		// Only a seat can be held.
+		if signup.Status != workshop.StatusPending {
+			signup.HoldExpiresAt = time.Time{}
+		}
+		now := time.Now().UTC()
+		signup.Price.Currency = currency(signup.Price.Currency)
+		signup.AmountPaid = money.Money{Currency: signup.Price.Currency}
+		signup.BalanceRemindedAt, signup.BalanceOverdueAt = time.Time{}, time.Time{}
+		if err := applyDiscount(tx, &signup, now); err != nil {
+			return err
+		}
+		voucherClasses, err := applyVoucher(tx, &signup, now)
+		if err != nil {
+			return err
+		}
+		sqlCmd := "INSERT INTO signups (workshop_id, first_name, last_name, email, created_at, updated_at, message, status, price_amount, price_currency, price_tier, discount_code, discount_amount, hold_expires_at, voucher_code, voucher_amount) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err := tx.Exec(
+			sqlCmd,
+			signup.WorkshopID,
+			signup.FirstName,
+			signup.LastName,
+			signup.Email,
+			now,
+			now,
+			signup.Message,
+			signup.Status,
+			signup.Price.Amount,
+			signup.Price.Currency,
+			signup.PriceTier,
+			signup.DiscountCode,
+			signup.Discount.Amount,
+			nullTime(signup.HoldExpiresAt),
+			signup.VoucherCode,
+			signup.VoucherAmount.Amount,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		signup.ID = int(id)
+		signup.CreatedAt = now
+		signup.UpdatedAt = now
+		if err := recordTransition(tx, signup.ID, workshop.Transition{To: signup.Status, At: now}); err != nil {
+			return err
+		}
+		if err := adjustRedemption(tx, signup, "", signup.Status); err != nil {
+			return err
+		}
+		if err := recordVoucherRedemption(tx, signup, voucherClasses); err != nil {
+			return err
+		}
+		if err := adjustCounts(tx, signup.WorkshopID, "", signup.Status); err != nil {
+			return err
+		}
+		if signup.Status == workshop.StatusWaitlisted {
+			signup.Position = seats.waitlisted + 1
+		}
+		log.Printf("signup %s %s for workshop %s: %s", signup.FirstName, signup.LastName, signup.WorkshopID, signup.Status)
+		return nil
+	})
+	return signup, err
+}
+
+// SignUpByID returns the signup with its full status history.
+func (w workshopDB) SignUpByID(signupID int) (workshop.SignUp, error) {
+	s, err := scanSignUp(w.db.QueryRow("SELECT "+signupColumns+" FROM signups WHERE id = ?", signupID))
+	if err != nil {
+		return s, err
+	}
+	rows, err := w.db.Query("SELECT from_status, to_status, at FROM signup_transitions WHERE signup_id = ? ORDER BY id", signupID)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t workshop.Transition
+		if err := rows.Scan(&t.From, &t.To, &t.At); err != nil {
+			return s, err
+		}
+		s.History = append(s.History, t)
+	}
+	return s, rows.Err()
+}
+
+// promotedStatus is the status s is promoted to at now. Signups held pending
+// get their hold set.
+func promotedStatus(s *workshop.SignUp, hold time.Duration, now time.Time) workshop.Status {
+	if hold <= 0 || s.Due().IsZero() {
+		return workshop.StatusConfirmed
+	}
+	s.HoldExpiresAt = now.Add(hold)
+	return workshop.StatusPending
+}
+
+// UpdateSignUpStatus moves a signup to status, if the lifecycle allows it.
+// Moving onto a seat fails with ErrWorkshopFull when none is free, and a
+// signup giving up its seat promotes from the waitlist; the promoted signups
+// are returned. It also returns the signup as it was before, read under the
+// workshop lock, and whether it changed: setting the status a signup already
+// has is a no-op, so callers act on a transition only once.
+func (w workshopDB) UpdateSignUpStatus(signupID int, status workshop.Status) (workshop.SignUp, []workshop.SignUp, bool, error) {
+	var (
+		before   workshop.SignUp
+		promoted []workshop.SignUp
+		changed  bool
+	)
+	err := transact(w.db, func(tx *sql.Tx) error {
+		var workshopID string
+		if err := tx.QueryRow("SELECT workshop_id FROM signups WHERE id = ?", signupID).Scan(&workshopID); err != nil {
+			return err
+		}
+		seats, err := lockWorkshop(tx, workshopID)
+		if err != nil {
+			return err
+		}
+		s, err := scanSignUp(tx.QueryRow("SELECT "+signupColumns+" FROM signups WHERE id = ? FOR UPDATE", signupID))
+		if err != nil {
+			return err
+		}
+		before = s
+		if s.Status == status {
+			return nil
+		}
+		from := s.Status
+		if !from.HoldsSeat() && status.HoldsSeat() && seats.cancelled {
+			return ErrWorkshopCancelled
+		}
+		if !from.HoldsSeat() && status.HoldsSeat() && seats.free() <= 0 {
+			return ErrWorkshopFull
+		}
+		if err := setStatus(tx, &s, status); err != nil {
+			return err
+		}
+		changed = true
+		if from.HoldsSeat() && !status.HoldsSeat() {
+			promoted, err = w.promote(tx, workshopID)
+		}
+		return err
+	})
+	if err != nil {
+		return before, nil, false, err
+	}
+	return before, promoted, changed, nil
+}
+
+// setStatus applies a lifecycle transition to s and persists it, along with
+// the workshop's counters.
+func setStatus(tx *sql.Tx, s *workshop.SignUp, status workshop.Status) error {
+	from := s.Status
+	if err := s.Transition(status, time.Now().UTC()); err != nil {
+		return err
+	}
+	if err := adjustCounts(tx, s.WorkshopID, from, status); err != nil {
+		return err
+	}
+	if err := adjustRedemption(tx, *s, from, status); err != nil {
+		return err
+	}
+	// Signups that never got to use their seat get their voucher back in
+	// full; confirmed ones are credited by the refund policy instead.
+	if status == workshop.StatusCancelled && from != workshop.StatusConfirmed {
+		if _, err := creditVoucher(tx, *s, s.VoucherAmount, 1, "signup cancelled before it was confirmed"); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		"UPDATE signups SET status = ?, cancelled_at = ?, hold_expires_at = ?, updated_at = ? WHERE id = ?",
+		s.Status,
+		nullTime(s.CancelledAt),
+		nullTime(s.HoldExpiresAt),
+		s.UpdatedAt,
+		s.ID,
+	); err != nil {
+		return err
+	}
+	return recordTransition(tx, s.ID, s.History[len(s.History)-1])
+}
+
+func recordTransition(tx *sql.Tx, signupID int, t workshop.Transition) error {
+	_, err := tx.Exec("INSERT INTO signup_transitions (signup_id, from_status, to_status, at) VALUES (?, ?, ?, ?)", signupID, t.From, t.To, t.At)
+	return err
+}
+
+// promote moves people from the waitlist onto free seats, oldest first, and
+// returns them. Those who still owe money are held pending while they pay,
+// when there is a promotion hold, and confirmed otherwise. The caller must
+// hold the workshop lock.
+func (w workshopDB) promote(tx *sql.Tx, workshopID string) ([]workshop.SignUp, error) {
+	var seats seats
+	if err := tx.QueryRow("SELECT cap, signup_count FROM workshops WHERE workshop_id = ?", workshopID).Scan(&seats.cap, &seats.taken); err != nil {
+		return nil, err
+	}
+	free := seats.free()
+	if free <= 0 {
+		return nil, nil
+	}
+	rows, err := tx.Query("SELECT "+signupColumns+" FROM signups WHERE workshop_id = ? AND status = ? ORDER BY id LIMIT ?", workshopID, workshop.StatusWaitlisted, free)
+	if err != nil {
+		return nil, err
+	}
+	var promoted []workshop.SignUp
+	for rows.Next() {
+		s, err := scanSignUp(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		promoted = append(promoted, s)
+	}
+	rows.Close()
+	now := time.Now().UTC()
+	for i := range promoted {
+		if err := setStatus(tx, &promoted[i], promotedStatus(&promoted[i], w.promotionHold, now)); err != nil {
+			return nil, err
+		}
+	}
+	return promoted, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSignUp(r rowScanner) (workshop.SignUp, error) {
+	var (
+		s           workshop.SignUp
+		cancelledAt mysql.NullTime
+		holdExpires mysql.NullTime
+		reminded    mysql.NullTime
+		overdue     mysql.NullTime
+	)
+	err := r.Scan(&s.ID, &s.WorkshopID, &s.FirstName, &s.LastName, &s.Email, &s.CreatedAt, &s.UpdatedAt, &s.Message, &s.Status, &cancelledAt, &s.Price.Amount, &s.Price.Currency, &s.PriceTier, &s.DiscountCode, &s.Discount.Amount, &holdExpires, &s.VoucherCode, &s.VoucherAmount.Amount, &s.AmountPaid.Amount, &reminded, &overdue)
+	s.Discount.Currency = s.Price.Currency
+	s.VoucherAmount.Currency = s.Price.Currency
+	s.AmountPaid.Currency = s.Price.Currency
+	s.BalanceRemindedAt = reminded.Time
+	s.BalanceOverdueAt = overdue.Time
+	s.CancelledAt = cancelledAt.Time
+	s.HoldExpiresAt = holdExpires.Time
+	return s, err
+}
+
+// GetSignUpsByWorkshopID returns every signup on the workshop in the order
+// they were made, with queue positions filled in for the waitlist.
+func (w workshopDB) GetSignUpsByWorkshopID(workshopID string) ([]workshop.SignUp, error) {
+	sqlCmd := "SELECT " + signupColumns + " FROM signups WHERE workshop_id = ? ORDER BY id"
+	var signups []workshop.SignUp
+	rows, err := w.db.Query(sqlCmd, workshopID)
+	if err != nil {
+		return signups, err
+	}
+	defer rows.Close()
+	position := 0
+	for rows.Next() {
+		s, err := scanSignUp(rows)
+		if err != nil {
+			return signups, err
+		}
+		if s.Status == workshop.StatusWaitlisted {
+			position++
+			s.Position = position
+		}
+		signups = append(signups, s)
+
+	}
+	return signups, nil
+}
+
+// GetNumSignUpsByWorkshopID returns the number of seats taken on the
+// workshop.
+func (w workshopDB) GetNumSignUpsByWorkshopID(workshopID string) (int, error) {
+	var count int
+	err := w.db.QueryRow("SELECT signup_count FROM workshops WHERE workshop_id = ?", workshopID).Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return count, err
+}
+
+// ReconcileSignUpCounts recomputes every workshop's counters from the
+// signups table and reports the workshops whose stored counters had drifted.
+// With fix set the stored counters are corrected.
+func (w workshopDB) ReconcileSignUpCounts(fix bool) ([]CountDrift, error) {
+	var drifts []CountDrift
+	err := transact(w.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT w.workshop_id, w.signup_count, w.waitlist_count, COALESCE(c.seats, 0), COALESCE(c.waitlisted, 0)" +
+			" FROM workshops w LEFT JOIN (" + signupCountsQuery + ") c ON c.workshop_id = w.workshop_id ORDER BY w.id FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var d CountDrift
+			if err := rows.Scan(&d.WorkshopID, &d.StoredSeats, &d.StoredWaitlist, &d.Seats, &d.Waitlist); err != nil {
+				rows.Close()
+				return err
+			}
+			if d.StoredSeats != d.Seats || d.StoredWaitlist != d.Waitlist {
+				drifts = append(drifts, d)
+			}
+		}
+		rows.Close()
+		if !fix {
+			return nil
+		}
+		for _, d := range drifts {
+			if _, err := tx.Exec("UPDATE workshops SET signup_count = ?, waitlist_count = ? WHERE workshop_id = ?", d.Seats, d.Waitlist, d.WorkshopID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return drifts, err
+}
+
+// signupCountsQuery aggregates seat and waitlist counts per workshop from
+// the signups table.
+var signupCountsQuery = "SELECT workshop_id, SUM(" + seatCondition + ") AS seats, SUM(status = '" + string(workshop.StatusWaitlisted) + "') AS waitlisted" +
+	" FROM signups GROUP BY workshop_id"
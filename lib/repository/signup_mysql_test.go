@@ -0,0 +1,115 @@
+//go:build mysql
+// +build mysql
+
+package repository
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// testWorkshopDB connects to the migrated database in MYSQL_TEST_DNS, e.g.
+// root@/workshop_test?parseTime=true. Run with go test -tags mysql.
+func testWorkshopDB(t testing.TB) *workshopDB {
+	dns := os.Getenv("MYSQL_TEST_DNS")
+	if dns == "" {
+		t.Skip("MYSQL_TEST_DNS not set")
+	}
+	db, err := NewWorkshopDB(dns, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// TestSignUpRaceForLastSeatMySQL exercises the FOR UPDATE lock taken by
+// lockWorkshop.
+func TestSignUpRaceForLastSeatMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	raceForLastSeat(t, db, 20)
+}
+
+func TestWaitlistPromotesMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	waitlistPromotes(t, db)
+}
+
+func TestReconcileFixesDriftMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	reconcileFixesDrift(t, db, func(workshopID string, seats, waitlist int) {
+		if _, err := db.db.Exec("UPDATE workshops SET signup_count = ?, waitlist_count = ? WHERE workshop_id = ?", seats, waitlist, workshopID); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestDiscountRedeemedBySeatsMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	discountRedeemedBySeats(t, db)
+}
+
+func TestCreditNotesWithoutGapsMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	creditNotesWithoutGaps(t, db)
+}
+
+func TestVouchersRedeemedAndCreditedMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	vouchersRedeemedAndCredited(t, db)
+}
+
+func TestBalancesRemindedAndFlaggedMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	balancesRemindedAndFlagged(t, db)
+}
+
+func TestInstructorsAssignedMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	instructorsAssigned(t, db)
+}
+
+func TestCategoriesAndTagsFilteredMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	categoriesAndTagsFiltered(t, db)
+}
+
+func TestListingsPagedMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	listingsPaged(t, db)
+}
+
+func TestInvoicesNumberedPerYearMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	// Find two years without invoices, as the database outlives the test.
+	year := 2100
+	for {
+		var n int
+		if err := db.db.QueryRow("SELECT COUNT(*) FROM invoice_sequences WHERE year IN (?, ?)", year, year+1).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		year += 2
+	}
+	invoicesNumberedPerYear(t, db, year)
+}
+
+func TestPromoteOnPaidWorkshopMySQL(t *testing.T) {
+	db := testWorkshopDB(t)
+	defer db.db.Close()
+	db.SetPromotionHold(24 * time.Hour)
+	promoteOnPaidWorkshop(t, db, 24*time.Hour)
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// raceForLastSeat has n people sign up at once for a workshop with a single
+// seat and checks that exactly one of them gets it.
+func raceForLastSeat(t *testing.T, db WorkshopDB, n int) {
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	ws := workshop.Workshop{
+		WorkshopID:  fmt.Sprintf("race-%d", time.Now().UnixNano()),
+		Name:        "Last seat",
+		Description: "One seat, many takers",
+		StartTime:   start,
+		EndTime:     start.Add(2 * time.Hour),
+		Cap:         1,
+		Location:    "Forster Strasse 51, Berlin",
+		Level:       "Beginner",
+	}
+	if err := db.InsertWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := db.SignUp(workshop.SignUp{
+				WorkshopID: ws.WorkshopID,
+				FirstName:  fmt.Sprintf("Racer%d", i),
+				LastName:   "Doe",
+				Email:      fmt.Sprintf("racer%d@example.com", i),
+				Message:    "no message",
+			}, false)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	var won, full int
+	for err := range errs {
+		switch err {
+		case nil:
+			won++
+		case ErrWorkshopFull:
+			full++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if won != 1 || full != n-1 {
+		t.Errorf("got %d signups and %d ErrWorkshopFull, want 1 and %d", won, full, n-1)
+	}
+	taken, err := db.GetNumSignUpsByWorkshopID(ws.WorkshopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taken != 1 {
+		t.Errorf("workshop has %d seats taken, want 1", taken)
+	}
+}
+
+func TestSignUpRaceForLastSeatMemory(t *testing.T) {
+	raceForLastSeat(t, NewMemoryDB(), 50)
+}
+
+// waitlistPromotes fills the only seat of a workshop, queues two more people
+// and checks the counters, positions, promotion and history every store
+// must agree on.
+func waitlistPromotes(t *testing.T, db WorkshopDB) {
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	ws := workshop.Workshop{
+		WorkshopID: fmt.Sprintf("waitlist-%d", time.Now().UnixNano()),
+		Name:       "Waitlist",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Cap:        1,
+	}
+	if err := db.InsertWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	var signups []workshop.SignUp
+	for _, name := range []string{"ada", "grace", "hedy"} {
+		su, err := db.SignUp(workshop.SignUp{WorkshopID: ws.WorkshopID, FirstName: name, LastName: "Doe", Email: name + "@example.com"}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signups = append(signups, su)
+	}
+	if _, err := db.SignUp(workshop.SignUp{WorkshopID: ws.WorkshopID, FirstName: "ada", Email: "ada@example.com"}, true); err == nil {
+		t.Error("duplicate signup accepted")
+	}
+	if _, err := db.SignUp(workshop.SignUp{WorkshopID: ws.WorkshopID, FirstName: "kate", Email: "kate@example.com"}, false); err != ErrWorkshopFull {
+		t.Errorf("got %v signing up to a full workshop without the waitlist, want %v", err, ErrWorkshopFull)
+	}
+	if _, err := db.SignUp(workshop.SignUp{WorkshopID: "missing", FirstName: "kate", Email: "kate@example.com"}, true); err == nil {
+		t.Error("signup for a missing workshop accepted")
+	}
+	for i, want := range []struct {
+		status   workshop.Status
+		position int
+	}{{workshop.StatusConfirmed, 0}, {workshop.StatusWaitlisted, 1}, {workshop.StatusWaitlisted, 2}} {
+		if signups[i].Status != want.status || signups[i].Position != want.position {
+			t.Errorf("signup %d: got %s at %d, want %s at %d", i, signups[i].Status, signups[i].Position, want.status, want.position)
+		}
+	}
+	stored, err := db.WorkshopByID(ws.WorkshopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.IsFull {
+		t.Errorf("got %+v, want the workshop full", stored)
+	}
+
+	before, promoted, changed, err := db.UpdateSignUpStatus(signups[0].ID, workshop.StatusCancelled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || before.Status != workshop.StatusConfirmed {
+		t.Errorf("got %s before, changed %v, want confirmed and changed", before.Status, changed)
+	}
+	if len(promoted) != 1 || promoted[0].ID != signups[1].ID || promoted[0].Status != workshop.StatusConfirmed {
+		t.Fatalf("got promoted %+v, want signup %d confirmed", promoted, signups[1].ID)
+	}
+	if _, _, changed, err := db.UpdateSignUpStatus(signups[0].ID, workshop.StatusCancelled); err != nil || changed {
+		t.Errorf("cancelling again: got changed %v and %v, want no change", changed, err)
+	}
+	if _, _, _, err := db.UpdateSignUpStatus(signups[0].ID, workshop.StatusConfirmed); err == nil {
+		t.Error("cancelled signup confirmed again")
+	}
+	if _, _, _, err := db.UpdateSignUpStatus(signups[2].ID, workshop.StatusConfirmed); err != ErrWorkshopFull {
+		t.Errorf("got %v confirming a waitlisted signup onto a full workshop, want %v", err, ErrWorkshopFull)
+	}
+	taken, err := db.GetNumSignUpsByWorkshopID(ws.WorkshopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taken != 1 {
+		t.Errorf("workshop has %d seats taken, want 1", taken)
+	}
+	if drifts, err := db.ReconcileSignUpCounts(false); err != nil || len(drifts) != 0 {
+		t.Errorf("got drifts %+v and %v, want the counters in step", drifts, err)
+	}
+	su, err := db.SignUpByID(signups[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var path []workshop.Status
+	for _, tr := range su.History {
+		path = append(path, tr.To)
+	}
+	if fmt.Sprint(path) != fmt.Sprint([]workshop.Status{workshop.StatusWaitlisted, workshop.StatusConfirmed}) {
+		t.Errorf("got history %v, want waitlisted then confirmed", path)
+	}
+
+	if err := db.DeleteWorkshop(ws.WorkshopID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SignUpByID(signups[2].ID); err != sql.ErrNoRows {
+		t.Errorf("signup of a deleted workshop: got %v, want it deleted with it", err)
+	}
+}
+
+func TestWaitlistPromotesMemory(t *testing.T) {
+	waitlistPromotes(t, NewMemoryDB())
+}
+
+// reconcileFixesDrift books one seat and one waitlist place, lets drift
+// overwrite the stored counters of the workshop and checks that
+// ReconcileSignUpCounts reports the drift, leaves it alone without fix and
+// corrects it with fix.
+func reconcileFixesDrift(t *testing.T, db WorkshopDB, drift func(workshopID string, seats, waitlist int)) {
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	ws := workshop.Workshop{
+		WorkshopID: fmt.Sprintf("drift-%d", time.Now().UnixNano()),
+		Name:       "Drift",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Cap:        1,
+	}
+	if err := db.InsertWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ada", "grace"} {
+		if _, err := db.SignUp(workshop.SignUp{WorkshopID: ws.WorkshopID, FirstName: name, LastName: "Doe", Email: name + "@example.com"}, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	driftOf := func(fix bool) []CountDrift {
+		drifts, err := db.ReconcileSignUpCounts(fix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ours []CountDrift
+		for _, d := range drifts {
+			if d.WorkshopID == ws.WorkshopID {
+				ours = append(ours, d)
+			}
+		}
+		return ours
+	}
+	if d := driftOf(false); len(d) != 0 {
+		t.Fatalf("got drift %+v before tampering with the counters", d)
+	}
+
+	drift(ws.WorkshopID, 3, 0)
+	want := []CountDrift{{WorkshopID: ws.WorkshopID, StoredSeats: 3, Seats: 1, StoredWaitlist: 0, Waitlist: 1}}
+	for _, fix := range []bool{false, false, true} {
+		if d := driftOf(fix); fmt.Sprint(d) != fmt.Sprint(want) {
+			t.Errorf("reconcile with fix %v: got %+v, want %+v", fix, d, want)
+		}
+	}
+	if d := driftOf(false); len(d) != 0 {
+		t.Errorf("got drift %+v after fixing it", d)
+	}
+	taken, err := db.GetNumSignUpsByWorkshopID(ws.WorkshopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taken != 1 {
+		t.Errorf("workshop has %d seats taken after the fix, want 1", taken)
+	}
+	if err := db.DeleteWorkshop(ws.WorkshopID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcileFixesDriftMemory(t *testing.T) {
+	db := NewMemoryDB()
+	reconcileFixesDrift(t, db, func(workshopID string, seats, waitlist int) {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		db.counts[workshopID] = seatCounts{seats: seats, waitlist: waitlist}
+	})
+}
+
+// promoteOnPaidWorkshop frees the only seat of a paid workshop and checks
+// that the waitlisted signup who still owes money is held pending, while
+// one with nothing to pay is confirmed.
+func promoteOnPaidWorkshop(t *testing.T, db WorkshopDB, hold time.Duration) {
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	ws := workshop.Workshop{
+		WorkshopID: fmt.Sprintf("promote-%d", time.Now().UnixNano()),
+		Name:       "Promote",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Cap:        1,
+		Price:      money.New(4500, "EUR"),
+	}
+	if err := db.InsertWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	signUp := func(name string, price money.Money) workshop.SignUp {
+		su, err := db.SignUp(workshop.SignUp{
+			WorkshopID: ws.WorkshopID,
+			FirstName:  name,
+			LastName:   "Doe",
+			Email:      name + "@example.com",
+			Price:      price,
+		}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return su
+	}
+	seated := signUp("seated", ws.Price)
+	paying := signUp("paying", ws.Price)
+	free := signUp("free", money.New(0, "EUR"))
+
+	before := time.Now().UTC()
+	_, promoted, _, err := db.UpdateSignUpStatus(seated.ID, workshop.StatusCancelled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promoted) != 1 || promoted[0].ID != paying.ID {
+		t.Fatalf("got promoted %+v, want signup %d", promoted, paying.ID)
+	}
+	held, err := db.SignUpByID(paying.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held.Status != workshop.StatusPending || held.HoldExpiresAt.Before(before.Add(hold)) || held.HoldExpiresAt.After(time.Now().Add(hold)) {
+		t.Errorf("got %s held until %v, want pending for %v", held.Status, held.HoldExpiresAt, hold)
+	}
+
+	// Releasing the hold moves the seat on to the next in line.
+	released, promoted, err := db.ReleaseExpiredHolds(held.HoldExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 || len(promoted) != 1 || promoted[0].ID != free.ID || promoted[0].Status != workshop.StatusConfirmed {
+		t.Errorf("got released %+v and promoted %+v, want signup %d confirmed", released, promoted, free.ID)
+	}
+}
+
+func TestPromoteOnPaidWorkshopMemory(t *testing.T) {
+	db := NewMemoryDB()
+	db.SetPromotionHold(24 * time.Hour)
+	promoteOnPaidWorkshop(t, db, 24*time.Hour)
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/workshop"
+)
+
+var (
+	// ErrUnknownRoom is returned when a workshop or event is booked into a
+	// room that does not exist.
+	ErrUnknownRoom = errors.New("unknown room")
+	// ErrRoomInUse is returned when a room, or the venue it belongs to, is
+	// deleted while workshops or events are booked into it.
+	ErrRoomInUse = errors.New("room is booked by workshops or events")
+)
+
+const (
+	venueColumns = "id, name, address, accessibility_notes, created_at, updated_at"
+	roomColumns  = "id, venue_id, name, capacity, accessibility_notes"
+)
+
+func scanVenue(r rowScanner) (workshop.Venue, error) {
+	var v workshop.Venue
+	err := r.Scan(&v.ID, &v.Name, &v.Address, &v.AccessibilityNotes, &v.CreatedAt, &v.UpdatedAt)
+	return v, err
+}
+
+func scanRoom(r rowScanner) (workshop.Room, error) {
+	var room workshop.Room
+	err := r.Scan(&room.ID, &room.VenueID, &room.Name, &room.Capacity, &room.AccessibilityNotes)
+	return room, err
+}
+
+// inUse maps the foreign key violation of deleting a booked room.
+func inUse(err error) error {
+	if e, ok := err.(*mysql.MySQLError); ok && e.Number == 1451 {
+		return ErrRoomInUse
+	}
+	return err
+}
+
+func (w workshopDB) InsertVenue(v workshop.Venue) (workshop.Venue, error) {
+	v.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	v.UpdatedAt = v.CreatedAt
+	v.Rooms = nil
+	res, err := w.db.Exec(
+		"INSERT INTO venues (name, address, accessibility_notes, created_at, updated_at) VALUES (?,?,?,?,?)",
+		v.Name, v.Address, v.AccessibilityNotes, v.CreatedAt, v.UpdatedAt,
+	)
+	if err != nil {
+		return v, err
+	}
+	id, err := res.LastInsertId()
+	v.ID = int(id)
+	return v, err
+}
+
+// VenueByID returns a venue with its rooms.
+func (w workshopDB) VenueByID(venueID int) (workshop.Venue, error) {
+	v, err := scanVenue(w.db.QueryRow("SELECT "+venueColumns+" FROM venues WHERE id = ?", venueID))
+	if err != nil {
+		return v, err
+	}
+	venues := []workshop.Venue{v}
+	err = loadRooms(w.db, venues)
+	return venues[0], err
+}
+
+// GetVenues lists the venues by name, with their rooms.
+func (w workshopDB) GetVenues() ([]workshop.Venue, error) {
+	var venues []workshop.Venue
+	rows, err := w.db.Query("SELECT " + venueColumns + " FROM venues ORDER BY name, id")
+	if err != nil {
+		return venues, err
+	}
+	for rows.Next() {
+		v, err := scanVenue(rows)
+		if err != nil {
+			rows.Close()
+			return venues, err
+		}
+		venues = append(venues, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return venues, err
+	}
+	return venues, loadRooms(w.db, venues)
+}
+
+// loadRooms fills in the rooms of venues in one query.
+func loadRooms(db queryer, venues []workshop.Venue) error {
+	if len(venues) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(venues))
+	for i, v := range venues {
+		args[i] = v.ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(venues)), ",")
+	rooms := make(map[int][]workshop.Room)
+	rows, err := db.Query("SELECT "+roomColumns+" FROM rooms WHERE venue_id IN ("+placeholders+") ORDER BY venue_id, name, id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return err
+		}
+		rooms[room.VenueID] = append(rooms[room.VenueID], room)
+	}
+	for i := range venues {
+		venues[i].Rooms = rooms[venues[i].ID]
+	}
+	return rows.Err()
+}
+
+// UpdateVenue saves the name, address and notes of v; its rooms are
+// changed on their own.
+func (w workshopDB) UpdateVenue(v workshop.Venue) (workshop.Venue, error) {
+	res, err := w.db.Exec(
+		"UPDATE venues SET name=?, address=?, accessibility_notes=?, updated_at=? WHERE id=?",
+		v.Name, v.Address, v.AccessibilityNotes, time.Now().UTC(), v.ID,
+	)
+	if err != nil {
+		return v, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return v, err
+	} else if n == 0 {
+		return v, sql.ErrNoRows
+	}
+	return w.VenueByID(v.ID)
+}
+
+// DeleteVenue removes a venue and its rooms, unless any of them is booked.
+func (w workshopDB) DeleteVenue(venueID int) error {
+	res, err := w.db.Exec("DELETE FROM venues WHERE id = ?", venueID)
+	if err != nil {
+		return inUse(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// InsertRoom adds a room to its venue, which must exist.
+func (w workshopDB) InsertRoom(room workshop.Room) (workshop.Room, error) {
+	res, err := w.db.Exec(
+		"INSERT INTO rooms (venue_id, name, capacity, accessibility_notes) VALUES (?,?,?,?)",
+		room.VenueID, room.Name, room.Capacity, room.AccessibilityNotes,
+	)
+	if e, ok := err.(*mysql.MySQLError); ok && e.Number == 1452 {
+		return room, sql.ErrNoRows
+	}
+	if err != nil {
+		return room, err
+	}
+	id, err := res.LastInsertId()
+	room.ID = int(id)
+	return room, err
+}
+
+func (w workshopDB) RoomByID(roomID int) (workshop.Room, error) {
+	return scanRoom(w.db.QueryRow("SELECT "+roomColumns+" FROM rooms WHERE id = ?", roomID))
+}
+
+// UpdateRoom saves the name, capacity and notes of room. A capacity below
+// the cap of a workshop booked into the room is refused.
+func (w workshopDB) UpdateRoom(room workshop.Room) (workshop.Room, error) {
+	err := transact(w.db, func(tx *sql.Tx) error {
+		if err := tx.QueryRow("SELECT venue_id FROM rooms WHERE id = ? FOR UPDATE", room.ID).Scan(&room.VenueID); err != nil {
+			return err
+		}
+		var over int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM workshops WHERE room_id = ? AND cancelled_at IS NULL AND cap > ?", room.ID, room.Capacity).Scan(&over); err != nil {
+			return err
+		}
+		if over > 0 {
+			return workshop.ErrOverRoomCapacity
+		}
+		_, err := tx.Exec("UPDATE rooms SET name=?, capacity=?, accessibility_notes=? WHERE id=?", room.Name, room.Capacity, room.AccessibilityNotes, room.ID)
+		return err
+	})
+	return room, err
+}
+
+// DeleteRoom removes a room nothing is booked into.
+func (w workshopDB) DeleteRoom(roomID int) error {
+	res, err := w.db.Exec("DELETE FROM rooms WHERE id = ?", roomID)
+	if err != nil {
+		return inUse(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// bookRoom checks that a workshop with cap seats, or an event for a cap of
+// 0, can hold roomID during wanted. The room is locked until tx ends so
+// that two bookings cannot both take the same slot.
+func bookRoom(tx *sql.Tx, roomID, cap int, wanted []workshop.Booking) error {
+	if roomID == 0 {
+		return nil
+	}
+	var capacity int
+	err := tx.QueryRow("SELECT capacity FROM rooms WHERE id = ? FOR UPDATE", roomID).Scan(&capacity)
+	if err == sql.ErrNoRows {
+		return ErrUnknownRoom
+	}
+	if err != nil {
+		return err
+	}
+	if cap > capacity {
+		return workshop.ErrOverRoomCapacity
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+	from, to := workshop.BookingSpan(wanted)
+	existing, err := roomBookings(tx, roomID, from, to)
+	if err != nil {
+		return err
+	}
+	if conflicts := workshop.Overlapping(existing, wanted); len(conflicts) > 0 {
+		return &workshop.RoomConflictError{RoomID: roomID, Conflicts: conflicts}
+	}
+	return nil
+}
+
+// roomBookings lists the workshop sessions and events holding roomID
+// between from and to. Cancelled workshops and sessions held elsewhere do
+// not hold it.
+func roomBookings(db queryer, roomID int, from, to time.Time) ([]workshop.Booking, error) {
+	var bookings []workshop.Booking
+	rows, err := db.Query(
+		"SELECT 'workshop', w.workshop_id, w.name, s.start_time, s.end_time FROM workshop_sessions s JOIN workshops w ON w.workshop_id = s.workshop_id WHERE w.room_id = ? AND w.cancelled_at IS NULL AND s.location = '' AND s.start_time < ? AND s.end_time > ?"+
+			" UNION ALL SELECT 'event', event_id, name, start_time, end_time FROM events WHERE room_id = ? AND start_time < ? AND end_time > ?",
+		roomID, to.UTC(), from.UTC(), roomID, to.UTC(), from.UTC(),
+	)
+	if err != nil {
+		return bookings, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b workshop.Booking
+		if err := rows.Scan(&b.Kind, &b.ID, &b.Name, &b.StartTime, &b.EndTime); err != nil {
+			return bookings, err
+		}
+		bookings = append(bookings, b)
+	}
+	return bookings, rows.Err()
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// ErrDuplicateVoucher is returned when a voucher code is issued twice.
+var ErrDuplicateVoucher = errors.New("voucher already exists")
+
+const (
+	voucherColumns   = "code, kind, balance_amount, currency, classes, expires_at, voided_at, note, created_at"
+	voucherTxColumns = "id, code, kind, amount, currency, classes, signup_id, workshop_id, note, at"
+)
+
+func scanVoucher(r rowScanner) (workshop.Voucher, error) {
+	var (
+		v         workshop.Voucher
+		expiresAt mysql.NullTime
+		voidedAt  mysql.NullTime
+	)
+	err := r.Scan(&v.Code, &v.Kind, &v.Balance.Amount, &v.Balance.Currency, &v.Classes, &expiresAt, &voidedAt, &v.Note, &v.CreatedAt)
+	v.ExpiresAt = expiresAt.Time
+	v.VoidedAt = voidedAt.Time
+	return v, err
+}
+
+func scanVoucherTx(r rowScanner) (workshop.VoucherTransaction, error) {
+	var (
+		t        workshop.VoucherTransaction
+		signupID sql.NullInt64
+	)
+	err := r.Scan(&t.ID, &t.Code, &t.Kind, &t.Amount.Amount, &t.Amount.Currency, &t.Classes, &signupID, &t.WorkshopID, &t.Note, &t.At)
+	t.SignUpID = int(signupID.Int64)
+	return t, err
+}
+
+func insertVoucherTx(tx *sql.Tx, t *workshop.VoucherTransaction) error {
+	var signupID interface{}
+	if t.SignUpID != 0 {
+		signupID = t.SignUpID
+	}
+	res, err := tx.Exec(
+		"INSERT INTO voucher_transactions (code, kind, amount, currency, classes, signup_id, workshop_id, note, at) VALUES (?,?,?,?,?,?,?,?,?)",
+		t.Code, t.Kind, t.Amount.Amount, t.Amount.Currency, t.Classes, signupID, t.WorkshopID, t.Note, t.At,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	t.ID = int(id)
+	return err
+}
+
+// moveVoucher applies t to the balance of its voucher and records it.
+func moveVoucher(tx *sql.Tx, t *workshop.VoucherTransaction) error {
+	if _, err := tx.Exec("UPDATE vouchers SET balance_amount = balance_amount + ?, classes = classes + ? WHERE code = ?", t.Amount.Amount, t.Classes, t.Code); err != nil {
+		return err
+	}
+	return insertVoucherTx(tx, t)
+}
+
+// InsertVoucher issues a voucher with its opening balance.
+func (w workshopDB) InsertVoucher(v workshop.Voucher) (workshop.Voucher, error) {
+	v.Code = workshop.NormalizeCode(v.Code)
+	v.Balance.Currency = currency(v.Balance.Currency)
+	v.CreatedAt = time.Now().UTC()
+	v.VoidedAt = time.Time{}
+	err := transact(w.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			"INSERT INTO vouchers ("+voucherColumns+") VALUES (?,?,0,?,0,?,NULL,?,?)",
+			v.Code, v.Kind, v.Balance.Currency, nullTime(v.ExpiresAt), v.Note, v.CreatedAt,
+		)
+		if e, ok := err.(*mysql.MySQLError); ok && e.Number == 1062 {
+			return ErrDuplicateVoucher
+		}
+		if err != nil {
+			return err
+		}
+		return moveVoucher(tx, &workshop.VoucherTransaction{Code: v.Code, Kind: workshop.VoucherIssued, Amount: v.Balance, Classes: v.Classes, Note: v.Note, At: v.CreatedAt})
+	})
+	return v, err
+}
+
+// VoucherByCode returns a voucher with its transactions, oldest first.
+func (w workshopDB) VoucherByCode(code string) (workshop.Voucher, []workshop.VoucherTransaction, error) {
+	var history []workshop.VoucherTransaction
+	v, err := scanVoucher(w.db.QueryRow("SELECT "+voucherColumns+" FROM vouchers WHERE code = ?", workshop.NormalizeCode(code)))
+	if err != nil {
+		return v, history, err
+	}
+	rows, err := w.db.Query("SELECT "+voucherTxColumns+" FROM voucher_transactions WHERE code = ? ORDER BY id", v.Code)
+	if err != nil {
+		return v, history, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scanVoucherTx(rows)
+		if err != nil {
+			return v, history, err
+		}
+		history = append(history, t)
+	}
+	return v, history, rows.Err()
+}
+
+// VoidVoucher takes whatever balance is left off a voucher and stops it
+// from being redeemed again.
+func (w workshopDB) VoidVoucher(code, note string) (workshop.Voucher, error) {
+	var v workshop.Voucher
+	err := transact(w.db, func(tx *sql.Tx) error {
+		var err error
+		v, err = scanVoucher(tx.QueryRow("SELECT "+voucherColumns+" FROM vouchers WHERE code = ? FOR UPDATE", workshop.NormalizeCode(code)))
+		if err != nil {
+			return err
+		}
+		if !v.VoidedAt.IsZero() {
+			return workshop.ErrVoucherVoided
+		}
+		v.VoidedAt = time.Now().UTC()
+		if _, err := tx.Exec("UPDATE vouchers SET voided_at = ? WHERE code = ?", v.VoidedAt, v.Code); err != nil {
+			return err
+		}
+		err = moveVoucher(tx, &workshop.VoucherTransaction{Code: v.Code, Kind: workshop.VoucherVoided, Amount: v.Balance.Neg(), Classes: -v.Classes, Note: note, At: v.VoidedAt})
+		v.Balance.Amount, v.Classes = 0, 0
+		return err
+	})
+	return v, err
+}
+
+// CreditVoucher gives back up to amount, or for class passes classes, of
+// what the voucher paid for a cancelled signup. It never gives back more than
+// was redeemed on the signup; the zero transaction means nothing was.
+func (w workshopDB) CreditVoucher(signupID int, amount money.Money, classes int, note string) (workshop.VoucherTransaction, error) {
+	var t workshop.VoucherTransaction
+	err := transact(w.db, func(tx *sql.Tx) error {
+		s, err := scanSignUp(tx.QueryRow("SELECT "+signupColumns+" FROM signups WHERE id = ?", signupID))
+		if err != nil {
+			return err
+		}
+		t, err = creditVoucher(tx, s, amount, classes, note)
+		return err
+	})
+	return t, err
+}
+
+// applyVoucher pays what it can of the signup's price with its voucher and
+// takes that off the voucher, which stays locked until the transaction ends.
+// It returns the classes used, for class passes.
+func applyVoucher(tx *sql.Tx, signup *workshop.SignUp, now time.Time) (int, error) {
+	signup.VoucherCode = workshop.NormalizeCode(signup.VoucherCode)
+	signup.VoucherAmount = money.Money{Currency: signup.Price.Currency}
+	// There is nothing to pay for with a voucher.
+	if signup.Price.IsZero() {
+		signup.VoucherCode = ""
+	}
+	if signup.VoucherCode == "" {
+		return 0, nil
+	}
+	v, err := scanVoucher(tx.QueryRow("SELECT "+voucherColumns+" FROM vouchers WHERE code = ? FOR UPDATE", signup.VoucherCode))
+	if err == sql.ErrNoRows {
+		return 0, workshop.ErrVoucherUnknown
+	}
+	if err != nil {
+		return 0, err
+	}
+	amount, classes, err := v.Redeem(signup.Price, now)
+	if err != nil {
+		return 0, err
+	}
+	if v.Kind == workshop.VoucherGift {
+		_, err = tx.Exec("UPDATE vouchers SET balance_amount = balance_amount - ? WHERE code = ?", amount.Amount, v.Code)
+	} else {
+		_, err = tx.Exec("UPDATE vouchers SET classes = classes - ? WHERE code = ?", classes, v.Code)
+	}
+	signup.VoucherAmount = amount
+	return classes, err
+}
+
+// recordVoucherRedemption adds the redemption of a new signup to its
+// voucher's history.
+func recordVoucherRedemption(tx *sql.Tx, signup workshop.SignUp, classes int) error {
+	if signup.VoucherCode == "" {
+		return nil
+	}
+	t := workshop.VoucherTransaction{
+		Code:       signup.VoucherCode,
+		Kind:       workshop.VoucherRedeemed,
+		Amount:     signup.VoucherAmount.Neg(),
+		Classes:    -classes,
+		SignUpID:   signup.ID,
+		WorkshopID: signup.WorkshopID,
+		At:         signup.CreatedAt,
+	}
+	if classes > 0 {
+		// Passes pay in classes, not money.
+		t.Amount.Amount = 0
+	}
+	return insertVoucherTx(tx, &t)
+}
+
+// creditVoucher is CreditVoucher within a transaction, for a signup that
+// is already loaded.
+func creditVoucher(tx *sql.Tx, s workshop.SignUp, amount money.Money, classes int, note string) (workshop.VoucherTransaction, error) {
+	if s.VoucherCode == "" {
+		return workshop.VoucherTransaction{}, nil
+	}
+	v, err := scanVoucher(tx.QueryRow("SELECT "+voucherColumns+" FROM vouchers WHERE code = ? FOR UPDATE", s.VoucherCode))
+	if err != nil {
+		return workshop.VoucherTransaction{}, err
+	}
+	var owedAmount int64
+	var owedClasses int
+	if err := tx.QueryRow(
+		"SELECT COALESCE(-SUM(amount), 0), COALESCE(-SUM(classes), 0) FROM voucher_transactions WHERE code = ? AND signup_id = ?",
+		v.Code, s.ID,
+	).Scan(&owedAmount, &owedClasses); err != nil {
+		return workshop.VoucherTransaction{}, err
+	}
+	t := creditFor(v, s, amount, classes, owedAmount, owedClasses, note)
+	if t.Amount.IsZero() && t.Classes == 0 {
+		return workshop.VoucherTransaction{}, nil
+	}
+	return t, moveVoucher(tx, &t)
+}
+
+// creditFor is the credit of up to amount or classes on v for s, given what
+// is still owed to v for s. Voided vouchers are not credited.
+func creditFor(v workshop.Voucher, s workshop.SignUp, amount money.Money, classes int, owedAmount int64, owedClasses int, note string) workshop.VoucherTransaction {
+	if !v.VoidedAt.IsZero() {
+		return workshop.VoucherTransaction{}
+	}
+	t := workshop.VoucherTransaction{
+		Code:       v.Code,
+		Kind:       workshop.VoucherCredited,
+		Amount:     money.Money{Currency: v.Balance.Currency},
+		SignUpID:   s.ID,
+		WorkshopID: s.WorkshopID,
+		Note:       note,
+		At:         time.Now().UTC(),
+	}
+	if v.Kind == workshop.VoucherPass {
+		t.Classes = classes
+		if t.Classes > owedClasses {
+			t.Classes = owedClasses
+		}
+	} else if amount.Currency == v.Balance.Currency {
+		t.Amount.Amount = amount.Amount
+		if t.Amount.Amount > owedAmount {
+			t.Amount.Amount = owedAmount
+		}
+	}
+	if t.Amount.Amount < 0 || t.Classes < 0 {
+		t.Amount.Amount, t.Classes = 0, 0
+	}
+	return t
+}
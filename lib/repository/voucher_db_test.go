@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/workshop"
+)
+
+// vouchersRedeemedAndCredited pays for two signups with one gift voucher,
+// cancels them and checks that the voucher gets back what was redeemed and
+// no more, and that voided vouchers are neither redeemed nor credited.
+func vouchersRedeemedAndCredited(t *testing.T, db WorkshopDB) {
+	suffix := time.Now().UnixNano()
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	ws := workshop.Workshop{
+		WorkshopID: fmt.Sprintf("vouchers-%d", suffix),
+		Name:       "Vouchers",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Cap:        5,
+		Price:      money.New(4500, "EUR"),
+	}
+	if err := db.InsertWorkshop(ws); err != nil {
+		t.Fatal(err)
+	}
+	code := fmt.Sprintf("GIFT%d", suffix)
+	if _, err := db.InsertVoucher(workshop.Voucher{Code: code, Kind: workshop.VoucherGift, Balance: money.New(6000, "EUR")}); err != nil {
+		t.Fatal(err)
+	}
+	balance := func() int64 {
+		v, _, err := db.VoucherByCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.Balance.Amount
+	}
+	signUp := func(name string, status workshop.Status) (workshop.SignUp, error) {
+		return db.SignUp(workshop.SignUp{WorkshopID: ws.WorkshopID, FirstName: name, Email: name + "@example.com", Price: ws.Price, VoucherCode: code, Status: status}, false)
+	}
+
+	confirmed, err := signUp("ada", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := signUp("grace", workshop.StatusPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.VoucherAmount.Amount != 4500 || pending.VoucherAmount.Amount != 1500 || balance() != 0 {
+		t.Fatalf("voucher paid %v and %v, %d left; want 45.00, 15.00 and nothing", confirmed.VoucherAmount, pending.VoucherAmount, balance())
+	}
+	if _, err := signUp("hedy", ""); err != workshop.ErrVoucherUsedUp {
+		t.Errorf("signing up with a used up voucher: got %v, want %v", err, workshop.ErrVoucherUsedUp)
+	}
+
+	// A signup cancelled before it was confirmed gives back all it took.
+	if _, _, _, err := db.UpdateSignUpStatus(pending.ID, workshop.StatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != 1500 {
+		t.Errorf("after cancelling the pending signup the voucher holds %d, want 1500", got)
+	}
+	if tx, err := db.CreditVoucher(pending.ID, money.New(1500, "EUR"), 0, "twice"); err != nil || tx.ID != 0 {
+		t.Errorf("crediting the pending signup again: got %+v, %v; want nothing", tx, err)
+	}
+	// Confirmed ones are credited by the refund, up to what they took.
+	if _, _, _, err := db.UpdateSignUpStatus(confirmed.ID, workshop.StatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != 1500 {
+		t.Errorf("cancelling the confirmed signup credited the voucher %d, want nothing", got-1500)
+	}
+	if tx, err := db.CreditVoucher(confirmed.ID, money.New(9000, "EUR"), 0, "refund"); err != nil || tx.Amount.Amount != 4500 || tx.Kind != workshop.VoucherCredited {
+		t.Errorf("crediting the confirmed signup: got %+v, %v; want 45.00 credited", tx, err)
+	}
+	if got := balance(); got != 6000 {
+		t.Errorf("voucher holds %d after both signups were cancelled, want 6000", got)
+	}
+
+	if _, err := db.VoidVoucher(code, "refunded"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signUp("hedy", ""); err != workshop.ErrVoucherVoided {
+		t.Errorf("signing up with a voided voucher: got %v, want %v", err, workshop.ErrVoucherVoided)
+	}
+	v, history, err := db.VoucherByCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum int64
+	for _, tx := range history {
+		sum += tx.Amount.Amount
+	}
+	if v.Balance.Amount != 0 || sum != 0 || len(history) != 6 {
+		t.Errorf("voided voucher holds %v, its %d transactions add up to %d; want nothing left", v.Balance, len(history), sum)
+	}
+	if err := db.DeleteWorkshop(ws.WorkshopID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVouchersRedeemedAndCreditedMemory(t *testing.T) {
+	vouchersRedeemedAndCredited(t, NewMemoryDB())
+}
@@ -3,36 +3,125 @@ package repository
 import (
 	"database/sql"
 	"errors"
-	"log"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/workshop/lib/migrations"
+	"github.com/workshop/lib/money"
 	"github.com/workshop/lib/workshop"
 )
 
+// ErrWorkshopFull is returned by SignUp when every seat on the workshop is
+// taken.
+var ErrWorkshopFull = errors.New("workshop is full")
+
+// ErrWorkshopCancelled is returned for changes to a workshop that was called
+// off.
+var ErrWorkshopCancelled = errors.New("workshop has been cancelled")
+
+// CountDrift reports a workshop whose stored signup counters disagree with
+// its signups.
+type CountDrift struct {
+	WorkshopID     string
+	StoredSeats    int
+	Seats          int
+	StoredWaitlist int
+	Waitlist       int
+}
+
 type WorkshopDB interface {
 	WorkshopByID(workshopID string) (workshop.Workshop, error)
 	InsertWorkshop(workshop.Workshop) error
 	GetWorkshopsAfterDate(date time.Time) ([]workshop.Workshop, error)
 	GetWorkshops() ([]workshop.Workshop, error)
 	GetEvents() ([]workshop.Event, error)
-	UpdateWorkshop(workshop workshop.Workshop) error
+	ListWorkshops(filter workshop.Filter, page workshop.Page) ([]workshop.Workshop, string, error)
+	ListEvents(filter workshop.Filter, page workshop.Page) ([]workshop.Event, string, error)
+	UpdateWorkshop(workshop workshop.Workshop) ([]workshop.SignUp, error)
 	DeleteWorkshop(workshopID string) error
 	GetEventsAfterDate(date time.Time) ([]workshop.Event, error)
 	InsertEvent(event workshop.Event) error
 	DeleteEvent(eventID string) error
 	UpdateEvent(event workshop.Event) error
 	EventByID(eventID string) (workshop.Event, error)
-	SignUp(signup workshop.SignUp) error
+	SignUp(signup workshop.SignUp, waitlist bool) (workshop.SignUp, error)
+	SignUpByID(signupID int) (workshop.SignUp, error)
+	UpdateSignUpStatus(signupID int, status workshop.Status) (workshop.SignUp, []workshop.SignUp, bool, error)
 	GetSignUpsByWorkshopID(workshopID string) ([]workshop.SignUp, error)
 	GetNumSignUpsByWorkshopID(workshopID string) (int, error)
 	GetAllSignUps() ([]workshop.SignUpTable, error)
+	ReconcileSignUpCounts(fix bool) ([]CountDrift, error)
+	InsertSeries(series workshop.Series) ([]workshop.Workshop, error)
+	SeriesByID(seriesID string) (workshop.Series, error)
+	GetWorkshopsBySeriesID(seriesID string) ([]workshop.Workshop, error)
+	SplitSeries(seriesID, workshopID string, next workshop.Series) ([]workshop.Workshop, error)
+	InsertDiscountCode(code workshop.DiscountCode) error
+	GetDiscountCodes() ([]workshop.DiscountCode, error)
+	GetRedemptions(code string) ([]workshop.Redemption, error)
+	InsertPayment(p workshop.Payment) (workshop.Payment, error)
+	GetPaymentsBySignUpID(signupID int) ([]workshop.Payment, error)
+	GetPaymentsByStatus(status workshop.PaymentStatus) ([]workshop.Payment, error)
+	PaymentBySessionID(provider, sessionID string) (workshop.Payment, error)
+	SettlePayment(provider, sessionID string, status workshop.PaymentStatus) (workshop.Payment, []workshop.SignUp, error)
+	HoldSeatUntil(signupID int, until time.Time) error
+	ReleaseExpiredHolds(now time.Time) ([]workshop.SignUp, []workshop.SignUp, error)
+	CancelWorkshop(workshopID string) ([]workshop.SignUp, error)
+	InsertRefund(r workshop.Refund) (workshop.Refund, error)
+	RefundByID(refundID int) (workshop.Refund, error)
+	GetRefunds(workshopID string) ([]workshop.Refund, error)
+	GetRefundsBySignUpID(signupID int) ([]workshop.Refund, error)
+	InsertInvoice(inv workshop.Invoice) (workshop.Invoice, error)
+	InvoiceByNumber(number string) (workshop.Invoice, error)
+	InvoiceBySignUpID(signupID int) (workshop.Invoice, error)
+	GetInvoices(workshopID string) ([]workshop.Invoice, error)
+	InsertVoucher(v workshop.Voucher) (workshop.Voucher, error)
+	VoucherByCode(code string) (workshop.Voucher, []workshop.VoucherTransaction, error)
+	VoidVoucher(code, note string) (workshop.Voucher, error)
+	CreditVoucher(signupID int, amount money.Money, classes int, note string) (workshop.VoucherTransaction, error)
+	RemindBalances(until, now time.Time) ([]workshop.SignUp, error)
+	FlagOverdueBalances(now time.Time, grace time.Duration) ([]workshop.SignUp, []workshop.SignUp, error)
+	InsertInstructor(instructor workshop.Instructor) (workshop.Instructor, error)
+	InstructorByID(instructorID int) (workshop.Instructor, error)
+	GetInstructors() ([]workshop.Instructor, error)
+	UpdateInstructor(instructor workshop.Instructor) (workshop.Instructor, error)
+	DeleteInstructor(instructorID int) error
+	GetWorkshopsByInstructorID(instructorID int) ([]workshop.Workshop, error)
+	InsertVenue(venue workshop.Venue) (workshop.Venue, error)
+	VenueByID(venueID int) (workshop.Venue, error)
+	GetVenues() ([]workshop.Venue, error)
+	UpdateVenue(venue workshop.Venue) (workshop.Venue, error)
+	DeleteVenue(venueID int) error
+	InsertRoom(room workshop.Room) (workshop.Room, error)
+	RoomByID(roomID int) (workshop.Room, error)
+	UpdateRoom(room workshop.Room) (workshop.Room, error)
+	DeleteRoom(roomID int) error
+	GetCalendar() (workshop.Calendar, error)
+	SetOpeningHours(hours []workshop.OpeningHours) error
+	InsertClosure(closure workshop.Closure) (workshop.Closure, error)
+	DeleteClosure(closureID int) error
+	GetClosures(from time.Time) ([]workshop.Closure, error)
+	InsertCategory(category workshop.Category) (workshop.Category, error)
+	CategoryByID(categoryID int) (workshop.Category, error)
+	GetCategories(now time.Time) ([]workshop.Category, error)
+	UpdateCategory(category workshop.Category) (workshop.Category, error)
+	DeleteCategory(categoryID int) error
+	RecordAttendance(attendance workshop.Attendance) error
+	GetAttendanceBySessionID(sessionID int) ([]workshop.Attendance, error)
 
 	GetDB() interface{}
 }
 
 type workshopDB struct {
 	db *sql.DB
+	// promotionHold is how long signups promoted off the waitlist hold their
+	// seat to pay what they owe; zero confirms them straight away.
+	promotionHold time.Duration
+}
+
+// SetPromotionHold makes signups that owe money pending when they are
+// promoted off the waitlist, holding their seat for hold while they pay.
+func (w *workshopDB) SetPromotionHold(hold time.Duration) {
+	w.promotionHold = hold
 }
 
 func (w workshopDB) GetDB() interface{} {
@@ -55,7 +144,19 @@ func transact(db *sql.DB, txFunc func(*sql.Tx) error) (err error) {
 	return err
 }
 
-func NewWorkshopDB(dns string) (*workshopDB, error) {
+// Column lists shared by the queries below, so that scans do not depend on
+// the physical column order of the tables.
+const (
+	workshopColumns = "workshop_id, name, description, start_time, end_time, timezone, created_at, updated_at, cap, price_amount, currency, location, level, caption, cancel_deadline, refund_full_days, refund_partial_days, refund_partial_percent, deposit_amount, balance_due_at, release_unpaid, cancelled_at, signup_count, waitlist_count, series_id, room_id"
+	eventColumns    = "event_id, name, description, start_time, end_time, timezone, created_at, updated_at, price_amount, currency, location, caption, room_id"
+	signupColumns   = "id, workshop_id, first_name, last_name, email, created_at, updated_at, message, status, cancelled_at, price_amount, price_currency, price_tier, discount_code, discount_amount, hold_expires_at, voucher_code, voucher_amount, amount_paid, balance_reminded_at, balance_overdue_at"
+)
+
+// NewWorkshopDB connects to mysql and checks that the database matches the
+// schema in the migrations package. With migrate set, pending migrations are
+// applied first; otherwise a database that is behind or has drifted is
+// refused.
+func NewWorkshopDB(dns string, migrate bool) (*workshopDB, error) {
 	if dns == "" {
 		return nil, errors.New("db dns not found")
 	}
@@ -65,36 +166,47 @@ func NewWorkshopDB(dns string) (*workshopDB, error) {
 		return nil, err
 	}
 
-	rows, err := db.Query("DESCRIBE workshops")
-	if err != nil {
+	if migrate {
+		if err := migrations.Up(db); err != nil {
+			return nil, err
+		}
+	}
+	if err := migrations.Verify(db); err != nil {
 		return nil, err
 	}
 
-	rows.Close()
-
 	return &workshopDB{db: db}, nil
 }
 
 func (w workshopDB) WorkshopByID(workshopID string) (workshop.Workshop, error) {
-	var ws workshop.Workshop
-
-	err := w.db.QueryRow(`SELECT workshop_id, name, description, time, created_at, updated_at, cap, cost, location, level, caption FROM workshops WHERE id = ?`, workshopID).Scan(&ws.WorkshopID, &ws.Name, &ws.Description, &ws.Time, &ws.CreatedAt, &ws.UpdatedAt, &ws.Cap, &ws.Cost, &ws.Location, &ws.Level, &ws.Caption)
-
+	ws, err := scanWorkshop(w.db.QueryRow("SELECT "+workshopColumns+" FROM workshops WHERE workshop_id = ?", workshopID))
+	if err != nil {
+		return ws, err
+	}
+	sessions, err := sessionsByWorkshopID(w.db, []string{workshopID})
 	if err != nil {
 		return ws, err
 	}
-	return ws, nil
+	ws.Sessions = sessions[workshopID]
+	workshops := []workshop.Workshop{ws}
+	if err := loadPriceTiers(w.db, workshops); err != nil {
+		return workshops[0], err
+	}
+	if err := loadInstructors(w.db, workshops); err != nil {
+		return workshops[0], err
+	}
+	err = loadWorkshopTaxonomy(w.db, workshops)
+	return workshops[0], err
 }
 
 func (w workshopDB) EventByID(eventID string) (workshop.Event, error) {
-	var e workshop.Event
-
-	err := w.db.QueryRow(`SELECT event_id, name, description, time, updated_at, cost, location, caption FROM events WHERE id = ?`, eventID).Scan(&e.ID, &e.Name, &e.Description, &e.Time, &e.CreatedAt, &e.UpdatedAt, &e.Cost, &e.Location, &e.Caption)
-
+	e, err := scanEvent(w.db.QueryRow("SELECT "+eventColumns+" FROM events WHERE event_id = ?", eventID))
 	if err != nil {
 		return e, err
 	}
-	return e, nil
+	events := []workshop.Event{e}
+	err = loadEventTaxonomy(w.db, events)
+	return events[0], err
 }
 
 func (w workshopDB) DeleteEvent(eventID string) error {
@@ -121,287 +233,405 @@ func (w workshopDB) DeleteWorkshop(workshopID string) error {
 	return nil
 }
 func (w workshopDB) InsertWorkshop(ws workshop.Workshop) error {
+	return transact(w.db, func(tx *sql.Tx) error {
+		cal, err := loadCalendar(tx)
+		if err != nil {
+			return err
+		}
+		if err := cal.CheckWorkshop(ws, nil); err != nil {
+			return err
+		}
+		return insertWorkshop(tx, ws)
+	})
+}
+
+// insertWorkshop stores ws and its sessions, once its room is free.
+func insertWorkshop(db *sql.Tx, ws workshop.Workshop) error {
+	ws.SetSessions(ws.SessionList())
+	if err := bookRoom(db, ws.RoomID, ws.Cap, ws.RoomBookings()); err != nil {
+		return err
+	}
 
-	sqlCmd := "INSERT INTO workshops (workshop_id, name, description, time, created_at, updated_at, cap, cost, location, level, caption) VALUES (?,?,?,?,NOW(),NOW(),?,?,?,?,?)"
+	sqlCmd := "INSERT INTO workshops (workshop_id, name, description, start_time, end_time, timezone, created_at, updated_at, cap, price_amount, currency, location, room_id, level, caption, cancel_deadline, refund_full_days, refund_partial_days, refund_partial_percent, deposit_amount, balance_due_at, release_unpaid, series_id) VALUES (?,?,?,?,?,?,NOW(),NOW(),?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
 
-	if _, err := w.db.Exec(
+	if _, err := db.Exec(
 		sqlCmd,
 		ws.WorkshopID,
 		ws.Name,
 		ws.Description,
-		ws.Time,
+		ws.StartTime.UTC(),
+		ws.EndTime.UTC(),
+		timezone(ws.Timezone),
 		ws.Cap,
-		ws.Cost,
+		ws.Price.Amount,
+		currency(ws.Price.Currency),
 		ws.Location,
+		nullInt(ws.RoomID),
 		ws.Level,
 		ws.Caption,
+		nullTime(ws.CancelDeadline),
+		ws.RefundPolicy.FullDays,
+		ws.RefundPolicy.PartialDays,
+		ws.RefundPolicy.PartialPercent,
+		ws.Deposit.Amount,
+		nullTime(ws.BalanceDueAt),
+		ws.ReleaseUnpaid,
+		nullString(ws.SeriesID),
 	); err != nil {
 		return err
 	}
-	return nil
+	for _, s := range ws.Sessions {
+		if err := insertSession(db, s); err != nil {
+			return err
+		}
+	}
+	if err := insertPriceTiers(db, ws); err != nil {
+		return err
+	}
+	if err := insertInstructorAssignments(db, ws); err != nil {
+		return err
+	}
+	return insertTaxonomy(db, workshopOffering, ws.WorkshopID, ws.CategoryIDs, ws.Tags)
 }
 
 func (w workshopDB) InsertEvent(e workshop.Event) error {
+	return transact(w.db, func(tx *sql.Tx) error {
+		cal, err := loadCalendar(tx)
+		if err != nil {
+			return err
+		}
+		if err := cal.CheckEvent(e, workshop.Event{}); err != nil {
+			return err
+		}
+		if err := bookRoom(tx, e.RoomID, 0, e.RoomBookings()); err != nil {
+			return err
+		}
 
-	sqlCmd := "INSERT INTO events (event_id, name, description, time, created_at, updated_at, cost, location, caption) VALUES (?,?,?,?,NOW(),NOW(),?,?,?)"
-
-	if _, err := w.db.Exec(
-		sqlCmd,
-		e.ID,
-		e.Name,
-		e.Description,
-		e.Time,
-		e.Cost,
-		e.Location,
-		e.Caption,
-	); err != nil {
-		return err
-	}
-	return nil
+		sqlCmd := "INSERT INTO events (event_id, name, description, start_time, end_time, timezone, created_at, updated_at, price_amount, currency, location, room_id, caption) VALUES (?,?,?,?,?,?,NOW(),NOW(),?,?,?,?,?)"
+
+		_, err = tx.Exec(
+			sqlCmd,
+			e.ID,
+			e.Name,
+			e.Description,
+			e.StartTime.UTC(),
+			e.EndTime.UTC(),
+			timezone(e.Timezone),
+			e.Price.Amount,
+			currency(e.Price.Currency),
+			e.Location,
+			nullInt(e.RoomID),
+			e.Caption,
+		)
+		if err != nil {
+			return err
+		}
+		return insertTaxonomy(tx, eventOffering, e.ID, e.CategoryIDs, e.Tags)
+	})
 }
 
-func (w workshopDB) UpdateWorkshop(ws workshop.Workshop) error {
-	sqlCmd := "UPDATE workshops SET name=?, description=?, time=?, cap=?, level=?, cost=?, location=?, caption=? WHERE workshop_id=?"
-
-	if _, err := w.db.Exec(
-		sqlCmd,
-		ws.Name,
-		ws.Description,
-		ws.Time,
-		ws.Cap,
-		ws.Level,
-		ws.Cost,
-		ws.Location,
-		ws.WorkshopID,
-		ws.Caption,
-	); err != nil {
+// UpdateWorkshop saves ws and its sessions and, if its cap was raised,
+// promotes people from the waitlist onto the new seats. The promoted signups
+// are returned so the caller can let them know. It returns sql.ErrNoRows
+// when there is no such workshop.
+func (w workshopDB) UpdateWorkshop(ws workshop.Workshop) ([]workshop.SignUp, error) {
+	var promoted []workshop.SignUp
+	err := transact(w.db, func(tx *sql.Tx) error {
+		if _, err := lockWorkshop(tx, ws.WorkshopID); err != nil {
+			return err
+		}
+		sessions, err := sessionsByWorkshopID(tx, []string{ws.WorkshopID})
+		if err != nil {
+			return err
+		}
+		existing := sessions[ws.WorkshopID]
+		ws.SetSessions(planSessions(existing, ws))
+		cal, err := loadCalendar(tx)
+		if err != nil {
+			return err
+		}
+		if err := cal.CheckWorkshop(ws, existing); err != nil {
+			return err
+		}
+		if err := bookRoom(tx, ws.RoomID, ws.Cap, ws.RoomBookings()); err != nil {
+			return err
+		}
+		sqlCmd := "UPDATE workshops SET name=?, description=?, start_time=?, end_time=?, timezone=?, cap=?, level=?, price_amount=?, currency=?, location=?, room_id=?, caption=?, cancel_deadline=?, refund_full_days=?, refund_partial_days=?, refund_partial_percent=?, deposit_amount=?, balance_due_at=?, release_unpaid=?, updated_at=NOW() WHERE workshop_id=?"
+		if _, err := tx.Exec(
+			sqlCmd,
+			ws.Name,
+			ws.Description,
+			ws.StartTime.UTC(),
+			ws.EndTime.UTC(),
+			timezone(ws.Timezone),
+			ws.Cap,
+			ws.Level,
+			ws.Price.Amount,
+			currency(ws.Price.Currency),
+			ws.Location,
+			nullInt(ws.RoomID),
+			ws.Caption,
+			nullTime(ws.CancelDeadline),
+			ws.RefundPolicy.FullDays,
+			ws.RefundPolicy.PartialDays,
+			ws.RefundPolicy.PartialPercent,
+			ws.Deposit.Amount,
+			nullTime(ws.BalanceDueAt),
+			ws.ReleaseUnpaid,
+			ws.WorkshopID,
+		); err != nil {
+			return err
+		}
+		if err := syncSessions(tx, existing, ws); err != nil {
+			return err
+		}
+		if err := replacePriceTiers(tx, ws); err != nil {
+			return err
+		}
+		if err := replaceInstructorAssignments(tx, ws); err != nil {
+			return err
+		}
+		if err := replaceTaxonomy(tx, workshopOffering, ws.WorkshopID, ws.CategoryIDs, ws.Tags); err != nil {
+			return err
+		}
+		promoted, err = w.promote(tx, ws.WorkshopID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
 		return err
-	}
-	return nil
-
+	})
+	return promoted, err
 }
 
 func (w workshopDB) UpdateEvent(e workshop.Event) error {
-	sqlCmd := "UPDATE events SET name=?, description=?, time=?, cost=?, location=?, caption=? WHERE event_id=?"
-	if _, err := w.db.Exec(
-		sqlCmd,
-		e.Name,
-		e.Description,
-		e.Time,
-		e.Cost,
-		e.Location,
-		e.Caption,
-		e.ID,
-	); err != nil {
-		return err
-	}
-	return nil
-
-}
-func (w workshopDB) GetWorkshopsAfterDate(date time.Time) ([]workshop.Workshop, error) {
-	sqlCmd := "SELECT * FROM workshops where start_time > ?"
-	var workshops []workshop.Workshop
-	rows, err := w.db.Query(
-		sqlCmd,
-		date,
-	)
-	if err != nil {
-		return workshops, err
-	}
-	var id int
-	for rows.Next() {
-		var ws workshop.Workshop
-		err := rows.Scan(&id, &ws.WorkshopID, &ws.Name, &ws.Description, &ws.Time, &ws.CreatedAt, &ws.UpdatedAt, &ws.Cap, &ws.Cost, &ws.Location, &ws.Level, &ws.Caption)
+	return transact(w.db, func(tx *sql.Tx) error {
+		before, err := scanEvent(tx.QueryRow("SELECT "+eventColumns+" FROM events WHERE event_id = ?", e.ID))
+		if err == sql.ErrNoRows {
+			return nil
+		}
 		if err != nil {
-			return workshops, err
+			return err
 		}
-		workshops = append(workshops, ws)
-
-	}
-	for index, _ := range workshops {
-		c, err := w.GetNumSignUpsByWorkshopID(workshops[index].WorkshopID)
+		cal, err := loadCalendar(tx)
 		if err != nil {
-			return workshops, err
+			return err
 		}
-		if workshops[index].Cap == c {
-			workshops[index].IsFull = true
+		if err := cal.CheckEvent(e, before); err != nil {
+			return err
 		}
-	}
-	return workshops, nil
+		if err := bookRoom(tx, e.RoomID, 0, e.RoomBookings()); err != nil {
+			return err
+		}
+		sqlCmd := "UPDATE events SET name=?, description=?, start_time=?, end_time=?, timezone=?, price_amount=?, currency=?, location=?, room_id=?, caption=?, updated_at=NOW() WHERE event_id=?"
+		_, err = tx.Exec(
+			sqlCmd,
+			e.Name,
+			e.Description,
+			e.StartTime.UTC(),
+			e.EndTime.UTC(),
+			timezone(e.Timezone),
+			e.Price.Amount,
+			currency(e.Price.Currency),
+			e.Location,
+			nullInt(e.RoomID),
+			e.Caption,
+			e.ID,
+		)
+		if err != nil {
+			return err
+		}
+		return replaceTaxonomy(tx, eventOffering, e.ID, e.CategoryIDs, e.Tags)
+	})
+}
+func (w workshopDB) GetWorkshopsAfterDate(date time.Time) ([]workshop.Workshop, error) {
+	return w.listWorkshops("WHERE w.start_time > ?", date.UTC())
 }
 
 func (w workshopDB) GetWorkshops() ([]workshop.Workshop, error) {
-	sqlCmd := "SELECT * FROM workshops"
+	return w.listWorkshops("")
+}
+
+func (w workshopDB) listWorkshops(where string, args ...interface{}) ([]workshop.Workshop, error) {
+	return queryWorkshops(w.db, where+" ORDER BY w.id", args...)
+}
+
+// queryer is the part of *sql.DB and *sql.Tx that reads use.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryWorkshops lists the workshops matching where, with their sessions,
+// price tiers, instructors, categories and tags, in six queries whatever
+// their number.
+func queryWorkshops(db queryer, where string, args ...interface{}) ([]workshop.Workshop, error) {
 	var workshops []workshop.Workshop
-	rows, err := w.db.Query(
-		sqlCmd,
-	)
+	rows, err := db.Query("SELECT "+workshopColumns+" FROM workshops w "+where, args...)
 	if err != nil {
 		return workshops, err
 	}
-	var id int
 	for rows.Next() {
-		var ws workshop.Workshop
-		err := rows.Scan(&id, &ws.WorkshopID, &ws.Name, &ws.Description, &ws.CreatedAt, &ws.UpdatedAt, &ws.Cap, &ws.Location, &ws.Level, &ws.Time, &ws.Cost, &ws.Caption)
+		ws, err := scanWorkshop(rows)
 		if err != nil {
+			rows.Close()
 			return workshops, err
 		}
 		workshops = append(workshops, ws)
-
 	}
-	for index, _ := range workshops {
-		c, err := w.GetNumSignUpsByWorkshopID(workshops[index].WorkshopID)
-		if err != nil {
-			return workshops, err
-		}
-		if workshops[index].Cap == c {
-			workshops[index].IsFull = true
-		}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return workshops, err
 	}
-	return workshops, nil
+	if err := loadSessions(db, workshops); err != nil {
+		return workshops, err
+	}
+	if err := loadPriceTiers(db, workshops); err != nil {
+		return workshops, err
+	}
+	if err := loadInstructors(db, workshops); err != nil {
+		return workshops, err
+	}
+	return workshops, loadWorkshopTaxonomy(db, workshops)
 }
+
 func (w workshopDB) GetEvents() ([]workshop.Event, error) {
-	sqlCmd := "SELECT * FROM events"
+	return queryEvents(w.db, "")
+}
+
+func (w workshopDB) GetEventsAfterDate(date time.Time) ([]workshop.Event, error) {
+	return queryEvents(w.db, "WHERE e.start_time > ?", date.UTC())
+}
+
+// queryEvents lists the events matching where with their categories and
+// tags.
+func queryEvents(db queryer, where string, args ...interface{}) ([]workshop.Event, error) {
 	var events []workshop.Event
-	rows, err := w.db.Query(
-		sqlCmd,
-	)
+	rows, err := db.Query("SELECT "+eventColumns+" FROM events e "+where, args...)
 	if err != nil {
 		return events, err
 	}
-	var id int
 	for rows.Next() {
-		var e workshop.Event
-		err := rows.Scan(&id, &e.ID, &e.Name, &e.Description, &e.CreatedAt, &e.UpdatedAt, &e.Location, &e.Time, &e.Cost, &e.Caption)
+		e, err := scanEvent(rows)
 		if err != nil {
+			rows.Close()
 			return events, err
 		}
 		events = append(events, e)
-
 	}
-	return events, nil
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return events, err
+	}
+	return events, loadEventTaxonomy(db, events)
 }
 
-func (w workshopDB) GetEventsAfterDate(date time.Time) ([]workshop.Event, error) {
-	sqlCmd := "SELECT * FROM events where start_time > ?"
-	var events []workshop.Event
-	rows, err := w.db.Query(
-		sqlCmd,
-		date,
+func scanWorkshop(r rowScanner) (workshop.Workshop, error) {
+	var (
+		ws                workshop.Workshop
+		cancelDeadline    mysql.NullTime
+		cancelledAt       mysql.NullTime
+		balanceDueAt      mysql.NullTime
+		taken, waitlisted int
+		seriesID          sql.NullString
+		roomID            sql.NullInt64
 	)
-	if err != nil {
-		return events, err
-	}
-	var id int
-	for rows.Next() {
-		var e workshop.Event
-		err := rows.Scan(&id, &e.ID, &e.Name, &e.Description, &e.Time, &e.CreatedAt, &e.UpdatedAt, &e.Cost, &e.Location, &e.Caption)
-		if err != nil {
-			return events, err
-		}
-		events = append(events, e)
+	err := r.Scan(&ws.WorkshopID, &ws.Name, &ws.Description, &ws.StartTime, &ws.EndTime, &ws.Timezone, &ws.CreatedAt, &ws.UpdatedAt, &ws.Cap, &ws.Price.Amount, &ws.Price.Currency, &ws.Location, &ws.Level, &ws.Caption, &cancelDeadline, &ws.RefundPolicy.FullDays, &ws.RefundPolicy.PartialDays, &ws.RefundPolicy.PartialPercent, &ws.Deposit.Amount, &balanceDueAt, &ws.ReleaseUnpaid, &cancelledAt, &taken, &waitlisted, &seriesID, &roomID)
+	ws.CancelDeadline = cancelDeadline.Time
+	ws.CancelledAt = cancelledAt.Time
+	ws.Deposit.Currency = ws.Price.Currency
+	ws.BalanceDueAt = balanceDueAt.Time
+	ws.SeriesID = seriesID.String
+	ws.RoomID = int(roomID.Int64)
+	ws.SetSeats(taken, waitlisted)
+	return ws, err
+}
 
-	}
-	return events, nil
+func scanEvent(r rowScanner) (workshop.Event, error) {
+	var (
+		e      workshop.Event
+		roomID sql.NullInt64
+	)
+	err := r.Scan(&e.ID, &e.Name, &e.Description, &e.StartTime, &e.EndTime, &e.Timezone, &e.CreatedAt, &e.UpdatedAt, &e.Price.Amount, &e.Price.Currency, &e.Location, &e.Caption, &roomID)
+	e.RoomID = int(roomID.Int64)
+	return e, err
 }
 
-func (w workshopDB) SignUp(signup workshop.SignUp) error {
-	log.Println(signup.WorkshopID)
-	log.Println(signup.FirstName)
-	log.Println(signup.LastName)
-	sqlCmd := "INSERT INTO signups (workshop_id, first_name, last_name, email, created_at, updated_at) VALUES(?, ?, ?, ?, NOW(), NOW())"
-	if _, err := w.db.Exec(
-		sqlCmd,
-		signup.WorkshopID,
-		signup.FirstName,
-		signup.LastName,
-		signup.Email,
-	); err != nil {
-		return err
+// timezone stores the empty timezone as the studio's.
+func timezone(name string) string {
+	if name == "" {
+		return workshop.DefaultTimezone
 	}
-	return nil
+	return name
+}
 
+// currency stores the empty currency as the default one.
+func currency(code string) string {
+	return money.New(0, code).Currency
 }
 
-func (w workshopDB) GetSignUpsByWorkshopID(workshopID string) ([]workshop.SignUp, error) {
-	sqlCmd := "SELECT * FROM signups WHERE workshop_id = ?"
-	var signups []workshop.SignUp
-	rows, err := w.db.Query(sqlCmd, workshopID)
-	if err != nil {
-		return signups, err
+// nullTime stores the zero time as NULL.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
 	}
-	var id int
-	for rows.Next() {
-		var s workshop.SignUp
-		if err := rows.Scan(&id, &s.WorkshopID, &s.FirstName, &s.LastName, &s.Email, &s.CreatedAt, &s.UpdatedAt, &s.Message); err != nil {
-			return signups, err
-		}
-		signups = append(signups, s)
+	return t
+}
 
+// nullInt stores the zero ID as NULL.
+func nullInt(id int) interface{} {
+	if id == 0 {
+		return nil
 	}
-	return signups, nil
+	return id
 }
-func (w workshopDB) GetNumSignUpsByWorkshopID(workshopID string) (int, error) {
-	sqlCmd := "SELECT count(*) FROM signups WHERE workshop_id = ?"
-	var count int
-	rows, err := w.db.Query(sqlCmd, workshopID)
-	if err != nil {
-		return 0, err
-	}
-	for rows.Next() {
-		if err := rows.Scan(&count); err != nil {
-			return 0, err
-
-		}
 
+// nullString stores the empty string as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
 	}
-	return count, nil
+	return s
 }
 
+// GetAllSignUps returns every workshop's signups, in two queries regardless
+// of the number of workshops.
 func (w workshopDB) GetAllSignUps() ([]workshop.SignUpTable, error) {
 	var table []workshop.SignUpTable
-	wNames, err := w.getWorkshopNames()
+	rows, err := w.db.Query("SELECT workshop_id, name FROM workshops ORDER BY id")
 	if err != nil {
 		return table, err
 	}
-	for _, n := range wNames {
-		sups, err := w.GetSignUpsByWorkshopID(n.ID)
-		if err != nil {
+	index := make(map[string]int)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
 			return table, err
 		}
-		table = append(table, workshop.SignUpTable{
-			WorkshopName: n.Name,
-			SignUps:      sups,
-		})
-
+		index[id] = len(table)
+		table = append(table, workshop.SignUpTable{WorkshopName: name})
 	}
-	return table, nil
-
-}
-
-type workshopName struct {
-	ID   string
-	Name string
-}
-
-func (w workshopDB) getWorkshopNames() ([]workshopName, error) {
+	rows.Close()
 
-	sqlCmd := "SELECT workshop_id, name FROM workshops"
-	var (
-		id     string
-		name   string
-		wNames []workshopName
-	)
-	rows, err := w.db.Query(sqlCmd)
+	rows, err = w.db.Query("SELECT " + signupColumns + " FROM signups ORDER BY workshop_id, id")
 	if err != nil {
-		return wNames, err
+		return table, err
 	}
+	defer rows.Close()
+	positions := make(map[string]int)
 	for rows.Next() {
-		if err := rows.Scan(&id, &name); err != nil {
-			return wNames, err
+		s, err := scanSignUp(rows)
+		if err != nil {
+			return table, err
+		}
+		i, ok := index[s.WorkshopID]
+		if !ok {
+			continue
 		}
-		wNames = append(wNames, workshopName{ID: id, Name: name})
+		if s.Status == workshop.StatusWaitlisted {
+			positions[s.WorkshopID]++
+			s.Position = positions[s.WorkshopID]
+		}
+		table[i].SignUps = append(table[i].SignUps, s)
 	}
-	return wNames, nil
-
+	return table, rows.Err()
 }
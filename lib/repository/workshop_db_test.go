@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/workshop"
+)
+
+// countingDriver is a database/sql driver that counts the queries run
+// through it. It answers every select with one row per workshop, so that the
+// workshops listed come with a session, price tier, instructor, category,
+// tag and signup each, which is enough to see how the number of queries
+// grows with the number of workshops listed.
+type countingDriver struct {
+	mu     sync.Mutex
+	stores map[string]*countingStore
+}
+
+type countingStore struct {
+	workshops int
+	queries   int64
+	// fail, when set, is asked before each statement runs whether it
+	// fails instead.
+	fail func(query string, args []driver.Value) error
+}
+
+var counting = &countingDriver{stores: make(map[string]*countingStore)}
+
+func init() {
+	sql.Register("counting", counting)
+}
+
+// openCountingDB returns a workshopDB holding n workshops and the store that
+// counts its queries.
+func openCountingDB(tb testing.TB, n int) (workshopDB, *countingStore) {
+	name := fmt.Sprintf("%s-%d", tb.Name(), n)
+	store := &countingStore{workshops: n}
+	counting.mu.Lock()
+	counting.stores[name] = store
+	counting.mu.Unlock()
+	db, err := sql.Open("counting", name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return workshopDB{db: db}, store
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	store, ok := d.stores[name]
+	if !ok {
+		return nil, fmt.Errorf("no counting store %q", name)
+	}
+	return countingConn{store}, nil
+}
+
+type countingConn struct {
+	store *countingStore
+}
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	return countingStmt{store: c.store, query: query}, nil
+}
+
+func (c countingConn) Close() error { return nil }
+
+func (c countingConn) Begin() (driver.Tx, error) { return countingTx{}, nil }
+
+type countingTx struct{}
+
+func (countingTx) Commit() error   { return nil }
+func (countingTx) Rollback() error { return nil }
+
+type countingStmt struct {
+	store *countingStore
+	query string
+}
+
+func (s countingStmt) Close() error  { return nil }
+func (s countingStmt) NumInput() int { return -1 }
+
+func (s countingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	atomic.AddInt64(&s.store.queries, 1)
+	if s.store.fail != nil {
+		if err := s.store.fail(s.query, args); err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt64(&s.store.queries, 1)
+	if s.store.fail != nil {
+		if err := s.store.fail(s.query, args); err != nil {
+			return nil, err
+		}
+	}
+	from := strings.Index(s.query, " FROM ")
+	if !strings.HasPrefix(s.query, "SELECT ") || from < 0 {
+		return &countingRows{}, nil
+	}
+	columns := strings.Split(s.query[len("SELECT "):from], ", ")
+	rows := &countingRows{columns: columns}
+	for i := 0; i < s.store.workshops; i++ {
+		row := make([]driver.Value, len(columns))
+		for j, c := range columns {
+			row[j] = countingValue(c, i)
+		}
+		rows.values = append(rows.values, row)
+	}
+	return rows, nil
+}
+
+// countingValue is the value of column in the row for the i-th workshop.
+func countingValue(column string, i int) driver.Value {
+	start := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC).AddDate(0, 0, i)
+	column = strings.TrimPrefix(column, "DISTINCT ")
+	switch column[strings.Index(column, ".")+1:] {
+	case "workshop_id":
+		return "workshop-" + strconv.Itoa(i)
+	case "id", "position":
+		return int64(i + 1)
+	case "start_time", "created_at", "updated_at":
+		return start
+	case "end_time":
+		return start.Add(2 * time.Hour)
+	case "cap", "price_amount", "refund_full_days", "refund_partial_days", "refund_partial_percent", "deposit_amount", "signup_count", "waitlist_count", "discount_amount", "voucher_amount", "amount_paid":
+		return int64(0)
+	case "release_unpaid":
+		return false
+	case "status":
+		return string(workshop.StatusConfirmed)
+	case "cancel_deadline", "balance_due_at", "cancelled_at", "series_id", "room_id", "parent_id", "valid_from", "valid_until", "hold_expires_at", "balance_reminded_at", "balance_overdue_at":
+		return nil
+	}
+	return column
+}
+
+type countingRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *countingRows) Columns() []string { return r.columns }
+func (r *countingRows) Close() error      { return nil }
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// queriesForGetWorkshops lists n workshops once and returns how many
+// queries it took.
+func queriesForGetWorkshops(t *testing.T, n int) int64 {
+	db, store := openCountingDB(t, n)
+	defer db.db.Close()
+	workshops, err := db.GetWorkshops()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workshops) != n {
+		t.Fatalf("listed %d workshops, want %d", len(workshops), n)
+	}
+	return atomic.LoadInt64(&store.queries)
+}
+
+func TestGetWorkshopsQueryCountIsFlat(t *testing.T) {
+	few, many := queriesForGetWorkshops(t, 10), queriesForGetWorkshops(t, 1000)
+	if few != many {
+		t.Errorf("listing 10 workshops took %d queries, 1000 took %d", few, many)
+	}
+}
+
+func TestGetWorkshopsLoadsChildRows(t *testing.T) {
+	db, _ := openCountingDB(t, 3)
+	defer db.db.Close()
+	workshops, err := db.GetWorkshops()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ws := range workshops {
+		if len(ws.Sessions) != 1 || len(ws.PriceTiers) != 1 || len(ws.Instructors) != 1 || len(ws.Categories) != 1 || len(ws.Tags) != 1 {
+			t.Errorf("workshop %s got %d sessions, %d price tiers, %d instructors, %d categories and %d tags, want one each",
+				ws.WorkshopID, len(ws.Sessions), len(ws.PriceTiers), len(ws.Instructors), len(ws.Categories), len(ws.Tags))
+		}
+	}
+}
+
+// queriesForGetAllSignUps lists the signups of n workshops once and returns
+// how many queries it took.
+func queriesForGetAllSignUps(t *testing.T, n int) int64 {
+	db, store := openCountingDB(t, n)
+	defer db.db.Close()
+	table, err := db.GetAllSignUps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != n {
+		t.Fatalf("listed %d workshops, want %d", len(table), n)
+	}
+	for _, ws := range table {
+		if len(ws.SignUps) != 1 {
+			t.Fatalf("workshop %s has %d signups, want 1", ws.WorkshopName, len(ws.SignUps))
+		}
+	}
+	return atomic.LoadInt64(&store.queries)
+}
+
+func TestGetAllSignUpsQueryCountIsFlat(t *testing.T) {
+	few, many := queriesForGetAllSignUps(t, 10), queriesForGetAllSignUps(t, 1000)
+	if few != many {
+		t.Errorf("listing the signups of 10 workshops took %d queries, 1000 took %d", few, many)
+	}
+}
+
+func BenchmarkGetWorkshops(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			db, store := openCountingDB(b, n)
+			defer db.db.Close()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := db.GetWorkshops(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&store.queries))/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Package search is an in-process full-text index of the workshops and
+// events, analysed for German and English: words are folded to lowercase
+// without umlauts, stop words of either language are dropped, and every
+// other word is indexed under its German and its English stem.
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a word of a text and where it is, in bytes.
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits text into its words, lowercased. Anything but letters and
+// digits separates words.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+var folder = strings.NewReplacer("ä", "a", "ö", "o", "ü", "u", "ß", "ss")
+
+// fold spells a lowercase word without umlauts and ß, so that "Töpfern"
+// and "Topfern" are one word.
+func fold(word string) string {
+	return folder.Replace(word)
+}
+
+// indexed reports whether word is worth indexing: stop words and single
+// letters are not.
+func indexed(word string) bool {
+	return utf8.RuneCountInString(word) > 1 && !stopWords[fold(word)]
+}
+
+// terms are the stems word is indexed under: its German and its English
+// stem, once if they agree.
+func terms(word string) []string {
+	w := fold(word)
+	de, en := stemGerman(w), stemEnglish(w)
+	if de == en {
+		return []string{de}
+	}
+	return []string{de, en}
+}
+
+// stopWords are the folded stop words of German and English.
+var stopWords = make(map[string]bool)
+
+func init() {
+	for _, list := range []string{germanStopWords, englishStopWords} {
+		for _, w := range strings.Fields(list) {
+			stopWords[fold(w)] = true
+		}
+	}
+}
+
+const germanStopWords = `
+aber alle allem allen aller alles als also am an ander andere anderem anderen
+anderer anderes auch auf aus bei bin bis bist da damit dann das dass dem den
+denn der des dich die dir doch dort du durch ein eine einem einen einer eines
+er es etwas euch euer eure für hab habe haben hat hatte hier hin hinter ich ihm
+ihn ihnen ihr ihre im in ins ist ja jede jedem jeden jeder jedes jetzt kann
+kein keine man mich mir mit muss nach nicht nichts noch nun nur ob oder ohne
+sehr sein seine sich sie sind so solche soll sondern sonst über um und uns
+unser unter viel vom von vor war waren was weil wenn wer wie wir wird wo zu
+zum zur zwischen
+`
+
+const englishStopWords = `
+a about above after again all also am an and any are as at be because been
+before being below between both but by can could did do does doing down during
+each few for from further had has have having he her here hers him his how i
+if in into is it its itself just me more most my no nor not of off on once only
+or other our ours out over own same she should so some such than that the their
+them then there these they this those through to too under until up very was
+we were what when where which while who whom why will with you your yours
+`
@@ -0,0 +1,60 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	text := "Töpfern & <b>Raku</b>-Brand, 2x!"
+	var got []string
+	for _, tok := range tokenize(text) {
+		got = append(got, fmt.Sprintf("%s@%d", tok.word, tok.start))
+	}
+	// Places are in bytes, and ö takes two.
+	if want := "[töpfern@0 b@12 raku@14 b@20 brand@23 2x@30]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestFoldAndTerms(t *testing.T) {
+	for _, c := range []struct {
+		word, want string
+	}{
+		// Umlauts fold away, so that either spelling is one term.
+		{"töpfern", "[topf topfern]"},
+		{"topfern", "[topf topfern]"},
+		{"größe", "[gross]"},
+		{"grosse", "[gross]"},
+		{"müsli", "[musli]"},
+		// Words both stemmers agree on are indexed once.
+		{"raku", "[raku]"},
+		{"printing", "[printing print]"},
+	} {
+		if got := fmt.Sprint(terms(c.word)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.word, got, c.want)
+		}
+	}
+}
+
+func TestIndexed(t *testing.T) {
+	for word, want := range map[string]bool{
+		"und":  false,
+		"für":  false,
+		"fur":  false,
+		"über": false,
+		"the":  false,
+		"with": false,
+		"a":    false,
+		"x":    false,
+		"ö":    false,
+		"2x":   true,
+		"raku": true,
+		"kurs": true,
+		"fell": true,
+	} {
+		if got := indexed(word); got != want {
+			t.Errorf("%s: got %v, want %v", word, got, want)
+		}
+	}
+}
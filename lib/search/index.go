@@ -0,0 +1,405 @@
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of documents.
+const (
+	KindWorkshop = "workshop"
+	KindEvent    = "event"
+)
+
+// Doc is a workshop or event as it is searched.
+type Doc struct {
+	Kind        string
+	ID          string
+	Name        string
+	Caption     string
+	Description string
+	// Categories and Instructors are the names of the categories the
+	// offering is filed under and of the instructors teaching it.
+	Categories  []string
+	Instructors []string
+	// Start is in the offering's own timezone.
+	Start     time.Time
+	End       time.Time
+	Cancelled bool
+}
+
+// Fields of a Doc, as named in highlights, and how much a match in each
+// weighs.
+var fields = []struct {
+	name   string
+	weight float64
+	text   func(Doc) string
+}{
+	{"name", 3, func(d Doc) string { return d.Name }},
+	{"caption", 1.5, func(d Doc) string { return d.Caption }},
+	{"description", 1, func(d Doc) string { return d.Description }},
+	{"categories", 2, func(d Doc) string { return strings.Join(d.Categories, ", ") }},
+	{"instructors", 2, func(d Doc) string { return strings.Join(d.Instructors, ", ") }},
+}
+
+// Hit is a document matching a search.
+type Hit struct {
+	Doc   Doc
+	Score float64
+	// Highlights are the fields with matches, and always the name, as HTML
+	// with the matching words in <mark>. Long descriptions are cut to the
+	// part around the first match.
+	Highlights map[string]string
+}
+
+type key struct{ kind, id string }
+
+type entry struct {
+	doc Doc
+	// counts are how often each term is in each field of the document, and
+	// lengths how many indexed words each field has.
+	counts  map[string][]float64
+	lengths []float64
+	// words are the distinct indexed words, for suggestions.
+	words []string
+}
+
+// Index is an inverted index of documents, safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[key]*entry
+	postings map[string]map[key]bool
+	// lengths are the total lengths of each field over all documents.
+	lengths []float64
+	stale   bool
+}
+
+// NewIndex returns an empty index.
+func NewIndex() *Index {
+	return &Index{docs: make(map[key]*entry), postings: make(map[string]map[key]bool), lengths: make([]float64, len(fields))}
+}
+
+// Put adds d to the index in place of the document of the same kind and
+// id.
+func (x *Index) Put(d Doc) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.put(d)
+}
+
+func (x *Index) put(d Doc) {
+	k := key{d.Kind, d.ID}
+	x.remove(k)
+	e := &entry{doc: d, counts: make(map[string][]float64), lengths: make([]float64, len(fields))}
+	seen := make(map[string]bool)
+	for i, f := range fields {
+		for _, t := range tokenize(f.text(d)) {
+			if !indexed(t.word) {
+				continue
+			}
+			for _, term := range terms(t.word) {
+				if e.counts[term] == nil {
+					e.counts[term] = make([]float64, len(fields))
+				}
+				e.counts[term][i]++
+			}
+			e.lengths[i]++
+			x.lengths[i]++
+			if !seen[t.word] {
+				seen[t.word] = true
+				e.words = append(e.words, t.word)
+			}
+		}
+	}
+	for term := range e.counts {
+		if x.postings[term] == nil {
+			x.postings[term] = make(map[key]bool)
+		}
+		x.postings[term][k] = true
+	}
+	x.docs[k] = e
+}
+
+// Remove takes the document of kind and id out of the index.
+func (x *Index) Remove(kind, id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(key{kind, id})
+}
+
+func (x *Index) remove(k key) {
+	e, ok := x.docs[k]
+	if !ok {
+		return
+	}
+	for term := range e.counts {
+		delete(x.postings[term], k)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	for i, l := range e.lengths {
+		x.lengths[i] -= l
+	}
+	delete(x.docs, k)
+}
+
+// Reset replaces every document of the index with docs.
+func (x *Index) Reset(docs []Doc) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.docs = make(map[key]*entry)
+	x.postings = make(map[string]map[key]bool)
+	x.lengths = make([]float64, len(fields))
+	x.stale = false
+	for _, d := range docs {
+		x.put(d)
+	}
+}
+
+// Invalidate marks the index as out of date, for a write whose documents
+// could not be read back. It stays so until Reset.
+func (x *Index) Invalidate() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.stale = true
+}
+
+// Stale reports whether the index was invalidated.
+func (x *Index) Stale() bool {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.stale
+}
+
+// live reports whether d is still to be found at now: not cancelled and
+// not over.
+func live(d Doc, now time.Time) bool {
+	return !d.Cancelled && d.End.After(now)
+}
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Search returns up to limit of the live documents containing every word of
+// query, best first. A word matches on its German or its English stem.
+// Scores are BM25F: term counts are normalised by the length of their
+// field and weighted by it.
+func (x *Index) Search(query string, now time.Time, limit int) []Hit {
+	var words [][]string
+	wanted := make(map[string]bool)
+	for _, t := range tokenize(query) {
+		if !indexed(t.word) {
+			continue
+		}
+		ts := terms(t.word)
+		words = append(words, ts)
+		for _, term := range ts {
+			wanted[term] = true
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	n := float64(len(x.docs))
+	var hits []Hit
+	for k := range x.candidates(words[0]) {
+		e := x.docs[k]
+		if !live(e.doc, now) {
+			continue
+		}
+		score := 0.0
+		for _, ts := range words {
+			best := 0.0
+			for _, term := range ts {
+				if s := x.score(e, term, n); s > best {
+					best = s
+				}
+			}
+			if best == 0 {
+				score = 0
+				break
+			}
+			score += best
+		}
+		if score > 0 {
+			hits = append(hits, Hit{Doc: e.doc, Score: score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if !hits[i].Doc.Start.Equal(hits[j].Doc.Start) {
+			return hits[i].Doc.Start.Before(hits[j].Doc.Start)
+		}
+		return hits[i].Doc.Kind+hits[i].Doc.ID < hits[j].Doc.Kind+hits[j].Doc.ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		hits[i].Highlights = highlights(hits[i].Doc, wanted)
+	}
+	return hits
+}
+
+// score is the BM25F score of term in e, of n documents.
+func (x *Index) score(e *entry, term string, n float64) float64 {
+	counts := e.counts[term]
+	if counts == nil {
+		return 0
+	}
+	tf := 0.0
+	for i, f := range fields {
+		if counts[i] > 0 {
+			avg := x.lengths[i] / n
+			tf += f.weight * counts[i] / (1 - b + b*e.lengths[i]/avg)
+		}
+	}
+	df := float64(len(x.postings[term]))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	return idf * tf * (k1 + 1) / (tf + k1)
+}
+
+// candidates are the documents containing any of terms.
+func (x *Index) candidates(terms []string) map[key]bool {
+	found := make(map[key]bool)
+	for _, term := range terms {
+		for k := range x.postings[term] {
+			found[k] = true
+		}
+	}
+	return found
+}
+
+// Suggest completes the last word of prefix with up to limit words of the
+// live documents, the most widely used first, and returns the whole
+// completed prefixes.
+func (x *Index) Suggest(prefix string, now time.Time, limit int) []string {
+	tokens := tokenize(prefix)
+	if len(tokens) == 0 || tokens[len(tokens)-1].end != len(prefix) {
+		return nil
+	}
+	last := tokens[len(tokens)-1]
+	head, partial := prefix[:last.start], fold(last.word)
+	x.mu.RLock()
+	counts := make(map[string]int)
+	for _, e := range x.docs {
+		if !live(e.doc, now) {
+			continue
+		}
+		for _, w := range e.words {
+			if strings.HasPrefix(fold(w), partial) {
+				counts[w]++
+			}
+		}
+	}
+	x.mu.RUnlock()
+	words := make([]string, 0, len(counts))
+	for w := range counts {
+		words = append(words, w)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if counts[words[i]] != counts[words[j]] {
+			return counts[words[i]] > counts[words[j]]
+		}
+		return words[i] < words[j]
+	})
+	if len(words) > limit {
+		words = words[:limit]
+	}
+	for i, w := range words {
+		words[i] = head + w
+	}
+	return words
+}
+
+// snippetLength is about how many bytes of a long description highlights
+// keep.
+const snippetLength = 200
+
+// highlights marks the words of d that have a wanted term.
+func highlights(d Doc, wanted map[string]bool) map[string]string {
+	out := make(map[string]string)
+	for _, f := range fields {
+		text := f.text(d)
+		var marked []token
+		for _, t := range tokenize(text) {
+			if !indexed(t.word) {
+				continue
+			}
+			for _, term := range terms(t.word) {
+				if wanted[term] {
+					marked = append(marked, t)
+					break
+				}
+			}
+		}
+		if len(marked) == 0 && f.name != "name" {
+			continue
+		}
+		from, to := 0, len(text)
+		if f.name == "description" && len(text) > snippetLength {
+			from, to = snippet(text, marked[0])
+		}
+		out[f.name] = mark(text, from, to, marked)
+	}
+	return out
+}
+
+// snippet picks the words of text around t, cutting at spaces.
+func snippet(text string, t token) (int, int) {
+	from := t.start - snippetLength/4
+	if from <= 0 {
+		from = 0
+	} else if i := strings.IndexByte(text[from:t.start], ' '); i >= 0 {
+		from += i + 1
+	} else {
+		from = t.start
+	}
+	to := from + snippetLength
+	if to >= len(text) {
+		return from, len(text)
+	}
+	if i := strings.LastIndexByte(text[t.end:to], ' '); i >= 0 {
+		to = t.end + i
+	} else {
+		to = t.end
+	}
+	return from, to
+}
+
+// mark escapes text[from:to] as HTML with the marked tokens in <mark>, and
+// with an ellipsis where it was cut.
+func mark(text string, from, to int, marked []token) string {
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+	pos := from
+	for _, t := range marked {
+		if t.start < from || t.end > to {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[pos:t.start]))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(text[t.start:t.end]))
+		sb.WriteString("</mark>")
+		pos = t.end
+	}
+	sb.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
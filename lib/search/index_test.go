@@ -0,0 +1,162 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func testIndex() *Index {
+	at := func(days int) time.Time { return testNow.AddDate(0, 0, days) }
+	x := NewIndex()
+	for _, d := range []Doc{
+		{Kind: KindWorkshop, ID: "sieb", Name: "Siebdruck für Anfänger", Description: "Wir drucken Siebdrucke auf Stoff.", Categories: []string{"Drucken"}, Start: at(1), End: at(1).Add(2 * time.Hour)},
+		{Kind: KindWorkshop, ID: "screen", Name: "Screen printing", Description: "Printed tote bags, <b>bold</b> & bright, from our Druckerei.", Start: at(2), End: at(2).Add(2 * time.Hour)},
+		{Kind: KindWorkshop, ID: "topf", Name: "Töpfern am Abend", Description: "Topfern an der Scheibe.", Instructors: []string{"Jörg Müller"}, Start: at(3), End: at(3).Add(2 * time.Hour)},
+		{Kind: KindEvent, ID: "open", Name: "Open studio", Description: "Töpfern, drucken and printing for everyone.", Start: at(4), End: at(4).Add(4 * time.Hour)},
+		{Kind: KindWorkshop, ID: "cancelled", Name: "Siebdruck Intensiv", Start: at(5), End: at(5).Add(2 * time.Hour), Cancelled: true},
+		{Kind: KindWorkshop, ID: "over", Name: "Siebdruck im Sommer", Start: at(-2), End: at(-2).Add(2 * time.Hour)},
+	} {
+		x.Put(d)
+	}
+	return x
+}
+
+func hitIDs(hits []Hit) string {
+	var ids []string
+	for _, h := range hits {
+		ids = append(ids, h.Doc.ID)
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestSearch(t *testing.T) {
+	x := testIndex()
+	for _, c := range []struct {
+		query, want string
+	}{
+		// German plurals find the singular, and neither cancelled nor
+		// past offerings are found.
+		{"Siebdrucke", "[sieb]"},
+		{"siebdruck", "[sieb]"},
+		// English inflections find each other, best match first.
+		{"printing", "[screen open]"},
+		{"printed", "[screen open]"},
+		{"prints", "[screen open]"},
+		// Umlauts are folded on either side.
+		{"Töpfern", "[topf open]"},
+		{"topfern", "[topf open]"},
+		{"MÜLLER", "[topf]"},
+		// Stop words of either language are left out of queries.
+		{"und the für", "[]"},
+		{"Töpfern und the", "[topf open]"},
+		{"a b c", "[]"},
+		{"", "[]"},
+		// Every other word must match.
+		{"drucken printing", "[open]"},
+		{"siebdruck printing", "[]"},
+		{"<b>bold</b>", "[screen]"},
+	} {
+		if got := hitIDs(x.Search(c.query, testNow, 10)); got != c.want {
+			t.Errorf("%q: got %s, want %s", c.query, got, c.want)
+		}
+	}
+	if got := hitIDs(x.Search("printing", testNow, 1)); got != "[screen]" {
+		t.Errorf("limit 1: got %s", got)
+	}
+	if hits := x.Search("siebdruck", testNow.AddDate(0, 0, -3), 10); hitIDs(hits) != "[sieb over]" {
+		t.Errorf("before the summer course ended: got %s, want [sieb over]", hitIDs(hits))
+	}
+
+	x.Remove(KindWorkshop, "topf")
+	if got := hitIDs(x.Search("töpfern", testNow, 10)); got != "[open]" {
+		t.Errorf("after removing topf: got %s, want [open]", got)
+	}
+	x.Put(Doc{Kind: KindEvent, ID: "open", Name: "Open studio", Start: testNow.AddDate(0, 0, 4), End: testNow.AddDate(0, 0, 5)})
+	if got := hitIDs(x.Search("töpfern", testNow, 10)); got != "[]" {
+		t.Errorf("after putting open without pottery: got %s, want []", got)
+	}
+	x.Reset(nil)
+	if got := hitIDs(x.Search("printing", testNow, 10)); got != "[]" {
+		t.Errorf("after a reset: got %s, want []", got)
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	x := testIndex()
+	for _, c := range []struct {
+		query string
+		want  map[string]string
+	}{
+		{"Siebdrucke", map[string]string{
+			"name":        "<mark>Siebdruck</mark> für Anfänger",
+			"description": "Wir drucken <mark>Siebdrucke</mark> auf Stoff.",
+		}},
+		// Markup in the text is escaped, including around a marked word.
+		{"bold", map[string]string{
+			"name":        "Screen printing",
+			"description": "Printed tote bags, &lt;b&gt;<mark>bold</mark>&lt;/b&gt; &amp; bright, from our Druckerei.",
+		}},
+		{"müller topfern", map[string]string{
+			"name":        "<mark>Töpfern</mark> am Abend",
+			"description": "<mark>Topfern</mark> an der Scheibe.",
+			"instructors": "Jörg <mark>Müller</mark>",
+		}},
+	} {
+		hits := x.Search(c.query, testNow, 1)
+		if len(hits) != 1 {
+			t.Fatalf("%q: got %d hits", c.query, len(hits))
+		}
+		if fmt.Sprint(hits[0].Highlights) != fmt.Sprint(c.want) {
+			t.Errorf("%q: got %q, want %q", c.query, hits[0].Highlights, c.want)
+		}
+	}
+
+	// Long descriptions are cut around the first match, at spaces.
+	long := strings.Repeat("Ton und Glasur. ", 20) + "Zum Schluss <brennen> wir im Rakuofen. " + strings.Repeat("Mit Tee & Kuchen. ", 20)
+	x.Put(Doc{Kind: KindWorkshop, ID: "raku", Name: "Raku", Description: long, Start: testNow.Add(time.Hour), End: testNow.Add(3 * time.Hour)})
+	hits := x.Search("rakuofen", testNow, 1)
+	if len(hits) != 1 {
+		t.Fatalf("got %d hits for the long description", len(hits))
+	}
+	d := hits[0].Highlights["description"]
+	if !strings.HasPrefix(d, "…") || !strings.HasSuffix(d, "…") || !strings.Contains(d, "&lt;brennen&gt; wir im <mark>Rakuofen</mark>. Mit Tee &amp; Kuchen.") || len(d) > snippetLength+100 {
+		t.Errorf("got snippet %q", d)
+	}
+	if strings.Contains(d, "  ") || strings.Contains(d, "… ") || strings.Contains(d, " …") {
+		t.Errorf("snippet %q is not cut at spaces", d)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	x := testIndex()
+	for _, c := range []struct {
+		prefix string
+		limit  int
+		want   string
+	}{
+		{"sie", 10, "[siebdruck siebdrucke]"},
+		// Words used by more offerings come first, then in order.
+		{"dr", 10, "[drucken druckerei]"},
+		{"Kurs dr", 10, "[Kurs drucken Kurs druckerei]"},
+		{"to", 10, "[töpfern topfern tote]"},
+		{"TÖ", 2, "[töpfern topfern]"},
+		{"pr", 1, "[printing]"},
+		// Stop words are not suggested, nor are words of past or cancelled
+		// offerings.
+		{"fü", 10, "[]"},
+		{"som", 10, "[]"},
+		{"intens", 10, "[]"},
+		// Only the last word is completed, and only while it is typed.
+		{"dr ", 10, "[]"},
+		{"", 10, "[]"},
+		{"xyz", 10, "[]"},
+	} {
+		if got := fmt.Sprint(x.Suggest(c.prefix, testNow, c.limit)); got != c.want {
+			t.Errorf("%q: got %s, want %s", c.prefix, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+)
+
+// stemGerman is the CISTEM stemmer of Weissweiler and Fraser (2017) for a
+// folded, lowercase word, ignoring case like its case-insensitive mode.
+func stemGerman(w string) string {
+	if len(w) >= 6 && strings.HasPrefix(w, "ge") {
+		w = w[2:]
+	}
+	w = germanMarks.Replace(w)
+	w = markDoubles(w)
+	for len(w) > 3 {
+		if len(w) > 5 {
+			if strings.HasSuffix(w, "em") || strings.HasSuffix(w, "er") || strings.HasSuffix(w, "nd") {
+				w = w[:len(w)-2]
+				continue
+			}
+		}
+		if last := w[len(w)-1]; last == 't' || last == 'e' || last == 's' || last == 'n' {
+			w = w[:len(w)-1]
+			continue
+		}
+		break
+	}
+	w = unmarkDoubles.ReplaceAllString(w, "$1$1")
+	return germanUnmarks.Replace(w)
+}
+
+var (
+	// germanMarks stand in one character for the digraphs CISTEM does not
+	// strip apart.
+	germanMarks   = strings.NewReplacer("sch", "$", "ei", "%", "ie", "&")
+	germanUnmarks = strings.NewReplacer("$", "sch", "%", "ei", "&", "ie")
+	unmarkDoubles = regexp.MustCompile(`(.)\*`)
+)
+
+// markDoubles writes the second of two equal letters as "*", so that
+// stripping does not split them.
+func markDoubles(w string) string {
+	b := []byte(w)
+	for i := 1; i < len(b); i++ {
+		if b[i] == b[i-1] && b[i] < 0x80 {
+			b[i] = '*'
+		}
+	}
+	return string(b)
+}
+
+// stemEnglish is the Porter stemmer for a lowercase word. Bytes other than
+// ASCII vowels count as consonants.
+func stemEnglish(w string) string {
+	if len(w) <= 2 {
+		return w
+	}
+	w = porterStep1a(w)
+	w = porterStep1b(w)
+	if stem, ok := trimSuffix(w, "y"); ok && hasVowel(stem) {
+		w = stem + "i"
+	}
+	w = porterReplace(w, porterStep2)
+	w = porterReplace(w, porterStep3)
+	w = porterStep4(w)
+	if stem, ok := trimSuffix(w, "e"); ok {
+		if m := measure(stem); m > 1 || (m == 1 && !cvc(stem)) {
+			w = stem
+		}
+	}
+	if measure(w) > 1 && strings.HasSuffix(w, "ll") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
+
+func porterStep1a(w string) string {
+	switch {
+	case strings.HasSuffix(w, "sses"), strings.HasSuffix(w, "ies"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "ss"):
+		return w
+	case strings.HasSuffix(w, "s"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+func porterStep1b(w string) string {
+	if stem, ok := trimSuffix(w, "eed"); ok {
+		if measure(stem) > 0 {
+			return stem + "ee"
+		}
+		return w
+	}
+	stem, ok := trimSuffix(w, "ed")
+	if !ok {
+		stem, ok = trimSuffix(w, "ing")
+	}
+	if !ok || !hasVowel(stem) {
+		return w
+	}
+	switch {
+	case strings.HasSuffix(stem, "at"), strings.HasSuffix(stem, "bl"), strings.HasSuffix(stem, "iz"):
+		return stem + "e"
+	case endsDouble(stem) && !strings.ContainsAny(stem[len(stem)-1:], "lsz"):
+		return stem[:len(stem)-1]
+	case measure(stem) == 1 && cvc(stem):
+		return stem + "e"
+	}
+	return stem
+}
+
+// porterStep2 and porterStep3 replace suffixes of stems with a measure
+// above 0, porterStep4Suffixes are dropped from stems with one above 1.
+var (
+	porterStep2 = [][2]string{
+		{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+		{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+		{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+		{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+		{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+		{"logi", "log"},
+	}
+	porterStep3 = [][2]string{
+		{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+		{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+	}
+	porterStep4Suffixes = []string{
+		"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+		"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+	}
+)
+
+// porterReplace replaces the longest suffix of rules that w ends in, if
+// the stem before it has a measure above 0.
+func porterReplace(w string, rules [][2]string) string {
+	longest := -1
+	for i, r := range rules {
+		if strings.HasSuffix(w, r[0]) && (longest < 0 || len(r[0]) > len(rules[longest][0])) {
+			longest = i
+		}
+	}
+	if longest < 0 {
+		return w
+	}
+	stem := w[:len(w)-len(rules[longest][0])]
+	if measure(stem) > 0 {
+		return stem + rules[longest][1]
+	}
+	return w
+}
+
+func porterStep4(w string) string {
+	suffix := ""
+	for _, s := range porterStep4Suffixes {
+		if strings.HasSuffix(w, s) && len(s) > len(suffix) {
+			suffix = s
+		}
+	}
+	if suffix == "" {
+		return w
+	}
+	stem := w[:len(w)-len(suffix)]
+	if measure(stem) <= 1 {
+		return w
+	}
+	if suffix == "ion" && !strings.HasSuffix(stem, "s") && !strings.HasSuffix(stem, "t") {
+		return w
+	}
+	return stem
+}
+
+func trimSuffix(w, suffix string) (string, bool) {
+	if strings.HasSuffix(w, suffix) {
+		return w[:len(w)-len(suffix)], true
+	}
+	return w, false
+}
+
+// consonant reports whether w[i] is a consonant: y is one only at the start
+// or after a vowel.
+func consonant(w string, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !consonant(w, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences of w.
+func measure(w string) int {
+	n, i := 0, 0
+	for i < len(w) && consonant(w, i) {
+		i++
+	}
+	for i < len(w) {
+		for i < len(w) && !consonant(w, i) {
+			i++
+		}
+		if i == len(w) {
+			break
+		}
+		n++
+		for i < len(w) && consonant(w, i) {
+			i++
+		}
+	}
+	return n
+}
+
+func hasVowel(w string) bool {
+	for i := range w {
+		if !consonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func endsDouble(w string) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && consonant(w, n-1)
+}
+
+// cvc reports whether w ends consonant, vowel, consonant, the last not w, x
+// or y, as in "hop".
+func cvc(w string) bool {
+	n := len(w)
+	return n >= 3 && consonant(w, n-3) && !consonant(w, n-2) && consonant(w, n-1) && !strings.ContainsAny(w[n-1:], "wxy")
+}
@@ -0,0 +1,65 @@
+package search
+
+import "testing"
+
+func TestStemGerman(t *testing.T) {
+	for _, c := range []struct{ word, want string }{
+		{"siebdruck", "siebdruck"},
+		{"siebdrucke", "siebdruck"},
+		{"siebdrucks", "siebdruck"},
+		{"keramik", "keramik"},
+		{"keramiken", "keramik"},
+		{"aquarelle", "aquarell"},
+		{"aquarell", "aquarell"},
+		{"ringe", "ring"},
+		{"topfern", "topf"},
+		{"kurse", "kur"},
+		{"kurs", "kur"},
+		// "ge" is stripped from words of six letters or more, "sch", "ei"
+		// and "ie" are not split, nor are double letters.
+		{"gemalt", "mal"},
+		{"malen", "mal"},
+		{"schmuck", "schmuck"},
+		{"malerei", "malerei"},
+		{"glasieren", "glasier"},
+		{"gut", "gut"},
+	} {
+		if got := stemGerman(c.word); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.word, got, c.want)
+		}
+	}
+}
+
+func TestStemEnglish(t *testing.T) {
+	for _, c := range []struct{ word, want string }{
+		{"print", "print"},
+		{"prints", "print"},
+		{"printed", "print"},
+		{"printing", "print"},
+		{"glaze", "glaze"},
+		{"glazed", "glaze"},
+		{"glazing", "glaze"},
+		{"throws", "throw"},
+		{"throwing", "throw"},
+		{"classes", "class"},
+		{"caresses", "caress"},
+		{"ponies", "poni"},
+		{"running", "run"},
+		{"hopping", "hop"},
+		{"hoping", "hope"},
+		{"filing", "file"},
+		{"feed", "feed"},
+		{"agreed", "agre"},
+		{"happy", "happi"},
+		{"pottery", "potteri"},
+		{"relational", "relat"},
+		{"generalization", "gener"},
+		{"electrical", "electr"},
+		{"adjustment", "adjust"},
+		{"by", "by"},
+	} {
+		if got := stemEnglish(c.word); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.word, got, c.want)
+		}
+	}
+}
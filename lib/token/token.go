@@ -0,0 +1,82 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("invalid token")
+	ErrExpired = errors.New("token has expired")
+)
+
+// Claims identify the signup a cancellation token was issued for.
+type Claims struct {
+	WorkshopID string    `json:"w"`
+	SignupID   int       `json:"s"`
+	Expires    time.Time `json:"e"`
+}
+
+// Signer issues and verifies signup cancellation tokens. A token is the
+// base64url encoded claims followed by an HMAC-SHA256 of them, so it can be
+// handed to participants without storing anything server side.
+type Signer struct {
+	key []byte
+	ttl time.Duration
+}
+
+func NewSigner(key string, ttl time.Duration) Signer {
+	return Signer{key: []byte(key), ttl: ttl}
+}
+
+// Issue returns a token for the signup that expires after the signer's ttl.
+func (s Signer) Issue(workshopID string, signupID int, now time.Time) string {
+	payload, _ := json.Marshal(Claims{
+		WorkshopID: workshopID,
+		SignupID:   signupID,
+		Expires:    now.Add(s.ttl).UTC().Truncate(time.Second),
+	})
+	return encode(payload) + "." + encode(s.mac(payload))
+}
+
+// Verify checks the signature and expiry of tok and returns its claims.
+func (s Signer) Verify(tok string, now time.Time) (Claims, error) {
+	var c Claims
+	parts := strings.Split(tok, ".")
+	if len(parts) != 2 {
+		return c, ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return c, ErrInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return c, ErrInvalid
+	}
+	if !hmac.Equal(sig, s.mac(payload)) {
+		return c, ErrInvalid
+	}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, ErrInvalid
+	}
+	if !now.Before(c.Expires) {
+		return c, ErrExpired
+	}
+	return c, nil
+}
+
+func (s Signer) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
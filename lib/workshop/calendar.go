@@ -0,0 +1,224 @@
+package workshop
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OpeningHours is a span of a weekday the studio is open. Opens and Closes
+// are minutes after local midnight; a Closes of 1440 is open until
+// midnight.
+type OpeningHours struct {
+	Weekday time.Weekday
+	Opens   int
+	Closes  int
+}
+
+// Closure is a run of days the studio is closed, e.g. over the holidays.
+// From and Until are inclusive local dates, kept as midnight UTC.
+type Closure struct {
+	ID        int
+	From      time.Time
+	Until     time.Time
+	Reason    string
+	CreatedAt time.Time
+}
+
+// Calendar is when the studio is open. Without any Hours it is open around
+// the clock on every day outside its Closures.
+type Calendar struct {
+	Hours    []OpeningHours
+	Closures []Closure
+}
+
+var (
+	ErrInvalidOpeningHours = errors.New("opening hours must close after they open on the same day and must not overlap")
+	ErrInvalidClosure      = errors.New("closure must end on or after the day it starts")
+)
+
+// ClosedError rejects a workshop session or event held while the studio is
+// closed.
+type ClosedError struct {
+	StartTime time.Time
+	EndTime   time.Time
+	// Closure is the closure the span falls in, or nil when it is outside
+	// the opening hours.
+	Closure *Closure
+}
+
+func (e *ClosedError) Error() string {
+	if e.Closure != nil {
+		msg := fmt.Sprintf("the studio is closed from %s until %s", e.Closure.From.Format(DateLayout), e.Closure.Until.Format(DateLayout))
+		if e.Closure.Reason != "" {
+			msg += " (" + e.Closure.Reason + ")"
+		}
+		return msg
+	}
+	return fmt.Sprintf("%s to %s is outside the opening hours", e.StartTime.Format(time.RFC3339), e.EndTime.Format(time.RFC3339))
+}
+
+// DateLayout is how closure dates are written.
+const DateLayout = "2006-01-02"
+
+// Date returns the calendar date of t, in t's location, as midnight UTC.
+func Date(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// ValidateOpeningHours checks that each span closes after it opens within
+// its day and that the spans of a weekday do not overlap.
+func ValidateOpeningHours(hours []OpeningHours) error {
+	for i, h := range hours {
+		if h.Weekday < time.Sunday || h.Weekday > time.Saturday || h.Opens < 0 || h.Closes > 24*60 || h.Closes <= h.Opens {
+			return ErrInvalidOpeningHours
+		}
+		for _, o := range hours[:i] {
+			if o.Weekday == h.Weekday && o.Opens < h.Closes && h.Opens < o.Closes {
+				return ErrInvalidOpeningHours
+			}
+		}
+	}
+	return nil
+}
+
+// Validate turns the dates of c into midnight UTC and checks that it does
+// not end before it starts.
+func (c *Closure) Validate() error {
+	c.Reason = strings.TrimSpace(c.Reason)
+	if c.From.IsZero() || c.Until.IsZero() {
+		return ErrInvalidClosure
+	}
+	c.From, c.Until = Date(c.From), Date(c.Until)
+	if c.Until.Before(c.From) {
+		return ErrInvalidClosure
+	}
+	return nil
+}
+
+// closureOn returns the closure covering day, a date as midnight UTC.
+func (c Calendar) closureOn(day time.Time) *Closure {
+	for i, cl := range c.Closures {
+		if !day.Before(cl.From) && !day.After(cl.Until) {
+			return &c.Closures[i]
+		}
+	}
+	return nil
+}
+
+// ClosedOn reports whether the studio stays closed all of day, a date as
+// midnight UTC: it falls in a closure or, once opening hours are set, has
+// none.
+func (c Calendar) ClosedOn(day time.Time) bool {
+	if c.closureOn(day) != nil {
+		return true
+	}
+	if len(c.Hours) == 0 {
+		return false
+	}
+	for _, h := range c.Hours {
+		if h.Weekday == day.Weekday() {
+			return false
+		}
+	}
+	return true
+}
+
+// days returns the local dates from start up to end, in timezone.
+func days(start, end time.Time, timezone string) []time.Time {
+	from, to := inZone(start, timezone), inZone(end, timezone)
+	last := Date(to.Add(-time.Nanosecond))
+	var out []time.Time
+	for d := Date(from); !d.After(last); d = d.AddDate(0, 0, 1) {
+		out = append(out, d)
+	}
+	return out
+}
+
+// Check reports a ClosedError unless the studio is open from start to end,
+// read in timezone: no day of it falls in a closure and, once opening hours
+// are set, it lies within the hours of a single day.
+func (c Calendar) Check(start, end time.Time, timezone string) error {
+	spanned := days(start, end, timezone)
+	for _, d := range spanned {
+		if cl := c.closureOn(d); cl != nil {
+			return &ClosedError{StartTime: start, EndTime: end, Closure: cl}
+		}
+	}
+	if len(c.Hours) == 0 {
+		return nil
+	}
+	closed := &ClosedError{StartTime: start, EndTime: end}
+	if len(spanned) != 1 {
+		return closed
+	}
+	from, to := inZone(start, timezone), inZone(end, timezone)
+	opens := from.Hour()*60 + from.Minute()
+	closes := to.Hour()*60 + to.Minute()
+	if Date(to).After(spanned[0]) {
+		closes = 24 * 60
+	}
+	for _, h := range c.Hours {
+		if h.Weekday == from.Weekday() && h.Opens <= opens && closes <= h.Closes {
+			return nil
+		}
+	}
+	return closed
+}
+
+// CheckWorkshop checks the sessions of ws against the calendar, except
+// those that kept their times from before, which were accepted when they
+// were scheduled.
+func (c Calendar) CheckWorkshop(ws Workshop, before []Session) error {
+	kept := make(map[[2]int64]bool)
+	for _, s := range before {
+		kept[[2]int64{s.StartTime.Unix(), s.EndTime.Unix()}] = true
+	}
+	for _, s := range ws.SessionList() {
+		if kept[[2]int64{s.StartTime.Unix(), s.EndTime.Unix()}] {
+			continue
+		}
+		if err := c.Check(s.StartTime, s.EndTime, ws.Timezone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckEvent checks e against the calendar unless it keeps the times it
+// had before.
+func (c Calendar) CheckEvent(e, before Event) error {
+	if e.StartTime.Equal(before.StartTime) && e.EndTime.Equal(before.EndTime) {
+		return nil
+	}
+	return c.Check(e.StartTime, e.EndTime, e.Timezone)
+}
+
+// Schedule drops the occurrences of a series that meet on a day the studio
+// is closed and checks the rest against the calendar.
+func (c Calendar) Schedule(occurrences []Workshop) ([]Workshop, error) {
+	var open []Workshop
+	for _, ws := range occurrences {
+		if c.meetsWhileClosed(ws) {
+			continue
+		}
+		if err := c.CheckWorkshop(ws, nil); err != nil {
+			return nil, err
+		}
+		open = append(open, ws)
+	}
+	return open, nil
+}
+
+func (c Calendar) meetsWhileClosed(ws Workshop) bool {
+	for _, s := range ws.SessionList() {
+		for _, d := range days(s.StartTime, s.EndTime, ws.Timezone) {
+			if c.ClosedOn(d) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package workshop
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateOpeningHours(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		hours []OpeningHours
+		want  error
+	}{
+		{"none", nil, nil},
+		{"split day", []OpeningHours{{time.Monday, 9 * 60, 12 * 60}, {time.Monday, 12 * 60, 18 * 60}}, nil},
+		{"until midnight", []OpeningHours{{time.Friday, 18 * 60, 24 * 60}}, nil},
+		{"past midnight", []OpeningHours{{time.Friday, 18 * 60, 24*60 + 1}}, ErrInvalidOpeningHours},
+		{"closing before opening", []OpeningHours{{time.Monday, 18 * 60, 9 * 60}}, ErrInvalidOpeningHours},
+		{"closing as it opens", []OpeningHours{{time.Monday, 9 * 60, 9 * 60}}, ErrInvalidOpeningHours},
+		{"overlapping", []OpeningHours{{time.Monday, 9 * 60, 13 * 60}, {time.Monday, 12 * 60, 18 * 60}}, ErrInvalidOpeningHours},
+		{"same hours on other days", []OpeningHours{{time.Monday, 9 * 60, 18 * 60}, {time.Tuesday, 9 * 60, 18 * 60}}, nil},
+		{"no such weekday", []OpeningHours{{time.Weekday(7), 9 * 60, 18 * 60}}, ErrInvalidOpeningHours},
+		{"negative", []OpeningHours{{time.Monday, -60, 18 * 60}}, ErrInvalidOpeningHours},
+	} {
+		if err := ValidateOpeningHours(c.hours); err != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestClosureValidate(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Closure{From: time.Date(2030, 12, 24, 0, 0, 0, 0, berlin), Until: time.Date(2031, 1, 1, 23, 0, 0, 0, time.UTC), Reason: " holidays "}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.From.Equal(time.Date(2030, 12, 24, 0, 0, 0, 0, time.UTC)) || !c.Until.Equal(time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)) || c.Reason != "holidays" {
+		t.Errorf("got %+v, want the dates as midnight UTC and the reason trimmed", c)
+	}
+	day := Closure{From: c.From, Until: c.From}
+	if err := day.Validate(); err != nil {
+		t.Errorf("one day closure: %v", err)
+	}
+	for _, bad := range []Closure{
+		{From: c.Until, Until: c.From},
+		{From: c.From},
+		{Until: c.Until},
+	} {
+		if err := bad.Validate(); err != ErrInvalidClosure {
+			t.Errorf("%+v: got %v, want %v", bad, err, ErrInvalidClosure)
+		}
+	}
+}
+
+// studio is open on weekdays from nine to six, on Saturdays from ten to two
+// and on Friday evenings until midnight, and closed over Easter 2030.
+var studio = Calendar{
+	Hours: []OpeningHours{
+		{time.Monday, 9 * 60, 18 * 60},
+		{time.Tuesday, 9 * 60, 18 * 60},
+		{time.Wednesday, 9 * 60, 18 * 60},
+		{time.Thursday, 9 * 60, 18 * 60},
+		{time.Friday, 9 * 60, 18 * 60},
+		{time.Friday, 19 * 60, 24 * 60},
+		{time.Saturday, 10 * 60, 14 * 60},
+	},
+	Closures: []Closure{{ID: 1, From: time.Date(2030, 4, 19, 0, 0, 0, 0, time.UTC), Until: time.Date(2030, 4, 22, 0, 0, 0, 0, time.UTC), Reason: "Easter"}},
+}
+
+func TestCalendarCheck(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2030, 4, day, hour, minute, 0, 0, berlin)
+	}
+	for _, c := range []struct {
+		name       string
+		start, end time.Time
+		open       bool
+		closure    bool
+	}{
+		{"within the hours", at(8, 10, 0), at(8, 12, 0), true, false},
+		{"opening to closing", at(8, 9, 0), at(8, 18, 0), true, false},
+		{"before opening", at(8, 8, 30), at(8, 10, 0), false, false},
+		{"after closing", at(8, 17, 0), at(8, 18, 1), false, false},
+		{"across the Friday break", at(12, 17, 0), at(12, 20, 0), false, false},
+		{"Friday until midnight", at(12, 21, 0), at(13, 0, 0), true, false},
+		{"past midnight", at(12, 23, 0), at(13, 1, 0), false, false},
+		{"Saturday", at(13, 10, 0), at(13, 14, 0), true, false},
+		{"Sunday", at(14, 10, 0), at(14, 12, 0), false, false},
+		{"Easter Monday", at(22, 10, 0), at(22, 12, 0), false, true},
+		{"after Easter", at(23, 10, 0), at(23, 12, 0), true, false},
+	} {
+		err := studio.Check(c.start.UTC(), c.end.UTC(), "Europe/Berlin")
+		if c.open {
+			if err != nil {
+				t.Errorf("%s: %v", c.name, err)
+			}
+			continue
+		}
+		closed, ok := err.(*ClosedError)
+		if !ok {
+			t.Errorf("%s: got %v, want a ClosedError", c.name, err)
+			continue
+		}
+		if (closed.Closure != nil) != c.closure || !closed.StartTime.Equal(c.start) {
+			t.Errorf("%s: got %+v", c.name, closed)
+		}
+	}
+	// Without opening hours only closures count.
+	always := Calendar{Closures: studio.Closures}
+	if err := always.Check(at(14, 23, 0).UTC(), at(15, 2, 0).UTC(), "Europe/Berlin"); err != nil {
+		t.Errorf("open around the clock: %v", err)
+	}
+	if err := always.Check(at(18, 23, 0).UTC(), at(19, 2, 0).UTC(), "Europe/Berlin"); err == nil {
+		t.Error("open into Easter")
+	}
+	// Hours are read in the workshop's timezone: 9:30 in Berlin is 3:30
+	// in New York, and 15:30 in Berlin 9:30.
+	if err := studio.Check(at(8, 9, 30).UTC(), at(8, 11, 0).UTC(), "America/New_York"); err == nil {
+		t.Error("open at 3:30 in New York")
+	}
+	if err := studio.Check(at(8, 15, 30).UTC(), at(8, 17, 0).UTC(), "America/New_York"); err != nil {
+		t.Errorf("9:30 in New York: %v", err)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	// The series meets on Mondays from seven to nine in the evening.
+	s := weeklySeries(t, "FREQ=WEEKLY;COUNT=6")
+	s.Template.StartTime = time.Date(2030, 4, 8, 17, 0, 0, 0, time.UTC)
+	s.Template.EndTime = s.Template.StartTime.Add(2 * time.Hour)
+	occurrences, err := s.Occurrences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	evenings := Calendar{
+		Hours:    []OpeningHours{{time.Monday, 18 * 60, 22 * 60}, {time.Tuesday, 18 * 60, 22 * 60}},
+		Closures: studio.Closures,
+	}
+	open, err := evenings.Schedule(occurrences)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dates []string
+	for _, ws := range open {
+		dates = append(dates, ws.LocalStart().Format(DateLayout))
+	}
+	// Easter Monday is skipped.
+	want := []string{"2030-04-08", "2030-04-15", "2030-04-29", "2030-05-06", "2030-05-13"}
+	if len(dates) != len(want) {
+		t.Fatalf("got %v, want %v", dates, want)
+	}
+	for i := range want {
+		if dates[i] != want[i] {
+			t.Errorf("got %v, want %v", dates, want)
+			break
+		}
+	}
+
+	// A studio not open on Mondays skips them all.
+	tuesdays := Calendar{Hours: []OpeningHours{{time.Tuesday, 18 * 60, 22 * 60}}}
+	if open, err := tuesdays.Schedule(occurrences); err != nil || len(open) != 0 {
+		t.Errorf("got %d occurrences and %v, want none", len(open), err)
+	}
+	// Occurrences on open days outside the hours are refused, not skipped.
+	if _, err := studio.Schedule(occurrences); err == nil {
+		t.Error("scheduled evening occurrences in daytime hours")
+	} else if closed, ok := err.(*ClosedError); !ok || closed.Closure != nil {
+		t.Errorf("got %v, want a ClosedError outside the opening hours", err)
+	}
+
+	// An occurrence is skipped when any of its sessions meets while closed.
+	ws := occurrences[0]
+	ws.SetSessions([]Session{
+		{StartTime: ws.StartTime, EndTime: ws.EndTime},
+		{StartTime: time.Date(2030, 4, 22, 17, 0, 0, 0, time.UTC), EndTime: time.Date(2030, 4, 22, 19, 0, 0, 0, time.UTC)},
+	})
+	if open, err := evenings.Schedule([]Workshop{ws}); err != nil || len(open) != 0 {
+		t.Errorf("got %d occurrences and %v, want the one meeting over Easter skipped", len(open), err)
+	}
+}
@@ -0,0 +1,158 @@
+package workshop
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Category groups workshops and events, e.g. ceramics or kids. Categories
+// form a tree through ParentID; an offering in a subcategory also belongs
+// to its ancestors.
+type Category struct {
+	ID int
+	// ParentID is the category this one is nested under, or 0 at the top.
+	ParentID int
+	Name     string
+	// Slug names the category in URLs; it is derived from Name when left
+	// empty.
+	Slug        string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	// WorkshopCount and EventCount are the upcoming workshops and events in
+	// the category or below it. They are filled in on listings only.
+	WorkshopCount int
+	EventCount    int
+}
+
+var (
+	ErrInvalidCategory   = errors.New("category needs a name, a slug of lowercase letters, digits and dashes, and a parent other than itself or its subcategories")
+	ErrInvalidCategories = errors.New("category ids must be positive and listed once")
+	ErrInvalidTags       = errors.New("tags must be at most 50 characters")
+)
+
+var (
+	slugRe    = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	nonSlugRe = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Slugify turns name into a slug, e.g. "Wheel Throwing" into
+// "wheel-throwing".
+func Slugify(name string) string {
+	return strings.Trim(nonSlugRe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// Validate trims the fields of c, derives its slug from its name if it has
+// none, and checks both.
+func (c *Category) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Description = strings.TrimSpace(c.Description)
+	c.Slug = strings.TrimSpace(c.Slug)
+	if c.Slug == "" {
+		c.Slug = Slugify(c.Name)
+	}
+	if c.Name == "" || !slugRe.MatchString(c.Slug) || c.ParentID < 0 || (c.ID != 0 && c.ParentID == c.ID) {
+		return ErrInvalidCategory
+	}
+	return nil
+}
+
+// Subtree returns the ids of the category id and of every category below
+// it.
+func Subtree(categories []Category, id int) []int {
+	children := make(map[int][]int)
+	for _, c := range categories {
+		children[c.ParentID] = append(children[c.ParentID], c.ID)
+	}
+	var out []int
+	seen := make(map[int]bool)
+	next := []int{id}
+	for len(next) > 0 {
+		cur := next[0]
+		next = next[1:]
+		if seen[cur] {
+			continue
+		}
+		seen[cur] = true
+		out = append(out, cur)
+		next = append(next, children[cur]...)
+	}
+	return out
+}
+
+// Ancestry returns ids with the ids of every category above them.
+func Ancestry(categories []Category, ids []int) []int {
+	parent := make(map[int]int)
+	for _, c := range categories {
+		parent[c.ID] = c.ParentID
+	}
+	var out []int
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		for id != 0 && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+			id = parent[id]
+		}
+	}
+	return out
+}
+
+// CountOfferings fills in the counts of categories from the upcoming
+// workshops and events assigned to each category id. An offering in
+// several categories of one subtree is counted once.
+func CountOfferings(categories []Category, workshops, events map[int][]string) {
+	count := func(ids []int, assigned map[int][]string) int {
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			for _, o := range assigned[id] {
+				seen[o] = true
+			}
+		}
+		return len(seen)
+	}
+	for i, c := range categories {
+		ids := Subtree(categories, c.ID)
+		categories[i].WorkshopCount = count(ids, workshops)
+		categories[i].EventCount = count(ids, events)
+	}
+}
+
+// ValidateCategoryIDs rejects non-positive and repeated category ids.
+func ValidateCategoryIDs(ids []int) error {
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if id <= 0 || seen[id] {
+			return ErrInvalidCategories
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// NormalizeTags lowercases tags and collapses their whitespace, dropping
+// empty and repeated ones. Nil stays nil, so that saving it keeps the
+// current tags.
+func NormalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	out := []string{}
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		t = strings.Join(strings.Fields(strings.ToLower(t)), " ")
+		if len(t) > 50 {
+			return nil, ErrInvalidTags
+		}
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out, nil
+}
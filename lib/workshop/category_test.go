@@ -0,0 +1,167 @@
+package workshop
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	for name, want := range map[string]string{
+		"Wheel Throwing":     "wheel-throwing",
+		"  Kids & Teens  ":   "kids-teens",
+		"Raku--Firing!":      "raku-firing",
+		"Töpfern":            "t-pfern",
+		"3D Printing (2030)": "3d-printing-2030",
+		"!!!":                "",
+	} {
+		if got := Slugify(name); got != want {
+			t.Errorf("%q: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCategoryValidate(t *testing.T) {
+	c := Category{Name: " Wheel Throwing ", Description: " On the wheel. "}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "Wheel Throwing" || c.Slug != "wheel-throwing" || c.Description != "On the wheel." {
+		t.Errorf("got %+v, want it trimmed with a slug from its name", c)
+	}
+	for _, c := range []Category{
+		{Name: " "},
+		{Name: "!!!"},
+		{Name: "Kids", Slug: "Kids"},
+		{Name: "Kids", Slug: "kids--teens"},
+		{Name: "Kids", Slug: "-kids"},
+		{Name: "Kids", ParentID: -1},
+		{ID: 3, Name: "Kids", ParentID: 3},
+	} {
+		if err := c.Validate(); err != ErrInvalidCategory {
+			t.Errorf("%+v: got %v, want %v", c, err, ErrInvalidCategory)
+		}
+	}
+}
+
+// categoryTree is
+//
+//	1 ceramics
+//	├─ 2 wheel throwing
+//	│  └─ 4 raku
+//	└─ 3 glazing
+//	5 kids
+var categoryTree = []Category{
+	{ID: 1, Name: "Ceramics"},
+	{ID: 2, ParentID: 1, Name: "Wheel throwing"},
+	{ID: 3, ParentID: 1, Name: "Glazing"},
+	{ID: 4, ParentID: 2, Name: "Raku"},
+	{ID: 5, Name: "Kids"},
+}
+
+func sortedIDs(ids []int) string {
+	ids = append([]int(nil), ids...)
+	sort.Ints(ids)
+	return fmt.Sprint(ids)
+}
+
+func TestSubtree(t *testing.T) {
+	for id, want := range map[int]string{
+		1:  "[1 2 3 4]",
+		2:  "[2 4]",
+		4:  "[4]",
+		5:  "[5]",
+		99: "[99]",
+	} {
+		if got := sortedIDs(Subtree(categoryTree, id)); got != want {
+			t.Errorf("%d: got %s, want %s", id, got, want)
+		}
+	}
+	// A cycle, which saving prevents, does not loop forever.
+	cycle := []Category{{ID: 1, ParentID: 2}, {ID: 2, ParentID: 1}}
+	if got := sortedIDs(Subtree(cycle, 1)); got != "[1 2]" {
+		t.Errorf("cycle: got %s", got)
+	}
+}
+
+func TestAncestry(t *testing.T) {
+	for _, c := range []struct {
+		ids  []int
+		want string
+	}{
+		{nil, "[]"},
+		{[]int{4}, "[1 2 4]"},
+		{[]int{3, 4}, "[1 2 3 4]"},
+		{[]int{4, 5}, "[1 2 4 5]"},
+		{[]int{1}, "[1]"},
+	} {
+		if got := sortedIDs(Ancestry(categoryTree, c.ids)); got != c.want {
+			t.Errorf("%v: got %s, want %s", c.ids, got, c.want)
+		}
+	}
+	cycle := []Category{{ID: 1, ParentID: 2}, {ID: 2, ParentID: 1}}
+	if got := sortedIDs(Ancestry(cycle, []int{1})); got != "[1 2]" {
+		t.Errorf("cycle: got %s", got)
+	}
+}
+
+func TestCountOfferings(t *testing.T) {
+	categories := append([]Category(nil), categoryTree...)
+	workshops := map[int][]string{
+		// raku-1 is filed under both raku and ceramics, and counts once.
+		1: {"raku-1"},
+		4: {"raku-1", "raku-2"},
+		3: {"glaze-1"},
+	}
+	events := map[int][]string{5: {"open-day"}, 2: {"demo"}}
+	CountOfferings(categories, workshops, events)
+	var got []string
+	for _, c := range categories {
+		got = append(got, fmt.Sprintf("%d:%d/%d", c.ID, c.WorkshopCount, c.EventCount))
+	}
+	if want := "1:3/1 2:2/1 3:1/0 4:2/0 5:0/1"; strings.Join(got, " ") != want {
+		t.Errorf("got %s, want %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestValidateCategoryIDs(t *testing.T) {
+	for _, c := range []struct {
+		ids  []int
+		want error
+	}{
+		{nil, nil},
+		{[]int{}, nil},
+		{[]int{1, 4}, nil},
+		{[]int{1, 1}, ErrInvalidCategories},
+		{[]int{0}, ErrInvalidCategories},
+		{[]int{-2}, ErrInvalidCategories},
+	} {
+		if err := ValidateCategoryIDs(c.ids); err != c.want {
+			t.Errorf("%v: got %v, want %v", c.ids, err, c.want)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	for _, c := range []struct {
+		tags []string
+		want []string
+		err  error
+	}{
+		{nil, nil, nil},
+		{[]string{}, []string{}, nil},
+		{[]string{"  Raku ", "beginner", "RAKU", ""}, []string{"beginner", "raku"}, nil},
+		{[]string{"Evening \t  Class"}, []string{"evening class"}, nil},
+		{[]string{" "}, []string{}, nil},
+		{[]string{strings.Repeat("a", 50)}, []string{strings.Repeat("a", 50)}, nil},
+		{[]string{strings.Repeat("a", 51)}, nil, ErrInvalidTags},
+		// Whitespace collapses before the length is checked.
+		{[]string{strings.Repeat("a", 25) + "    " + strings.Repeat("a", 24)}, []string{strings.Repeat("a", 25) + " " + strings.Repeat("a", 24)}, nil},
+	} {
+		got, err := NormalizeTags(c.tags)
+		if err != c.err || (got == nil) != (c.want == nil) || fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%q: got %q, %v; want %q, %v", c.tags, got, err, c.want, c.err)
+		}
+	}
+}
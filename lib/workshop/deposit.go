@@ -0,0 +1,54 @@
+package workshop
+
+import (
+	"errors"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+var ErrInvalidDeposit = errors.New("deposits need to be positive and no more than the price, with a balance due date no later than the start")
+
+// TakesDeposit reports whether signups only pay a deposit at booking.
+func (w Workshop) TakesDeposit() bool {
+	return w.Deposit.Amount > 0
+}
+
+// ValidateDeposit checks the deposit terms of w against its price and
+// start.
+func (w Workshop) ValidateDeposit() error {
+	if !w.TakesDeposit() {
+		if w.Deposit.Amount < 0 || !w.BalanceDueAt.IsZero() || w.ReleaseUnpaid {
+			return ErrInvalidDeposit
+		}
+		return nil
+	}
+	if w.Deposit.Currency != w.Price.Currency || w.Deposit.Amount > w.Price.Amount || w.BalanceDueAt.IsZero() || w.BalanceDueAt.After(w.StartTime) {
+		return ErrInvalidDeposit
+	}
+	return nil
+}
+
+// DepositFor is what a signup owing due pays when booking at t: the deposit,
+// or all of it when there is none or the balance is already due.
+func (w Workshop) DepositFor(due money.Money, at time.Time) money.Money {
+	if !w.TakesDeposit() || !at.Before(w.BalanceDueAt) || w.Deposit.Amount >= due.Amount {
+		return due
+	}
+	return money.New(w.Deposit.Amount, due.Currency)
+}
+
+// Balance is what the signup still owes: its price, less the voucher and
+// what was paid so far.
+func (s SignUp) Balance() money.Money {
+	due := s.Due()
+	if s.AmountPaid.Amount >= due.Amount {
+		return money.New(0, due.Currency)
+	}
+	return money.New(due.Amount-s.AmountPaid.Amount, due.Currency)
+}
+
+// LocalBalanceDue is the balance due date in the workshop's timezone.
+func (w Workshop) LocalBalanceDue() time.Time {
+	return inZone(w.BalanceDueAt, w.Timezone)
+}
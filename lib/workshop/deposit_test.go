@@ -0,0 +1,101 @@
+package workshop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+func depositWorkshop() Workshop {
+	start := time.Date(2030, 6, 1, 10, 0, 0, 0, time.UTC)
+	return Workshop{
+		StartTime:    start,
+		EndTime:      start.Add(2 * time.Hour),
+		Timezone:     "Europe/Berlin",
+		Price:        money.New(10000, "EUR"),
+		Deposit:      money.New(3000, "EUR"),
+		BalanceDueAt: start.AddDate(0, 0, -14),
+	}
+}
+
+func TestValidateDeposit(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		change func(*Workshop)
+		want   error
+	}{
+		{"deposit", func(w *Workshop) {}, nil},
+		{"deposit of the whole price", func(w *Workshop) { w.Deposit = w.Price }, nil},
+		{"balance due at the start", func(w *Workshop) { w.BalanceDueAt = w.StartTime }, nil},
+		{"no deposit", func(w *Workshop) { w.Deposit, w.BalanceDueAt = money.Money{}, time.Time{} }, nil},
+		{"more than the price", func(w *Workshop) { w.Deposit = money.New(10001, "EUR") }, ErrInvalidDeposit},
+		{"another currency", func(w *Workshop) { w.Deposit = money.New(3000, "CHF") }, ErrInvalidDeposit},
+		{"no due date", func(w *Workshop) { w.BalanceDueAt = time.Time{} }, ErrInvalidDeposit},
+		{"due after the start", func(w *Workshop) { w.BalanceDueAt = w.StartTime.Add(time.Second) }, ErrInvalidDeposit},
+		{"negative", func(w *Workshop) { w.Deposit = money.New(-1, "EUR") }, ErrInvalidDeposit},
+		{"due date without a deposit", func(w *Workshop) { w.Deposit = money.Money{} }, ErrInvalidDeposit},
+		{"releasing without a deposit", func(w *Workshop) { w.Deposit, w.BalanceDueAt, w.ReleaseUnpaid = money.Money{}, time.Time{}, true }, ErrInvalidDeposit},
+	} {
+		w := depositWorkshop()
+		c.change(&w)
+		if err := w.ValidateDeposit(); err != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestDepositFor(t *testing.T) {
+	w := depositWorkshop()
+	early := w.BalanceDueAt.Add(-time.Second)
+	for _, c := range []struct {
+		name string
+		w    Workshop
+		due  int64
+		at   time.Time
+		want int64
+	}{
+		{"before the balance is due", w, 10000, early, 3000},
+		{"once the balance is due", w, 10000, w.BalanceDueAt, 10000},
+		{"discounted below the deposit", w, 2500, early, 2500},
+		{"discounted to the deposit", w, 3000, early, 3000},
+		{"no deposit", Workshop{Price: w.Price}, 10000, early, 10000},
+	} {
+		if got := c.w.DepositFor(money.New(c.due, "EUR"), c.at); got != money.New(c.want, "EUR") {
+			t.Errorf("%s: got %v, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestBalance(t *testing.T) {
+	for _, c := range []struct {
+		name           string
+		voucher, paid  int64
+		balance, owing int64
+	}{
+		{"nothing paid", 0, 0, 10000, 10000},
+		{"deposit paid", 0, 3000, 7000, 10000},
+		{"paid in full", 0, 10000, 0, 10000},
+		{"paid more", 0, 12000, 0, 10000},
+		{"voucher and deposit", 2500, 3000, 4500, 7500},
+		{"voucher covering the price", 10000, 0, 0, 0},
+	} {
+		su := SignUp{Price: money.New(10000, "EUR"), AmountPaid: money.New(c.paid, "EUR")}
+		if c.voucher > 0 {
+			su.VoucherCode, su.VoucherAmount = "GIFT", money.New(c.voucher, "EUR")
+		}
+		if got := su.Balance(); got != money.New(c.balance, "EUR") {
+			t.Errorf("%s: got balance %v, want %d", c.name, got, c.balance)
+		}
+		if got := su.Due(); got != money.New(c.owing, "EUR") {
+			t.Errorf("%s: got %v due, want %d", c.name, got, c.owing)
+		}
+	}
+}
+
+func TestLocalBalanceDue(t *testing.T) {
+	w := depositWorkshop()
+	if got := w.LocalBalanceDue().Format("2006-01-02 15:04 MST"); got != "2030-05-18 12:00 CEST" {
+		t.Errorf("got %s, want the due date in Berlin", got)
+	}
+}
@@ -0,0 +1,139 @@
+package workshop
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+// DiscountKind says how a discount code reduces a price.
+type DiscountKind string
+
+const (
+	// DiscountPercent takes Percent percent off the price.
+	DiscountPercent DiscountKind = "percent"
+	// DiscountFixed takes Amount off the price, down to zero.
+	DiscountFixed DiscountKind = "fixed"
+)
+
+// DiscountCode is a promotion code that can be given at signup.
+type DiscountCode struct {
+	Code    string
+	Kind    DiscountKind
+	Percent int
+	Amount  money.Money
+	// MaxRedemptions caps how often the code can be used; zero is no cap.
+	MaxRedemptions int
+	Redemptions    int
+	// ExpiresAt is the moment the code stops working; the zero time never
+	// expires.
+	ExpiresAt time.Time
+	// WorkshopIDs and CategoryIDs restrict the code to those workshops and
+	// to the workshops filed under those categories or below them; with
+	// both empty it applies to all.
+	WorkshopIDs []string
+	CategoryIDs []int
+	CreatedAt   time.Time
+}
+
+// Redemption records a discount code used on a signup.
+type Redemption struct {
+	Code       string
+	SignUpID   int
+	WorkshopID string
+	Discount   money.Money
+	RedeemedAt time.Time
+}
+
+var (
+	ErrInvalidDiscountCode = errors.New("discount codes need a code, a percentage between 1 and 100 or a positive fixed amount, and a non-negative redemption limit")
+	ErrCodeUnknown         = errors.New("unknown discount code")
+	ErrCodeExpired         = errors.New("discount code has expired")
+	ErrCodeExhausted       = errors.New("discount code has been used up")
+	ErrCodeNotApplicable   = errors.New("discount code does not apply to this workshop")
+)
+
+// NormalizeCode is the form codes are stored and looked up in.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate checks a code before it is created.
+func (d DiscountCode) Validate() error {
+	switch {
+	case d.Code == "" || strings.ContainsAny(d.Code, ", "),
+		d.MaxRedemptions < 0,
+		d.Kind == DiscountPercent && (d.Percent < 1 || d.Percent > 100),
+		d.Kind == DiscountFixed && d.Amount.Amount <= 0,
+		d.Kind != DiscountPercent && d.Kind != DiscountFixed,
+		ValidateCategoryIDs(d.CategoryIDs) != nil:
+		return ErrInvalidDiscountCode
+	}
+	return nil
+}
+
+// Apply returns the discount the code gives on price for workshopID at t, or
+// why it cannot be used. categoryIDs are the categories of the workshop and
+// their ancestors.
+func (d DiscountCode) Apply(workshopID string, categoryIDs []int, price money.Money, at time.Time) (money.Money, error) {
+	if !d.ExpiresAt.IsZero() && !at.Before(d.ExpiresAt) {
+		return money.Money{}, ErrCodeExpired
+	}
+	if d.MaxRedemptions > 0 && d.Redemptions >= d.MaxRedemptions {
+		return money.Money{}, ErrCodeExhausted
+	}
+	if !d.appliesTo(workshopID, categoryIDs) {
+		return money.Money{}, ErrCodeNotApplicable
+	}
+	switch d.Kind {
+	case DiscountPercent:
+		// Round half up, in the currency's minor units.
+		return money.New((price.Amount*int64(d.Percent)+50)/100, price.Currency), nil
+	case DiscountFixed:
+		if d.Amount.Currency != price.Currency {
+			return money.Money{}, ErrCodeNotApplicable
+		}
+		if d.Amount.Amount > price.Amount {
+			return price, nil
+		}
+		return d.Amount, nil
+	}
+	return money.Money{}, ErrInvalidDiscountCode
+}
+
+// IsDiscountError reports whether err is why a code could not be redeemed.
+func IsDiscountError(err error) bool {
+	switch err {
+	case ErrCodeUnknown, ErrCodeExpired, ErrCodeExhausted, ErrCodeNotApplicable:
+		return true
+	}
+	return false
+}
+
+func (d DiscountCode) appliesTo(workshopID string, categoryIDs []int) bool {
+	if len(d.WorkshopIDs) == 0 && len(d.CategoryIDs) == 0 {
+		return true
+	}
+	if contains(d.WorkshopIDs, workshopID) {
+		return true
+	}
+	for _, id := range categoryIDs {
+		for _, want := range d.CategoryIDs {
+			if id == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
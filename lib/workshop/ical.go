@@ -0,0 +1,69 @@
+package workshop
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const icalLayout = "20060102T150405Z"
+
+// ICalendar renders the sessions of ws as an iCalendar (RFC 5545) calendar
+// with one event per session. Times are written in UTC so no VTIMEZONE is
+// needed.
+func ICalendar(ws Workshop, now time.Time) string {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		b.WriteString(foldLine(fmt.Sprintf(format, args...)))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//workshop//workshops//EN")
+	line("CALSCALE:GREGORIAN")
+	sessions := ws.SessionList()
+	for _, s := range sessions {
+		summary := ws.Name
+		if len(sessions) > 1 {
+			summary = fmt.Sprintf("%s (%d/%d)", ws.Name, s.Position, len(sessions))
+		}
+		line("BEGIN:VEVENT")
+		line("UID:%s-%d@workshop", ws.WorkshopID, s.ID)
+		line("DTSTAMP:%s", now.UTC().Format(icalLayout))
+		line("DTSTART:%s", s.StartTime.UTC().Format(icalLayout))
+		line("DTEND:%s", s.EndTime.UTC().Format(icalLayout))
+		line("SUMMARY:%s", icalEscape(summary))
+		if ws.Description != "" {
+			line("DESCRIPTION:%s", icalEscape(ws.Description))
+		}
+		if loc := ws.SessionLocation(s); loc != "" {
+			line("LOCATION:%s", icalEscape(loc))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icalEscape(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+// foldLine splits a content line into lines of at most 75 octets, without
+// breaking UTF-8 sequences; continuation lines start with a space.
+func foldLine(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package workshop
+
+import (
+	"errors"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Instructor teaches workshops. PhotoKey is the key of a photo uploaded
+// through the S3 upload flow, e.g. "/instructors/anna.jpg".
+type Instructor struct {
+	ID       int
+	Name     string
+	Bio      string
+	PhotoKey string
+	Email    string
+	Phone    string
+	Website  string
+	// CreatedAt and UpdatedAt are set by the repository.
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+var (
+	ErrInvalidInstructor = errors.New("instructor needs a name, and a valid email and website when they are set")
+	// ErrInvalidInstructors rejects a workshop that lists an instructor
+	// more than once.
+	ErrInvalidInstructors = errors.New("instructors must be listed once each")
+)
+
+// Validate trims the fields of i and checks that it has a name and that its
+// email address and website, if any, are well formed.
+func (i *Instructor) Validate() error {
+	i.Name = strings.TrimSpace(i.Name)
+	i.Email = strings.TrimSpace(i.Email)
+	i.Phone = strings.TrimSpace(i.Phone)
+	i.Website = strings.TrimSpace(i.Website)
+	i.PhotoKey = strings.TrimSpace(i.PhotoKey)
+	if i.Name == "" {
+		return ErrInvalidInstructor
+	}
+	if i.Email != "" {
+		if a, err := mail.ParseAddress(i.Email); err != nil || a.Address != i.Email {
+			return ErrInvalidInstructor
+		}
+	}
+	if i.Website != "" {
+		u, err := url.Parse(i.Website)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidInstructor
+		}
+	}
+	return nil
+}
+
+// ValidateInstructors checks that the instructors assigned to w are listed
+// once each.
+func (w Workshop) ValidateInstructors() error {
+	seen := make(map[int]bool)
+	for _, id := range w.InstructorIDs {
+		if id <= 0 || seen[id] {
+			return ErrInvalidInstructors
+		}
+		seen[id] = true
+	}
+	return nil
+}
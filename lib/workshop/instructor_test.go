@@ -0,0 +1,42 @@
+package workshop
+
+import "testing"
+
+func TestInstructorValidate(t *testing.T) {
+	in := Instructor{Name: "  Anna Weber ", Email: " anna@example.com", Website: "https://anna.example.com ", PhotoKey: " /instructors/anna.jpg"}
+	if err := in.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if in.Name != "Anna Weber" || in.Email != "anna@example.com" || in.Website != "https://anna.example.com" || in.PhotoKey != "/instructors/anna.jpg" {
+		t.Errorf("got %+v, want the fields trimmed", in)
+	}
+	for _, bad := range []Instructor{
+		{Name: " "},
+		{Name: "Anna", Email: "anna"},
+		{Name: "Anna", Email: "Anna <anna@example.com>"},
+		{Name: "Anna", Website: "anna.example.com"},
+		{Name: "Anna", Website: "ftp://anna.example.com"},
+		{Name: "Anna", Website: "https://"},
+	} {
+		if err := bad.Validate(); err != ErrInvalidInstructor {
+			t.Errorf("%+v: got %v, want %v", bad, err, ErrInvalidInstructor)
+		}
+	}
+}
+
+func TestValidateInstructors(t *testing.T) {
+	for _, c := range []struct {
+		ids  []int
+		want error
+	}{
+		{nil, nil},
+		{[]int{2, 1}, nil},
+		{[]int{1, 2, 1}, ErrInvalidInstructors},
+		{[]int{0}, ErrInvalidInstructors},
+		{[]int{-3}, ErrInvalidInstructors},
+	} {
+		if err := (Workshop{InstructorIDs: c.ids}).ValidateInstructors(); err != c.want {
+			t.Errorf("%v: got %v, want %v", c.ids, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package workshop
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/workshop/lib/money"
+	"github.com/workshop/lib/pdf"
+)
+
+// StandardVATRate is the German standard VAT rate in basis points.
+const StandardVATRate = 1900
+
+// Seller is who invoices are issued by.
+type Seller struct {
+	Name string
+	// Address is the postal address, one line per line.
+	Address string
+	Email   string
+	// VATID is the Umsatzsteuer-Identifikationsnummer and TaxNumber the
+	// Steuernummer; invoices need at least one of them.
+	VATID     string
+	TaxNumber string
+}
+
+// Buyer is who an invoice is addressed to. Company and Address are only
+// known when a company asks for an invoice for its staff.
+type Buyer struct {
+	Name    string
+	Email   string
+	Company string
+	Address string
+}
+
+// Invoice is the bill for one paid signup. It is a snapshot of everything
+// printed on it, so it never changes once issued, and is numbered without
+// gaps per calendar year.
+type Invoice struct {
+	ID         int
+	Number     string
+	SignUpID   int
+	WorkshopID string
+	IssuedAt   time.Time
+	// ServiceDate is the day the workshop takes place, in its timezone.
+	ServiceDate time.Time
+	Seller      Seller
+	Buyer       Buyer
+	Description string
+	// VATRate is in basis points, e.g. 1900 for 19 %. Gross is what was
+	// paid; Net and VAT are worked out from it.
+	VATRate int
+	Net     money.Money
+	VAT     money.Money
+	Gross   money.Money
+}
+
+var (
+	ErrInvoiceExists     = errors.New("the signup has already been invoiced")
+	ErrInvalidSeller     = errors.New("invoices need a seller name, address and VAT ID or tax number")
+	ErrNothingToInvoice  = errors.New("only confirmed signups with a price can be invoiced")
+	ErrInvalidVATRate    = errors.New("VAT rates are between 0 and 10000 basis points")
+	invoiceNumberPattern = "RE-%d-%05d"
+)
+
+func (s Seller) Validate() error {
+	if s.Name == "" || s.Address == "" || s.VATID == "" && s.TaxNumber == "" {
+		return ErrInvalidSeller
+	}
+	return nil
+}
+
+// InvoiceNumber is the seq'th invoice number of year, e.g. RE-2024-00042.
+func InvoiceNumber(year, seq int) string {
+	return fmt.Sprintf(invoiceNumberPattern, year, seq)
+}
+
+// SplitVAT splits a gross amount into the net amount and the VAT included
+// at rate basis points, rounding the net amount half up.
+func SplitVAT(gross money.Money, rate int) (net, vat money.Money) {
+	net = money.New((gross.Amount*10000*2+int64(10000+rate))/(int64(10000+rate)*2), gross.Currency)
+	vat, _ = gross.Sub(net)
+	return net, vat
+}
+
+// NewInvoice drafts the invoice for su on ws, which is numbered when it is
+// stored.
+func NewInvoice(seller Seller, rate int, su SignUp, ws Workshop, buyer Buyer, at time.Time) (Invoice, error) {
+	if err := seller.Validate(); err != nil {
+		return Invoice{}, err
+	}
+	if rate < 0 || rate > 10000 {
+		return Invoice{}, ErrInvalidVATRate
+	}
+	if su.Status != StatusConfirmed && su.Status != StatusAttended && su.Status != StatusNoShow || su.Price.Amount <= 0 {
+		return Invoice{}, ErrNothingToInvoice
+	}
+	if buyer.Name == "" {
+		buyer.Name = strings.TrimSpace(su.FirstName + " " + su.LastName)
+	}
+	if buyer.Email == "" {
+		buyer.Email = su.Email
+	}
+	start := ws.LocalStart()
+	description := fmt.Sprintf("Workshop %q, %s", ws.Name, start.Format("02.01.2006 15:04"))
+	if su.PriceTier != "" && su.PriceTier != StandardTier {
+		description += ", " + su.PriceTier
+	}
+	if su.DiscountCode != "" {
+		description += fmt.Sprintf(", discount %s -%s", su.DiscountCode, su.Discount.Decimal())
+	}
+	if su.VoucherCode != "" {
+		description += fmt.Sprintf(", %s paid with voucher %s", su.VoucherAmount.Decimal(), su.VoucherCode)
+	}
+	inv := Invoice{
+		SignUpID:    su.ID,
+		WorkshopID:  ws.WorkshopID,
+		IssuedAt:    at.UTC(),
+		ServiceDate: time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC),
+		Seller:      seller,
+		Buyer:       buyer,
+		Description: description,
+		VATRate:     rate,
+		Gross:       su.Price,
+	}
+	inv.Net, inv.VAT = SplitVAT(su.Price, rate)
+	return inv, nil
+}
+
+// FormatRate renders a rate in basis points as a percentage, e.g. "19 %".
+func FormatRate(rate int) string {
+	s := fmt.Sprintf("%d", rate/100)
+	if rate%100 != 0 {
+		s += fmt.Sprintf(",%02d", rate%100)
+		s = strings.TrimRight(s, "0")
+	}
+	return s + " %"
+}
+
+// germanAmount renders m the German way, e.g. "1.234,50 EUR".
+func germanAmount(m money.Money) string {
+	d := m.Decimal()
+	sign := ""
+	if strings.HasPrefix(d, "-") {
+		sign, d = "-", d[1:]
+	}
+	whole, frac := d, ""
+	if i := strings.IndexByte(d, '.'); i >= 0 {
+		whole, frac = d[:i], ","+d[i+1:]
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "." + whole[i:]
+	}
+	return sign + whole + frac + " " + m.Currency
+}
+
+// PDF renders the invoice. It only depends on the invoice, so an invoice
+// renders to the same document every time.
+func (inv Invoice) PDF() []byte {
+	const (
+		left  = 56.0
+		right = pdf.PageWidth - 56
+	)
+	d := pdf.New()
+	y := pdf.PageHeight - 64
+	line := func(x float64, font pdf.Font, size float64, s string) {
+		d.Text(x, y, font, size, s)
+		y -= size * 1.4
+	}
+
+	d.Text(left, y, pdf.Bold, 14, inv.Seller.Name)
+	y -= 18
+	for _, l := range strings.Split(inv.Seller.Address, "\n") {
+		line(left, pdf.Regular, 9, l)
+	}
+	if inv.Seller.Email != "" {
+		line(left, pdf.Regular, 9, inv.Seller.Email)
+	}
+
+	y -= 24
+	if inv.Buyer.Company != "" {
+		line(left, pdf.Regular, 10, inv.Buyer.Company)
+	}
+	line(left, pdf.Regular, 10, inv.Buyer.Name)
+	for _, l := range strings.Split(inv.Buyer.Address, "\n") {
+		if l != "" {
+			line(left, pdf.Regular, 10, l)
+		}
+	}
+	line(left, pdf.Regular, 10, inv.Buyer.Email)
+
+	y -= 24
+	d.Text(left, y, pdf.Bold, 16, "Rechnung "+inv.Number)
+	y -= 24
+	for _, kv := range [][2]string{
+		{"Rechnungsdatum", inv.IssuedAt.Format("02.01.2006")},
+		{"Leistungsdatum", inv.ServiceDate.Format("02.01.2006")},
+		{"Rechnungsnummer", inv.Number},
+	} {
+		d.Text(left, y, pdf.Regular, 10, kv[0]+":")
+		d.Text(left+110, y, pdf.Regular, 10, kv[1])
+		y -= 14
+	}
+
+	y -= 20
+	d.Text(left, y, pdf.Bold, 10, "Pos.")
+	d.Text(left+36, y, pdf.Bold, 10, "Beschreibung")
+	d.TextRight(right-100, y, pdf.Bold, 10, "Menge")
+	d.TextRight(right, y, pdf.Bold, 10, "Betrag")
+	y -= 6
+	d.Line(left, y, right, y)
+	y -= 14
+	d.Text(left, y, pdf.Regular, 10, "1")
+	d.TextRight(right-100, y, pdf.Regular, 10, "1")
+	d.TextRight(right, y, pdf.Regular, 10, germanAmount(inv.Gross))
+	for _, l := range wrap(inv.Description, pdf.Regular, 10, right-150-(left+36)) {
+		d.Text(left+36, y, pdf.Regular, 10, l)
+		y -= 14
+	}
+	y += 6
+	d.Line(left, y, right, y)
+
+	y -= 18
+	for _, row := range []struct {
+		label  string
+		amount money.Money
+		font   pdf.Font
+	}{
+		{"Nettobetrag", inv.Net, pdf.Regular},
+		{"zzgl. USt. " + FormatRate(inv.VATRate), inv.VAT, pdf.Regular},
+		{"Gesamtbetrag", inv.Gross, pdf.Bold},
+	} {
+		d.TextRight(right-100, y, row.font, 10, row.label)
+		d.TextRight(right, y, row.font, 10, germanAmount(row.amount))
+		y -= 14
+	}
+
+	y -= 20
+	line(left, pdf.Regular, 9, "Der Betrag wurde bereits bezahlt. Vielen Dank!")
+	if inv.VATRate == 0 {
+		line(left, pdf.Regular, 9, "Umsatzsteuerfreie Leistung.")
+	}
+
+	y = 56
+	var ids []string
+	if inv.Seller.VATID != "" {
+		ids = append(ids, "USt-IdNr.: "+inv.Seller.VATID)
+	}
+	if inv.Seller.TaxNumber != "" {
+		ids = append(ids, "Steuernummer: "+inv.Seller.TaxNumber)
+	}
+	d.Line(left, y+12, right, y+12)
+	d.Text(left, y, pdf.Regular, 8, inv.Seller.Name+" | "+strings.Join(ids, " | "))
+	return d.Bytes()
+}
+
+// wrap breaks s into lines no wider than width, between words.
+func wrap(s string, font pdf.Font, size, width float64) []string {
+	var lines []string
+	cur := ""
+	for _, word := range strings.Fields(s) {
+		if cur != "" && pdf.Width(font, size, cur+" "+word) > width {
+			lines = append(lines, cur)
+			cur = ""
+		}
+		if cur != "" {
+			cur += " "
+		}
+		cur += word
+	}
+	return append(lines, cur)
+}
@@ -0,0 +1,131 @@
+package workshop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+func TestSplitVAT(t *testing.T) {
+	for _, c := range []struct {
+		gross    int64
+		rate     int
+		net, vat int64
+	}{
+		{107, 700, 100, 7},
+		{119, 1900, 100, 19},
+		// The net amount is rounded to the nearest cent and the VAT is
+		// what is left, so that the two always add up to what was paid.
+		{1, 700, 1, 0},
+		{1, 1900, 1, 0},
+		{119, 700, 111, 8},
+		{107, 1900, 90, 17},
+		{999, 700, 934, 65},
+		{999, 1900, 839, 160},
+		{1001, 1900, 841, 160},
+		{4999, 700, 4672, 327},
+		{4999, 1900, 4201, 798},
+		{12345, 1900, 10374, 1971},
+		{4500, 0, 4500, 0},
+	} {
+		net, vat := SplitVAT(money.New(c.gross, "EUR"), c.rate)
+		if net != money.New(c.net, "EUR") || vat != money.New(c.vat, "EUR") {
+			t.Errorf("%d at %s: got %v net and %v VAT, want %d and %d", c.gross, FormatRate(c.rate), net, vat, c.net, c.vat)
+		}
+	}
+	if net, vat := SplitVAT(money.New(3000, "JPY"), 1000); net != money.New(2727, "JPY") || vat != money.New(273, "JPY") {
+		t.Errorf("3000 JPY at 10 %%: got %v net and %v VAT", net, vat)
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	for rate, want := range map[int]string{1900: "19 %", 700: "7 %", 0: "0 %", 750: "7,5 %", 1925: "19,25 %"} {
+		if got := FormatRate(rate); got != want {
+			t.Errorf("%d: got %q, want %q", rate, got, want)
+		}
+	}
+}
+
+var testSeller = Seller{Name: "Clay Studio", Address: "Hauptstr. 1|10115 Berlin", VATID: "DE123456789"}
+
+func TestNewInvoice(t *testing.T) {
+	ws := Workshop{
+		WorkshopID: "glazing",
+		Name:       "Glazing",
+		// Half past midnight in Berlin, still the previous day in UTC.
+		StartTime: time.Date(2030, 6, 30, 22, 30, 0, 0, time.UTC),
+		Timezone:  "Europe/Berlin",
+	}
+	su := SignUp{
+		ID:            7,
+		FirstName:     "Ada",
+		LastName:      "Lovelace",
+		Email:         "ada@example.com",
+		Status:        StatusConfirmed,
+		Price:         money.New(4999, "EUR"),
+		PriceTier:     "Student",
+		DiscountCode:  "SPRING",
+		Discount:      money.New(500, "EUR"),
+		VoucherCode:   "GIFT",
+		VoucherAmount: money.New(1000, "EUR"),
+	}
+	at := time.Date(2030, 5, 2, 9, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	inv, err := NewInvoice(testSeller, 700, su, ws, Buyer{}, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.SignUpID != 7 || inv.WorkshopID != "glazing" || inv.Number != "" || inv.IssuedAt != at.UTC() {
+		t.Errorf("got invoice %+v", inv)
+	}
+	if want := time.Date(2030, 7, 1, 0, 0, 0, 0, time.UTC); !inv.ServiceDate.Equal(want) {
+		t.Errorf("got service date %v, want the local day %v", inv.ServiceDate, want)
+	}
+	if inv.Buyer != (Buyer{Name: "Ada Lovelace", Email: "ada@example.com"}) {
+		t.Errorf("got buyer %+v, want the participant", inv.Buyer)
+	}
+	if want := `Workshop "Glazing", 01.07.2030 00:30, Student, discount SPRING -5.00, 10.00 paid with voucher GIFT`; inv.Description != want {
+		t.Errorf("got description %q, want %q", inv.Description, want)
+	}
+	if inv.VATRate != 700 || inv.Gross != su.Price || inv.Net != money.New(4672, "EUR") || inv.VAT != money.New(327, "EUR") {
+		t.Errorf("got %v gross, %v net and %v VAT at %d", inv.Gross, inv.Net, inv.VAT, inv.VATRate)
+	}
+
+	company := Buyer{Name: "Jane Doe", Company: "ACME GmbH", Address: "Weg 2|20095 Hamburg"}
+	inv, err = NewInvoice(testSeller, StandardVATRate, su, ws, company, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Buyer.Name != "Jane Doe" || inv.Buyer.Email != "ada@example.com" || inv.Buyer.Company != "ACME GmbH" {
+		t.Errorf("got buyer %+v, want the company with the participant's email", inv.Buyer)
+	}
+}
+
+func TestNewInvoiceRejects(t *testing.T) {
+	ws := Workshop{WorkshopID: "glazing", StartTime: time.Date(2030, 6, 30, 10, 0, 0, 0, time.UTC)}
+	paid := SignUp{Status: StatusConfirmed, Price: money.New(4500, "EUR")}
+	for _, c := range []struct {
+		name   string
+		seller Seller
+		rate   int
+		su     SignUp
+		want   error
+	}{
+		{"no address", Seller{Name: "Clay Studio", VATID: "DE123456789"}, 1900, paid, ErrInvalidSeller},
+		{"no tax number", Seller{Name: "Clay Studio", Address: "Hauptstr. 1"}, 1900, paid, ErrInvalidSeller},
+		{"negative rate", testSeller, -1, paid, ErrInvalidVATRate},
+		{"rate over 100 %", testSeller, 10001, paid, ErrInvalidVATRate},
+		{"pending", testSeller, 1900, SignUp{Status: StatusPending, Price: paid.Price}, ErrNothingToInvoice},
+		{"cancelled", testSeller, 1900, SignUp{Status: StatusCancelled, Price: paid.Price}, ErrNothingToInvoice},
+		{"free", testSeller, 1900, SignUp{Status: StatusConfirmed, Price: money.New(0, "EUR")}, ErrNothingToInvoice},
+	} {
+		if _, err := NewInvoice(c.seller, c.rate, c.su, ws, Buyer{}, time.Now()); err != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+	for _, s := range []Status{StatusAttended, StatusNoShow} {
+		if _, err := NewInvoice(testSeller, 1900, SignUp{Status: s, Price: paid.Price}, ws, Buyer{}, time.Now()); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package workshop
+
+import (
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+// PaymentStatus is the state of a checkout at the payment provider.
+type PaymentStatus string
+
+const (
+	// PaymentOpen is waiting for the participant to pay.
+	PaymentOpen    PaymentStatus = "open"
+	PaymentPaid    PaymentStatus = "paid"
+	PaymentFailed  PaymentStatus = "failed"
+	PaymentExpired PaymentStatus = "expired"
+	// PaymentMismatched was reported paid for another amount than the
+	// checkout asked for. Its signup is left as it is for an admin to
+	// resolve.
+	PaymentMismatched PaymentStatus = "mismatched"
+)
+
+// Payment is a checkout session taken out for a pending signup.
+type Payment struct {
+	ID        int
+	SignUpID  int
+	Provider  string
+	SessionID string
+	Amount    money.Money
+	Status    PaymentStatus
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Settled reports whether the payment has reached a final state.
+func (s PaymentStatus) Settled() bool {
+	return s == PaymentPaid || s == PaymentFailed || s == PaymentExpired || s == PaymentMismatched
+}
+
+// SignUpStatus is the status a pending signup moves to once its payment
+// reaches s: paid signups are confirmed, the others give up their seat.
+func (s PaymentStatus) SignUpStatus() Status {
+	if s == PaymentPaid {
+		return StatusConfirmed
+	}
+	return StatusCancelled
+}
@@ -0,0 +1,106 @@
+package workshop
+
+import (
+	"errors"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+// StandardTier names the workshop's base price.
+const StandardTier = "standard"
+
+// Eligibility restricts a price tier to people who claim it when signing
+// up. Claims are checked at the door, not by the server.
+type Eligibility string
+
+const (
+	EligibleAnyone     Eligibility = ""
+	EligibleConcession Eligibility = "concession"
+	EligibleMember     Eligibility = "member"
+)
+
+func (e Eligibility) Valid() bool {
+	switch e {
+	case EligibleAnyone, EligibleConcession, EligibleMember:
+		return true
+	}
+	return false
+}
+
+// PriceTier is a named alternative to a workshop's base price, e.g. an
+// early bird price or a concession.
+type PriceTier struct {
+	ID         int
+	WorkshopID string
+	Name       string
+	Price      money.Money
+	// ValidFrom and ValidUntil bound when the tier can be booked; the zero
+	// time leaves that end open. ValidUntil is exclusive.
+	ValidFrom   time.Time
+	ValidUntil  time.Time
+	Eligibility Eligibility
+}
+
+var ErrInvalidPrice = errors.New("prices need a non-negative amount in a supported ISO 4217 currency")
+
+var ErrInvalidPriceTier = errors.New("price tiers need a unique name other than standard, a known eligibility, a non-negative price in the workshop's currency and a validity window that ends after it starts")
+
+// AvailableAt reports whether the tier can be booked at t.
+func (t PriceTier) AvailableAt(at time.Time) bool {
+	return (t.ValidFrom.IsZero() || !at.Before(t.ValidFrom)) && (t.ValidUntil.IsZero() || at.Before(t.ValidUntil))
+}
+
+// EligibleFor reports whether someone making claims may book the tier.
+func (t PriceTier) EligibleFor(claims []Eligibility) bool {
+	if t.Eligibility == EligibleAnyone {
+		return true
+	}
+	for _, c := range claims {
+		if c == t.Eligibility {
+			return true
+		}
+	}
+	return false
+}
+
+// PriceFor returns the cheapest tier someone making claims can book at t,
+// which is the standard price when no other tier applies.
+func (w Workshop) PriceFor(at time.Time, claims []Eligibility) PriceTier {
+	best := PriceTier{WorkshopID: w.WorkshopID, Name: StandardTier, Price: w.Price}
+	for _, t := range w.PriceTiers {
+		if t.AvailableAt(at) && t.EligibleFor(claims) && t.Price.Less(best.Price) {
+			best = t
+		}
+	}
+	return best
+}
+
+// ValidatePrice checks a standard price.
+func ValidatePrice(p money.Money) error {
+	if p.Amount < 0 || !money.ValidCurrency(p.Currency) {
+		return ErrInvalidPrice
+	}
+	return nil
+}
+
+// ValidatePriceTiers checks the base price of w and its price tiers against
+// it.
+func (w Workshop) ValidatePriceTiers() error {
+	if err := ValidatePrice(w.Price); err != nil {
+		return err
+	}
+	names := map[string]bool{StandardTier: true}
+	for _, t := range w.PriceTiers {
+		switch {
+		case t.Name == "" || names[t.Name],
+			!t.Eligibility.Valid(),
+			t.Price.Currency != w.Price.Currency,
+			t.Price.Amount < 0,
+			!t.ValidFrom.IsZero() && !t.ValidUntil.IsZero() && !t.ValidUntil.After(t.ValidFrom):
+			return ErrInvalidPriceTier
+		}
+		names[t.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package workshop
+
+import (
+	"testing"
+
+	"github.com/workshop/lib/money"
+)
+
+func TestValidatePriceTiersChecksStandardPrice(t *testing.T) {
+	for _, c := range []struct {
+		price money.Money
+		want  error
+	}{
+		{money.Money{Amount: 2950, Currency: "EUR"}, nil},
+		{money.Money{Amount: 0, Currency: "CHF"}, nil},
+		{money.Money{Amount: 3000, Currency: "JPY"}, nil},
+		{money.Money{Amount: -1, Currency: "EUR"}, ErrInvalidPrice},
+		{money.Money{Amount: 2950, Currency: "eur"}, ErrInvalidPrice},
+		{money.Money{Amount: 2950, Currency: ""}, ErrInvalidPrice},
+		{money.Money{Amount: 2950, Currency: "EURO"}, ErrInvalidPrice},
+		{money.Money{Amount: 2950, Currency: "XYZ"}, ErrInvalidPrice},
+	} {
+		if err := (Workshop{Price: c.price}).ValidatePriceTiers(); err != c.want {
+			t.Errorf("price %d %q: got %v, want %v", c.price.Amount, c.price.Currency, err, c.want)
+		}
+	}
+}
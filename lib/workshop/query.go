@@ -0,0 +1,216 @@
+package workshop
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter narrows listings of workshops and events. Zero fields do not
+// narrow anything.
+type Filter struct {
+	// Category is the slug of a category; its subcategories are included.
+	Category string
+	// Tags must all be carried.
+	Tags []string
+	// From and To bound the start time, From inclusive and To exclusive.
+	From time.Time
+	To   time.Time
+	// Level matches the level of workshops exactly; events have none and
+	// are left out when it is set.
+	Level string
+	// Location matches part of the location, ignoring case.
+	Location string
+	// VenueID matches offerings booked into a room of the venue.
+	VenueID int
+	// MinPrice and MaxPrice bound the standard price in minor units; nil is
+	// unbounded.
+	MinPrice *int64
+	MaxPrice *int64
+	// Available keeps workshops that are not cancelled and have a free
+	// seat; events have no seats and are left out when it is set.
+	Available bool
+}
+
+// Sort orders listings. A leading "-" sorts descending. Ties are broken by
+// id, so that pages are stable.
+type Sort string
+
+const (
+	SortStart     Sort = "start"
+	SortStartDesc Sort = "-start"
+	SortPrice     Sort = "price"
+	SortPriceDesc Sort = "-price"
+	SortName      Sort = "name"
+	SortNameDesc  Sort = "-name"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// Page selects a page of a listing: up to Limit items in Sort order after
+// the position of Cursor, or from the start without one.
+type Page struct {
+	Sort   Sort
+	Limit  int
+	Cursor string
+}
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter: dates must be RFC 3339 or YYYY-MM-DD with from before to, prices whole minor units with min not above max")
+	ErrInvalidPage   = errors.New("sort must be one of start, -start, price, -price, name or -name and limit between 1 and 200")
+	ErrInvalidCursor = errors.New("cursor is not valid for this listing")
+)
+
+// Validate checks the bounds of f.
+func (f Filter) Validate() error {
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return ErrInvalidFilter
+	}
+	if (f.MinPrice != nil && *f.MinPrice < 0) || (f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice) {
+		return ErrInvalidFilter
+	}
+	if f.VenueID < 0 {
+		return ErrInvalidFilter
+	}
+	return nil
+}
+
+// Validate fills in the default sort and limit and checks p, including
+// that its cursor was made for the same sort.
+func (p *Page) Validate() error {
+	if p.Sort == "" {
+		p.Sort = SortStart
+	}
+	if p.Limit == 0 {
+		p.Limit = DefaultPageSize
+	}
+	switch p.Sort {
+	case SortStart, SortStartDesc, SortPrice, SortPriceDesc, SortName, SortNameDesc:
+	default:
+		return ErrInvalidPage
+	}
+	if p.Limit < 1 || p.Limit > MaxPageSize {
+		return ErrInvalidPage
+	}
+	if p.Cursor != "" {
+		if _, err := DecodeCursor(p.Cursor, p.Sort); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Desc reports whether s sorts descending.
+func (s Sort) Desc() bool {
+	return strings.HasPrefix(string(s), "-")
+}
+
+// Field is what s sorts by: start, price or name.
+func (s Sort) Field() string {
+	return strings.TrimPrefix(string(s), "-")
+}
+
+// Cursor is the position of the last item of a page: its sort key, as
+// written by SortKey, and its id. It is handed out opaque.
+type Cursor struct {
+	Sort Sort   `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"i"`
+}
+
+// Encode writes c as an opaque, URL-safe string.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor reads a cursor written by Encode for a listing sorted by
+// sort.
+func DecodeCursor(s string, sort Sort) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.Sort != sort || c.ID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if _, err := c.Value(); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Value is the key of c as the type it sorts as: a time, a price in minor
+// units or a name.
+func (c Cursor) Value() (interface{}, error) {
+	switch c.Sort.Field() {
+	case "start":
+		return time.Parse(time.RFC3339Nano, c.Key)
+	case "price":
+		return strconv.ParseInt(c.Key, 10, 64)
+	}
+	return c.Key, nil
+}
+
+func sortKey(s Sort, start time.Time, price int64, name string) string {
+	switch s.Field() {
+	case "start":
+		return start.UTC().Format(time.RFC3339Nano)
+	case "price":
+		return strconv.FormatInt(price, 10)
+	}
+	return name
+}
+
+// SortKey is the key w sorts by under s.
+func (w Workshop) SortKey(s Sort) string {
+	return sortKey(s, w.StartTime, w.Price.Amount, w.Name)
+}
+
+// SortKey is the key e sorts by under s.
+func (e Event) SortKey(s Sort) string {
+	return sortKey(s, e.StartTime, e.Price.Amount, e.Name)
+}
+
+// Compare orders two items, given by their sort keys and ids, under s: it
+// is negative when a comes first. Names compare ignoring case.
+func (s Sort) Compare(keyA, idA, keyB, idB string) int {
+	c := compareKeys(s.Field(), keyA, keyB)
+	if c == 0 {
+		c = strings.Compare(idA, idB)
+	}
+	if s.Desc() {
+		return -c
+	}
+	return c
+}
+
+func compareKeys(field, a, b string) int {
+	switch field {
+	case "start":
+		ta, _ := time.Parse(time.RFC3339Nano, a)
+		tb, _ := time.Parse(time.RFC3339Nano, b)
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return 1
+		}
+		return 0
+	case "price":
+		pa, _ := strconv.ParseInt(a, 10, 64)
+		pb, _ := strconv.ParseInt(b, 10, 64)
+		switch {
+		case pa < pb:
+			return -1
+		case pa > pb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
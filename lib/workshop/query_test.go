@@ -0,0 +1,138 @@
+package workshop
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+func TestFilterValidate(t *testing.T) {
+	day := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	price := func(p int64) *int64 { return &p }
+	for _, c := range []struct {
+		name string
+		f    Filter
+		want error
+	}{
+		{"none", Filter{}, nil},
+		{"from before to", Filter{From: day, To: day.Add(time.Hour)}, nil},
+		{"from only", Filter{From: day}, nil},
+		{"from at to", Filter{From: day, To: day}, ErrInvalidFilter},
+		{"from after to", Filter{From: day.Add(time.Hour), To: day}, ErrInvalidFilter},
+		{"price range", Filter{MinPrice: price(0), MaxPrice: price(0)}, nil},
+		{"negative price", Filter{MinPrice: price(-1)}, ErrInvalidFilter},
+		{"min above max", Filter{MinPrice: price(2000), MaxPrice: price(1999)}, ErrInvalidFilter},
+		{"negative venue", Filter{VenueID: -1}, ErrInvalidFilter},
+	} {
+		if err := c.f.Validate(); err != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestPageValidate(t *testing.T) {
+	p := Page{}
+	if err := p.Validate(); err != nil || p.Sort != SortStart || p.Limit != DefaultPageSize {
+		t.Errorf("got %+v, %v; want the default sort and limit", p, err)
+	}
+	price := Cursor{Sort: SortPrice, Key: "4500", ID: "a"}.Encode()
+	for _, c := range []struct {
+		name string
+		p    Page
+		want error
+	}{
+		{"every sort", Page{Sort: SortNameDesc, Limit: MaxPageSize}, nil},
+		{"unknown sort", Page{Sort: "level"}, ErrInvalidPage},
+		{"descending twice", Page{Sort: "--start"}, ErrInvalidPage},
+		{"negative limit", Page{Limit: -1}, ErrInvalidPage},
+		{"limit too large", Page{Limit: MaxPageSize + 1}, ErrInvalidPage},
+		{"cursor", Page{Sort: SortPrice, Cursor: price}, nil},
+		{"cursor of another sort", Page{Sort: SortPriceDesc, Cursor: price}, ErrInvalidCursor},
+		{"cursor of the default sort", Page{Cursor: price}, ErrInvalidCursor},
+	} {
+		if err := c.p.Validate(); err != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	start := time.Date(2030, 6, 1, 17, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	ws := Workshop{WorkshopID: "raku", Name: "Raku", StartTime: start, Price: money.New(4500, "EUR")}
+	for _, s := range []Sort{SortStart, SortStartDesc, SortPrice, SortPriceDesc, SortName, SortNameDesc} {
+		want := Cursor{Sort: s, Key: ws.SortKey(s), ID: ws.WorkshopID}
+		got, err := DecodeCursor(want.Encode(), s)
+		if err != nil || got != want {
+			t.Errorf("%s: got %+v, %v; want %+v", s, got, err, want)
+		}
+	}
+	if v, _ := (Cursor{Sort: SortStart, Key: ws.SortKey(SortStart)}).Value(); !v.(time.Time).Equal(start) {
+		t.Errorf("start key reads as %v, want %v", v, start)
+	}
+
+	raw := func(json string) string { return base64.RawURLEncoding.EncodeToString([]byte(json)) }
+	valid := Cursor{Sort: SortPrice, Key: "4500", ID: "raku"}.Encode()
+	for _, c := range []struct {
+		name   string
+		cursor string
+		sort   Sort
+	}{
+		{"empty", "", SortStart},
+		{"not base64", "not a cursor!", SortPrice},
+		{"padded", base64.URLEncoding.EncodeToString([]byte(`{"s":"price","k":"4500","i":"raku"}`)), SortPrice},
+		{"standard alphabet", base64.RawStdEncoding.EncodeToString([]byte(`{"s":"price","k":"4500","i":"raku??>"}`)), SortPrice},
+		{"truncated", valid[:len(valid)-3], SortPrice},
+		{"not JSON", raw("price,4500,raku"), SortPrice},
+		{"wrong types", raw(`{"s":"price","k":4500,"i":"raku"}`), SortPrice},
+		{"another sort", valid, SortName},
+		{"no id", raw(`{"s":"price","k":"4500"}`), SortPrice},
+		{"price not a number", raw(`{"s":"price","k":"45.00","i":"raku"}`), SortPrice},
+		{"price too large", raw(`{"s":"price","k":"99999999999999999999","i":"raku"}`), SortPrice},
+		{"start not a time", raw(`{"s":"-start","k":"2030-06-01","i":"raku"}`), SortStartDesc},
+	} {
+		if got, err := DecodeCursor(c.cursor, c.sort); err != ErrInvalidCursor || got != (Cursor{}) {
+			t.Errorf("%s: got %+v, %v; want %v", c.name, got, err, ErrInvalidCursor)
+		}
+	}
+	// Names are taken as they are, ties and all.
+	if _, err := DecodeCursor(raw(`{"s":"name","k":"","i":"raku"}`), SortName); err != nil {
+		t.Errorf("empty name: %v", err)
+	}
+}
+
+func TestSortCompare(t *testing.T) {
+	at := func(hour int) string {
+		return time.Date(2030, 6, 1, hour, 0, 0, 0, time.UTC).Format(time.RFC3339Nano)
+	}
+	for _, c := range []struct {
+		s                    Sort
+		keyA, idA, keyB, idB string
+		want                 int
+	}{
+		{SortStart, at(9), "b", at(10), "a", -1},
+		{SortStartDesc, at(9), "b", at(10), "a", 1},
+		// Ties on the key are broken by id, reversed when descending.
+		{SortStart, at(9), "a", at(9), "b", -1},
+		{SortStartDesc, at(9), "a", at(9), "b", 1},
+		{SortStart, at(9), "a", at(9), "a", 0},
+		// Prices compare as numbers, not as text.
+		{SortPrice, "900", "a", "1000", "b", -1},
+		{SortPriceDesc, "900", "a", "1000", "b", 1},
+		{SortPrice, "1000", "a", "1000", "b", -1},
+		{SortPriceDesc, "1000", "a", "1000", "b", 1},
+		// Names ignore case, and tie when only the case differs.
+		{SortName, "raku", "b", "Wheel", "a", -1},
+		{SortName, "Raku", "b", "raku", "a", 1},
+		{SortNameDesc, "Raku", "b", "raku", "a", -1},
+	} {
+		got := c.s.Compare(c.keyA, c.idA, c.keyB, c.idB)
+		if (got < 0) != (c.want < 0) || (got > 0) != (c.want > 0) {
+			t.Errorf("%s: %s/%s against %s/%s: got %d, want %d", c.s, c.keyA, c.idA, c.keyB, c.idB, got, c.want)
+		}
+	}
+	if !SortPriceDesc.Desc() || SortPrice.Desc() || SortNameDesc.Field() != "name" {
+		t.Error("got the direction or field of a sort wrong")
+	}
+}
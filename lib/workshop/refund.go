@@ -0,0 +1,131 @@
+package workshop
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+// RefundPolicy says how much of the price a participant gets back for
+// cancelling: all of it until FullDays days before the workshop starts,
+// PartialPercent of it until PartialDays days before, and nothing after. The
+// zero policy refunds in full until the start.
+type RefundPolicy struct {
+	FullDays       int
+	PartialDays    int
+	PartialPercent int
+}
+
+var ErrInvalidRefundPolicy = errors.New("refund policies need full refund days at least as many as partial refund days, neither negative, and a partial refund percentage between 0 and 100")
+
+func (p RefundPolicy) Validate() error {
+	if p.PartialDays < 0 || p.FullDays < p.PartialDays || p.PartialPercent < 0 || p.PartialPercent > 100 {
+		return ErrInvalidRefundPolicy
+	}
+	return nil
+}
+
+// RefundFor is how much of paid the policy of w refunds for a cancellation
+// at t. Percentages round half up to the minor unit.
+func (w Workshop) RefundFor(paid money.Money, at time.Time) money.Money {
+	before := w.StartTime.Sub(at)
+	day := 24 * time.Hour
+	switch {
+	case before >= time.Duration(w.RefundPolicy.FullDays)*day:
+		return paid
+	case before >= time.Duration(w.RefundPolicy.PartialDays)*day:
+		return money.New((paid.Amount*int64(w.RefundPolicy.PartialPercent)+50)/100, paid.Currency)
+	}
+	return money.New(0, paid.Currency)
+}
+
+// RefundReason says why money was paid back.
+type RefundReason string
+
+const (
+	RefundSignupCancelled   RefundReason = "signup_cancelled"
+	RefundWorkshopCancelled RefundReason = "workshop_cancelled"
+	// RefundLatePayment is for payments that arrived after their signup was
+	// cancelled, e.g. once its hold was released.
+	RefundLatePayment RefundReason = "late_payment"
+	// RefundOther is for refunds issued by hand, e.g. as a goodwill gesture.
+	RefundOther RefundReason = "other"
+)
+
+// RefundMethod says how money was paid back.
+type RefundMethod string
+
+const (
+	// RefundProvider was paid back through the payment provider the signup
+	// paid with.
+	RefundProvider RefundMethod = "provider"
+	// RefundManual is paid back outside the system, e.g. by bank transfer.
+	RefundManual RefundMethod = "manual"
+)
+
+// Refund is money paid back on a signup. Every refund comes with a credit
+// note.
+type Refund struct {
+	ID         int
+	SignUpID   int
+	WorkshopID string
+	// PaymentID is the payment refunded through the provider; zero for
+	// manual refunds.
+	PaymentID        int
+	Method           RefundMethod
+	ProviderRefundID string
+	Amount           money.Money
+	Reason           RefundReason
+	Note             string
+	// Number is the number of the refund's credit note, e.g.
+	// CN-2024-00042.
+	Number    string
+	CreatedAt time.Time
+}
+
+var (
+	ErrInvalidRefund     = errors.New("refunds need a positive amount, a known reason and method")
+	ErrRefundExceedsPaid = errors.New("refund exceeds what is left of the signup's price")
+)
+
+func (r Refund) Validate() error {
+	switch {
+	case r.Amount.Amount <= 0,
+		r.Reason != RefundSignupCancelled && r.Reason != RefundWorkshopCancelled && r.Reason != RefundLatePayment && r.Reason != RefundOther,
+		r.Method != RefundProvider && r.Method != RefundManual:
+		return ErrInvalidRefund
+	}
+	return nil
+}
+
+// CreditNoteNumber is the seq'th credit note number of year, e.g.
+// CN-2024-00042.
+func CreditNoteNumber(year, seq int) string {
+	return fmt.Sprintf("CN-%d-%05d", year, seq)
+}
+
+// CreditNote renders the credit note of refund r on signup su for ws as
+// plain text.
+func CreditNote(r Refund, su SignUp, ws Workshop) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREDIT NOTE %s\n\n", r.Number)
+	fmt.Fprintf(&b, "Date:       %s\n", r.CreatedAt.UTC().Format("2 January 2006"))
+	fmt.Fprintf(&b, "Issued to:  %s %s <%s>\n", su.FirstName, su.LastName, su.Email)
+	fmt.Fprintf(&b, "Signup:     %d\n\n", su.ID)
+	fmt.Fprintf(&b, "Workshop:   %s, %s\n", ws.Name, ws.LocalStart().Format("2 January 2006 15:04 MST"))
+	fmt.Fprintf(&b, "Paid:       %s", su.Price)
+	if su.PriceTier != "" {
+		fmt.Fprintf(&b, " (%s)", su.PriceTier)
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Refunded:   %s\n", r.Amount)
+	fmt.Fprintf(&b, "Reason:     %s\n", strings.Replace(string(r.Reason), "_", " ", -1))
+	fmt.Fprintf(&b, "Paid back:  %s\n", r.Method)
+	if r.Note != "" {
+		fmt.Fprintf(&b, "\n%s\n", r.Note)
+	}
+	return b.String()
+}
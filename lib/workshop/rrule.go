@@ -0,0 +1,279 @@
+package workshop
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ part of a recurrence rule.
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// MaxOccurrences bounds the expansion of rules without COUNT or UNTIL.
+const MaxOccurrences = 52
+
+// RuleError rejects a recurrence rule.
+type RuleError struct {
+	Reason string
+}
+
+func (e RuleError) Error() string {
+	return "invalid recurrence rule: " + e.Reason
+}
+
+func ruleErrorf(format string, args ...interface{}) error {
+	return RuleError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Rule is the subset of the iCalendar RRULE (RFC 5545) that series use:
+// FREQ, INTERVAL, BYDAY, COUNT and UNTIL. Weeks start on Monday.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []WeekdayNum
+	// Count and Until end the rule; at most one of them is set.
+	Count int
+	Until time.Time
+}
+
+// WeekdayNum is a BYDAY entry. N picks the nth weekday of the month, counted
+// from the end when negative, and is only allowed with FREQ=MONTHLY. Zero
+// means every such weekday.
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+var weekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+const untilLayout = "20060102T150405Z"
+
+// ParseRule parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+// A leading "RRULE:" is accepted.
+func ParseRule(s string) (Rule, error) {
+	r := Rule{Interval: 1}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return r, ruleErrorf("%q", part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = Frequency(value)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		default:
+			err = fmt.Errorf("unsupported part %s", key)
+		}
+		if err != nil {
+			return r, RuleError{Reason: err.Error()}
+		}
+	}
+	return r, r.validate()
+}
+
+func (r Rule) validate() error {
+	switch {
+	case r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly:
+		return ruleErrorf("FREQ must be DAILY, WEEKLY or MONTHLY")
+	case r.Interval < 1:
+		return ruleErrorf("INTERVAL must be positive")
+	case r.Count < 0:
+		return ruleErrorf("COUNT must be positive")
+	case r.Count > 0 && !r.Until.IsZero():
+		return ruleErrorf("COUNT and UNTIL are exclusive")
+	case r.Freq == Daily && len(r.ByDay) > 0:
+		return ruleErrorf("BYDAY is not supported with FREQ=DAILY")
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && (r.Freq != Monthly || d.N < -5 || d.N > 5) {
+			return ruleErrorf("BYDAY ordinal %d", d.N)
+		}
+	}
+	return nil
+}
+
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{untilLayout, "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("UNTIL %q", s)
+}
+
+func parseByDay(s string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, d := range strings.Split(s, ",") {
+		if len(d) < 2 {
+			return nil, fmt.Errorf("BYDAY %q", d)
+		}
+		var wd WeekdayNum
+		if n := d[:len(d)-2]; n != "" {
+			var err error
+			if wd.N, err = strconv.Atoi(n); err != nil || wd.N == 0 {
+				return nil, fmt.Errorf("BYDAY %q", d)
+			}
+		}
+		i := indexOf(weekdays, d[len(d)-2:])
+		if i < 0 {
+			return nil, fmt.Errorf("BYDAY %q", d)
+		}
+		wd.Weekday = time.Weekday(i)
+		days = append(days, wd)
+	}
+	return days, nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// String renders r in its canonical RRULE form.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = weekdays[d.Weekday]
+			if d.N != 0 {
+				days[i] = strconv.Itoa(d.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Expand returns the instants r produces from start, in start's location so
+// the wall clock time is kept across DST changes. start itself is the first
+// instant when it matches the rule. Rules without COUNT or UNTIL stop after
+// MaxOccurrences.
+func (r Rule) Expand(start time.Time) []time.Time {
+	limit := r.Count
+	if limit == 0 {
+		limit = MaxOccurrences
+	}
+	var out []time.Time
+	// Monthly rules on the 31st or on a fifth weekday skip periods, so the
+	// number of empty periods tolerated is bounded rather than the loop.
+	for period, empty := 0, 0; len(out) < limit && empty < 120; period++ {
+		found := false
+		for _, t := range r.period(start, period) {
+			if t.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return out
+			}
+			found = true
+			out = append(out, t)
+			if len(out) == limit {
+				break
+			}
+		}
+		if found {
+			empty = 0
+		} else {
+			empty++
+		}
+	}
+	return out
+}
+
+// period returns the candidate instants of the nth period after start, in
+// order.
+func (r Rule) period(start time.Time, n int) []time.Time {
+	y, m, d := start.Date()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+	step := n * r.Interval
+	var out []time.Time
+	switch r.Freq {
+	case Daily:
+		out = append(out, at(y, m, d+step))
+	case Weekly:
+		monday := d - (int(start.Weekday())+6)%7 + 7*step
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(y, m, d+7*step)}
+		}
+		for _, wd := range r.ByDay {
+			out = append(out, at(y, m, monday+(int(wd.Weekday)+6)%7))
+		}
+	case Monthly:
+		month := time.Date(y, m+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		y, m = month.Year(), month.Month()
+		days := daysIn(y, m)
+		if len(r.ByDay) == 0 {
+			if d <= days {
+				out = append(out, at(y, m, d))
+			}
+			break
+		}
+		first := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC).Weekday()
+		for _, wd := range r.ByDay {
+			var matches []int
+			for day := 1 + (int(wd.Weekday)-int(first)+7)%7; day <= days; day += 7 {
+				matches = append(matches, day)
+			}
+			switch {
+			case wd.N == 0:
+				for _, day := range matches {
+					out = append(out, at(y, m, day))
+				}
+			case wd.N > 0 && wd.N <= len(matches):
+				out = append(out, at(y, m, matches[wd.N-1]))
+			case wd.N < 0 && -wd.N <= len(matches):
+				out = append(out, at(y, m, matches[len(matches)+wd.N]))
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return dedupe(out)
+}
+
+func daysIn(y int, m time.Month) int {
+	return time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func dedupe(times []time.Time) []time.Time {
+	out := times[:0]
+	for _, t := range times {
+		if len(out) == 0 || !t.Equal(out[len(out)-1]) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
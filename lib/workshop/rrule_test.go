@@ -0,0 +1,121 @@
+package workshop
+
+import (
+	"testing"
+	"time"
+)
+
+const expandLayout = "2006-01-02 15:04 -0700"
+
+func TestExpand(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		rule  string
+		start time.Time
+		want  []string
+	}{
+		{"FREQ=WEEKLY;COUNT=3", time.Date(2030, 1, 7, 19, 0, 0, 0, berlin),
+			[]string{"2030-01-07 19:00 +0100", "2030-01-14 19:00 +0100", "2030-01-21 19:00 +0100"}},
+		// UNTIL is inclusive.
+		{"FREQ=WEEKLY;UNTIL=20300121T180000Z", time.Date(2030, 1, 7, 19, 0, 0, 0, berlin),
+			[]string{"2030-01-07 19:00 +0100", "2030-01-14 19:00 +0100", "2030-01-21 19:00 +0100"}},
+		{"FREQ=WEEKLY;UNTIL=20300121T175959Z", time.Date(2030, 1, 7, 19, 0, 0, 0, berlin),
+			[]string{"2030-01-07 19:00 +0100", "2030-01-14 19:00 +0100"}},
+		// The wall clock time is kept across the change to and from summer
+		// time.
+		{"FREQ=WEEKLY;COUNT=3", time.Date(2030, 3, 25, 19, 0, 0, 0, berlin),
+			[]string{"2030-03-25 19:00 +0100", "2030-04-01 19:00 +0200", "2030-04-08 19:00 +0200"}},
+		{"FREQ=DAILY;INTERVAL=2;COUNT=3", time.Date(2030, 10, 25, 9, 30, 0, 0, berlin),
+			[]string{"2030-10-25 09:30 +0200", "2030-10-27 09:30 +0100", "2030-10-29 09:30 +0100"}},
+		// A start that does not match BYDAY is not an occurrence itself.
+		{"FREQ=WEEKLY;BYDAY=WE,FR;COUNT=3", time.Date(2030, 1, 7, 19, 0, 0, 0, berlin),
+			[]string{"2030-01-09 19:00 +0100", "2030-01-11 19:00 +0100", "2030-01-16 19:00 +0100"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=4", time.Date(2030, 1, 1, 10, 0, 0, 0, berlin),
+			[]string{"2030-01-25 10:00 +0100", "2030-02-22 10:00 +0100", "2030-03-29 10:00 +0100", "2030-04-26 10:00 +0200"}},
+		{"FREQ=MONTHLY;BYDAY=1MO,-2MO;COUNT=4", time.Date(2030, 1, 1, 10, 0, 0, 0, berlin),
+			[]string{"2030-01-07 10:00 +0100", "2030-01-21 10:00 +0100", "2030-02-04 10:00 +0100", "2030-02-18 10:00 +0100"}},
+		// Months without a 31st are skipped.
+		{"FREQ=MONTHLY;COUNT=3", time.Date(2030, 1, 31, 10, 0, 0, 0, berlin),
+			[]string{"2030-01-31 10:00 +0100", "2030-03-31 10:00 +0200", "2030-05-31 10:00 +0200"}},
+	} {
+		rule, err := ParseRule(c.rule)
+		if err != nil {
+			t.Errorf("%s: %v", c.rule, err)
+			continue
+		}
+		var got []string
+		for _, at := range rule.Expand(c.start) {
+			got = append(got, at.Format(expandLayout))
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%s from %v: got %v, want %v", c.rule, c.start, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s from %v: got %v, want %v", c.rule, c.start, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestExpandStopsAtMaxOccurrences(t *testing.T) {
+	start := time.Date(2030, 1, 7, 19, 0, 0, 0, time.UTC)
+	for _, s := range []string{"FREQ=DAILY", "FREQ=WEEKLY;BYDAY=MO,TH", "FREQ=MONTHLY;BYDAY=5FR"} {
+		rule, err := ParseRule(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rule.Expand(start); len(got) != MaxOccurrences {
+			t.Errorf("%s: got %d occurrences, want %d", s, len(got), MaxOccurrences)
+		}
+	}
+	rule, err := ParseRule("FREQ=DAILY;COUNT=100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rule.Expand(start); len(got) != 100 {
+		t.Errorf("COUNT=100: got %d occurrences, want COUNT to override the cap", len(got))
+	}
+}
+
+func TestParseRuleRejects(t *testing.T) {
+	for _, s := range []string{
+		"FREQ=YEARLY",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;COUNT=3;UNTIL=20300101T000000Z",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=-1FR",
+		"FREQ=MONTHLY;BYDAY=-6FR",
+		"FREQ=MONTHLY;BYDAY=0FR",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYSETPOS=1",
+		"FREQ=WEEKLY;UNTIL=tomorrow",
+	} {
+		if _, err := ParseRule(s); err == nil {
+			t.Errorf("%s: accepted", s)
+		} else if _, ok := err.(RuleError); !ok {
+			t.Errorf("%s: got %T, want a RuleError", s, err)
+		}
+	}
+}
+
+func TestRuleStringRoundTrips(t *testing.T) {
+	for _, s := range []string{
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10",
+		"FREQ=MONTHLY;BYDAY=-1FR,2TU;UNTIL=20301231T235959Z",
+		"FREQ=DAILY",
+	} {
+		rule, err := ParseRule("RRULE:" + s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rule.String(); got != s {
+			t.Errorf("got %s, want %s", got, s)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package workshop
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Series is a workshop that repeats. Template carries the fields every
+// occurrence starts out with; its StartTime and EndTime are those of the
+// first occurrence, and RRule repeats them in the template's timezone.
+type Series struct {
+	ID       string
+	Template Workshop
+	RRule    string
+	// ExDates are the instants the rule produces that are skipped.
+	ExDates   []time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// EditScope says which occurrences of a series an edit applies to.
+type EditScope string
+
+const (
+	ScopeThis      EditScope = "this"
+	ScopeFollowing EditScope = "following"
+)
+
+var ErrNotInSeries = errors.New("workshop is not an occurrence of the series")
+
+// Rule parses the series' recurrence rule.
+func (s Series) Rule() (Rule, error) {
+	return ParseRule(s.RRule)
+}
+
+// Validate checks the template times and the recurrence rule.
+func (s Series) Validate() error {
+	if err := ValidateTimes(s.Template.StartTime, s.Template.EndTime, s.Template.Timezone); err != nil {
+		return err
+	}
+	_, err := s.Rule()
+	return err
+}
+
+// OccurrenceID is the workshop_id of the occurrence of series at start.
+func OccurrenceID(seriesID string, start time.Time) string {
+	return seriesID + "-" + start.UTC().Format("20060102T1504")
+}
+
+// Occurrences expands the series into workshops, each with its own ID and
+// the template's capacity.
+func (s Series) Occurrences() ([]Workshop, error) {
+	rule, err := s.Rule()
+	if err != nil {
+		return nil, err
+	}
+	length := s.Template.Duration()
+	var out []Workshop
+	for _, t := range rule.Expand(s.Template.LocalStart()) {
+		if s.excluded(t) {
+			continue
+		}
+		ws := s.Template
+		ws.WorkshopID = OccurrenceID(s.ID, t)
+		ws.SeriesID = s.ID
+		ws.Sessions = nil
+		ws.StartTime = t.UTC()
+		ws.EndTime = t.Add(length).UTC()
+		out = append(out, ws)
+	}
+	return out, nil
+}
+
+func (s Series) excluded(t time.Time) bool {
+	for _, ex := range s.ExDates {
+		if ex.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Truncate ends the series before at and returns the continuation of its
+// rule from at: the same rule, with COUNT reduced by the occurrences that
+// stay behind.
+func (s Series) Truncate(at time.Time) (Series, Rule, error) {
+	rule, err := s.Rule()
+	if err != nil {
+		return s, rule, err
+	}
+	next := rule
+	if rule.Count > 0 {
+		before := 0
+		for _, t := range rule.Expand(s.Template.LocalStart()) {
+			if !t.Before(at) {
+				break
+			}
+			before++
+		}
+		next.Count = rule.Count - before
+	}
+	rule.Count = 0
+	rule.Until = at.Add(-time.Second).UTC()
+	s.RRule = rule.String()
+	var exdates []time.Time
+	for _, ex := range s.ExDates {
+		if ex.Before(at) {
+			exdates = append(exdates, ex)
+		}
+	}
+	s.ExDates = exdates
+	return s, next, nil
+}
+
+const exdateLayout = "20060102T150405Z"
+
+// FormatExDates renders exdates as a comma separated iCalendar EXDATE list
+// in UTC.
+func FormatExDates(exdates []time.Time) string {
+	parts := make([]string, len(exdates))
+	for i, t := range exdates {
+		parts[i] = t.UTC().Format(exdateLayout)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseExDates reads a list written by FormatExDates.
+func ParseExDates(s string) ([]time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var exdates []time.Time
+	for _, part := range strings.Split(s, ",") {
+		t, err := time.Parse(exdateLayout, part)
+		if err != nil {
+			return nil, err
+		}
+		exdates = append(exdates, t)
+	}
+	return exdates, nil
+}
@@ -0,0 +1,104 @@
+package workshop
+
+import (
+	"testing"
+	"time"
+)
+
+// weeklySeries is a Berlin evening class on Mondays from 2030-03-18, so
+// that it crosses the start of summer time on 2030-03-31.
+func weeklySeries(t *testing.T, rrule string, exdates ...time.Time) Series {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2030, 3, 18, 19, 0, 0, 0, berlin)
+	return Series{
+		ID: "pottery",
+		Template: Workshop{
+			Name:      "Pottery",
+			StartTime: start.UTC(),
+			EndTime:   start.Add(2 * time.Hour).UTC(),
+			Timezone:  "Europe/Berlin",
+			Cap:       5,
+		},
+		RRule:   rrule,
+		ExDates: exdates,
+	}
+}
+
+func occurrenceStarts(t *testing.T, s Series) []time.Time {
+	occurrences, err := s.Occurrences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var starts []time.Time
+	for _, ws := range occurrences {
+		if ws.SeriesID != s.ID || ws.WorkshopID != OccurrenceID(s.ID, ws.StartTime) || ws.Duration() != 2*time.Hour {
+			t.Errorf("occurrence %+v does not carry the series", ws)
+		}
+		starts = append(starts, ws.StartTime)
+	}
+	return starts
+}
+
+func TestOccurrencesSkipExDates(t *testing.T) {
+	march25 := time.Date(2030, 3, 25, 18, 0, 0, 0, time.UTC)
+	s := weeklySeries(t, "FREQ=WEEKLY;COUNT=4", march25)
+	want := []time.Time{
+		time.Date(2030, 3, 18, 18, 0, 0, 0, time.UTC),
+		time.Date(2030, 4, 1, 17, 0, 0, 0, time.UTC),
+		time.Date(2030, 4, 8, 17, 0, 0, 0, time.UTC),
+	}
+	got := occurrenceStarts(t, s)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("occurrence %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+	// An exdate off the wall clock time skips nothing.
+	s.ExDates = []time.Time{march25.Add(time.Hour)}
+	if got := occurrenceStarts(t, s); len(got) != 4 {
+		t.Errorf("got %d occurrences, want 4", len(got))
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	april1 := time.Date(2030, 4, 1, 17, 0, 0, 0, time.UTC)
+	march25, april8 := time.Date(2030, 3, 25, 18, 0, 0, 0, time.UTC), time.Date(2030, 4, 8, 17, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		rrule string
+		// kept are the occurrences left before april1, next the rule that
+		// continues from there.
+		kept int
+		next string
+	}{
+		{"FREQ=WEEKLY;COUNT=5", 1, "FREQ=WEEKLY;COUNT=3"},
+		{"FREQ=WEEKLY;UNTIL=20300415T170000Z", 1, "FREQ=WEEKLY;UNTIL=20300415T170000Z"},
+		{"FREQ=WEEKLY", 1, "FREQ=WEEKLY"},
+	} {
+		s := weeklySeries(t, c.rrule, march25, april8)
+		truncated, next, err := s.Truncate(april1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "FREQ=WEEKLY;UNTIL=20300401T165959Z"; truncated.RRule != want {
+			t.Errorf("%s: truncated to %s, want %s", c.rrule, truncated.RRule, want)
+		}
+		if next.String() != c.next {
+			t.Errorf("%s: continues as %s, want %s", c.rrule, next, c.next)
+		}
+		if len(truncated.ExDates) != 1 || !truncated.ExDates[0].Equal(march25) {
+			t.Errorf("%s: kept exdates %v, want only %v", c.rrule, truncated.ExDates, march25)
+		}
+		if got := occurrenceStarts(t, truncated); len(got) != c.kept {
+			t.Errorf("%s: %d occurrences stay behind, want %d", c.rrule, len(got), c.kept)
+		}
+		if len(s.ExDates) != 2 || s.RRule != c.rrule {
+			t.Errorf("%s: Truncate changed the series it was called on", c.rrule)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package workshop
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Session is one meeting of a workshop. A workshop that meets once has a
+// single session spanning its start and end time.
+type Session struct {
+	ID         int
+	WorkshopID string
+	// Position orders the sessions of a workshop, starting at 1.
+	Position  int
+	StartTime time.Time
+	EndTime   time.Time
+	// Location overrides the workshop's location when set.
+	Location string
+}
+
+// Attendance records whether a signup turned up to a session.
+type Attendance struct {
+	SessionID  int
+	SignUpID   int
+	Attended   bool
+	RecordedAt time.Time
+}
+
+var ErrInvalidSessions = errors.New("sessions must each end after they start and must not overlap")
+
+// SessionList returns the sessions of w, or a single session spanning its
+// start and end time when none are set.
+func (w Workshop) SessionList() []Session {
+	if len(w.Sessions) > 0 {
+		return w.Sessions
+	}
+	return []Session{{WorkshopID: w.WorkshopID, Position: 1, StartTime: w.StartTime, EndTime: w.EndTime}}
+}
+
+// SetSessions orders sessions by start time, numbers them and sets the
+// workshop's start and end time to span them.
+func (w *Workshop) SetSessions(sessions []Session) {
+	sessions = append([]Session(nil), sessions...)
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].StartTime.Before(sessions[j].StartTime) })
+	for i := range sessions {
+		sessions[i].WorkshopID = w.WorkshopID
+		sessions[i].Position = i + 1
+		sessions[i].StartTime = sessions[i].StartTime.UTC()
+		sessions[i].EndTime = sessions[i].EndTime.UTC()
+	}
+	w.Sessions = sessions
+	if len(sessions) > 0 {
+		w.StartTime = sessions[0].StartTime
+		w.EndTime = sessions[len(sessions)-1].EndTime
+	}
+}
+
+// ValidateSessions checks that every session ends after it starts and that
+// no two overlap.
+func ValidateSessions(sessions []Session) error {
+	sorted := append([]Session(nil), sessions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartTime.Before(sorted[j].StartTime) })
+	for i, s := range sorted {
+		if s.StartTime.IsZero() || !s.EndTime.After(s.StartTime) {
+			return ErrInvalidSessions
+		}
+		if i > 0 && s.StartTime.Before(sorted[i-1].EndTime) {
+			return ErrInvalidSessions
+		}
+	}
+	return nil
+}
+
+// LocalStart is the start time in the workshop's timezone.
+func (s Session) LocalStart(timezone string) time.Time {
+	return inZone(s.StartTime, timezone)
+}
+
+// LocalEnd is the end time in the workshop's timezone.
+func (s Session) LocalEnd(timezone string) time.Time {
+	return inZone(s.EndTime, timezone)
+}
+
+// SessionLocation is where s takes place.
+func (w Workshop) SessionLocation(s Session) string {
+	if s.Location != "" {
+		return s.Location
+	}
+	return w.Location
+}
@@ -0,0 +1,114 @@
+package workshop
+
+import (
+	"fmt"
+	"time"
+)
+
+// Status is the lifecycle state of a signup.
+type Status string
+
+const (
+	// StatusPending holds a seat that is not confirmed yet.
+	StatusPending Status = "pending"
+	// StatusConfirmed holds a seat.
+	StatusConfirmed Status = "confirmed"
+	// StatusWaitlisted is queued for a seat and promoted, in signup order,
+	// when one frees up: to confirmed, or to pending while it pays.
+	StatusWaitlisted Status = "waitlisted"
+	StatusCancelled  Status = "cancelled"
+	StatusAttended   Status = "attended"
+	StatusNoShow     Status = "no_show"
+)
+
+// SeatStatuses are the states that count against a workshop's cap.
+var SeatStatuses = []Status{StatusPending, StatusConfirmed, StatusAttended, StatusNoShow}
+
+// BookedStatuses are the states of signups that still want to come: they
+// hold a seat or wait for one.
+var BookedStatuses = append([]Status{StatusWaitlisted}, SeatStatuses...)
+
+var transitions = map[Status][]Status{
+	StatusPending:    {StatusConfirmed, StatusCancelled},
+	StatusWaitlisted: {StatusPending, StatusConfirmed, StatusCancelled},
+	StatusConfirmed:  {StatusCancelled, StatusAttended, StatusNoShow},
+	// attendance can be corrected after the fact
+	StatusAttended: {StatusNoShow},
+	StatusNoShow:   {StatusAttended},
+}
+
+// Valid reports whether s is a known status.
+func (s Status) Valid() bool {
+	switch s {
+	case StatusPending, StatusConfirmed, StatusWaitlisted, StatusCancelled, StatusAttended, StatusNoShow:
+		return true
+	}
+	return false
+}
+
+// HoldsSeat reports whether a signup in state s takes up a seat.
+func (s Status) HoldsSeat() bool {
+	for _, seat := range SeatStatuses {
+		if s == seat {
+			return true
+		}
+	}
+	return false
+}
+
+// Booked reports whether a signup in state s still wants to come.
+func (s Status) Booked() bool {
+	return s == StatusWaitlisted || s.HoldsSeat()
+}
+
+// CanTransitionTo reports whether a signup may move from s to to.
+func (s Status) CanTransitionTo(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition is one recorded status change of a signup. From is empty for
+// the status a signup was created with.
+type Transition struct {
+	From Status
+	To   Status
+	At   time.Time
+}
+
+// TransitionError is returned when a status change is not allowed.
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e TransitionError) Error() string {
+	return fmt.Sprintf("signup cannot move from %s to %s", e.From, e.To)
+}
+
+// Transition moves the signup to status to at the given time, recording the
+// change in its history.
+func (su *SignUp) Transition(to Status, at time.Time) error {
+	if !su.Status.CanTransitionTo(to) {
+		return TransitionError{From: su.Status, To: to}
+	}
+	su.History = append(su.History, Transition{From: su.Status, To: to, At: at})
+	su.Status = to
+	su.UpdatedAt = at
+	if to == StatusCancelled {
+		su.CancelledAt = at
+	}
+	if to != StatusPending {
+		su.HoldExpiresAt = time.Time{}
+	}
+	return nil
+}
+
+// HoldExpired reports whether the seat of a pending signup is up for release
+// at t.
+func (su SignUp) HoldExpired(at time.Time) bool {
+	return su.Status == StatusPending && !su.HoldExpiresAt.IsZero() && !at.Before(su.HoldExpiresAt)
+}
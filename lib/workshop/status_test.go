@@ -0,0 +1,104 @@
+package workshop
+
+import (
+	"testing"
+	"time"
+)
+
+var allStatuses = []Status{StatusPending, StatusConfirmed, StatusWaitlisted, StatusCancelled, StatusAttended, StatusNoShow}
+
+func TestCanTransitionTo(t *testing.T) {
+	legal := map[[2]Status]bool{
+		{StatusPending, StatusConfirmed}:    true,
+		{StatusPending, StatusCancelled}:    true,
+		{StatusWaitlisted, StatusPending}:   true,
+		{StatusWaitlisted, StatusConfirmed}: true,
+		{StatusWaitlisted, StatusCancelled}: true,
+		{StatusConfirmed, StatusCancelled}:  true,
+		{StatusConfirmed, StatusAttended}:   true,
+		{StatusConfirmed, StatusNoShow}:     true,
+		{StatusAttended, StatusNoShow}:      true,
+		{StatusNoShow, StatusAttended}:      true,
+	}
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			if got, want := from.CanTransitionTo(to), legal[[2]Status{from, to}]; got != want {
+				t.Errorf("%s -> %s: got %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestTransitionRecordsHistory(t *testing.T) {
+	created := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	su := SignUp{Status: StatusWaitlisted}
+	path := []Status{StatusPending, StatusConfirmed, StatusAttended, StatusNoShow}
+	for i, to := range path {
+		if err := su.Transition(to, created.Add(time.Duration(i+1)*time.Minute)); err != nil {
+			t.Fatalf("%s -> %s: %v", su.Status, to, err)
+		}
+	}
+	from := StatusWaitlisted
+	for i, tr := range su.History {
+		want := Transition{From: from, To: path[i], At: created.Add(time.Duration(i+1) * time.Minute)}
+		if tr != want {
+			t.Errorf("transition %d: got %+v, want %+v", i, tr, want)
+		}
+		from = path[i]
+	}
+	if len(su.History) != len(path) || su.Status != StatusNoShow || !su.UpdatedAt.Equal(created.Add(4*time.Minute)) {
+		t.Errorf("got %s updated at %v after %d transitions", su.Status, su.UpdatedAt, len(su.History))
+	}
+}
+
+func TestTransitionRejectsIllegalMoves(t *testing.T) {
+	for _, c := range []struct{ from, to Status }{
+		{StatusCancelled, StatusConfirmed},
+		{StatusCancelled, StatusWaitlisted},
+		{StatusAttended, StatusCancelled},
+		{StatusPending, StatusAttended},
+		{StatusConfirmed, StatusWaitlisted},
+		{StatusConfirmed, StatusConfirmed},
+	} {
+		su := SignUp{Status: c.from}
+		err := su.Transition(c.to, time.Now())
+		if err != (TransitionError{From: c.from, To: c.to}) {
+			t.Errorf("%s -> %s: got %v, want a TransitionError", c.from, c.to, err)
+		}
+		if su.Status != c.from || len(su.History) != 0 {
+			t.Errorf("%s -> %s: rejected move changed the signup to %s with history %v", c.from, c.to, su.Status, su.History)
+		}
+	}
+}
+
+func TestTransitionSetsCancelledAtAndClearsHold(t *testing.T) {
+	at := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	su := SignUp{Status: StatusPending, HoldExpiresAt: at.Add(time.Hour)}
+	if !su.HoldExpired(at.Add(time.Hour)) || su.HoldExpired(at) {
+		t.Errorf("hold until %v: expired at %v and %v", su.HoldExpiresAt, at, at.Add(time.Hour))
+	}
+	if err := su.Transition(StatusCancelled, at); err != nil {
+		t.Fatal(err)
+	}
+	if !su.CancelledAt.Equal(at) || !su.HoldExpiresAt.IsZero() || su.HoldExpired(at.Add(2*time.Hour)) {
+		t.Errorf("got cancelled at %v, hold until %v, want %v and no hold", su.CancelledAt, su.HoldExpiresAt, at)
+	}
+}
+
+func TestStatusSets(t *testing.T) {
+	for _, s := range allStatuses {
+		if !s.Valid() {
+			t.Errorf("%s is not valid", s)
+		}
+		seat := s == StatusPending || s == StatusConfirmed || s == StatusAttended || s == StatusNoShow
+		if s.HoldsSeat() != seat {
+			t.Errorf("%s holds a seat: got %v, want %v", s, s.HoldsSeat(), seat)
+		}
+		if s.Booked() != (seat || s == StatusWaitlisted) {
+			t.Errorf("%s booked: got %v", s, s.Booked())
+		}
+	}
+	if Status("lost").Valid() {
+		t.Error("unknown status is valid")
+	}
+}
@@ -0,0 +1,81 @@
+package workshop
+
+import (
+	"errors"
+	"time"
+	// Bundle the zone database so Berlin's DST rules are available even on
+	// hosts without /usr/share/zoneinfo.
+	_ "time/tzdata"
+)
+
+// DefaultTimezone is the studio's timezone, used for workshops and events
+// that do not name their own.
+const DefaultTimezone = "Europe/Berlin"
+
+var (
+	ErrInvalidTimes    = errors.New("end time must be after start time")
+	ErrInvalidTimezone = errors.New("unknown timezone")
+)
+
+// Zone resolves an IANA timezone name, falling back to DefaultTimezone for
+// the empty name.
+func Zone(name string) (*time.Location, error) {
+	if name == "" {
+		name = DefaultTimezone
+	}
+	return time.LoadLocation(name)
+}
+
+// inZone renders t in the named zone, or in UTC if the zone is unknown.
+func inZone(t time.Time, name string) time.Time {
+	loc, err := Zone(name)
+	if err != nil {
+		return t.UTC()
+	}
+	return t.In(loc)
+}
+
+// ValidateTimes checks a start and end pair and the timezone they are shown
+// in.
+func ValidateTimes(start, end time.Time, timezone string) error {
+	if start.IsZero() || !end.After(start) {
+		return ErrInvalidTimes
+	}
+	if _, err := Zone(timezone); err != nil {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
+
+// LocalStart is the start time in the workshop's timezone.
+func (w Workshop) LocalStart() time.Time {
+	return inZone(w.StartTime, w.Timezone)
+}
+
+// LocalEnd is the end time in the workshop's timezone.
+func (w Workshop) LocalEnd() time.Time {
+	return inZone(w.EndTime, w.Timezone)
+}
+
+// Local is t in the workshop's timezone.
+func (w Workshop) Local(t time.Time) time.Time {
+	return inZone(t, w.Timezone)
+}
+
+func (w Workshop) Duration() time.Duration {
+	return w.EndTime.Sub(w.StartTime)
+}
+
+// LocalStart is the start time in the event's timezone.
+func (e Event) LocalStart() time.Time {
+	return inZone(e.StartTime, e.Timezone)
+}
+
+// LocalEnd is the end time in the event's timezone.
+func (e Event) LocalEnd() time.Time {
+	return inZone(e.EndTime, e.Timezone)
+}
+
+func (e Event) Duration() time.Duration {
+	return e.EndTime.Sub(e.StartTime)
+}
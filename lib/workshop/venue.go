@@ -0,0 +1,145 @@
+package workshop
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Venue is a building workshops and events are held at. Its Rooms are
+// what they book.
+type Venue struct {
+	ID                 int
+	Name               string
+	Address            string
+	AccessibilityNotes string
+	Rooms              []Room
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// Room is a bookable room of a venue. Capacity caps the seats a workshop
+// held in it can offer.
+type Room struct {
+	ID                 int
+	VenueID            int
+	Name               string
+	Capacity           int
+	AccessibilityNotes string
+}
+
+var (
+	ErrInvalidVenue = errors.New("venue needs a name and an address")
+	ErrInvalidRoom  = errors.New("room needs a name and a capacity of at least one")
+	// ErrOverRoomCapacity rejects a workshop with more seats than its room
+	// holds.
+	ErrOverRoomCapacity = errors.New("cap exceeds the capacity of the room")
+)
+
+// Validate trims the fields of v and checks that it has a name and an
+// address.
+func (v *Venue) Validate() error {
+	v.Name = strings.TrimSpace(v.Name)
+	v.Address = strings.TrimSpace(v.Address)
+	if v.Name == "" || v.Address == "" {
+		return ErrInvalidVenue
+	}
+	return nil
+}
+
+// Validate trims the name of r and checks that it seats someone.
+func (r *Room) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || r.Capacity < 1 {
+		return ErrInvalidRoom
+	}
+	return nil
+}
+
+// BookingKind is what holds a room.
+type BookingKind string
+
+const (
+	BookingWorkshop BookingKind = "workshop"
+	BookingEvent    BookingKind = "event"
+)
+
+// Booking is a span of time a workshop session or an event holds a room.
+type Booking struct {
+	Kind      BookingKind
+	ID        string
+	Name      string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// RoomConflictError rejects a booking that overlaps Conflicts, the
+// bookings already holding the room then.
+type RoomConflictError struct {
+	RoomID    int
+	Conflicts []Booking
+}
+
+func (e *RoomConflictError) Error() string {
+	names := make([]string, len(e.Conflicts))
+	for i, b := range e.Conflicts {
+		names[i] = fmt.Sprintf("%s %q", b.Kind, b.ID)
+	}
+	return fmt.Sprintf("room %d is already booked by %s", e.RoomID, strings.Join(names, ", "))
+}
+
+// RoomBookings are the spans w holds its room: each of its sessions, except
+// those held at a location of their own.
+func (w Workshop) RoomBookings() []Booking {
+	var bookings []Booking
+	for _, s := range w.SessionList() {
+		if s.Location != "" {
+			continue
+		}
+		bookings = append(bookings, Booking{Kind: BookingWorkshop, ID: w.WorkshopID, Name: w.Name, StartTime: s.StartTime.UTC(), EndTime: s.EndTime.UTC()})
+	}
+	return bookings
+}
+
+// RoomBookings is the span e holds its room.
+func (e Event) RoomBookings() []Booking {
+	return []Booking{{Kind: BookingEvent, ID: e.ID, Name: e.Name, StartTime: e.StartTime.UTC(), EndTime: e.EndTime.UTC()}}
+}
+
+// BookingSpan returns the earliest start and latest end of bookings.
+func BookingSpan(bookings []Booking) (time.Time, time.Time) {
+	var from, to time.Time
+	for i, b := range bookings {
+		if i == 0 || b.StartTime.Before(from) {
+			from = b.StartTime
+		}
+		if i == 0 || b.EndTime.After(to) {
+			to = b.EndTime
+		}
+	}
+	return from, to
+}
+
+// Overlapping returns the existing bookings that overlap any of wanted,
+// earliest first and each once. The bookings of whatever wanted is for are
+// left out, so that it does not clash with itself when it is moved.
+// Bookings that only touch, one ending when the other starts, do not
+// overlap.
+func Overlapping(existing, wanted []Booking) []Booking {
+	var out []Booking
+	for _, b := range existing {
+		for _, want := range wanted {
+			if b.Kind == want.Kind && b.ID == want.ID {
+				break
+			}
+			if b.StartTime.Before(want.EndTime) && want.StartTime.Before(b.EndTime) {
+				out = append(out, b)
+				break
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartTime.Before(out[j].StartTime) })
+	return out
+}
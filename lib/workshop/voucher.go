@@ -0,0 +1,162 @@
+package workshop
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+// VoucherKind says what a voucher's balance is counted in.
+type VoucherKind string
+
+const (
+	// VoucherGift holds a money Balance that pays for seats fully or in
+	// part.
+	VoucherGift VoucherKind = "gift"
+	// VoucherPass holds a number of Classes; each pays for one seat.
+	VoucherPass VoucherKind = "pass"
+)
+
+// Voucher is a gift voucher or class pass sold up front and redeemed at
+// signup. Its balance only changes through VoucherTransactions.
+type Voucher struct {
+	Code    string
+	Kind    VoucherKind
+	Balance money.Money
+	Classes int
+	// ExpiresAt is the moment the voucher stops working; the zero time
+	// never expires.
+	ExpiresAt time.Time
+	// VoidedAt is when an admin voided the voucher, e.g. because it was
+	// refunded; the zero time if it was not.
+	VoidedAt  time.Time
+	Note      string
+	CreatedAt time.Time
+}
+
+// VoucherTxKind is what happened to a voucher's balance.
+type VoucherTxKind string
+
+const (
+	VoucherIssued   VoucherTxKind = "issue"
+	VoucherRedeemed VoucherTxKind = "redeem"
+	// VoucherCredited gives back balance redeemed on a cancelled signup.
+	VoucherCredited VoucherTxKind = "credit"
+	VoucherVoided   VoucherTxKind = "void"
+)
+
+// VoucherTransaction is one change to a voucher's balance. Amount and
+// Classes are signed: redemptions and voiding take balance off.
+type VoucherTransaction struct {
+	ID         int
+	Code       string
+	Kind       VoucherTxKind
+	Amount     money.Money
+	Classes    int
+	SignUpID   int
+	WorkshopID string
+	Note       string
+	At         time.Time
+}
+
+var (
+	ErrInvalidVoucher       = errors.New("vouchers need a code, and a positive balance for gift vouchers or a positive number of classes for passes")
+	ErrVoucherUnknown       = errors.New("unknown voucher")
+	ErrVoucherExpired       = errors.New("voucher has expired")
+	ErrVoucherVoided        = errors.New("voucher has been voided")
+	ErrVoucherUsedUp        = errors.New("voucher has no balance left")
+	ErrVoucherNotApplicable = errors.New("voucher is in a different currency than the price")
+)
+
+// voucherAlphabet leaves out characters that are easily mistaken for each
+// other on paper.
+const voucherAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NewVoucherCode makes a random code to print on a voucher, e.g.
+// "K7QM-3XHP-ZR2A".
+func NewVoucherCode() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var code strings.Builder
+	for i, c := range b {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(voucherAlphabet[int(c)%len(voucherAlphabet)])
+	}
+	return code.String(), nil
+}
+
+// Validate checks a voucher before it is issued.
+func (v Voucher) Validate() error {
+	switch {
+	case v.Code == "" || strings.ContainsAny(v.Code, ", "),
+		v.Kind == VoucherGift && (v.Balance.Amount <= 0 || v.Classes != 0),
+		v.Kind == VoucherPass && (v.Classes <= 0 || v.Balance.Amount != 0),
+		v.Kind != VoucherGift && v.Kind != VoucherPass:
+		return ErrInvalidVoucher
+	}
+	return nil
+}
+
+// Redeem returns what the voucher pays of price at t, in money and in
+// classes, or why it cannot be used.
+func (v Voucher) Redeem(price money.Money, at time.Time) (money.Money, int, error) {
+	switch {
+	case !v.VoidedAt.IsZero():
+		return money.Money{}, 0, ErrVoucherVoided
+	case !v.ExpiresAt.IsZero() && !at.Before(v.ExpiresAt):
+		return money.Money{}, 0, ErrVoucherExpired
+	}
+	switch v.Kind {
+	case VoucherGift:
+		if v.Balance.Currency != price.Currency {
+			return money.Money{}, 0, ErrVoucherNotApplicable
+		}
+		if v.Balance.Amount <= 0 {
+			return money.Money{}, 0, ErrVoucherUsedUp
+		}
+		if v.Balance.Amount < price.Amount {
+			return v.Balance, 0, nil
+		}
+		return price, 0, nil
+	case VoucherPass:
+		if v.Classes <= 0 {
+			return money.Money{}, 0, ErrVoucherUsedUp
+		}
+		return price, 1, nil
+	}
+	return money.Money{}, 0, ErrInvalidVoucher
+}
+
+// IsVoucherError reports whether err is why a voucher could not be redeemed.
+func IsVoucherError(err error) bool {
+	switch err {
+	case ErrVoucherUnknown, ErrVoucherExpired, ErrVoucherVoided, ErrVoucherUsedUp, ErrVoucherNotApplicable:
+		return true
+	}
+	return false
+}
+
+// Due is what is left to pay for the signup once its voucher is taken off.
+func (s SignUp) Due() money.Money {
+	if s.VoucherAmount.Amount == 0 {
+		return s.Price
+	}
+	return money.New(s.Price.Amount-s.VoucherAmount.Amount, s.Price.Currency)
+}
+
+// VoucherShare is the part of refund that goes back to the signup's voucher,
+// in proportion to how much of the price the voucher paid, rounded half up
+// to the cent.
+func (s SignUp) VoucherShare(refund money.Money) money.Money {
+	if s.VoucherCode == "" || s.Price.Amount <= 0 {
+		return money.New(0, refund.Currency)
+	}
+	return money.New((2*refund.Amount*s.VoucherAmount.Amount+s.Price.Amount)/(2*s.Price.Amount), refund.Currency)
+}
@@ -0,0 +1,106 @@
+package workshop
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
+
+func TestNewVoucherCode(t *testing.T) {
+	format := regexp.MustCompile(`^[` + voucherAlphabet + `]{4}-[` + voucherAlphabet + `]{4}-[` + voucherAlphabet + `]{4}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := NewVoucherCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !format.MatchString(code) || seen[code] {
+			t.Errorf("got code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestVoucherValidate(t *testing.T) {
+	eur := money.New(5000, "EUR")
+	for _, c := range []struct {
+		v    Voucher
+		want error
+	}{
+		{Voucher{Code: "GIFT", Kind: VoucherGift, Balance: eur}, nil},
+		{Voucher{Code: "PASS", Kind: VoucherPass, Classes: 5}, nil},
+		{Voucher{Kind: VoucherGift, Balance: eur}, ErrInvalidVoucher},
+		{Voucher{Code: "GI FT", Kind: VoucherGift, Balance: eur}, ErrInvalidVoucher},
+		{Voucher{Code: "GIFT,PASS", Kind: VoucherGift, Balance: eur}, ErrInvalidVoucher},
+		{Voucher{Code: "GIFT", Kind: VoucherGift}, ErrInvalidVoucher},
+		{Voucher{Code: "GIFT", Kind: VoucherGift, Balance: eur, Classes: 1}, ErrInvalidVoucher},
+		{Voucher{Code: "PASS", Kind: VoucherPass}, ErrInvalidVoucher},
+		{Voucher{Code: "PASS", Kind: VoucherPass, Classes: 5, Balance: eur}, ErrInvalidVoucher},
+		{Voucher{Code: "CARD", Kind: "card", Balance: eur}, ErrInvalidVoucher},
+	} {
+		if err := c.v.Validate(); err != c.want {
+			t.Errorf("%+v: got %v, want %v", c.v, err, c.want)
+		}
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	at := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	price := money.New(4500, "EUR")
+	for _, c := range []struct {
+		name    string
+		v       Voucher
+		amount  money.Money
+		classes int
+		err     error
+	}{
+		{"gift covering the price", Voucher{Kind: VoucherGift, Balance: money.New(5000, "EUR")}, price, 0, nil},
+		{"gift paying part", Voucher{Kind: VoucherGift, Balance: money.New(1000, "EUR")}, money.New(1000, "EUR"), 0, nil},
+		{"gift used up", Voucher{Kind: VoucherGift, Balance: money.New(0, "EUR")}, money.Money{}, 0, ErrVoucherUsedUp},
+		{"gift in another currency", Voucher{Kind: VoucherGift, Balance: money.New(5000, "CHF")}, money.Money{}, 0, ErrVoucherNotApplicable},
+		{"pass", Voucher{Kind: VoucherPass, Classes: 1}, price, 1, nil},
+		{"pass used up", Voucher{Kind: VoucherPass}, money.Money{}, 0, ErrVoucherUsedUp},
+		{"expiring later", Voucher{Kind: VoucherPass, Classes: 1, ExpiresAt: at.Add(time.Second)}, price, 1, nil},
+		{"expiring now", Voucher{Kind: VoucherPass, Classes: 1, ExpiresAt: at}, money.Money{}, 0, ErrVoucherExpired},
+		{"voided", Voucher{Kind: VoucherGift, Balance: money.New(5000, "EUR"), VoidedAt: at.Add(-time.Hour)}, money.Money{}, 0, ErrVoucherVoided},
+	} {
+		amount, classes, err := c.v.Redeem(price, at)
+		if amount != c.amount || classes != c.classes || err != c.err {
+			t.Errorf("%s: got %v, %d classes, %v; want %v, %d classes, %v", c.name, amount, classes, err, c.amount, c.classes, c.err)
+		}
+		if err != nil && !IsVoucherError(err) {
+			t.Errorf("%s: %v is not a voucher error", c.name, err)
+		}
+	}
+}
+
+func TestVoucherShare(t *testing.T) {
+	for _, c := range []struct {
+		price, voucher, refund, want int64
+	}{
+		// A full refund gives the voucher back all it paid.
+		{4500, 1000, 4500, 1000},
+		{4500, 4500, 4500, 4500},
+		{4500, 1000, 2250, 500},
+		// 3749 * 2000 / 4999 is 1499.9.
+		{4999, 2000, 3749, 1500},
+		// 1125 * 1234 / 4500 is 308.5.
+		{4500, 1234, 1125, 309},
+		{4500, 1000, 1, 0},
+		{4500, 1000, 3, 1},
+		{4500, 0, 4500, 0},
+	} {
+		su := SignUp{Price: money.New(c.price, "EUR"), VoucherCode: "GIFT", VoucherAmount: money.New(c.voucher, "EUR")}
+		if got := su.VoucherShare(money.New(c.refund, "EUR")); got != money.New(c.want, "EUR") {
+			t.Errorf("%d of %d paid by a voucher of %d: got %v back on the voucher, want %d", c.refund, c.price, c.voucher, got, c.want)
+		}
+		if c.voucher > 0 && su.Due() != money.New(c.price-c.voucher, "EUR") {
+			t.Errorf("voucher of %d on %d: %v left to pay", c.voucher, c.price, su.Due())
+		}
+	}
+	if got := (SignUp{Price: money.New(4500, "EUR")}).VoucherShare(money.New(4500, "EUR")); !got.IsZero() {
+		t.Errorf("got %v back on no voucher", got)
+	}
+}
@@ -1,20 +1,66 @@
 package workshop
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"github.com/workshop/lib/money"
+)
 
 type Workshop struct {
 	WorkshopID  string
 	Name        string
 	Description string
-	Time        string
-	Caption     string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Cap         int
-	IsFull      bool
-	Cost        string
-	Location    string
-	Level       string
+	// StartTime and EndTime are stored in UTC; Timezone is the IANA zone of
+	// the venue they are shown in.
+	StartTime      time.Time
+	EndTime        time.Time
+	Timezone       string
+	Caption        string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Cap            int
+	SignupCount    int
+	SpotsLeft      int
+	IsFull         bool
+	WaitlistLength int
+	// Price is the standard price; PriceTiers are the alternatives to it.
+	Price      money.Money
+	PriceTiers []PriceTier
+	Location   string
+	// RoomID is the room the workshop is booked into, or 0.
+	RoomID int
+	Level  string
+	// CancelDeadline is the last moment participants can cancel their own
+	// signup. The zero time means there is no deadline.
+	CancelDeadline time.Time
+	// RefundPolicy decides the refund for signups that cancel.
+	RefundPolicy RefundPolicy
+	// Deposit is taken at booking when set, and the rest of the price is
+	// due by BalanceDueAt. Seats still unpaid then are flagged, or released
+	// with ReleaseUnpaid.
+	Deposit       money.Money
+	BalanceDueAt  time.Time
+	ReleaseUnpaid bool
+	// CancelledAt is when the workshop was called off, or zero.
+	CancelledAt time.Time
+	// SeriesID is the series the workshop is an occurrence of, if any.
+	SeriesID string
+	// Sessions are the meetings of the workshop in order. StartTime and
+	// EndTime span them.
+	Sessions []Session
+	// InstructorIDs assigns instructors to the workshop in the order they
+	// are billed; saving nil keeps the current ones. Instructors is loaded
+	// in the same order.
+	InstructorIDs []int
+	Instructors   []Instructor
+	// CategoryIDs files the workshop under categories; saving nil keeps the
+	// current ones. Categories is loaded by name.
+	CategoryIDs []int
+	Categories  []Category
+	// Tags are free-form labels, normalized by NormalizeTags; saving nil
+	// keeps the current ones.
+	Tags []string
 }
 
 type Event struct {
@@ -22,21 +68,54 @@ type Event struct {
 	Name        string
 	Description string
 	Caption     string
-	Time        string
+	StartTime   time.Time
+	EndTime     time.Time
+	Timezone    string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
-	Cost        string
+	Price       money.Money
 	Location    string
+	// RoomID is the room the event is booked into, or 0.
+	RoomID int
+	// CategoryIDs and Tags are as for workshops.
+	CategoryIDs []int
+	Categories  []Category
+	Tags        []string
 }
 
 type SignUp struct {
-	WorkshopID string
-	FirstName  string
-	LastName   string
-	Email      string
-	Message    string
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	ID          int
+	WorkshopID  string
+	FirstName   string
+	LastName    string
+	Email       string
+	Message     string
+	Status      Status
+	Position    int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CancelledAt time.Time
+	// Price is what the signup pays, in the tier it booked, after Discount
+	// from DiscountCode.
+	Price        money.Money
+	PriceTier    string
+	DiscountCode string
+	Discount     money.Money
+	// VoucherAmount is the part of Price paid with the gift voucher or
+	// class pass VoucherCode.
+	VoucherCode   string
+	VoucherAmount money.Money
+	// AmountPaid is the money received for the signup so far.
+	AmountPaid money.Money
+	// BalanceRemindedAt is when the signup was reminded of its balance due
+	// and BalanceOverdueAt when it was flagged for not paying it in time.
+	BalanceRemindedAt time.Time
+	BalanceOverdueAt  time.Time
+	// HoldExpiresAt is when a pending signup gives its seat back unless it
+	// is confirmed first; the zero time holds the seat until it is.
+	HoldExpiresAt time.Time
+	// History is only loaded when a single signup is looked up.
+	History []Transition
 }
 
 type SignUpTable struct {
@@ -44,6 +123,34 @@ type SignUpTable struct {
 	SignUps      []SignUp
 }
 
+// SetSeats fills in the capacity fields derived from the number of seats
+// taken and the number of people on the waitlist.
+func (w *Workshop) SetSeats(taken, waitlisted int) {
+	w.SignupCount = taken
+	w.WaitlistLength = waitlisted
+	w.SpotsLeft = w.Cap - taken
+	if w.SpotsLeft < 0 {
+		w.SpotsLeft = 0
+	}
+	w.IsFull = w.SpotsLeft == 0
+}
+
+var ErrInvalidCancelDeadline = errors.New("cancellation deadline must be before the start")
+
+// ValidateCancelDeadline checks that participants stop cancelling before w
+// starts.
+func (w Workshop) ValidateCancelDeadline() error {
+	if !w.CancelDeadline.IsZero() && !w.CancelDeadline.Before(w.StartTime) {
+		return ErrInvalidCancelDeadline
+	}
+	return nil
+}
+
+// CancellationOpen reports whether participants may still cancel at now.
+func (w Workshop) CancellationOpen(now time.Time) bool {
+	return w.CancelDeadline.IsZero() || now.Before(w.CancelDeadline)
+}
+
 func (w Workshop) New() Workshop {
 	return Workshop{}
 }